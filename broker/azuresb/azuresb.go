@@ -14,6 +14,9 @@ import (
 	"github.com/tx7do/kratos-transport/broker"
 )
 
+// entity names must start with a letter or a number
+const defaultInboxPrefix = "inbox-"
+
 type azureBroker struct {
 	sync.RWMutex
 
@@ -24,6 +27,8 @@ type azureBroker struct {
 	running bool
 
 	subscribers *broker.SubscriberSyncMap
+
+	requester *broker.Requester
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
@@ -33,6 +38,7 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		options:     options,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	b.requester = broker.NewRequester(b, broker.WithInboxPrefix(defaultInboxPrefix))
 
 	return b
 }
@@ -92,6 +98,8 @@ func (b *azureBroker) Connect() error {
 }
 
 func (b *azureBroker) Disconnect() error {
+	_ = b.requester.Close()
+
 	b.Lock()
 	defer b.Unlock()
 
@@ -113,8 +121,10 @@ func (b *azureBroker) Disconnect() error {
 	return nil
 }
 
+// Request sends a request and waits for the reply. Queues are not created on demand, so the
+// reply queue must exist (see EnsureQueue): pass its name with broker.WithReplyTopic.
 func (b *azureBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *azureBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -132,6 +142,7 @@ func (b *azureBroker) internalPublish(ctx context.Context, topic string, msg *br
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -209,9 +220,10 @@ func (b *azureBroker) Subscribe(topic string, handler broker.Handler, binder bro
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	// Build receiver options
 	receiverOpts := &azservicebus.ReceiverOptions{
@@ -251,6 +263,8 @@ func (b *azureBroker) Subscribe(topic string, handler broker.Handler, binder bro
 		topic:   topic,
 		options: options,
 		b:       b,
+		// hand messages to a worker pool so that SubscribeOptions.Concurrency is honoured
+		pool: broker.NewSubscribeWorkerPool(options),
 	}
 
 	subCtx, cancel := context.WithCancel(options.Context)
@@ -269,7 +283,10 @@ func (b *azureBroker) receive(ctx context.Context, receiver *azservicebus.Receiv
 	handler broker.Handler, binder broker.Binder, options broker.SubscribeOptions,
 	sub *subscriber) {
 
+	pool := sub.pool
+
 	defer func() {
+		pool.Wait()
 		LogInfof("subscriber stopped, topic: %s", sub.topic)
 		_ = receiver.Close(ctx)
 	}()
@@ -281,7 +298,7 @@ func (b *azureBroker) receive(ctx context.Context, receiver *azservicebus.Receiv
 		default:
 		}
 
-		messages, err := receiver.ReceiveMessages(ctx, 1, nil)
+		messages, err := receiver.ReceiveMessages(ctx, pool.Size(), nil)
 		if err != nil {
 			if ctx.Err() != nil {
 				return
@@ -291,7 +308,9 @@ func (b *azureBroker) receive(ctx context.Context, receiver *azservicebus.Receiv
 		}
 
 		for _, msg := range messages {
-			b.processMessage(ctx, receiver, handler, binder, options, sub, msg)
+			pool.Submit(func() {
+				b.processMessage(ctx, receiver, handler, binder, options, sub, msg)
+			})
 		}
 	}
 }
@@ -336,7 +355,10 @@ func (b *azureBroker) processMessage(ctx context.Context, receiver *azservicebus
 	if err := handler(ctx, p); err != nil {
 		p.err = err
 		LogErrorf("handle message failed: %v", err)
-		_ = receiver.AbandonMessage(ctx, sbMsg, nil)
+		// unless the handler settled the message itself
+		if !p.acked && !p.nacked {
+			_ = receiver.AbandonMessage(ctx, sbMsg, nil)
+		}
 		return
 	}
 
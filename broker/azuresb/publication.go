@@ -2,13 +2,23 @@ package azuresb
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
 
 	"github.com/tx7do/kratos-transport/broker"
 )
 
+// rejectReason is the dead-letter reason of the messages rejected with Nack(false).
+const rejectReason = "rejected by the consumer"
+
+var (
+	_ broker.Nacker           = (*publication)(nil)
+	_ broker.DeadlineExtender = (*publication)(nil)
+)
+
 type publication struct {
 	topic    string
 	msg      *broker.Message
@@ -16,6 +26,7 @@ type publication struct {
 	receiver *azservicebus.Receiver
 	err      error
 	acked    bool
+	nacked   bool
 }
 
 func (p *publication) Topic() string {
@@ -30,8 +41,16 @@ func (p *publication) RawMessage() any {
 	return p.sbMsg
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.sbMsg == nil {
+		return nil
+	}
+	return p.sbMsg.Body
+}
+
 func (p *publication) Ack() error {
-	if p.acked {
+	if p.acked || p.nacked {
 		return nil
 	}
 	if p.receiver == nil {
@@ -46,6 +65,54 @@ func (p *publication) Ack() error {
 	return nil
 }
 
+// Nack abandons the message, which is redelivered at once, or without requeue moves it
+// to the dead-letter sub-queue.
+func (p *publication) Nack(requeue bool) error {
+	if err := p.settle(); err != nil {
+		return err
+	}
+	if !requeue {
+		reason := rejectReason
+		return p.receiver.DeadLetterMessage(context.Background(), p.sbMsg, &azservicebus.DeadLetterOptions{
+			Reason: &reason,
+		})
+	}
+	return p.receiver.AbandonMessage(context.Background(), p.sbMsg, nil)
+}
+
+// NackWithDelay keeps the message locked for delay, then abandons it. Service Bus releases the
+// message when its lock expires: delays longer than the lock duration need ExtendDeadline.
+func (p *publication) NackWithDelay(delay time.Duration) error {
+	if err := p.settle(); err != nil {
+		return err
+	}
+	time.AfterFunc(delay, func() {
+		if err := p.receiver.AbandonMessage(context.Background(), p.sbMsg, nil); err != nil {
+			LogErrorf("delayed abandon failed: %v", err)
+		}
+	})
+	return nil
+}
+
+// ExtendDeadline renews the lock of the message, by the lock duration of the entity whatever d.
+func (p *publication) ExtendDeadline(_ time.Duration) error {
+	if p.receiver == nil {
+		return errors.New("receiver is nil")
+	}
+	return p.receiver.RenewMessageLock(context.Background(), p.sbMsg, nil)
+}
+
+func (p *publication) settle() error {
+	if p.receiver == nil {
+		return errors.New("receiver is nil")
+	}
+	if p.acked || p.nacked {
+		return errors.New("the message is already settled")
+	}
+	p.nacked = true
+	return nil
+}
+
 func (p *publication) Error() error {
 	return p.err
 }
@@ -14,6 +14,7 @@ type subscriber struct {
 	options broker.SubscribeOptions
 
 	b      *azureBroker
+	pool   *broker.WorkerPool
 	cancel context.CancelFunc
 	closed bool
 }
@@ -31,6 +32,9 @@ func (s *subscriber) Topic() string {
 }
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
+	// the handlers settle the messages with the receive context, they finish before it is canceled
+	s.pool.Drain()
+
 	s.Lock()
 	defer s.Unlock()
 
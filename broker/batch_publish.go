@@ -0,0 +1,68 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PublishResult reports the outcome of publishing one message of a batch.
+type PublishResult struct {
+	// Message is the message as passed to PublishBatch
+	Message *Message
+
+	// Err is nil when the message was published
+	Err error
+}
+
+// BatchPublisher is implemented by brokers which can send several messages in a single call
+// (Kafka, SQS, Pulsar, Redis Stream, RabbitMQ). Use PublishBatch to fall back to Publish
+// for the other brokers.
+type BatchPublisher interface {
+	// PublishBatch publishes msgs to topic and returns one result per message, in order.
+	// The error is non-nil when at least one message failed, see JoinPublishResults.
+	// Publish middlewares wrap single messages and are not applied to batches.
+	PublishBatch(ctx context.Context, topic string, msgs []*Message, opts ...PublishOption) ([]PublishResult, error)
+}
+
+// PublishBatch publishes msgs to topic through b, in a single call when b implements
+// BatchPublisher, and message by message with Publish otherwise.
+func PublishBatch(ctx context.Context, b Broker, topic string, msgs []*Message, opts ...PublishOption) ([]PublishResult, error) {
+	if b == nil {
+		return nil, errors.New("broker is nil")
+	}
+	if bp, ok := b.(BatchPublisher); ok {
+		return bp.PublishBatch(ctx, topic, msgs, opts...)
+	}
+
+	results := NewPublishResults(msgs)
+	for i, msg := range msgs {
+		if msg == nil {
+			results[i].Err = errors.New("message is nil")
+			continue
+		}
+		results[i].Err = b.Publish(ctx, topic, msg, opts...)
+	}
+	return results, JoinPublishResults(results)
+}
+
+// NewPublishResults returns the results of msgs, with no error yet.
+func NewPublishResults(msgs []*Message) []PublishResult {
+	results := make([]PublishResult, len(msgs))
+	for i, msg := range msgs {
+		results[i].Message = msg
+	}
+	return results
+}
+
+// JoinPublishResults returns the errors of the failed messages joined together,
+// each prefixed with its index in the batch, or nil when every message was published.
+func JoinPublishResults(results []PublishResult) error {
+	var errs []error
+	for i, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("message [%d]: %w", i, r.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
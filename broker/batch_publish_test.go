@@ -0,0 +1,84 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failingBroker 对指定 Key 的消息返回发布错误
+type failingBroker struct {
+	recordingBroker
+	failKey string
+}
+
+func (f *failingBroker) Publish(ctx context.Context, topic string, msg *Message, opts ...PublishOption) error {
+	if msg.Key == f.failKey {
+		return errors.New("rejected")
+	}
+	return f.recordingBroker.Publish(ctx, topic, msg, opts...)
+}
+
+// nativeBatchBroker 实现 BatchPublisher，记录批量调用次数
+type nativeBatchBroker struct {
+	recordingBroker
+	batches int
+}
+
+func (n *nativeBatchBroker) PublishBatch(_ context.Context, _ string, msgs []*Message, _ ...PublishOption) ([]PublishResult, error) {
+	n.batches++
+	return NewPublishResults(msgs), nil
+}
+
+func TestPublishBatch_Fallback(t *testing.T) {
+	b := &failingBroker{failKey: "bad"}
+	msgs := []*Message{
+		NewMessage("a", WithKey("good")),
+		NewMessage("b", WithKey("bad")),
+		nil,
+		NewMessage("c", WithKey("good")),
+	}
+
+	results, err := PublishBatch(context.Background(), b, "orders", msgs)
+	if err == nil {
+		t.Fatal("expected batch error")
+	}
+	if len(results) != len(msgs) {
+		t.Fatalf("expected %d results, got %d", len(msgs), len(results))
+	}
+	for i, wantErr := range []bool{false, true, true, false} {
+		if (results[i].Err != nil) != wantErr {
+			t.Fatalf("result %d: unexpected error %v", i, results[i].Err)
+		}
+		if results[i].Message != msgs[i] {
+			t.Fatalf("result %d: message mismatch", i)
+		}
+	}
+	if len(b.messages) != 2 {
+		t.Fatalf("expected 2 published messages, got %d", len(b.messages))
+	}
+}
+
+func TestPublishBatch_Native(t *testing.T) {
+	b := &nativeBatchBroker{}
+
+	results, err := PublishBatch(context.Background(), b, "orders", []*Message{NewMessage("a"), NewMessage("b")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || b.batches != 1 || len(b.messages) != 0 {
+		t.Fatalf("native batch not used: results=%d batches=%d publishes=%d", len(results), b.batches, len(b.messages))
+	}
+}
+
+func TestJoinPublishResults(t *testing.T) {
+	if err := JoinPublishResults([]PublishResult{{}, {}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cause := errors.New("rejected")
+	err := JoinPublishResults([]PublishResult{{}, {Err: cause}})
+	if !errors.Is(err, cause) || err.Error() != "message [1]: rejected" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BatchHandler handles the events of a batch at once.
+//
+// With auto-ack, the broker acknowledges the whole batch when the handler returns nil and
+// none of it when it returns an error. With auto-ack disabled, the handler acknowledges
+// the events it processed one by one with Event.Ack.
+type BatchHandler func(ctx context.Context, evts []Event) error
+
+// BatchSubscriber is implemented by brokers which can fetch messages in batches natively
+// (Kafka, Redis Stream, SQS, NATS JetStream pull consumers).
+type BatchSubscriber interface {
+	// SubscribeBatch subscribes to topic, delivering the messages to handler in batches.
+	// Subscriber middlewares wrap single events and are not applied; MaxRetries and RetryDelay
+	// retry the whole batch. What happens to a batch which still fails depends on the broker.
+	SubscribeBatch(topic string, handler BatchHandler, binder Binder, opts ...SubscribeOption) (Subscriber, error)
+}
+
+// TypedBatchHandler handles a batch of messages of the same type; headers[i] belongs to msgs[i].
+type TypedBatchHandler[T any] func(ctx context.Context, topic string, headers []Headers, msgs []*T) error
+
+// SubscribeBatch is a helper function to subscribe to a topic with a typed batch handler.
+// Brokers which do not implement BatchSubscriber deliver batches of a single message.
+func SubscribeBatch[T any](b Broker, topic string, handler TypedBatchHandler[T], opts ...SubscribeOption) (Subscriber, error) {
+	if b == nil {
+		return nil, errors.New("broker is nil")
+	}
+
+	batchHandler := func(ctx context.Context, evts []Event) error {
+		if len(evts) == 0 {
+			return nil
+		}
+
+		headers := make([]Headers, 0, len(evts))
+		msgs := make([]*T, 0, len(evts))
+		for _, evt := range evts {
+			if evt == nil || evt.Message() == nil || evt.Message().Body == nil {
+				return fmt.Errorf("event or message body is nil")
+			}
+
+			switch v := evt.Message().Body.(type) {
+			case *T:
+				msgs = append(msgs, v)
+			case T:
+				msgs = append(msgs, &v)
+			default:
+				var zero T
+				return fmt.Errorf("unsupported type: expected %T, got %T", &zero, evt.Message().Body)
+			}
+			headers = append(headers, evt.Message().Headers)
+		}
+
+		return handler(ctx, evts[0].Topic(), headers, msgs)
+	}
+
+	binder := func() any {
+		var t T
+		return &t
+	}
+
+	if bs, ok := b.(BatchSubscriber); ok {
+		return bs.SubscribeBatch(topic, batchHandler, binder, opts...)
+	}
+
+	return b.Subscribe(topic, func(ctx context.Context, evt Event) error {
+		return batchHandler(ctx, []Event{evt})
+	}, binder, opts...)
+}
+
+// WrapSubscribeBatchHandler applies MaxRetries and RetryDelay of SubscribeOptions to h,
+// retrying the whole batch.
+func WrapSubscribeBatchHandler(h BatchHandler, options SubscribeOptions) BatchHandler {
+	if h == nil || options.MaxRetries <= 0 {
+		return h
+	}
+
+	return func(ctx context.Context, evts []Event) error {
+		_, err := retry(ctx, nil, func(ctx context.Context, _ Event) error {
+			return h(ctx, evts)
+		}, options.MaxRetries, options.RetryDelay)
+		return err
+	}
+}
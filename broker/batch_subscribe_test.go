@@ -0,0 +1,121 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type reading struct {
+	Value int
+}
+
+// handlerBroker 保存 Subscribe 传入的 Handler 与 Binder
+type handlerBroker struct {
+	recordingBroker
+	handler Handler
+	binder  Binder
+}
+
+func (h *handlerBroker) Subscribe(_ string, handler Handler, binder Binder, _ ...SubscribeOption) (Subscriber, error) {
+	h.handler = handler
+	h.binder = binder
+	return nil, nil
+}
+
+// batchBroker 实现 BatchSubscriber，保存 BatchHandler
+type batchBroker struct {
+	handlerBroker
+	batchHandler BatchHandler
+}
+
+func (b *batchBroker) SubscribeBatch(_ string, handler BatchHandler, binder Binder, _ ...SubscribeOption) (Subscriber, error) {
+	b.batchHandler = handler
+	b.binder = binder
+	return nil, nil
+}
+
+func newBoundEvent(binder Binder, value int, header string) Event {
+	body := binder().(*reading)
+	body.Value = value
+	return newTopicEvent("readings", &Message{Body: body, Headers: Headers{"h": header}})
+}
+
+func TestSubscribeBatch_Native(t *testing.T) {
+	b := &batchBroker{}
+
+	var got []int
+	var gotHeaders []string
+	_, err := SubscribeBatch(b, "readings", func(_ context.Context, topic string, headers []Headers, msgs []*reading) error {
+		if topic != "readings" {
+			t.Errorf("unexpected topic: %s", topic)
+		}
+		for i, m := range msgs {
+			got = append(got, m.Value)
+			gotHeaders = append(gotHeaders, headers[i]["h"])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if b.batchHandler == nil || b.handler != nil {
+		t.Fatal("native batch subscription not used")
+	}
+
+	evts := []Event{newBoundEvent(b.binder, 1, "a"), newBoundEvent(b.binder, 2, "b")}
+	if err = b.batchHandler(context.Background(), evts); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 || gotHeaders[0] != "a" || gotHeaders[1] != "b" {
+		t.Fatalf("unexpected batch: %v %v", got, gotHeaders)
+	}
+
+	// 类型不匹配时返回错误
+	wrong := newTopicEvent("readings", &Message{Body: "text"})
+	if err = b.batchHandler(context.Background(), []Event{wrong}); err == nil {
+		t.Fatal("expected type error")
+	}
+}
+
+func TestSubscribeBatch_Fallback(t *testing.T) {
+	b := &handlerBroker{}
+
+	var sizes []int
+	_, err := SubscribeBatch(b, "readings", func(_ context.Context, _ string, _ []Headers, msgs []*reading) error {
+		sizes = append(sizes, len(msgs))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if err = b.handler(context.Background(), newBoundEvent(b.binder, 1, "a")); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	if len(sizes) != 1 || sizes[0] != 1 {
+		t.Fatalf("expected a batch of one, got %v", sizes)
+	}
+}
+
+func TestWrapSubscribeBatchHandler_Retry(t *testing.T) {
+	calls := 0
+	h := WrapSubscribeBatchHandler(func(_ context.Context, evts []Event) error {
+		calls++
+		if len(evts) != 2 {
+			t.Errorf("unexpected batch size: %d", len(evts))
+		}
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	}, NewSubscribeOptions(WithSubscribeRetry(2, time.Millisecond)))
+
+	if err := h(context.Background(), []Event{dummyEvent{}, dummyEvent{}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
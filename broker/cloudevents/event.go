@@ -0,0 +1,243 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/google/uuid"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// SpecVersion is the version of the CloudEvents specification implemented by this package.
+const SpecVersion = "1.0"
+
+var (
+	// ErrInvalidEvent is returned when an event lacks a required attribute or has a malformed one.
+	ErrInvalidEvent = errors.New("invalid cloudevent")
+	// ErrNotCloudEvent is returned by FromMessage when a message carries no CloudEvent.
+	ErrNotCloudEvent = errors.New("message is not a cloudevent")
+)
+
+// Event is a CloudEvent v1.0.
+type Event struct {
+	// ID identifies the event, together with Source. Required.
+	ID string
+	// Source identifies the context in which the event happened, e.g. a URI. Required.
+	Source string
+	// SpecVersion is the CloudEvents version, "1.0". Required.
+	SpecVersion string
+	// Type describes the kind of the event, e.g. "com.example.order.created". Required.
+	Type string
+
+	// DataContentType is the media type of Data, e.g. "application/json".
+	DataContentType string
+	// DataSchema is the URI of the schema Data adheres to.
+	DataSchema string
+	// Subject is the subject of the event in the context of Source.
+	Subject string
+	// Time is when the occurrence happened.
+	Time time.Time
+
+	// Extensions are the extension attributes, by lower-case name.
+	Extensions map[string]string
+
+	// Data is the payload of the event. It is a json.RawMessage or a []byte when decoded
+	// from a message, read it with DataAs.
+	Data any
+}
+
+// New creates an event of type eventType from source, with a random ID and the current time.
+func New(source, eventType string, data any) *Event {
+	return &Event{
+		ID:          uuid.New().String(),
+		Source:      source,
+		SpecVersion: SpecVersion,
+		Type:        eventType,
+		Time:        time.Now().UTC(),
+		Data:        data,
+	}
+}
+
+// SetExtension sets the extension attribute name, which must consist of lower-case letters and digits.
+func (e *Event) SetExtension(name, value string) {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]string)
+	}
+	e.Extensions[name] = value
+}
+
+// Extension returns the value of the extension attribute name.
+func (e *Event) Extension(name string) string {
+	return e.Extensions[name]
+}
+
+// Validate checks that the required attributes are set and that the extension names are valid.
+func (e *Event) Validate() error {
+	var missing []string
+	if e.ID == "" {
+		missing = append(missing, "id")
+	}
+	if e.Source == "" {
+		missing = append(missing, "source")
+	}
+	if e.SpecVersion == "" {
+		missing = append(missing, "specversion")
+	}
+	if e.Type == "" {
+		missing = append(missing, "type")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrInvalidEvent, strings.Join(missing, ", "))
+	}
+
+	if e.SpecVersion != SpecVersion {
+		return fmt.Errorf("%w: unsupported specversion %q", ErrInvalidEvent, e.SpecVersion)
+	}
+
+	for name := range e.Extensions {
+		if !validExtensionName(name) {
+			return fmt.Errorf("%w: invalid extension name %q", ErrInvalidEvent, name)
+		}
+		if _, ok := reservedAttributes[name]; ok {
+			return fmt.Errorf("%w: extension %q shadows a context attribute", ErrInvalidEvent, name)
+		}
+	}
+
+	return nil
+}
+
+// HasData reports whether the event carries data, an empty payload counting as none.
+func (e *Event) HasData() bool {
+	switch t := e.Data.(type) {
+	case nil:
+		return false
+	case json.RawMessage:
+		return len(t) > 0
+	case broker.RawBody:
+		return len(t) > 0
+	case []byte:
+		return len(t) > 0
+	}
+	return true
+}
+
+// DataAs decodes Data into v, which must be a pointer.
+//
+// Raw data is decoded with the kratos codec matching DataContentType (JSON when it is empty)
+// or copied into a *[]byte or a *string; data already decoded by the broker is assigned to v
+// when their types match.
+func (e *Event) DataAs(v any) error {
+	if v == nil {
+		return errors.New("target is nil; must be a pointer")
+	}
+	if e.Data == nil {
+		return errors.New("event has no data")
+	}
+
+	var raw []byte
+	switch t := e.Data.(type) {
+	case json.RawMessage:
+		return json.Unmarshal(t, v)
+	case broker.RawBody:
+		raw = t
+	case []byte:
+		raw = t
+	default:
+		return assign(e.Data, v)
+	}
+
+	switch t := v.(type) {
+	case *[]byte:
+		*t = append([]byte(nil), raw...)
+		return nil
+	case *string:
+		*t = string(raw)
+		return nil
+	}
+
+	codec := codecOf(e.DataContentType)
+	if codec == nil {
+		return fmt.Errorf("no codec for datacontenttype %q", e.DataContentType)
+	}
+	return codec.Unmarshal(raw, v)
+}
+
+// assign stores data into the pointer v when data holds a value or a pointer of the type of *v.
+func assign(data, v any) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", v)
+	}
+	elem := target.Elem()
+
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Pointer && !value.IsNil() && value.Elem().Type().AssignableTo(elem.Type()) {
+		value = value.Elem()
+	}
+	if !value.Type().AssignableTo(elem.Type()) {
+		return fmt.Errorf("cannot assign data of type %T to %T", data, v)
+	}
+
+	elem.Set(value)
+	return nil
+}
+
+// codecOf returns the kratos codec of a media type, JSON for an empty one, or nil when there is none.
+func codecOf(contentType string) encoding.Codec {
+	if isJSON(contentType) {
+		return encoding.GetCodec("json")
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case strings.HasSuffix(mediaType, "protobuf"):
+		return encoding.GetCodec("proto")
+	case strings.HasSuffix(mediaType, "/xml"), strings.HasSuffix(mediaType, "+xml"):
+		return encoding.GetCodec("xml")
+	case strings.HasSuffix(mediaType, "/yaml"), strings.HasSuffix(mediaType, "+yaml"):
+		return encoding.GetCodec("yaml")
+	}
+	return nil
+}
+
+// isJSON reports whether a media type is JSON; an empty one defaults to JSON as in the structured mode.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+var reservedAttributes = map[string]struct{}{
+	"id":              {},
+	"source":          {},
+	"specversion":     {},
+	"type":            {},
+	"datacontenttype": {},
+	"dataschema":      {},
+	"subject":         {},
+	"time":            {},
+	"data":            {},
+	"data_base64":     {},
+}
+
+func validExtensionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
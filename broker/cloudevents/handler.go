@@ -0,0 +1,51 @@
+package cloudevents
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// TypedHandler handles a CloudEvent along with its data decoded as T.
+type TypedHandler[T any] func(ctx context.Context, event *Event, data *T) error
+
+// Subscribe subscribes to topic with a typed CloudEvents handler. The messages are received
+// without a binder so that both content modes are accepted, and the data is decoded with
+// Event.DataAs. Messages which are not valid CloudEvents fail with ErrNotCloudEvent or ErrInvalidEvent.
+func Subscribe[T any](b broker.Broker, topic string, handler TypedHandler[T], opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	if b == nil {
+		return nil, errors.New("broker is nil")
+	}
+
+	return b.Subscribe(topic, TypedToEventHandler(handler), nil, opts...)
+}
+
+// TypedToEventHandler converts a TypedHandler to a broker.Handler, for subscriptions without a binder.
+func TypedToEventHandler[T any](h TypedHandler[T]) broker.Handler {
+	return func(ctx context.Context, evt broker.Event) error {
+		if evt == nil {
+			return errors.New("evt is nil")
+		}
+
+		e, ok := FromContext(ctx)
+		if !ok {
+			var err error
+			if e, err = FromMessage(evt.Message()); err != nil {
+				return err
+			}
+			if err = e.Validate(); err != nil {
+				return err
+			}
+		}
+
+		var data T
+		if e.HasData() {
+			if err := e.DataAs(&data); err != nil {
+				return err
+			}
+		}
+
+		return h(ctx, e, &data)
+	}
+}
@@ -0,0 +1,140 @@
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// MarshalJSON encodes the event in the JSON event format of the structured content mode.
+//
+// JSON data is embedded as the "data" member; binary data of a non-JSON media type is
+// base64-encoded into "data_base64".
+func (e Event) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, 9+len(e.Extensions))
+	for name, value := range e.Extensions {
+		out[name] = value
+	}
+
+	out["id"] = e.ID
+	out["source"] = e.Source
+	out["specversion"] = e.SpecVersion
+	out["type"] = e.Type
+	if e.DataContentType != "" {
+		out["datacontenttype"] = e.DataContentType
+	}
+	if e.DataSchema != "" {
+		out["dataschema"] = e.DataSchema
+	}
+	if e.Subject != "" {
+		out["subject"] = e.Subject
+	}
+	if !e.Time.IsZero() {
+		out["time"] = e.Time.Format(time.RFC3339Nano)
+	}
+
+	if e.Data != nil {
+		var raw []byte
+		switch t := e.Data.(type) {
+		case json.RawMessage:
+			out["data"] = t
+		case broker.RawBody:
+			raw = t
+		case []byte:
+			raw = t
+		default:
+			out["data"] = t
+		}
+		if raw != nil {
+			if isJSON(e.DataContentType) && json.Valid(raw) {
+				out["data"] = json.RawMessage(raw)
+			} else {
+				out["data_base64"] = base64.StdEncoding.EncodeToString(raw)
+			}
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes an event in the JSON event format. Data is left as a json.RawMessage,
+// or a []byte for "data_base64"; extension attributes which are not strings keep their JSON text.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*e = Event{}
+
+	for name, value := range members {
+		if name == "data" {
+			e.Data = value
+			continue
+		}
+		if string(value) == "null" {
+			continue
+		}
+
+		var s string
+		isString := json.Unmarshal(value, &s) == nil
+
+		switch name {
+		case "data_base64":
+			if !isString {
+				return fmt.Errorf("%w: data_base64 is not a string", ErrInvalidEvent)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("%w: data_base64: %s", ErrInvalidEvent, err.Error())
+			}
+			e.Data = b
+		case "id", "source", "specversion", "type", "datacontenttype", "dataschema", "subject", "time":
+			if !isString {
+				return fmt.Errorf("%w: %s is not a string", ErrInvalidEvent, name)
+			}
+			if err := e.setAttribute(name, s); err != nil {
+				return err
+			}
+		default:
+			if !isString {
+				s = string(value)
+			}
+			e.SetExtension(name, s)
+		}
+	}
+
+	return nil
+}
+
+// setAttribute sets a context attribute from its string representation.
+func (e *Event) setAttribute(name, value string) error {
+	switch name {
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "specversion":
+		e.SpecVersion = value
+	case "type":
+		e.Type = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("%w: time: %s", ErrInvalidEvent, err.Error())
+		}
+		e.Time = t
+	default:
+		e.SetExtension(name, value)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+const (
+	// HeaderPrefix prefixes the attribute headers of the binary mode, as in the HTTP and NATS bindings.
+	HeaderPrefix = "ce-"
+	// KafkaHeaderPrefix prefixes the attribute headers of the Kafka binding.
+	KafkaHeaderPrefix = "ce_"
+	// AMQPHeaderPrefix prefixes the attribute application properties of the AMQP binding.
+	AMQPHeaderPrefix = "cloudEvents:"
+
+	// HeaderContentType carries the datacontenttype in the binary mode, and
+	// ContentTypeStructured in the structured mode.
+	HeaderContentType = "content-type"
+
+	// ContentTypeStructured is the media type of the JSON event format.
+	ContentTypeStructured = "application/cloudevents+json"
+
+	// partitionKeyExtension is mapped onto the message key, as in the Kafka binding.
+	partitionKeyExtension = "partitionkey"
+)
+
+// knownPrefixes are tried in turn when reading an event in binary mode.
+var knownPrefixes = []string{HeaderPrefix, KafkaHeaderPrefix, AMQPHeaderPrefix, "cloudEvents_"}
+
+// ToMessage maps a valid event onto a message, in binary mode unless WithMode(StructuredMode) is given.
+//
+// In binary mode the message body is the event data, encoded by the codec of the broker,
+// except for []byte and json.RawMessage data which are sent as they are.
+func ToMessage(e *Event, opts ...Option) (*broker.Message, error) {
+	if e == nil {
+		return nil, fmt.Errorf("%w: event is nil", ErrInvalidEvent)
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts...)
+
+	msg := broker.NewMessage(nil, broker.WithID(e.ID))
+	if key := e.Extension(partitionKeyExtension); key != "" {
+		msg.Key = key
+	}
+
+	if o.mode == StructuredMode {
+		buf, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		msg.Body = broker.RawBody(buf)
+		msg.SetHeader(HeaderContentType, ContentTypeStructured+"; charset=utf-8")
+		return msg, nil
+	}
+
+	switch t := e.Data.(type) {
+	case nil:
+		msg.Body = broker.RawBody{}
+	case json.RawMessage:
+		msg.Body = broker.RawBody(t)
+	case []byte:
+		msg.Body = broker.RawBody(t)
+	default:
+		msg.Body = t
+	}
+
+	setBinaryHeaders(msg, e, o.headerPrefix)
+
+	return msg, nil
+}
+
+func setBinaryHeaders(msg *broker.Message, e *Event, prefix string) {
+	msg.SetHeader(prefix+"id", e.ID)
+	msg.SetHeader(prefix+"source", e.Source)
+	msg.SetHeader(prefix+"specversion", e.SpecVersion)
+	msg.SetHeader(prefix+"type", e.Type)
+	if e.DataContentType != "" {
+		msg.SetHeader(HeaderContentType, e.DataContentType)
+	}
+	if e.DataSchema != "" {
+		msg.SetHeader(prefix+"dataschema", e.DataSchema)
+	}
+	if e.Subject != "" {
+		msg.SetHeader(prefix+"subject", e.Subject)
+	}
+	if !e.Time.IsZero() {
+		msg.SetHeader(prefix+"time", e.Time.Format(time.RFC3339Nano))
+	}
+	for name, value := range e.Extensions {
+		msg.SetHeader(prefix+name, value)
+	}
+}
+
+// FromMessage reads the event carried by a message, in structured mode when its content type
+// is ContentTypeStructured and in binary mode otherwise, whatever the prefix of its headers.
+// It returns ErrNotCloudEvent when the message carries no event; the event is not validated.
+//
+// In binary mode Data is the message body as delivered by the broker: raw bytes when the
+// subscription has no binder, the decoded value otherwise.
+func FromMessage(msg *broker.Message) (*Event, error) {
+	if msg == nil {
+		return nil, ErrNotCloudEvent
+	}
+
+	switch t := msg.Body.(type) {
+	case *Event:
+		if t != nil {
+			return t, nil
+		}
+	case Event:
+		return &t, nil
+	}
+
+	if isStructured(msg) {
+		var raw []byte
+		switch t := msg.Body.(type) {
+		case broker.RawBody:
+			raw = t
+		case []byte:
+			raw = t
+		case json.RawMessage:
+			raw = t
+		case string:
+			raw = []byte(t)
+		default:
+			return nil, fmt.Errorf("%w: structured event in a body of type %T, subscribe without a binder", ErrInvalidEvent, msg.Body)
+		}
+
+		var e Event
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEvent, err.Error())
+		}
+		return &e, nil
+	}
+
+	prefix := binaryPrefix(msg.Headers)
+	if prefix == "" {
+		return nil, ErrNotCloudEvent
+	}
+
+	e := &Event{
+		DataContentType: headerValue(msg.Headers, HeaderContentType),
+		Data:            msg.Body,
+	}
+	for k, v := range msg.Headers {
+		if len(k) <= len(prefix) || !strings.EqualFold(k[:len(prefix)], prefix) {
+			continue
+		}
+		if err := e.setAttribute(strings.ToLower(k[len(prefix):]), v); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// isStructured reports whether the content type of msg is the JSON event format.
+func isStructured(msg *broker.Message) bool {
+	contentType := strings.ToLower(strings.TrimSpace(headerValue(msg.Headers, HeaderContentType)))
+	return strings.HasPrefix(contentType, ContentTypeStructured)
+}
+
+// binaryPrefix returns the prefix of the attribute headers, or "" when there is neither
+// a specversion, a type nor an id header.
+func binaryPrefix(headers broker.Headers) string {
+	for _, prefix := range knownPrefixes {
+		for _, name := range []string{"specversion", "type", "id"} {
+			if headerValue(headers, prefix+name) != "" {
+				return prefix
+			}
+		}
+	}
+	return ""
+}
+
+// headerValue looks key up case-insensitively, since some brokers canonicalise header names.
+func headerValue(headers broker.Headers, key string) string {
+	if v, ok := headers[key]; ok {
+		return v
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
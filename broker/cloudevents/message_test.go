@@ -0,0 +1,186 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+type order struct {
+	ID     string  `json:"id"`
+	Amount float64 `json:"amount"`
+}
+
+func newOrderEvent() *Event {
+	e := New("/orders", "com.example.order.created", &order{ID: "o-1", Amount: 9.5})
+	e.DataContentType = "application/json"
+	e.Subject = "o-1"
+	e.Time = time.Date(2024, 5, 1, 12, 0, 0, 123000000, time.UTC)
+	e.SetExtension("tenant", "acme")
+	return e
+}
+
+func TestToMessage_Binary(t *testing.T) {
+	e := newOrderEvent()
+
+	msg, err := ToMessage(e)
+	if err != nil {
+		t.Fatalf("to message: %v", err)
+	}
+
+	if msg.ID != e.ID {
+		t.Fatalf("unexpected message id: %s", msg.ID)
+	}
+	for k, v := range map[string]string{
+		"ce-id":          e.ID,
+		"ce-source":      "/orders",
+		"ce-specversion": "1.0",
+		"ce-type":        "com.example.order.created",
+		"ce-subject":     "o-1",
+		"ce-time":        "2024-05-01T12:00:00.123Z",
+		"ce-tenant":      "acme",
+		"content-type":   "application/json",
+	} {
+		if got := msg.GetHeader(k); got != v {
+			t.Errorf("header %s: expected %q, got %q", k, v, got)
+		}
+	}
+	if msg.Body != e.Data {
+		t.Fatalf("expected the data as body, got %T", msg.Body)
+	}
+}
+
+func TestToMessage_Invalid(t *testing.T) {
+	e := New("", "", nil)
+	if _, err := ToMessage(e); !errors.Is(err, ErrInvalidEvent) {
+		t.Fatalf("expected ErrInvalidEvent, got %v", err)
+	}
+
+	e = New("/src", "t", nil)
+	e.SetExtension("Bad-Name", "x")
+	if _, err := ToMessage(e); !errors.Is(err, ErrInvalidEvent) {
+		t.Fatalf("expected ErrInvalidEvent for an invalid extension name, got %v", err)
+	}
+}
+
+func TestFromMessage_BinaryKafkaPrefix(t *testing.T) {
+	e := newOrderEvent()
+	msg, err := ToMessage(e, WithHeaderPrefix(KafkaHeaderPrefix))
+	if err != nil {
+		t.Fatalf("to message: %v", err)
+	}
+	if msg.GetHeader("ce_type") == "" {
+		t.Fatalf("expected kafka prefixed headers, got %v", msg.Headers)
+	}
+
+	// as received without a binder
+	msg.Body = []byte(`{"id":"o-1","amount":9.5}`)
+
+	got, err := FromMessage(msg)
+	if err != nil {
+		t.Fatalf("from message: %v", err)
+	}
+	if err = got.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if got.ID != e.ID || got.Type != e.Type || got.Subject != e.Subject || !got.Time.Equal(e.Time) {
+		t.Fatalf("unexpected attributes: %+v", got)
+	}
+	if got.Extension("tenant") != "acme" {
+		t.Fatalf("unexpected extensions: %v", got.Extensions)
+	}
+
+	var o order
+	if err = got.DataAs(&o); err != nil {
+		t.Fatalf("data as: %v", err)
+	}
+	if o.ID != "o-1" || o.Amount != 9.5 {
+		t.Fatalf("unexpected data: %+v", o)
+	}
+}
+
+func TestFromMessage_Structured(t *testing.T) {
+	e := newOrderEvent()
+	msg, err := ToMessage(e, WithMode(StructuredMode))
+	if err != nil {
+		t.Fatalf("to message: %v", err)
+	}
+	if _, ok := msg.Body.(broker.RawBody); !ok {
+		t.Fatalf("expected a raw body, got %T", msg.Body)
+	}
+	if msg.GetHeader("ce-id") != "" {
+		t.Fatal("structured messages carry no attribute header")
+	}
+
+	got, err := FromMessage(msg)
+	if err != nil {
+		t.Fatalf("from message: %v", err)
+	}
+	if got.ID != e.ID || got.Source != e.Source || got.DataContentType != "application/json" || !got.Time.Equal(e.Time) {
+		t.Fatalf("unexpected attributes: %+v", got)
+	}
+
+	var o order
+	if err = got.DataAs(&o); err != nil {
+		t.Fatalf("data as: %v", err)
+	}
+	if o.ID != "o-1" {
+		t.Fatalf("unexpected data: %+v", o)
+	}
+}
+
+func TestFromMessage_NotCloudEvent(t *testing.T) {
+	msg := broker.NewMessage([]byte("hello"))
+	msg.SetHeader("trace", "abc")
+
+	if _, err := FromMessage(msg); !errors.Is(err, ErrNotCloudEvent) {
+		t.Fatalf("expected ErrNotCloudEvent, got %v", err)
+	}
+}
+
+func TestEventJSON_Base64Data(t *testing.T) {
+	e := New("/images", "com.example.image", []byte{0xff, 0x00, 0x01})
+	e.DataContentType = "image/png"
+
+	buf, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var members map[string]any
+	if err = json.Unmarshal(buf, &members); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if members["data_base64"] != "/wAB" {
+		t.Fatalf("expected data_base64, got %v", members)
+	}
+
+	var got Event
+	if err = json.Unmarshal(buf, &got); err != nil {
+		t.Fatalf("unmarshal event: %v", err)
+	}
+	var data []byte
+	if err = got.DataAs(&data); err != nil {
+		t.Fatalf("data as: %v", err)
+	}
+	if string(data) != string([]byte{0xff, 0x00, 0x01}) {
+		t.Fatalf("unexpected data: %v", data)
+	}
+}
+
+func TestEventJSON_Extensions(t *testing.T) {
+	var e Event
+	doc := `{"specversion":"1.0","id":"1","source":"/s","type":"t","traceparent":"00-abc","priority":5,"data":{"a":1}}`
+	if err := json.Unmarshal([]byte(doc), &e); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if e.Extension("traceparent") != "00-abc" || e.Extension("priority") != "5" {
+		t.Fatalf("unexpected extensions: %v", e.Extensions)
+	}
+	if string(e.Data.(json.RawMessage)) != `{"a":1}` {
+		t.Fatalf("unexpected data: %s", e.Data)
+	}
+}
@@ -0,0 +1,134 @@
+package cloudevents
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+type eventKey struct{}
+
+// NewContext returns a copy of ctx carrying e.
+func NewContext(ctx context.Context, e *Event) context.Context {
+	return context.WithValue(ctx, eventKey{}, e)
+}
+
+// FromContext returns the event put in ctx by SubscriberMiddleware.
+func FromContext(ctx context.Context) (*Event, bool) {
+	e, ok := ctx.Value(eventKey{}).(*Event)
+	return e, ok && e != nil
+}
+
+// PublishMiddleware returns a PublishMiddleware which publishes every message as a CloudEvent.
+//
+// The message body may be an *Event; otherwise the event is read from the message, see FromMessage,
+// and a plain message becomes the data of a new event. Missing attributes are defaulted: specversion
+// to SpecVersion, id to the message ID or a random one, source to WithSource. Messages which still
+// lack a required attribute, typically the type, are rejected with ErrInvalidEvent.
+func PublishMiddleware(opts ...Option) broker.PublishMiddleware {
+	o := newOptions(opts...)
+
+	return func(next broker.PublishHandler) broker.PublishHandler {
+		return func(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+			out, err := o.toMessage(msg)
+			if err != nil {
+				return err
+			}
+			return next(ctx, topic, out, opts...)
+		}
+	}
+}
+
+func (o options) toMessage(msg *broker.Message) (*broker.Message, error) {
+	if msg == nil {
+		return nil, errors.New("message is nil")
+	}
+
+	e, err := FromMessage(msg)
+	switch {
+	case errors.Is(err, ErrNotCloudEvent):
+		e = &Event{Data: msg.Body}
+	case err != nil:
+		return nil, err
+	default:
+		// never modify the event of the caller
+		cp := *e
+		e = &cp
+	}
+
+	if e.SpecVersion == "" {
+		e.SpecVersion = SpecVersion
+	}
+	if e.ID == "" {
+		e.ID = msg.ID
+	}
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.Source == "" {
+		e.Source = o.source
+	}
+
+	converted, err := ToMessage(e, WithMode(o.mode), WithHeaderPrefix(o.headerPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the other headers (trace context, reply topic...) and the routing of the message
+	out := msg.Clone()
+	for k := range out.Headers {
+		if isEventHeader(k) {
+			delete(out.Headers, k)
+		}
+	}
+	for k, v := range converted.Headers {
+		out.SetHeader(k, v)
+	}
+	out.ID = converted.ID
+	out.Body = converted.Body
+	if converted.Key != "" {
+		out.Key = converted.Key
+	}
+
+	return out, nil
+}
+
+// isEventHeader reports whether a header carries an attribute in either content mode.
+func isEventHeader(key string) bool {
+	if strings.EqualFold(key, HeaderContentType) {
+		return true
+	}
+	for _, prefix := range knownPrefixes {
+		if len(key) > len(prefix) && strings.EqualFold(key[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriberMiddleware returns a SubscriberMiddleware which rejects the messages which are not valid
+// CloudEvents with ErrNotCloudEvent or ErrInvalidEvent, and passes the event of the others to the
+// handler in its context, see FromContext.
+func SubscriberMiddleware() broker.SubscriberMiddleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, evt broker.Event) error {
+			if evt == nil {
+				return next(ctx, evt)
+			}
+
+			e, err := FromMessage(evt.Message())
+			if err != nil {
+				return err
+			}
+			if err = e.Validate(); err != nil {
+				return err
+			}
+
+			return next(NewContext(ctx, e), evt)
+		}
+	}
+}
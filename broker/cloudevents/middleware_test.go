@@ -0,0 +1,150 @@
+package cloudevents
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tx7do/kratos-transport/broker"
+	"github.com/tx7do/kratos-transport/broker/memory"
+)
+
+func newConnectedBroker(t *testing.T, opts ...broker.Option) broker.Broker {
+	t.Helper()
+
+	b := memory.NewBroker(opts...)
+	if err := b.Init(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if err := b.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Disconnect() })
+	return b
+}
+
+func TestMiddlewares_RoundTrip(t *testing.T) {
+	for name, mode := range map[string]Mode{"binary": BinaryMode, "structured": StructuredMode} {
+		t.Run(name, func(t *testing.T) {
+			b := newConnectedBroker(t,
+				broker.WithPublishMiddlewares(PublishMiddleware(WithMode(mode), WithSource("/orders"))),
+				broker.WithSubscriberMiddlewares(SubscriberMiddleware()),
+			)
+
+			type received struct {
+				event *Event
+				data  *order
+				trace string
+			}
+			got := make(chan received, 1)
+
+			_, err := Subscribe(b, "orders", func(ctx context.Context, e *Event, data *order) error {
+				fromCtx, ok := FromContext(ctx)
+				if !ok || fromCtx != e {
+					t.Error("expected the event of the middleware in the context")
+				}
+				got <- received{event: e, data: data}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+
+			e := New("", "com.example.order.created", &order{ID: "o-1", Amount: 3})
+			msg := broker.NewMessage(e)
+			msg.SetHeader("trace", "abc")
+			if err = b.Publish(context.Background(), "orders", msg); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+
+			select {
+			case r := <-got:
+				if r.event.ID != e.ID || r.event.Source != "/orders" || r.event.Type != e.Type {
+					t.Fatalf("unexpected event: %+v", r.event)
+				}
+				if r.data.ID != "o-1" || r.data.Amount != 3 {
+					t.Fatalf("unexpected data: %+v", r.data)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for the event")
+			}
+
+			if e.Source != "" {
+				t.Fatal("the published event must not be modified")
+			}
+		})
+	}
+}
+
+func TestPublishMiddleware_PlainMessage(t *testing.T) {
+	b := newConnectedBroker(t,
+		broker.WithPublishMiddlewares(PublishMiddleware(WithSource("/sensors"))),
+	)
+
+	got := make(chan *broker.Message, 1)
+	_, err := b.Subscribe("sensors", func(_ context.Context, evt broker.Event) error {
+		got <- evt.Message()
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	// a plain message without type is rejected
+	if err = b.Publish(context.Background(), "sensors", broker.NewMessage(map[string]int{"t": 20})); !errors.Is(err, ErrInvalidEvent) {
+		t.Fatalf("expected ErrInvalidEvent, got %v", err)
+	}
+
+	msg := broker.NewMessage(map[string]int{"t": 20}, broker.WithID("m-1"))
+	msg.SetHeader("ce-type", "com.example.reading")
+	if err = b.Publish(context.Background(), "sensors", msg); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case m := <-got:
+		for k, v := range map[string]string{
+			"ce-id":          "m-1",
+			"ce-source":      "/sensors",
+			"ce-specversion": "1.0",
+			"ce-type":        "com.example.reading",
+		} {
+			if m.GetHeader(k) != v {
+				t.Errorf("header %s: expected %q, got %q", k, v, m.GetHeader(k))
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+}
+
+func TestSubscriberMiddleware_RejectsInvalid(t *testing.T) {
+	var called bool
+	h := SubscriberMiddleware()(func(context.Context, broker.Event) error {
+		called = true
+		return nil
+	})
+
+	msg := broker.NewMessage([]byte("{}"))
+	msg.SetHeader("ce-specversion", "1.0")
+	msg.SetHeader("ce-id", "1")
+
+	err := h(context.Background(), &testEvent{msg: msg})
+	if !errors.Is(err, ErrInvalidEvent) {
+		t.Fatalf("expected ErrInvalidEvent, got %v", err)
+	}
+	if called {
+		t.Fatal("the handler must not be called")
+	}
+}
+
+type testEvent struct {
+	msg *broker.Message
+}
+
+func (e *testEvent) Topic() string            { return "t" }
+func (e *testEvent) Message() *broker.Message { return e.msg }
+func (e *testEvent) RawMessage() any          { return nil }
+func (e *testEvent) Ack() error               { return nil }
+func (e *testEvent) Error() error             { return nil }
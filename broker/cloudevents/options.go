@@ -0,0 +1,55 @@
+package cloudevents
+
+// Mode is the content mode mapping an event onto a message.
+type Mode int
+
+const (
+	// BinaryMode carries the attributes in the message headers and the data as the message body.
+	BinaryMode Mode = iota
+	// StructuredMode carries the whole event as a JSON document in the message body.
+	StructuredMode
+)
+
+type options struct {
+	mode         Mode
+	headerPrefix string
+	source       string
+}
+
+// Option configures ToMessage and the middlewares.
+type Option func(*options)
+
+func newOptions(opts ...Option) options {
+	o := options{
+		mode:         BinaryMode,
+		headerPrefix: HeaderPrefix,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithMode sets the content mode, BinaryMode by default.
+func WithMode(mode Mode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithHeaderPrefix sets the prefix of the attribute headers of the binary mode, HeaderPrefix by default.
+// Use KafkaHeaderPrefix or AMQPHeaderPrefix to interoperate with the CloudEvents SDKs over Kafka or AMQP.
+func WithHeaderPrefix(prefix string) Option {
+	return func(o *options) {
+		if prefix != "" {
+			o.headerPrefix = prefix
+		}
+	}
+}
+
+// WithSource sets the source given by PublishMiddleware to the messages which have none.
+func WithSource(source string) Option {
+	return func(o *options) {
+		o.source = source
+	}
+}
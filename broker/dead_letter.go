@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// HeaderDeadLetterError carries the error returned by the last failed attempt.
+	HeaderDeadLetterError = "x-dead-letter-error"
+	// HeaderDeadLetterAttempts carries the number of attempts made before dead-lettering.
+	HeaderDeadLetterAttempts = "x-dead-letter-attempts"
+	// HeaderDeadLetterOriginalTopic carries the topic the message was consumed from.
+	HeaderDeadLetterOriginalTopic = "x-dead-letter-original-topic"
+)
+
+// DeadLetterPolicy moves messages whose handler keeps failing to a dead-letter topic.
+type DeadLetterPolicy struct {
+	// Topic is the dead-letter topic failed messages are republished to
+	Topic string
+
+	// MaxAttempts is the number of handler attempts before a message is dead-lettered.
+	// When it is lower than MaxRetries+1, MaxRetries+1 is used instead.
+	MaxAttempts int
+}
+
+// Enabled reports whether the policy has a dead-letter topic.
+func (p *DeadLetterPolicy) Enabled() bool {
+	return p != nil && p.Topic != ""
+}
+
+// DeadLetterHandler wraps h with the retry and dead-letter policies of options.
+// Once every attempt has failed, the message is republished to the dead-letter topic with
+// b.Publish, carrying the last error, the attempt count and the original topic as headers,
+// and the source message is acknowledged. The payload is republished as it was received when
+// it is known, see Payload, rather than encoded again from the decoded body. If the dead-letter
+// publish fails, both errors are returned so that the broker falls back to its own failure handling.
+func DeadLetterHandler(b Broker, h Handler, options SubscribeOptions) Handler {
+	policy := options.DeadLetter
+	if h == nil || b == nil || !policy.Enabled() {
+		return RetryHandler(h, options.MaxRetries, options.RetryDelay)
+	}
+
+	maxRetries := options.MaxRetries
+	if policy.MaxAttempts-1 > maxRetries {
+		maxRetries = policy.MaxAttempts - 1
+	}
+
+	return func(ctx context.Context, evt Event) error {
+		attempts, err := retry(ctx, evt, h, maxRetries, options.RetryDelay)
+		if err == nil {
+			return nil
+		}
+		// the subscriber is shutting down: leave redelivery to the broker
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if dlErr := PublishDeadLetter(ctx, b, policy.Topic, evt, attempts, err); dlErr != nil {
+			return errors.Join(err, fmt.Errorf("publish to dead-letter topic [%s] failed: %w", policy.Topic, dlErr))
+		}
+
+		// with auto-ack the broker acknowledges the message once the handler returns nil
+		if !options.AutoAck {
+			return evt.Ack()
+		}
+		return nil
+	}
+}
+
+// PublishDeadLetter republishes the message of evt to topic with b.Publish, carrying cause, the
+// attempt count and the original topic as headers. Brokers use it to dead-letter the messages of
+// a batch which keeps failing, see BatchSubscriber.
+func PublishDeadLetter(ctx context.Context, b Broker, topic string, evt Event, attempts int, cause error) error {
+	msg, err := NewDeadLetterMessage(evt, attempts, cause)
+	if err != nil {
+		return err
+	}
+	return b.Publish(ctx, topic, msg)
+}
+
+// NewDeadLetterMessage returns the message dead-lettering evt: a copy of its message carrying
+// cause, the attempt count and the original topic as headers, with the payload as it was received
+// when it is known. Brokers which publish it by other means than Publish, e.g. in a transaction, use it.
+func NewDeadLetterMessage(evt Event, attempts int, cause error) (*Message, error) {
+	src := evt.Message()
+	if src == nil {
+		return nil, errors.New("message is nil")
+	}
+
+	msg := src.Clone()
+	msg.Msg = nil
+	msg.Partition = -1
+	msg.Offset = -1
+	msg.SetHeader(HeaderDeadLetterError, cause.Error())
+	msg.SetHeader(HeaderDeadLetterAttempts, strconv.Itoa(attempts))
+	msg.SetHeader(HeaderDeadLetterOriginalTopic, evt.Topic())
+
+	if payload, ok := Payload(evt); ok {
+		msg.Body = RawBody(payload)
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,181 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// recordingBroker 记录 Publish 调用，其余方法均为空实现
+type recordingBroker struct {
+	topics     []string
+	messages   []*Message
+	publishErr error
+}
+
+func (r *recordingBroker) Name() string         { return "recording" }
+func (r *recordingBroker) Options() Options     { return Options{} }
+func (r *recordingBroker) Address() string      { return "" }
+func (r *recordingBroker) Init(...Option) error { return nil }
+func (r *recordingBroker) Connect() error       { return nil }
+func (r *recordingBroker) Disconnect() error    { return nil }
+func (r *recordingBroker) Request(context.Context, string, *Message, ...RequestOption) (*Message, error) {
+	return nil, nil
+}
+func (r *recordingBroker) Subscribe(string, Handler, Binder, ...SubscribeOption) (Subscriber, error) {
+	return nil, nil
+}
+func (r *recordingBroker) Publish(_ context.Context, topic string, msg *Message, _ ...PublishOption) error {
+	r.topics = append(r.topics, topic)
+	r.messages = append(r.messages, msg)
+	return r.publishErr
+}
+
+type ackEvent struct {
+	msg   *Message
+	acked int
+}
+
+func (e *ackEvent) Topic() string     { return "orders" }
+func (e *ackEvent) Message() *Message { return e.msg }
+func (e *ackEvent) RawMessage() any   { return nil }
+func (e *ackEvent) Ack() error        { e.acked++; return nil }
+func (e *ackEvent) Error() error      { return nil }
+
+func TestDeadLetterHandler_PublishesAfterMaxAttempts(t *testing.T) {
+	b := &recordingBroker{}
+	options := NewSubscribeOptions(DisableAutoAck(), WithSubscribeDeadLetter("orders.dlq", 3))
+
+	calls := 0
+	h := WrapSubscribeHandler(b, func(context.Context, Event) error {
+		calls++
+		return errors.New("boom")
+	}, options)
+
+	evt := &ackEvent{msg: NewMessage("payload", WithHeader("trace", "abc"), WithKey("k1"))}
+	if err := h(context.Background(), evt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+	if len(b.topics) != 1 || b.topics[0] != "orders.dlq" {
+		t.Fatalf("unexpected dead-letter publishes: %v", b.topics)
+	}
+
+	dl := b.messages[0]
+	if dl.Body != "payload" || dl.Key != "k1" || dl.GetHeader("trace") != "abc" {
+		t.Fatalf("original message not preserved: %+v", dl)
+	}
+	if dl.GetHeader(HeaderDeadLetterError) != "boom" ||
+		dl.GetHeader(HeaderDeadLetterAttempts) != "3" ||
+		dl.GetHeader(HeaderDeadLetterOriginalTopic) != "orders" {
+		t.Fatalf("unexpected dead-letter headers: %v", dl.Headers)
+	}
+	if evt.msg.GetHeader(HeaderDeadLetterError) != "" {
+		t.Fatal("source message headers were modified")
+	}
+	if evt.acked != 1 {
+		t.Fatalf("expected source message to be acked once, got %d", evt.acked)
+	}
+}
+
+// payloadEvent keeps the payload a typed body was decoded from
+type payloadEvent struct {
+	ackEvent
+	payload []byte
+}
+
+func (e *payloadEvent) Payload() []byte { return e.payload }
+
+func TestDeadLetterHandler_PublishesOriginalPayload(t *testing.T) {
+	b := &recordingBroker{}
+	h := WrapSubscribeHandler(b, func(context.Context, Event) error {
+		return errors.New("boom")
+	}, NewSubscribeOptions(WithSubscribeDeadLetter("dlq", 1)))
+
+	// a body which was not decoded is not encoded again, e.g. to base64 by the json codec
+	_ = h(context.Background(), &ackEvent{msg: NewMessage([]byte(`{"id":1}`))})
+
+	// a decoded body is replaced by the payload it was decoded from
+	typed := &payloadEvent{
+		ackEvent: ackEvent{msg: NewMessage(map[string]any{"id": 2.0})},
+		payload:  []byte(`{"id": 2}`),
+	}
+	_ = h(context.Background(), typed)
+
+	if len(b.messages) != 2 {
+		t.Fatalf("expected 2 dead-letter publishes, got %d", len(b.messages))
+	}
+	if body, ok := b.messages[0].Body.(RawBody); !ok || string(body) != `{"id":1}` {
+		t.Fatalf("unexpected dead-letter body: %#v", b.messages[0].Body)
+	}
+	if body, ok := b.messages[1].Body.(RawBody); !ok || string(body) != `{"id": 2}` {
+		t.Fatalf("unexpected dead-letter body: %#v", b.messages[1].Body)
+	}
+	if _, ok := typed.msg.Body.(map[string]any); !ok {
+		t.Fatal("source message body was modified")
+	}
+}
+
+func TestDeadLetterHandler_AutoAckLeavesAckToBroker(t *testing.T) {
+	b := &recordingBroker{}
+	h := WrapSubscribeHandler(b, func(context.Context, Event) error {
+		return errors.New("boom")
+	}, NewSubscribeOptions(WithSubscribeDeadLetter("dlq", 1)))
+
+	evt := &ackEvent{msg: NewMessage("payload")}
+	if err := h(context.Background(), evt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if evt.acked != 0 {
+		t.Fatalf("expected no explicit ack with auto-ack, got %d", evt.acked)
+	}
+}
+
+func TestDeadLetterHandler_RetriesTakePrecedence(t *testing.T) {
+	b := &recordingBroker{}
+	calls := 0
+	h := WrapSubscribeHandler(b, func(context.Context, Event) error {
+		calls++
+		return errors.New("boom")
+	}, NewSubscribeOptions(WithSubscribeRetry(4, 0), WithSubscribeDeadLetter("dlq", 2)))
+
+	_ = h(context.Background(), &ackEvent{msg: NewMessage("payload")})
+	if calls != 5 {
+		t.Fatalf("expected 1 attempt + 4 retries, got %d calls", calls)
+	}
+	if got := b.messages[0].GetHeader(HeaderDeadLetterAttempts); got != "5" {
+		t.Fatalf("unexpected attempts header: %s", got)
+	}
+}
+
+func TestDeadLetterHandler_PublishFailureReturnsError(t *testing.T) {
+	b := &recordingBroker{publishErr: errors.New("unavailable")}
+	h := WrapSubscribeHandler(b, func(context.Context, Event) error {
+		return errors.New("boom")
+	}, NewSubscribeOptions(DisableAutoAck(), WithSubscribeDeadLetter("dlq", 1)))
+
+	evt := &ackEvent{msg: NewMessage("payload")}
+	if err := h(context.Background(), evt); err == nil {
+		t.Fatal("expected error when dead-letter publish fails")
+	}
+	if evt.acked != 0 {
+		t.Fatal("source message acked although dead-lettering failed")
+	}
+}
+
+func TestDeadLetterHandler_SuccessSkipsDeadLetter(t *testing.T) {
+	b := &recordingBroker{}
+	h := WrapSubscribeHandler(b, func(context.Context, Event) error {
+		return nil
+	}, NewSubscribeOptions(WithSubscribeDeadLetter("dlq", 3)))
+
+	if err := h(context.Background(), &ackEvent{msg: NewMessage("payload")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.topics) != 0 {
+		t.Fatalf("unexpected dead-letter publishes: %v", b.topics)
+	}
+}
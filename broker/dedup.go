@@ -0,0 +1,209 @@
+package broker
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultDedupTTL = 24 * time.Hour
+
+// DedupStore records the keys of the messages which have been processed.
+type DedupStore interface {
+	// Claim records key for ttl and reports whether it was absent, i.e. whether the caller
+	// is the first to process it. It must be atomic with respect to concurrent callers.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Release removes key, so that the message can be processed again.
+	Release(ctx context.Context, key string) error
+}
+
+// DedupKeyFunc derives the dedup key of an event. An empty key disables deduplication for it.
+type DedupKeyFunc func(evt Event) string
+
+type dedupOptions struct {
+	keyFunc       DedupKeyFunc
+	ttl           time.Duration
+	ackDuplicates bool
+}
+
+// DedupOption configures DedupMiddleware.
+type DedupOption func(*dedupOptions)
+
+// WithDedupHeader derives the dedup key from the given message header instead of Message.ID.
+func WithDedupHeader(header string) DedupOption {
+	return func(o *dedupOptions) {
+		o.keyFunc = func(evt Event) string {
+			if evt.Message() == nil {
+				return ""
+			}
+			return evt.Message().GetHeader(header)
+		}
+	}
+}
+
+// WithDedupKeyFunc derives the dedup key with fn instead of Message.ID.
+func WithDedupKeyFunc(fn DedupKeyFunc) DedupOption {
+	return func(o *dedupOptions) {
+		if fn != nil {
+			o.keyFunc = fn
+		}
+	}
+}
+
+// WithDedupTTL sets how long a processed key is remembered, 24 hours by default.
+// It should exceed the longest redelivery delay of the broker.
+func WithDedupTTL(ttl time.Duration) DedupOption {
+	return func(o *dedupOptions) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// WithDedupAckDuplicates acknowledges the skipped duplicates, for subscriptions with auto-ack disabled.
+func WithDedupAckDuplicates() DedupOption {
+	return func(o *dedupOptions) {
+		o.ackDuplicates = true
+	}
+}
+
+func messageIDKey(evt Event) string {
+	if evt.Message() == nil {
+		return ""
+	}
+	return evt.Message().ID
+}
+
+// DedupMiddleware returns a SubscriberMiddleware which skips the events already processed,
+// making handlers idempotent under at-least-once delivery.
+//
+// The key of an event is its topic followed by Message.ID, or by the value chosen with
+// WithDedupHeader or WithDedupKeyFunc. It is claimed in store before the handler runs and
+// released when the handler fails, so that a redelivery is processed again. Duplicates are
+// dropped without calling the handler. Errors of the store are returned to the broker.
+func DedupMiddleware(store DedupStore, opts ...DedupOption) SubscriberMiddleware {
+	o := dedupOptions{
+		keyFunc: messageIDKey,
+		ttl:     defaultDedupTTL,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, evt Event) error {
+			if store == nil || evt == nil {
+				return next(ctx, evt)
+			}
+
+			key := o.keyFunc(evt)
+			if key == "" {
+				return next(ctx, evt)
+			}
+			key = evt.Topic() + ":" + key
+
+			claimed, err := store.Claim(ctx, key, o.ttl)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				if o.ackDuplicates {
+					return evt.Ack()
+				}
+				return nil
+			}
+
+			if err = next(ctx, evt); err != nil {
+				if relErr := store.Release(ctx, key); relErr != nil {
+					return errors.Join(err, fmt.Errorf("release dedup key [%s] failed: %w", key, relErr))
+				}
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// MemoryDedupStore is an in-process DedupStore. It holds at most a fixed number of keys,
+// evicting the least recently claimed ones first, and forgets keys once their TTL expires.
+type MemoryDedupStore struct {
+	sync.Mutex
+
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// NewMemoryDedupStore creates a MemoryDedupStore holding at most capacity keys, unbounded when capacity <= 0.
+func NewMemoryDedupStore(capacity int) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Claim implements DedupStore.
+func (s *MemoryDedupStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+
+	if el, ok := s.entries[key]; ok {
+		if now.Before(el.Value.(*dedupEntry).expiresAt) {
+			return false, nil
+		}
+		s.remove(el)
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	} else {
+		expiresAt = now.Add(defaultDedupTTL)
+	}
+	s.entries[key] = s.order.PushFront(&dedupEntry{key: key, expiresAt: expiresAt})
+
+	// the oldest claims sit at the back: drop the expired ones, then the overflow
+	for el := s.order.Back(); el != nil && !now.Before(el.Value.(*dedupEntry).expiresAt); el = s.order.Back() {
+		s.remove(el)
+	}
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+	return true, nil
+}
+
+// Release implements DedupStore.
+func (s *MemoryDedupStore) Release(_ context.Context, key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of keys held, including the expired ones not evicted yet.
+func (s *MemoryDedupStore) Len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.order.Len()
+}
+
+func (s *MemoryDedupStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*dedupEntry).key)
+}
@@ -0,0 +1,148 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// topicEvent 携带主题与消息的 Event 实现
+type topicEvent struct {
+	ackEvent
+	topic string
+}
+
+func (e *topicEvent) Topic() string { return e.topic }
+
+func newTopicEvent(topic string, msg *Message) *topicEvent {
+	return &topicEvent{ackEvent: ackEvent{msg: msg}, topic: topic}
+}
+
+func TestDedupMiddleware_SkipsDuplicates(t *testing.T) {
+	var calls int
+	h := DedupMiddleware(NewMemoryDedupStore(0))(func(context.Context, Event) error {
+		calls++
+		return nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := h(context.Background(), newTopicEvent("orders", &Message{ID: "1"})); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// 相同 ID 不同主题视为不同消息
+	if err := h(context.Background(), newTopicEvent("payments", &Message{ID: "1"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected 2 handler calls, got %d", calls)
+	}
+}
+
+func TestDedupMiddleware_ReleasesOnFailure(t *testing.T) {
+	var calls int
+	h := DedupMiddleware(NewMemoryDedupStore(0))(func(context.Context, Event) error {
+		calls++
+		if calls == 1 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	evt := newTopicEvent("orders", &Message{ID: "1"})
+	if err := h(context.Background(), evt); err == nil {
+		t.Fatal("expected handler error")
+	}
+	if err := h(context.Background(), evt); err != nil {
+		t.Fatalf("redelivery not processed: %v", err)
+	}
+	if err := h(context.Background(), evt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected 2 handler calls, got %d", calls)
+	}
+}
+
+func TestDedupMiddleware_KeySources(t *testing.T) {
+	var calls int
+	handler := func(context.Context, Event) error {
+		calls++
+		return nil
+	}
+
+	byHeader := DedupMiddleware(NewMemoryDedupStore(0), WithDedupHeader("x-idempotency-key"))(handler)
+	for _, id := range []string{"1", "2"} {
+		msg := &Message{ID: id, Headers: Headers{"x-idempotency-key": "k"}}
+		if err := byHeader(context.Background(), newTopicEvent("orders", msg)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("header key: expected 1 handler call, got %d", calls)
+	}
+
+	calls = 0
+	byKey := DedupMiddleware(NewMemoryDedupStore(0), WithDedupKeyFunc(func(evt Event) string {
+		return evt.Message().Key
+	}))(handler)
+	for _, key := range []string{"a", "a", "b", ""} {
+		if err := byKey(context.Background(), newTopicEvent("orders", &Message{Key: key})); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// 空键不参与去重
+	if calls != 3 {
+		t.Fatalf("key func: expected 3 handler calls, got %d", calls)
+	}
+}
+
+func TestDedupMiddleware_AckDuplicates(t *testing.T) {
+	h := DedupMiddleware(NewMemoryDedupStore(0), WithDedupAckDuplicates())(func(context.Context, Event) error {
+		return nil
+	})
+
+	first := newTopicEvent("orders", &Message{ID: "1"})
+	dup := newTopicEvent("orders", &Message{ID: "1"})
+	_ = h(context.Background(), first)
+	_ = h(context.Background(), dup)
+
+	if first.acked != 0 {
+		t.Fatal("processed event must be acknowledged by its handler, not the middleware")
+	}
+	if dup.acked != 1 {
+		t.Fatal("duplicate not acknowledged")
+	}
+}
+
+func TestMemoryDedupStore_CapacityAndTTL(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryDedupStore(2)
+
+	for _, k := range []string{"a", "b", "c"} {
+		if ok, _ := s.Claim(ctx, k, time.Hour); !ok {
+			t.Fatalf("claim %s failed", k)
+		}
+	}
+	if s.Len() != 2 {
+		t.Fatalf("expected 2 keys, got %d", s.Len())
+	}
+	// "a" 已被淘汰，可再次认领
+	if ok, _ := s.Claim(ctx, "a", time.Hour); !ok {
+		t.Fatal("evicted key not claimable")
+	}
+	if ok, _ := s.Claim(ctx, "c", time.Hour); ok {
+		t.Fatal("held key claimed twice")
+	}
+
+	if ok, _ := s.Claim(ctx, "short", 10*time.Millisecond); !ok {
+		t.Fatal("claim short failed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := s.Claim(ctx, "short", time.Hour); !ok {
+		t.Fatal("expired key not claimable")
+	}
+}
@@ -10,12 +10,20 @@ import (
 	_ "github.com/go-kratos/kratos/v2/encoding/proto"
 )
 
+// RawBody is a message body which is already encoded. Marshal returns it unchanged whatever the codec,
+// which lets a message encoded earlier (e.g. stored by an outbox) be published as is.
+type RawBody []byte
+
 // Marshal encodes a message into bytes using the provided codec.
 func Marshal(codec encoding.Codec, msg any) ([]byte, error) {
 	if msg == nil {
 		return nil, errors.New("message is nil")
 	}
 
+	if raw, ok := msg.(RawBody); ok {
+		return raw, nil
+	}
+
 	if codec != nil {
 		return codec.Marshal(msg)
 	}
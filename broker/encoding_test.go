@@ -0,0 +1,19 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/encoding"
+)
+
+func TestMarshal_RawBodyBypassesCodec(t *testing.T) {
+	raw := RawBody(`{"humidity":50}`)
+
+	buf, err := Marshal(encoding.GetCodec("json"), raw)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(buf) != string(raw) {
+		t.Fatalf("raw body re-encoded: %s", buf)
+	}
+}
@@ -0,0 +1,137 @@
+package broker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// envelopeMagic prefixes every encoded Envelope. The leading zero byte never starts
+// a JSON, text or protobuf payload produced by the codecs of this package.
+var envelopeMagic = []byte{0x00, 'K', 'T', 'E'}
+
+const envelopeVersion byte = 1
+
+var (
+	// ErrNotEnvelope is returned by DecodeEnvelope when data is not an encoded Envelope.
+	ErrNotEnvelope = errors.New("payload is not an envelope")
+	// ErrMalformedEnvelope is returned by DecodeEnvelope when an envelope is truncated or corrupt.
+	ErrMalformedEnvelope = errors.New("malformed envelope")
+)
+
+// Envelope frames the ID, headers and encoded body of a message into a single payload,
+// for protocols that cannot carry headers next to the body (MQTT 3.1.1, NSQ, Redis Pub/Sub).
+//
+// Layout: magic, version, uvarint-prefixed ID, uvarint header count,
+// uvarint-prefixed key and value of every header, then the body until the end of the payload.
+type Envelope struct {
+	ID      string
+	Headers Headers
+	Body    []byte
+}
+
+// Encode returns the framed payload of the envelope.
+func (e *Envelope) Encode() []byte {
+	size := len(envelopeMagic) + 1 + binary.MaxVarintLen64*(2+2*len(e.Headers)) + len(e.ID) + len(e.Body)
+	for k, v := range e.Headers {
+		size += len(k) + len(v)
+	}
+
+	buf := make([]byte, 0, size)
+	buf = append(buf, envelopeMagic...)
+	buf = append(buf, envelopeVersion)
+	buf = appendString(buf, e.ID)
+	buf = binary.AppendUvarint(buf, uint64(len(e.Headers)))
+	for k, v := range e.Headers {
+		buf = appendString(buf, k)
+		buf = appendString(buf, v)
+	}
+	return append(buf, e.Body...)
+}
+
+// IsEnvelope reports whether data starts like an encoded Envelope.
+func IsEnvelope(data []byte) bool {
+	return len(data) > len(envelopeMagic) && bytes.HasPrefix(data, envelopeMagic)
+}
+
+// DecodeEnvelope parses a payload produced by Envelope.Encode.
+// It returns ErrNotEnvelope when data is a plain payload, so that callers can fall back to it.
+func DecodeEnvelope(data []byte) (*Envelope, error) {
+	if !IsEnvelope(data) {
+		return nil, ErrNotEnvelope
+	}
+
+	data = data[len(envelopeMagic):]
+	if data[0] != envelopeVersion {
+		return nil, ErrMalformedEnvelope
+	}
+	data = data[1:]
+
+	var (
+		env Envelope
+		err error
+	)
+	if env.ID, data, err = readString(data); err != nil {
+		return nil, err
+	}
+
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return nil, ErrMalformedEnvelope
+	}
+	data = data[n:]
+
+	env.Headers = make(Headers, count)
+	for i := uint64(0); i < count; i++ {
+		var k, v string
+		if k, data, err = readString(data); err != nil {
+			return nil, err
+		}
+		if v, data, err = readString(data); err != nil {
+			return nil, err
+		}
+		env.Headers[k] = v
+	}
+
+	env.Body = data
+	return &env, nil
+}
+
+type envelopeKey struct{}
+
+// WithEnvelope makes the brokers of header-less protocols (MQTT 3.1.1, NSQ) wrap every
+// published message in an Envelope, so that its ID, headers and trace context reach the
+// consumers. Consumers detect envelopes by themselves and need no option: plain payloads
+// from publishers without the option are still delivered as they are.
+func WithEnvelope() Option {
+	return OptionContextWithValue(envelopeKey{}, true)
+}
+
+// EnvelopeEnabled reports whether WithEnvelope was applied to o.
+func EnvelopeEnabled(o Options) bool {
+	if o.Context == nil {
+		return false
+	}
+	enabled, _ := o.Context.Value(envelopeKey{}).(bool)
+	return enabled
+}
+
+// IsRequestReply reports whether msg takes part in a request/reply exchange,
+// i.e. whether it carries a reply topic or a correlation ID.
+func IsRequestReply(msg *Message) bool {
+	return msg != nil && (msg.GetHeader(HeaderReplyTo) != "" || msg.GetHeader(HeaderCorrelationID) != "")
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readString(data []byte) (string, []byte, error) {
+	l, n := binary.Uvarint(data)
+	if n <= 0 || l > uint64(len(data)-n) {
+		return "", nil, ErrMalformedEnvelope
+	}
+	data = data[n:]
+	return string(data[:l]), data[l:], nil
+}
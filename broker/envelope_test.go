@@ -0,0 +1,64 @@
+package broker
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	in := &Envelope{
+		ID:      "id-1",
+		Headers: Headers{HeaderReplyTo: "_INBOX.1", HeaderCorrelationID: "c-1", "empty": ""},
+		Body:    []byte(`{"humidity":50}`),
+	}
+
+	out, err := DecodeEnvelope(in.Encode())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.ID != in.ID || !bytes.Equal(out.Body, in.Body) || len(out.Headers) != len(in.Headers) {
+		t.Fatalf("unexpected envelope: %+v", out)
+	}
+	for k, v := range in.Headers {
+		if out.Headers[k] != v {
+			t.Fatalf("header %s: expected %q, got %q", k, v, out.Headers[k])
+		}
+	}
+}
+
+func TestEnvelope_EmptyBody(t *testing.T) {
+	out, err := DecodeEnvelope((&Envelope{ID: "x"}).Encode())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.ID != "x" || len(out.Body) != 0 || len(out.Headers) != 0 {
+		t.Fatalf("unexpected envelope: %+v", out)
+	}
+}
+
+func TestDecodeEnvelope_PlainPayload(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("hello"), []byte(`{"a":1}`), {0x00}} {
+		if _, err := DecodeEnvelope(data); !errors.Is(err, ErrNotEnvelope) {
+			t.Fatalf("expected ErrNotEnvelope for %q, got %v", data, err)
+		}
+	}
+}
+
+func TestDecodeEnvelope_Truncated(t *testing.T) {
+	data := (&Envelope{ID: "id", Headers: Headers{"k": "value"}, Body: []byte("b")}).Encode()
+
+	// cut inside the header value
+	if _, err := DecodeEnvelope(data[:len(data)-4]); !errors.Is(err, ErrMalformedEnvelope) {
+		t.Fatalf("expected ErrMalformedEnvelope, got %v", err)
+	}
+}
+
+func TestWithEnvelope(t *testing.T) {
+	if EnvelopeEnabled(NewOptions()) {
+		t.Fatal("envelope should be disabled by default")
+	}
+	if !EnvelopeEnabled(NewOptionsAndApply(WithEnvelope())) {
+		t.Fatal("expected envelope to be enabled")
+	}
+}
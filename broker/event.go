@@ -21,6 +21,34 @@ type Event interface {
 	Error() error
 }
 
+// Payloader is implemented by the events of brokers which keep the payload of a message as it
+// was received, before it was decoded into Message.Body.
+type Payloader interface {
+	// Payload returns the encoded body of the message.
+	Payload() []byte
+}
+
+// Payload returns the encoded body of evt: the payload kept by its broker, see Payloader, or the
+// body itself when it was not decoded. ok is false when only the decoded body is known.
+func Payload(evt Event) (payload []byte, ok bool) {
+	if p, isPayloader := evt.(Payloader); isPayloader {
+		if payload = p.Payload(); payload != nil {
+			return payload, true
+		}
+	}
+
+	if m := evt.Message(); m != nil {
+		switch body := m.Body.(type) {
+		case RawBody:
+			return body, true
+		case []byte:
+			return body, true
+		}
+	}
+
+	return nil, false
+}
+
 // Handler defines the handler invoked by subscribers
 type Handler func(ctx context.Context, evt Event) error
 
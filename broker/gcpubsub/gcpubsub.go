@@ -12,6 +12,9 @@ import (
 	"github.com/tx7do/kratos-transport/broker"
 )
 
+// topic names must start with a letter
+const defaultInboxPrefix = "inbox-"
+
 type gcpBroker struct {
 	sync.RWMutex
 
@@ -22,6 +25,8 @@ type gcpBroker struct {
 	running bool
 
 	subscribers *broker.SubscriberSyncMap
+
+	requester *broker.Requester
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
@@ -31,6 +36,7 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		options:     options,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	b.requester = broker.NewRequester(b, broker.WithInboxPrefix(defaultInboxPrefix))
 
 	return b
 }
@@ -103,6 +109,8 @@ func (b *gcpBroker) Connect() error {
 }
 
 func (b *gcpBroker) Disconnect() error {
+	_ = b.requester.Close()
+
 	b.Lock()
 	defer b.Unlock()
 
@@ -124,8 +132,10 @@ func (b *gcpBroker) Disconnect() error {
 	return nil
 }
 
+// Request sends a request and waits for the reply. Topics and subscriptions are not created
+// on demand, so the reply topic and its subscription must exist: pass the topic with broker.WithReplyTopic.
 func (b *gcpBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *gcpBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -143,6 +153,7 @@ func (b *gcpBroker) internalPublish(ctx context.Context, topic string, msg *brok
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -213,9 +224,10 @@ func (b *gcpBroker) Subscribe(topic string, handler broker.Handler, binder broke
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	// Resolve subscription name: subscribe context → topic name as default
 	subscriptionName := topic
@@ -226,6 +238,10 @@ func (b *gcpBroker) Subscribe(topic string, handler broker.Handler, binder broke
 	}
 
 	var receiveSettings pubsub.ReceiveSettings
+	// Pub/Sub runs callbacks concurrently up to MaxOutstandingMessages, the generic option maps onto it
+	if options.Concurrency > 0 {
+		receiveSettings.MaxOutstandingMessages = options.Concurrency
+	}
 	if options.Context != nil {
 		if v, ok := options.Context.Value(receiveSettingsKey{}).(pubsub.ReceiveSettings); ok {
 			receiveSettings = v
@@ -291,6 +307,7 @@ func (b *gcpBroker) receive(ctx context.Context, client *pubsub.Client, subscrip
 			msg:    &m,
 			gcpMsg: msg,
 			ack:    msg.Ack,
+			nack:   msg.Nack,
 		}
 
 		if err := handler(receiveCtx, p); err != nil {
@@ -1,20 +1,26 @@
 package gcpubsub
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"cloud.google.com/go/pubsub/v2"
 
 	"github.com/tx7do/kratos-transport/broker"
 )
 
+var _ broker.Nacker = (*publication)(nil)
+
 type publication struct {
 	topic  string
 	msg    *broker.Message
 	gcpMsg *pubsub.Message
 	err    error
 	ack    func()
+	nack   func()
 	acked  bool
+	nacked bool
 }
 
 func (p *publication) Topic() string {
@@ -29,8 +35,16 @@ func (p *publication) RawMessage() any {
 	return p.gcpMsg
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.gcpMsg == nil {
+		return nil
+	}
+	return p.gcpMsg.Data
+}
+
 func (p *publication) Ack() error {
-	if p.acked {
+	if p.acked || p.nacked {
 		return nil
 	}
 	if p.ack == nil {
@@ -42,6 +56,42 @@ func (p *publication) Ack() error {
 	return nil
 }
 
+// Nack redelivers the message at once, or following the retry policy of the subscription.
+// Pub/Sub cannot drop a message without acknowledging it: without requeue, it returns
+// broker.ErrNotSupported; the dead-letter policy of the subscription moves messages
+// delivered too often to its dead-letter topic.
+func (p *publication) Nack(requeue bool) error {
+	if !requeue {
+		return fmt.Errorf("pub/sub cannot reject a message: %w", broker.ErrNotSupported)
+	}
+	if err := p.settle(); err != nil {
+		return err
+	}
+	p.nack()
+	return nil
+}
+
+// NackWithDelay keeps the message leased for delay, the client extending its ack deadline
+// up to the MaxExtension of the receive settings, then nacks it.
+func (p *publication) NackWithDelay(delay time.Duration) error {
+	if err := p.settle(); err != nil {
+		return err
+	}
+	time.AfterFunc(delay, p.nack)
+	return nil
+}
+
+func (p *publication) settle() error {
+	if p.nack == nil {
+		return errors.New("nack function is nil")
+	}
+	if p.acked || p.nacked {
+		return errors.New("the message is already settled")
+	}
+	p.nacked = true
+	return nil
+}
+
 func (p *publication) Error() error {
 	return p.err
 }
@@ -0,0 +1,442 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// ErrGroupNotEmpty is returned when resetting the offsets of a consumer group that has active members
+var ErrGroupNotEmpty = errors.New("kafka: consumer group has active members")
+
+// TopicSpec describes a topic to provision.
+type TopicSpec struct {
+	Name string
+
+	// Partitions is the partition count, 0 uses the broker default.
+	Partitions int
+
+	// ReplicationFactor is the replication factor, 0 uses the broker default. It is only used to create the topic.
+	ReplicationFactor int
+
+	// Configs are topic configs such as retention.ms or cleanup.policy.
+	Configs map[string]string
+}
+
+// TopicInfo describes an existing topic.
+type TopicInfo struct {
+	Name       string
+	Internal   bool
+	Partitions []PartitionInfo
+}
+
+// PartitionInfo describes a partition of a topic.
+type PartitionInfo struct {
+	Partition int
+	Leader    int
+	Replicas  []int
+	ISR       []int
+}
+
+// ConfigEntry is a config of a topic.
+type ConfigEntry struct {
+	Name  string
+	Value string
+
+	// Override is set when the config is set on the topic rather than inherited from the broker.
+	Override bool
+
+	// Sensitive configs have no value.
+	Sensitive bool
+}
+
+// GroupInfo describes a consumer group.
+type GroupInfo struct {
+	Group        string
+	State        string
+	ProtocolType string
+}
+
+// PartitionLag is how far a consumer group is behind the end of a partition.
+type PartitionLag struct {
+	Topic     string
+	Partition int
+
+	// Member is the member consuming the partition, empty when the group has no active member.
+	Member string
+
+	// Committed is the committed offset of the group, -1 when it has none.
+	Committed int64
+	// End is the offset of the next message produced to the partition.
+	End int64
+	// Lag is End minus Committed, the whole partition when nothing is committed.
+	Lag int64
+}
+
+// OffsetSpec is the position ResetGroupOffsets moves a consumer group to.
+type OffsetSpec struct {
+	earliest bool
+	at       time.Time
+}
+
+// OffsetEarliest is the first message still in the partition.
+func OffsetEarliest() OffsetSpec {
+	return OffsetSpec{earliest: true}
+}
+
+// OffsetLatest is the end of the partition: only messages produced after the reset are consumed.
+func OffsetLatest() OffsetSpec {
+	return OffsetSpec{}
+}
+
+// OffsetAtTime is the first message published at or after t, or the end of the partition when there is none.
+func OffsetAtTime(t time.Time) OffsetSpec {
+	return OffsetSpec{at: t}
+}
+
+// Admin manages the topics and consumer groups of a cluster.
+//
+// Topic metadata is cached for up to 5 seconds, changes made by other clients may not be seen before then.
+type Admin struct {
+	client *kgo.Client
+	admin  *kadm.Client
+}
+
+// NewAdmin creates an admin client for the cluster configured by opts, which takes the broker
+// options: broker.WithAddress, the SASL options and TLS.
+func NewAdmin(opts ...broker.Option) (*Admin, error) {
+	return newAdmin(broker.NewOptionsAndApply(opts...))
+}
+
+func newAdmin(options broker.Options) (*Admin, error) {
+	var addrs []string
+	for _, addr := range options.Addrs {
+		if len(addr) > 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		addrs = []string{defaultAddr}
+	}
+	options.Addrs = addrs
+
+	client, err := kgo.NewClient(newClientOptions(options)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Admin{client: client, admin: kadm.NewClient(client)}, nil
+}
+
+// Close closes the connections of the admin client.
+func (a *Admin) Close() {
+	a.client.Close()
+}
+
+// ListTopics returns the names of the topics, internal topics excluded.
+func (a *Admin) ListTopics(ctx context.Context) ([]string, error) {
+	details, err := a.admin.ListTopics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return details.Names(), nil
+}
+
+// DescribeTopics returns the partitions of topics, or of every topic when none is given.
+func (a *Admin) DescribeTopics(ctx context.Context, topics ...string) ([]TopicInfo, error) {
+	details, err := a.admin.ListTopics(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	if err = details.Error(); err != nil {
+		return nil, err
+	}
+
+	var infos []TopicInfo
+	for _, detail := range details.Sorted() {
+		info := TopicInfo{
+			Name:     detail.Topic,
+			Internal: detail.IsInternal,
+		}
+		for _, p := range detail.Partitions.Sorted() {
+			info.Partitions = append(info.Partitions, PartitionInfo{
+				Partition: int(p.Partition),
+				Leader:    int(p.Leader),
+				Replicas:  toInts(p.Replicas),
+				ISR:       toInts(p.ISR),
+			})
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// EnsureTopic provisions spec: it creates the topic when it does not exist, otherwise adds the
+// missing partitions and sets the configs whose values differ. Partitions are never removed,
+// a topic with more partitions than spec is left as is. It returns whether the topic was created.
+func (a *Admin) EnsureTopic(ctx context.Context, spec TopicSpec) (bool, error) {
+	details, err := a.admin.ListTopics(ctx, spec.Name)
+	if err != nil {
+		return false, err
+	}
+
+	detail, ok := details[spec.Name]
+	if !ok || errors.Is(detail.Err, kerr.UnknownTopicOrPartition) {
+		if err = a.CreateTopic(ctx, spec); errors.Is(err, kerr.TopicAlreadyExists) {
+			// created concurrently, e.g. by another instance starting at the same time
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if detail.Err != nil {
+		return false, detail.Err
+	}
+
+	if spec.Partitions > len(detail.Partitions) {
+		if err = a.SetPartitions(ctx, spec.Name, spec.Partitions); err != nil {
+			return false, err
+		}
+	}
+
+	if len(spec.Configs) == 0 {
+		return false, nil
+	}
+
+	configs, err := a.DescribeTopicConfigs(ctx, spec.Name)
+	if err != nil {
+		return false, err
+	}
+	current := make(map[string]string, len(configs))
+	for _, c := range configs {
+		current[c.Name] = c.Value
+	}
+
+	changed := make(map[string]string)
+	for k, v := range spec.Configs {
+		if value, ok := current[k]; !ok || value != v {
+			changed[k] = v
+		}
+	}
+	if len(changed) == 0 {
+		return false, nil
+	}
+
+	return false, a.AlterTopicConfigs(ctx, spec.Name, changed)
+}
+
+// ensureTopics provisions the topics of WithTopics
+func (b *kafkaBroker) ensureTopics(ctx context.Context) error {
+	specs, ok := b.options.Context.Value(topicSpecsKey{}).([]TopicSpec)
+	if !ok || len(specs) == 0 {
+		return nil
+	}
+
+	admin, err := newAdmin(b.options)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	for _, spec := range specs {
+		if _, err = admin.EnsureTopic(ctx, spec); err != nil {
+			return fmt.Errorf("provision topic %s: %w", spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// CreateTopic creates the topic of spec, it fails with kerr.TopicAlreadyExists when the topic exists.
+func (a *Admin) CreateTopic(ctx context.Context, spec TopicSpec) error {
+	partitions := int32(-1)
+	if spec.Partitions > 0 {
+		partitions = int32(spec.Partitions)
+	}
+	replicationFactor := int16(-1)
+	if spec.ReplicationFactor > 0 {
+		replicationFactor = int16(spec.ReplicationFactor)
+	}
+
+	var configs map[string]*string
+	if len(spec.Configs) > 0 {
+		configs = make(map[string]*string, len(spec.Configs))
+		for k, v := range spec.Configs {
+			configs[k] = kadm.StringPtr(v)
+		}
+	}
+
+	_, err := a.admin.CreateTopic(ctx, partitions, replicationFactor, configs, spec.Name)
+	a.client.PurgeTopicsFromClient(spec.Name)
+	return err
+}
+
+// DeleteTopics deletes topics.
+func (a *Admin) DeleteTopics(ctx context.Context, topics ...string) error {
+	responses, err := a.admin.DeleteTopics(ctx, topics...)
+	a.client.PurgeTopicsFromClient(topics...)
+	if err != nil {
+		return err
+	}
+	return responses.Error()
+}
+
+// SetPartitions raises the partition count of topic to count. Kafka cannot remove partitions.
+func (a *Admin) SetPartitions(ctx context.Context, topic string, count int) error {
+	responses, err := a.admin.UpdatePartitions(ctx, count, topic)
+	a.client.PurgeTopicsFromClient(topic)
+	if err != nil {
+		return err
+	}
+	return responses.Error()
+}
+
+// DescribeTopicConfigs returns the configs of topic, sorted by name.
+func (a *Admin) DescribeTopicConfigs(ctx context.Context, topic string) ([]ConfigEntry, error) {
+	resources, err := a.admin.DescribeTopicConfigs(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := resources.On(topic, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resource.Err != nil {
+		return nil, resource.Err
+	}
+
+	entries := make([]ConfigEntry, 0, len(resource.Configs))
+	for _, c := range resource.Configs {
+		entries = append(entries, ConfigEntry{
+			Name:      c.Key,
+			Value:     c.MaybeValue(),
+			Override:  c.Source == kmsg.ConfigSourceDynamicTopicConfig,
+			Sensitive: c.Sensitive,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	return entries, nil
+}
+
+// AlterTopicConfigs sets the configs of topic in set and removes the overrides named in remove,
+// which then fall back to the broker defaults. Other configs are left unchanged.
+func (a *Admin) AlterTopicConfigs(ctx context.Context, topic string, set map[string]string, remove ...string) error {
+	var alters []kadm.AlterConfig
+	for k, v := range set {
+		alters = append(alters, kadm.AlterConfig{Op: kadm.SetConfig, Name: k, Value: kadm.StringPtr(v)})
+	}
+	for _, k := range remove {
+		alters = append(alters, kadm.AlterConfig{Op: kadm.DeleteConfig, Name: k})
+	}
+	if len(alters) == 0 {
+		return nil
+	}
+
+	responses, err := a.admin.AlterTopicConfigs(ctx, alters, topic)
+	if err != nil {
+		return err
+	}
+	for _, r := range responses {
+		if r.Err != nil {
+			return fmt.Errorf("alter configs of topic %s: %w", r.Name, r.Err)
+		}
+	}
+
+	return nil
+}
+
+// ListGroups returns the consumer groups, sorted by name.
+func (a *Admin) ListGroups(ctx context.Context) ([]GroupInfo, error) {
+	groups, err := a.admin.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []GroupInfo
+	for _, g := range groups.Sorted() {
+		infos = append(infos, GroupInfo{
+			Group:        g.Group,
+			State:        g.State,
+			ProtocolType: g.ProtocolType,
+		})
+	}
+
+	return infos, nil
+}
+
+// GroupLag returns the lag of group on each partition of the topics it committed offsets for
+// or is consuming, sorted by topic and partition.
+func (a *Admin) GroupLag(ctx context.Context, group string) ([]PartitionLag, error) {
+	lags, err := a.admin.Lag(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	described, ok := lags[group]
+	if !ok {
+		return nil, kerr.GroupIDNotFound
+	}
+	if err = described.Error(); err != nil {
+		return nil, err
+	}
+
+	var out []PartitionLag
+	for _, l := range described.Lag.Sorted() {
+		if l.Err != nil {
+			return nil, fmt.Errorf("lag of topic %s partition %d: %w", l.Topic, l.Partition, l.Err)
+		}
+
+		lag := PartitionLag{
+			Topic:     l.Topic,
+			Partition: int(l.Partition),
+			Committed: l.Commit.At,
+			End:       l.End.Offset,
+			Lag:       l.Lag,
+		}
+		if l.Member != nil {
+			lag.Member = l.Member.MemberID
+		}
+		out = append(out, lag)
+	}
+
+	return out, nil
+}
+
+// ResetGroupOffsets commits the offsets of to for every partition of topic in group, which
+// consumes from there when it next joins. The group must have no active member.
+func (a *Admin) ResetGroupOffsets(ctx context.Context, group, topic string, to OffsetSpec) error {
+	described, err := a.admin.DescribeGroups(ctx, group)
+	if err != nil {
+		return err
+	}
+	if g, ok := described[group]; ok && len(g.Members) > 0 {
+		return ErrGroupNotEmpty
+	}
+
+	var listed kadm.ListedOffsets
+	switch {
+	case to.earliest:
+		listed, err = a.admin.ListStartOffsets(ctx, topic)
+	case !to.at.IsZero():
+		listed, err = a.admin.ListOffsetsAfterMilli(ctx, to.at.UnixMilli(), topic)
+	default:
+		listed, err = a.admin.ListEndOffsets(ctx, topic)
+	}
+	if err != nil {
+		return err
+	}
+	if err = listed.Error(); err != nil {
+		return err
+	}
+
+	return a.admin.CommitAllOffsets(ctx, group, listed.Offsets())
+}
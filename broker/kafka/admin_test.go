@@ -0,0 +1,159 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+func newTestAdmin(t *testing.T, addrs []string) *Admin {
+	t.Helper()
+
+	admin, err := NewAdmin(broker.WithAddress(addrs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+
+	return admin
+}
+
+func TestAdmin_EnsureTopic(t *testing.T) {
+	cluster := newFakeCluster(t)
+	admin := newTestAdmin(t, cluster.ListenAddrs())
+	ctx := context.Background()
+
+	spec := TopicSpec{
+		Name:       "provisioned",
+		Partitions: 2,
+		Configs:    map[string]string{"retention.ms": "60000"},
+	}
+
+	created, err := admin.EnsureTopic(ctx, spec)
+	assert.Nil(t, err)
+	assert.True(t, created)
+
+	// provisioning the same spec again changes nothing
+	created, err = admin.EnsureTopic(ctx, spec)
+	assert.Nil(t, err)
+	assert.False(t, created)
+
+	spec.Partitions = 4
+	spec.Configs["retention.ms"] = "120000"
+	_, err = admin.EnsureTopic(ctx, spec)
+	assert.Nil(t, err)
+
+	topics, err := admin.ListTopics(ctx)
+	assert.Nil(t, err)
+	assert.Contains(t, topics, "provisioned")
+
+	infos, err := admin.DescribeTopics(ctx, "provisioned")
+	assert.Nil(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Len(t, infos[0].Partitions, 4)
+		assert.Equal(t, 3, infos[0].Partitions[3].Partition)
+	}
+
+	configs, err := admin.DescribeTopicConfigs(ctx, "provisioned")
+	assert.Nil(t, err)
+	assert.Contains(t, configs, ConfigEntry{Name: "retention.ms", Value: "120000", Override: true})
+
+	assert.Nil(t, admin.AlterTopicConfigs(ctx, "provisioned", map[string]string{"cleanup.policy": "compact"}, "retention.ms"))
+	configs, err = admin.DescribeTopicConfigs(ctx, "provisioned")
+	assert.Nil(t, err)
+	assert.Contains(t, configs, ConfigEntry{Name: "cleanup.policy", Value: "compact", Override: true})
+	for _, c := range configs {
+		if c.Name == "retention.ms" {
+			assert.False(t, c.Override)
+		}
+	}
+
+	assert.Nil(t, admin.DeleteTopics(ctx, "provisioned"))
+	topics, err = admin.ListTopics(ctx)
+	assert.Nil(t, err)
+	assert.NotContains(t, topics, "provisioned")
+}
+
+func TestAdmin_ProvisionTopicsOnConnect(t *testing.T) {
+	cluster := newFakeCluster(t)
+
+	b := NewBroker(
+		broker.WithAddress(cluster.ListenAddrs()...),
+		WithTopics(
+			TopicSpec{Name: "orders", Partitions: 3},
+			TopicSpec{Name: "audit", Configs: map[string]string{"cleanup.policy": "compact"}},
+		),
+	)
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	admin := newTestAdmin(t, cluster.ListenAddrs())
+	infos, err := admin.DescribeTopics(context.Background(), "audit", "orders")
+	assert.Nil(t, err)
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "audit", infos[0].Name)
+		assert.Len(t, infos[1].Partitions, 3)
+	}
+
+	configs, err := admin.DescribeTopicConfigs(context.Background(), "audit")
+	assert.Nil(t, err)
+	assert.Contains(t, configs, ConfigEntry{Name: "cleanup.policy", Value: "compact", Override: true})
+}
+
+func TestAdmin_GroupLagAndReset(t *testing.T) {
+	cluster := newFakeCluster(t, "lagging")
+	admin := newTestAdmin(t, cluster.ListenAddrs())
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	var records []*kgo.Record
+	for i := 0; i < 5; i++ {
+		records = append(records, &kgo.Record{
+			Topic:     "lagging",
+			Value:     []byte(fmt.Sprintf("m%d", i)),
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	produceRecords(t, cluster.ListenAddrs(), records...)
+
+	assert.Nil(t, admin.ResetGroupOffsets(ctx, "reporting", "lagging", OffsetEarliest()))
+
+	groups, err := admin.ListGroups(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, groups, 1) {
+		assert.Equal(t, "reporting", groups[0].Group)
+	}
+
+	lags, err := admin.GroupLag(ctx, "reporting")
+	assert.Nil(t, err)
+	assert.Equal(t, []PartitionLag{{Topic: "lagging", Partition: 0, Committed: 0, End: 5, Lag: 5}}, lags)
+
+	assert.Nil(t, admin.ResetGroupOffsets(ctx, "reporting", "lagging", OffsetAtTime(base.Add(150*time.Second))))
+	lags, err = admin.GroupLag(ctx, "reporting")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), lags[0].Committed)
+	assert.Equal(t, int64(2), lags[0].Lag)
+
+	assert.Nil(t, admin.ResetGroupOffsets(ctx, "reporting", "lagging", OffsetLatest()))
+	lags, err = admin.GroupLag(ctx, "reporting")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), lags[0].Lag)
+
+	// a group with an active member cannot be reset
+	b := NewBroker(broker.WithAddress(cluster.ListenAddrs()...))
+	assert.Nil(t, b.Init())
+	r := &receiver{}
+	sub, err := b.(ControlSubscriber).SubscribeWithControl("lagging", r.handle, nil, broker.WithSubscribeQueueName("reporting"))
+	assert.Nil(t, err)
+	defer func() { _ = sub.Unsubscribe(true) }()
+
+	assert.Eventually(t, func() bool { return len(sub.Assigned()) > 0 }, 10*time.Second, 20*time.Millisecond)
+	assert.ErrorIs(t, admin.ResetGroupOffsets(ctx, "reporting", "lagging", OffsetEarliest()), ErrGroupNotEmpty)
+}
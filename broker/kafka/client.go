@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	kafkaGo "github.com/segmentio/kafka-go"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	franzPlain "github.com/twmb/franz-go/pkg/sasl/plain"
+	franzScram "github.com/twmb/franz-go/pkg/sasl/scram"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// The kafka-go reader and writers cover plain publishing and subscribing. Transactions, the
+// subscriber control API and the admin API need features kafka-go lacks, they use franz-go
+// clients configured from the same broker options.
+
+type saslCredentials struct {
+	Scram    ScramAlgorithm
+	Username string
+	Password string
+}
+
+func (c saslCredentials) mechanism() sasl.Mechanism {
+	switch c.Scram {
+	case ScramAlgorithmSHA256:
+		return franzScram.Auth{User: c.Username, Pass: c.Password}.AsSha256Mechanism()
+	case ScramAlgorithmSHA512:
+		return franzScram.Auth{User: c.Username, Pass: c.Password}.AsSha512Mechanism()
+	}
+	return franzPlain.Auth{User: c.Username, Pass: c.Password}.AsMechanism()
+}
+
+// clientOptions returns the connection options of a franz-go client: brokers, SASL and TLS
+func (b *kafkaBroker) clientOptions() []kgo.Opt {
+	return newClientOptions(b.options)
+}
+
+func newClientOptions(options broker.Options) []kgo.Opt {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(options.Addrs...),
+	}
+
+	if value, ok := options.Context.Value(saslCredentialsKey{}).(saslCredentials); ok {
+		opts = append(opts, kgo.SASL(value.mechanism()))
+	}
+	if options.Secure && options.TLSConfig != nil {
+		opts = append(opts, kgo.DialTLSConfig(options.TLSConfig))
+	}
+
+	return opts
+}
+
+// groupConsumerOptions returns the options of a franz-go client consuming topic in the consumer group of the subscription
+func groupConsumerOptions(topic string, options broker.SubscribeOptions) []kgo.Opt {
+	opts := []kgo.Opt{
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumerGroup(options.Queue),
+	}
+
+	if value, ok := options.Context.Value(sessionTimeoutKey{}).(time.Duration); ok {
+		opts = append(opts, kgo.SessionTimeout(value))
+	}
+	if value, ok := options.Context.Value(rebalanceTimeoutKey{}).(time.Duration); ok {
+		opts = append(opts, kgo.RebalanceTimeout(value))
+	}
+	if value, ok := options.Context.Value(startOffsetKey{}).(int64); ok && value == kafkaGo.FirstOffset {
+		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	} else {
+		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()))
+	}
+
+	return opts
+}
+
+// newRecordPublication starts the consumer span of r and decodes it into an event.
+// The span is returned even when decoding fails, the caller finishes it.
+func (b *kafkaBroker) newRecordPublication(r *kgo.Record, binder broker.Binder) (context.Context, trace.Span, *publication, error) {
+	km := kafkaGo.Message{
+		Topic:     r.Topic,
+		Partition: int(r.Partition),
+		Offset:    r.Offset,
+		Key:       r.Key,
+		Value:     r.Value,
+		Time:      r.Timestamp,
+	}
+	for _, h := range r.Headers {
+		km.Headers = append(km.Headers, kafkaGo.Header{Key: h.Key, Value: h.Value})
+	}
+
+	ctx, span := b.startConsumerSpan(context.Background(), &km)
+
+	bm := &broker.Message{
+		Headers:   kafkaHeaderToMap(km.Headers),
+		Key:       string(r.Key),
+		Partition: km.Partition,
+		Offset:    km.Offset,
+	}
+
+	if binder != nil {
+		bm.Body = binder()
+		if err := broker.Unmarshal(b.options.Codec, r.Value, &bm.Body); err != nil {
+			LogErrorf("unmarshal message failed: %v", err)
+			return ctx, span, nil, err
+		}
+	} else {
+		bm.Body = r.Value
+	}
+
+	return ctx, span, newPublication(ctx, nil, km, bm), nil
+}
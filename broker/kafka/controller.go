@@ -0,0 +1,408 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// ErrPartitionNotAssigned is returned when seeking a partition that is not assigned to the subscription
+var ErrPartitionNotAssigned = errors.New("kafka: partition not assigned to the subscription")
+
+var _ ControlSubscriber = (*kafkaBroker)(nil)
+
+// PartitionsHandler is called with the partitions of topic assigned to or revoked from a subscription.
+type PartitionsHandler func(ctx context.Context, topic string, partitions []int)
+
+// PartitionController is a subscription whose position can be controlled while it runs.
+type PartitionController interface {
+	broker.Subscriber
+
+	// SeekToOffset moves partition to offset: the next message delivered from it is the one at offset.
+	// It takes effect once the messages already fetched are handled.
+	SeekToOffset(partition int, offset int64) error
+
+	// SeekToTime moves every assigned partition to its first message published at or after t,
+	// or to its end when there is none. It takes effect once the messages already fetched are handled.
+	SeekToTime(ctx context.Context, t time.Time) error
+
+	// Pause stops fetching partitions until they are resumed.
+	Pause(partitions ...int)
+
+	// Resume fetches paused partitions again.
+	Resume(partitions ...int)
+
+	// Assigned returns the partitions assigned to the subscription.
+	Assigned() []int
+}
+
+// ControlSubscriber is implemented by the kafka broker.
+type ControlSubscriber interface {
+	// SubscribeWithControl subscribes to topic like Subscribe, and returns a subscription that can
+	// seek, pause and resume its partitions. WithOnPartitionsAssigned and WithOnPartitionsRevoked
+	// report the partitions it is assigned.
+	//
+	// The subscription is consumed with franz-go rather than the kafka-go reader of Subscribe, which
+	// cannot seek within a consumer group: a subscription made with Subscribe or SubscribeBatch has
+	// none of these controls. Of the consumer options, it honours the queue name, WithStartOffset,
+	// WithSessionTimeout and WithRebalanceTimeout; the kafka-go reader options (WithReaderConfig,
+	// WithMinBytes, WithMaxWait, WithCommitInterval...) do not apply to it.
+	SubscribeWithControl(topic string, handler broker.Handler, binder broker.Binder, opts ...broker.SubscribeOption) (PartitionController, error)
+}
+
+func (b *kafkaBroker) SubscribeWithControl(
+	topic string,
+	handler broker.Handler,
+	binder broker.Binder,
+	opts ...broker.SubscribeOption,
+) (PartitionController, error) {
+	options := newSubscribeOptions(opts...)
+
+	if len(b.options.SubscriberMiddlewares) > 0 {
+		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
+	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
+
+	sub := newControlledSubscriber(b, topic, options, handler, binder)
+
+	clientOpts := append(b.clientOptions(), groupConsumerOptions(topic, options)...)
+	clientOpts = append(clientOpts,
+		kgo.DisableAutoCommit(),
+		kgo.BlockRebalanceOnPoll(),
+		kgo.OnPartitionsAssigned(sub.onAssigned),
+		kgo.OnPartitionsRevoked(sub.onRevoked),
+		kgo.OnPartitionsLost(sub.onRevoked),
+	)
+
+	client, err := kgo.NewClient(clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	sub.client = client
+	sub.admin = kadm.NewClient(client)
+
+	go sub.run()
+
+	b.subscribers.Add(topic, sub)
+
+	return sub, nil
+}
+
+// controlledSubscriber consumes a topic with a franz-go group consumer, which can seek and pause
+// partitions and reports partition assignments
+type controlledSubscriber struct {
+	b *kafkaBroker
+
+	topic   string
+	options broker.SubscribeOptions
+	handler broker.Handler
+	binder  broker.Binder
+
+	client *kgo.Client
+	admin  *kadm.Client
+	pool   *broker.WorkerPool
+	lag    fetchLag
+
+	onAssignedHandler PartitionsHandler
+	onRevokedHandler  PartitionsHandler
+
+	mtx      sync.Mutex
+	assigned map[int32]struct{}
+	seeks    map[int32]kgo.EpochOffset
+	// wake interrupts the running poll, so that requested seeks are applied without waiting for messages
+	wake context.CancelFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newControlledSubscriber(
+	b *kafkaBroker,
+	topic string,
+	options broker.SubscribeOptions,
+	handler broker.Handler,
+	binder broker.Binder,
+) *controlledSubscriber {
+	ctx, cancel := context.WithCancel(options.Context)
+
+	sub := &controlledSubscriber{
+		b:        b,
+		topic:    topic,
+		options:  options,
+		handler:  handler,
+		binder:   binder,
+		pool:     broker.NewSubscribeWorkerPool(options),
+		assigned: make(map[int32]struct{}),
+		seeks:    make(map[int32]kgo.EpochOffset),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	if value, ok := options.Context.Value(onPartitionsAssignedKey{}).(PartitionsHandler); ok {
+		sub.onAssignedHandler = value
+	}
+	if value, ok := options.Context.Value(onPartitionsRevokedKey{}).(PartitionsHandler); ok {
+		sub.onRevokedHandler = value
+	}
+
+	return sub
+}
+
+func (s *controlledSubscriber) Options() broker.SubscribeOptions {
+	return s.options
+}
+
+func (s *controlledSubscriber) Topic() string {
+	return s.topic
+}
+
+func (s *controlledSubscriber) Unsubscribe(removeFromManager bool) error {
+	s.once.Do(func() {
+		s.cancel()
+		<-s.done
+		s.pool.Drain()
+		// leaving the group revokes the assigned partitions
+		s.client.Close()
+	})
+
+	if s.b != nil && s.b.subscribers != nil && removeFromManager {
+		_ = s.b.subscribers.RemoveOnly(s.topic)
+	}
+
+	return nil
+}
+
+func (s *controlledSubscriber) SeekToOffset(partition int, offset int64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.assigned[int32(partition)]; !ok {
+		return ErrPartitionNotAssigned
+	}
+	s.seeks[int32(partition)] = kgo.EpochOffset{Epoch: -1, Offset: offset}
+
+	if s.wake != nil {
+		s.wake()
+	}
+	return nil
+}
+
+func (s *controlledSubscriber) SeekToTime(ctx context.Context, t time.Time) error {
+	offsets, err := s.admin.ListOffsetsAfterMilli(ctx, t.UnixMilli(), s.topic)
+	if err != nil {
+		return err
+	}
+	if err = offsets.Error(); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	offsets.Each(func(o kadm.ListedOffset) {
+		if _, ok := s.assigned[o.Partition]; !ok || o.Offset < 0 {
+			return
+		}
+		s.seeks[o.Partition] = kgo.EpochOffset{Epoch: -1, Offset: o.Offset}
+	})
+
+	if s.wake != nil {
+		s.wake()
+	}
+	return nil
+}
+
+func (s *controlledSubscriber) Pause(partitions ...int) {
+	s.client.PauseFetchPartitions(map[string][]int32{s.topic: toInt32s(partitions)})
+}
+
+func (s *controlledSubscriber) Resume(partitions ...int) {
+	s.client.ResumeFetchPartitions(map[string][]int32{s.topic: toInt32s(partitions)})
+}
+
+func (s *controlledSubscriber) Assigned() []int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	partitions := make([]int, 0, len(s.assigned))
+	for p := range s.assigned {
+		partitions = append(partitions, int(p))
+	}
+	sort.Ints(partitions)
+
+	return partitions
+}
+
+func (s *controlledSubscriber) onAssigned(ctx context.Context, _ *kgo.Client, assigned map[string][]int32) {
+	partitions := assigned[s.topic]
+	if len(partitions) == 0 {
+		return
+	}
+
+	s.mtx.Lock()
+	for _, p := range partitions {
+		s.assigned[p] = struct{}{}
+	}
+	s.mtx.Unlock()
+
+	if s.onAssignedHandler != nil {
+		s.onAssignedHandler(ctx, s.topic, sortedInts(partitions))
+	}
+}
+
+func (s *controlledSubscriber) onRevoked(ctx context.Context, _ *kgo.Client, revoked map[string][]int32) {
+	partitions := revoked[s.topic]
+	if len(partitions) == 0 {
+		return
+	}
+
+	if s.onRevokedHandler != nil {
+		s.onRevokedHandler(ctx, s.topic, sortedInts(partitions))
+	}
+
+	s.mtx.Lock()
+	for _, p := range partitions {
+		delete(s.assigned, p)
+		delete(s.seeks, p)
+	}
+	s.mtx.Unlock()
+
+	s.lag.revoked(ctx, nil, revoked)
+}
+
+// pollContext returns the context of the next poll, already canceled when seeks are pending
+func (s *controlledSubscriber) pollContext() (context.Context, context.CancelFunc) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.wake = cancel
+	if len(s.seeks) > 0 {
+		cancel()
+	}
+
+	return ctx, cancel
+}
+
+func (s *controlledSubscriber) run() {
+	defer close(s.done)
+
+	for {
+		ctx, cancel := s.pollContext()
+		fetches := s.client.PollFetches(ctx)
+		cancel()
+
+		if s.ctx.Err() != nil || fetches.IsClientClosed() {
+			s.client.AllowRebalance()
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			if !errors.Is(err, context.Canceled) {
+				LogErrorf("fetch topic %s partition %d failed: %v", topic, partition, err)
+			}
+		})
+
+		s.handleRecords(fetches.Records())
+		s.lag.update(fetches)
+		s.applySeeks()
+
+		// rebalances wait until the fetched messages are handled and the seeks applied
+		s.client.AllowRebalance()
+	}
+}
+
+// applySeeks moves the partitions with a pending seek
+func (s *controlledSubscriber) applySeeks() {
+	s.mtx.Lock()
+	if len(s.seeks) == 0 {
+		s.mtx.Unlock()
+		return
+	}
+	seeks := s.seeks
+	s.seeks = make(map[int32]kgo.EpochOffset)
+	s.mtx.Unlock()
+
+	s.client.SetOffsets(map[string]map[int32]kgo.EpochOffset{s.topic: seeks})
+}
+
+// handleRecords handles the fetched records and, with auto-ack, commits the handled ones
+func (s *controlledSubscriber) handleRecords(records []*kgo.Record) {
+	if len(records) == 0 {
+		return
+	}
+
+	var (
+		mtx     sync.Mutex
+		handled []*kgo.Record
+	)
+	for _, r := range records {
+		s.pool.Submit(func() {
+			if s.handleRecord(r) {
+				mtx.Lock()
+				handled = append(handled, r)
+				mtx.Unlock()
+			}
+		})
+	}
+	s.pool.Wait()
+
+	if s.options.AutoAck && len(handled) > 0 {
+		if err := s.client.CommitRecords(s.ctx, handled...); err != nil {
+			LogErrorf("unable to commit %d messages: %v", len(handled), err)
+		}
+	}
+}
+
+func (s *controlledSubscriber) handleRecord(r *kgo.Record) bool {
+	ctx, span, pub, err := s.b.newRecordPublication(r, s.binder)
+	if err != nil {
+		s.b.finishConsumerSpan(ctx, span, err)
+		return false
+	}
+
+	pub.ack = func() error {
+		return s.client.CommitRecords(s.ctx, r)
+	}
+
+	if err = s.handler(ctx, pub); err != nil {
+		LogErrorf("handle message failed: %v", err)
+		s.b.finishConsumerSpan(ctx, span, err)
+		return false
+	}
+
+	s.b.finishConsumerSpan(ctx, span, nil)
+	return true
+}
+
+func toInt32s(partitions []int) []int32 {
+	out := make([]int32, len(partitions))
+	for i, p := range partitions {
+		out[i] = int32(p)
+	}
+	return out
+}
+
+func toInts(partitions []int32) []int {
+	out := make([]int, len(partitions))
+	for i, p := range partitions {
+		out[i] = int(p)
+	}
+	return out
+}
+
+func sortedInts(partitions []int32) []int {
+	out := toInts(partitions)
+	sort.Ints(out)
+	return out
+}
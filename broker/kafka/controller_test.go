@@ -0,0 +1,149 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// receiver collects the bodies of the delivered messages
+type receiver struct {
+	mtx    sync.Mutex
+	bodies []string
+}
+
+func (r *receiver) handle(_ context.Context, evt broker.Event) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.bodies = append(r.bodies, string(evt.Message().Body.([]byte)))
+	return nil
+}
+
+func (r *receiver) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		return len(r.bodies) >= n
+	}, 10*time.Second, 20*time.Millisecond)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	bodies := r.bodies
+	r.bodies = nil
+	return bodies
+}
+
+func produceRecords(t *testing.T, addrs []string, records ...*kgo.Record) {
+	t.Helper()
+
+	cl, err := kgo.NewClient(kgo.SeedBrokers(addrs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	assert.Nil(t, cl.ProduceSync(context.Background(), records...).FirstErr())
+}
+
+func TestSubscribeWithControl_SeekAndRebalanceHooks(t *testing.T) {
+	cluster := newFakeCluster(t, "ctl")
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	var records []*kgo.Record
+	for i := 0; i < 5; i++ {
+		records = append(records, &kgo.Record{
+			Topic:     "ctl",
+			Value:     []byte(fmt.Sprintf("m%d", i)),
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	produceRecords(t, cluster.ListenAddrs(), records...)
+
+	b := NewBroker(broker.WithAddress(cluster.ListenAddrs()...))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	var (
+		mtx      sync.Mutex
+		assigned []int
+		revoked  []int
+	)
+	r := &receiver{}
+	sub, err := b.(ControlSubscriber).SubscribeWithControl("ctl", r.handle, nil,
+		broker.WithSubscribeQueueName("ctl-group"),
+		WithStartOffset(-2),
+		WithOnPartitionsAssigned(func(_ context.Context, topic string, partitions []int) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			assigned = append(assigned, partitions...)
+		}),
+		WithOnPartitionsRevoked(func(_ context.Context, topic string, partitions []int) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			revoked = append(revoked, partitions...)
+		}),
+	)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"m0", "m1", "m2", "m3", "m4"}, r.waitFor(t, 5))
+	assert.Equal(t, []int{0}, sub.Assigned())
+	mtx.Lock()
+	assert.Equal(t, []int{0}, assigned)
+	mtx.Unlock()
+
+	assert.ErrorIs(t, sub.SeekToOffset(7, 0), ErrPartitionNotAssigned)
+
+	assert.Nil(t, sub.SeekToOffset(0, 3))
+	assert.Equal(t, []string{"m3", "m4"}, r.waitFor(t, 2))
+
+	assert.Nil(t, sub.SeekToTime(context.Background(), base.Add(90*time.Second)))
+	assert.Equal(t, []string{"m2", "m3", "m4"}, r.waitFor(t, 3))
+
+	assert.Nil(t, sub.Unsubscribe(true))
+	mtx.Lock()
+	assert.Equal(t, []int{0}, revoked)
+	mtx.Unlock()
+}
+
+func TestSubscribeWithControl_PauseResume(t *testing.T) {
+	cluster := newFakeCluster(t, "paused")
+
+	b := NewBroker(broker.WithAddress(cluster.ListenAddrs()...))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	r := &receiver{}
+	sub, err := b.(ControlSubscriber).SubscribeWithControl("paused", r.handle, nil,
+		broker.WithSubscribeQueueName("paused-group"),
+		WithStartOffset(-2),
+	)
+	assert.Nil(t, err)
+	defer func() { _ = sub.Unsubscribe(true) }()
+
+	produceRecords(t, cluster.ListenAddrs(), &kgo.Record{Topic: "paused", Value: []byte("before")})
+	assert.Equal(t, []string{"before"}, r.waitFor(t, 1))
+
+	sub.Pause(0)
+	produceRecords(t, cluster.ListenAddrs(), &kgo.Record{Topic: "paused", Value: []byte("while paused")})
+
+	time.Sleep(500 * time.Millisecond)
+	r.mtx.Lock()
+	assert.Empty(t, r.bodies)
+	r.mtx.Unlock()
+
+	sub.Resume(0)
+	assert.Equal(t, []string{"while paused"}, r.waitFor(t, 1))
+}
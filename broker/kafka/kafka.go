@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 
 	kafkaGo "github.com/segmentio/kafka-go"
@@ -26,6 +28,14 @@ const (
 	defaultDialTimeout = 10 * time.Second
 
 	defaultRetriesCount = 1
+
+	defaultSubscribeBatchSize     = 100
+	defaultSubscribeBatchInterval = time.Second
+)
+
+var (
+	_ broker.BatchPublisher  = (*kafkaBroker)(nil)
+	_ broker.BatchSubscriber = (*kafkaBroker)(nil)
 )
 
 type kafkaBroker struct {
@@ -43,8 +53,16 @@ type kafkaBroker struct {
 
 	subscribers *broker.SubscriberSyncMap
 
+	requester *broker.Requester
+
 	producerTracer *tracing.Tracer
 	consumerTracer *tracing.Tracer
+
+	readerMetrics metric.Registration
+
+	// txnClient is the transactional producer, created by the first BeginTransaction
+	txnClient *kgo.Client
+	txnSem    chan struct{}
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
@@ -68,6 +86,7 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		retriesCount: defaultRetriesCount,
 		subscribers:  broker.NewSubscriberSyncMap(),
 	}
+	b.requester = b.newRequester()
 
 	return b
 }
@@ -90,6 +109,8 @@ func (b *kafkaBroker) Options() broker.Options {
 func (b *kafkaBroker) Init(opts ...broker.Option) error {
 	b.options.Apply(opts...)
 
+	b.requester = b.newRequester()
+
 	if value, ok := b.options.Context.Value(writerConfigKey{}).(WriterConfig); ok {
 		b.writerConfig = value
 	}
@@ -269,10 +290,26 @@ func (b *kafkaBroker) Connect() error {
 	}
 
 	b.Lock()
+	defer b.Unlock()
+
 	b.options.Addrs = kAddrs
 	b.readerConfig.Brokers = kAddrs
+
+	// the topics are provisioned first: a failed Connect leaves nothing registered, Disconnect
+	// only releases a connected broker
+	if err := b.ensureTopics(b.options.Context); err != nil {
+		return err
+	}
+
+	if provider, ok := b.options.Context.Value(meterProviderKey{}).(metric.MeterProvider); ok && provider != nil && b.readerMetrics == nil {
+		registration, err := b.registerReaderMetrics(provider)
+		if err != nil {
+			return err
+		}
+		b.readerMetrics = registration
+	}
+
 	b.connected = true
-	b.Unlock()
 
 	return nil
 }
@@ -286,6 +323,8 @@ func (b *kafkaBroker) Disconnect() error {
 	}
 	b.RUnlock()
 
+	_ = b.requester.Close()
+
 	b.Lock()
 	defer b.Unlock()
 
@@ -297,6 +336,13 @@ func (b *kafkaBroker) Disconnect() error {
 		b.subscribers.Clear()
 	}
 
+	b.closeTransactionalProducer()
+
+	if b.readerMetrics != nil {
+		_ = b.readerMetrics.Unregister()
+		b.readerMetrics = nil
+	}
+
 	b.connected = false
 	return nil
 }
@@ -337,9 +383,9 @@ func (b *kafkaBroker) initPublishOption(writer *kafkaGo.Writer, options broker.P
 	}
 }
 
-// Request sends a request and waits for a response
-func (b *kafkaBroker) Request(_ context.Context, _ string, _ *broker.Message, _ ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+// Request sends a request and waits for a response on the reply topic, see WithReplyTopic
+func (b *kafkaBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 // Publish publishes a message to a topic
@@ -359,6 +405,7 @@ func (b *kafkaBroker) internalPublish(ctx context.Context, topic string, msg *br
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -559,12 +606,175 @@ func (b *kafkaBroker) publishOneWriter(ctx context.Context, topic string, msg *b
 	return err
 }
 
+// PublishBatch publishes msgs to topic with a single WriteMessages call, which the writer
+// groups into produce requests per partition.
+func (b *kafkaBroker) PublishBatch(ctx context.Context, topic string, msgs []*broker.Message, opts ...broker.PublishOption) ([]broker.PublishResult, error) {
+	options := broker.PublishOptions{
+		Context: ctx,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	writer, err := b.batchWriter(topic, options)
+	if err != nil {
+		return nil, err
+	}
+
+	results := broker.NewPublishResults(msgs)
+
+	var (
+		kMsgs    []kafkaGo.Message
+		indexes  []int
+		spans    []trace.Span
+		spanCtxs []context.Context
+	)
+	for i, msg := range msgs {
+		if msg == nil {
+			results[i].Err = errors.New("message is nil")
+			continue
+		}
+
+		buf, err := broker.Marshal(b.options.Codec, msg.Body)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		kMsg := kafkaGo.Message{
+			Topic:     topic,
+			Value:     buf,
+			Key:       []byte(msg.Key),
+			Partition: msg.Partition,
+		}
+		for k, v := range msg.Headers {
+			kMsg.Headers = append(kMsg.Headers, kafkaGo.Header{Key: k, Value: []byte(v)})
+		}
+
+		spanCtx, span := b.startProducerSpan(options.Context, &kMsg)
+
+		kMsgs = append(kMsgs, kMsg)
+		indexes = append(indexes, i)
+		spans = append(spans, span)
+		spanCtxs = append(spanCtxs, spanCtx)
+	}
+
+	if len(kMsgs) > 0 {
+		err = writer.WriteMessages(options.Context, kMsgs...)
+		if err != nil {
+			LogErrorf("WriteMessages error: %s", err.Error())
+		}
+
+		var writeErrs kafkaGo.WriteErrors
+		perMessage := errors.As(err, &writeErrs) && len(writeErrs) == len(kMsgs)
+
+		for n, i := range indexes {
+			msgErr := err
+			if perMessage {
+				msgErr = writeErrs[n]
+			}
+			results[i].Err = msgErr
+			b.finishProducerSpan(spanCtxs[n], spans[n], int32(kMsgs[n].Partition), kMsgs[n].Offset, msgErr)
+		}
+	}
+
+	return results, broker.JoinPublishResults(results)
+}
+
+// batchWriter returns the writer used by PublishBatch for topic, creating it if needed
+func (b *kafkaBroker) batchWriter(topic string, options broker.PublishOptions) (*kafkaGo.Writer, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.writer == nil {
+		return nil, errors.New("kafka writer not initialized")
+	}
+
+	if b.writer.EnableOneTopicOneWriter {
+		writer, ok := b.writer.Writers[topic]
+		if !ok {
+			writer = b.writer.CreateProducer(b.writerConfig, b.saslMechanism, b.options.TLSConfig)
+			b.initPublishOption(writer, options)
+			b.writer.Writers[topic] = writer
+		}
+		return writer, nil
+	}
+
+	if b.writer.Writer == nil {
+		b.writer.Writer = b.writer.CreateProducer(b.writerConfig, b.saslMechanism, b.options.TLSConfig)
+		b.initPublishOption(b.writer.Writer, options)
+	}
+	return b.writer.Writer, nil
+}
+
 func (b *kafkaBroker) Subscribe(
 	topic string,
 	handler broker.Handler,
 	binder broker.Binder,
 	opts ...broker.SubscribeOption,
 ) (broker.Subscriber, error) {
+	options := newSubscribeOptions(opts...)
+
+	if len(b.options.SubscriberMiddlewares) > 0 {
+		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
+	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
+
+	sub := newSubscriber(b, topic, options, b.newReaderConfig(topic, options), handler, binder)
+
+	if value, ok := options.Context.Value(subscribeBatchSizeKey{}).(int); ok {
+		sub.batchSize = value
+	}
+	if value, ok := options.Context.Value(subscribeBatchIntervalKey{}).(time.Duration); ok {
+		sub.batchInterval = value
+	}
+
+	go func() {
+		sub.run()
+	}()
+
+	b.subscribers.Add(topic, sub)
+
+	return sub, nil
+}
+
+// SubscribeBatch subscribes to topic, delivering the messages to handler in batches of
+// WithSubscribeBatchSize messages (100 by default), or fewer once WithSubscribeBatchInterval
+// (1s by default) elapses. With auto-ack, the offsets of the whole batch are committed in
+// a single call once the handler returns nil. A batch which still fails after MaxRetries is
+// handled again with backoff, rather than skipped, until it succeeds; with a dead-letter policy,
+// once DeadLetter.MaxAttempts attempts have failed, its messages are republished to the
+// dead-letter topic and its offsets committed.
+func (b *kafkaBroker) SubscribeBatch(
+	topic string,
+	handler broker.BatchHandler,
+	binder broker.Binder,
+	opts ...broker.SubscribeOption,
+) (broker.Subscriber, error) {
+	options := newSubscribeOptions(opts...)
+
+	sub := newSubscriber(b, topic, options, b.newReaderConfig(topic, options), nil, binder)
+	sub.batchHandler = broker.WrapSubscribeBatchHandler(handler, options)
+	sub.batchSize = defaultSubscribeBatchSize
+	sub.batchInterval = defaultSubscribeBatchInterval
+
+	if value, ok := options.Context.Value(subscribeBatchSizeKey{}).(int); ok && value > 0 {
+		sub.batchSize = value
+	}
+	if value, ok := options.Context.Value(subscribeBatchIntervalKey{}).(time.Duration); ok && value > 0 {
+		sub.batchInterval = value
+	}
+
+	go func() {
+		sub.run()
+	}()
+
+	b.subscribers.Add(topic, sub)
+
+	return sub, nil
+}
+
+func newSubscribeOptions(opts ...broker.SubscribeOption) broker.SubscribeOptions {
 	options := broker.SubscribeOptions{
 		Context: context.Background(),
 		AutoAck: true,
@@ -573,7 +783,11 @@ func (b *kafkaBroker) Subscribe(
 	for _, o := range opts {
 		o(&options)
 	}
+	return options
+}
 
+// newReaderConfig creates the reader configuration of a subscription
+func (b *kafkaBroker) newReaderConfig(topic string, options broker.SubscribeOptions) kafkaGo.ReaderConfig {
 	readerConfig := b.readerConfig
 	readerConfig.Topic = topic
 	readerConfig.GroupID = options.Queue
@@ -654,24 +868,5 @@ func (b *kafkaBroker) Subscribe(
 		readerConfig.ReadBackoffMax = value
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
-		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
-	}
-
-	sub := newSubscriber(b, topic, options, readerConfig, handler, binder)
-
-	if value, ok := options.Context.Value(subscribeBatchSizeKey{}).(int); ok {
-		sub.batchSize = value
-	}
-	if value, ok := options.Context.Value(subscribeBatchIntervalKey{}).(time.Duration); ok {
-		sub.batchInterval = value
-	}
-
-	go func() {
-		sub.run()
-	}()
-
-	b.subscribers.Add(topic, sub)
-
-	return sub, nil
+	return readerConfig
 }
@@ -0,0 +1,139 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const metricsInstrumentationName = "github.com/tx7do/kratos-transport/broker/kafka"
+
+// registerReaderMetrics registers the gauges observing the readers of the subscribers: the
+// kafka-go readers of Subscribe and SubscribeBatch, and the franz-go clients of
+// SubscribeWithControl and SubscribeTransactional.
+func (b *kafkaBroker) registerReaderMetrics(provider metric.MeterProvider) (metric.Registration, error) {
+	meter := provider.Meter(metricsInstrumentationName)
+
+	lag, err := meter.Int64ObservableGauge("messaging.kafka.consumer.lag",
+		metric.WithDescription("Number of messages between the last consumed offset and the end of the partition."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		return nil, err
+	}
+
+	queueLength, err := meter.Int64ObservableGauge("messaging.kafka.consumer.queue.length",
+		metric.WithDescription("Number of messages fetched and waiting in the reader queue."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, s := range b.subscribers.GetAll() {
+			var (
+				client *kgo.Client
+				lags   *fetchLag
+			)
+			switch sub := s.(type) {
+			case *controlledSubscriber:
+				client, lags = sub.client, &sub.lag
+			case *transactionalSubscriber:
+				client, lags = sub.session.Client(), sub.lag
+			}
+			if client != nil {
+				lags.observe(o, lag, s.Topic(), s.Options().Queue)
+				o.ObserveInt64(queueLength, client.BufferedFetchRecords(), metric.WithAttributes(
+					consumerAttributes(s.Topic(), s.Options().Queue)...))
+				continue
+			}
+
+			sub, ok := s.(*subscriber)
+			if !ok {
+				continue
+			}
+
+			sub.RLock()
+			reader := sub.reader
+			group := sub.options.Queue
+			sub.RUnlock()
+			if reader == nil {
+				continue
+			}
+
+			stats := reader.Stats()
+
+			attrs := consumerAttributes(stats.Topic, group)
+			if group == "" {
+				attrs = append(attrs, attribute.String("messaging.destination.partition.id", stats.Partition))
+			}
+			set := metric.WithAttributes(attrs...)
+
+			o.ObserveInt64(lag, stats.Lag, set)
+			o.ObserveInt64(queueLength, stats.QueueLength, set)
+		}
+		return nil
+	}, lag, queueLength)
+}
+
+func consumerAttributes(topic, group string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", topic),
+	}
+	if group != "" {
+		attrs = append(attrs, attribute.String("messaging.consumer.group.name", group))
+	}
+	return attrs
+}
+
+// fetchLag tracks the lag of the partitions consumed by a franz-go client, from the high
+// watermarks returned with its fetches rather than with requests to the brokers
+type fetchLag struct {
+	mtx  sync.Mutex
+	lags map[int32]int64
+}
+
+// update records the lag of the partitions fetched, once their records are handled
+func (l *fetchLag) update(fetches kgo.Fetches) {
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		if len(p.Records) == 0 {
+			return
+		}
+		lag := p.HighWatermark - p.Records[len(p.Records)-1].Offset - 1
+
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+		if l.lags == nil {
+			l.lags = make(map[int32]int64)
+		}
+		l.lags[p.Partition] = max(lag, 0)
+	})
+}
+
+// revoked forgets the lag of the partitions which are no longer consumed, it is installed with
+// kgo.OnPartitionsRevoked and kgo.OnPartitionsLost
+func (l *fetchLag) revoked(_ context.Context, _ *kgo.Client, revoked map[string][]int32) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for _, partitions := range revoked {
+		for _, p := range partitions {
+			delete(l.lags, p)
+		}
+	}
+}
+
+func (l *fetchLag) observe(o metric.Observer, gauge metric.Int64ObservableGauge, topic, group string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for p, lag := range l.lags {
+		attrs := append(consumerAttributes(topic, group),
+			attribute.String("messaging.destination.partition.id", strconv.Itoa(int(p))))
+		o.ObserveInt64(gauge, lag, metric.WithAttributes(attrs...))
+	}
+}
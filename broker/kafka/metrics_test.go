@@ -0,0 +1,212 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	kafkaGo "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// collectGauges returns the data points of the int64 gauge name
+func collectGauges(t *testing.T, reader *sdkmetric.ManualReader, name string) []metricdata.DataPoint[int64] {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm))
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			if gauge, ok := md.Data.(metricdata.Gauge[int64]); ok && md.Name == name {
+				return gauge.DataPoints
+			}
+		}
+	}
+	return nil
+}
+
+// The middlewares of broker.Metrics are tested here rather than in the broker module, which
+// depends on the OpenTelemetry metric API only and not on the SDK.
+
+func newTestMetrics(t *testing.T) (*broker.Metrics, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	m, err := broker.NewMetrics(
+		broker.WithMetricsMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		broker.WithMetricsSystem("kafka"),
+	)
+	assert.Nil(t, err)
+	return m, reader
+}
+
+// collectSums sums the points of the counters and histograms by name, keyed by "name" or "name|error.type"
+func collectSums(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &rm))
+
+	key := func(name string, attrs attribute.Set) string {
+		if v, ok := attrs.Value("error.type"); ok {
+			return name + "|" + v.AsString()
+		}
+		return name
+	}
+
+	sums := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			switch data := md.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					sums[key(md.Name, dp.Attributes)] += dp.Value
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					sums[key(md.Name, dp.Attributes)] += dp.Sum
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					sums[key(md.Name, dp.Attributes)] += int64(dp.Count)
+				}
+			}
+		}
+	}
+	return sums
+}
+
+// newTestEvent returns the event of a message received on topic, payload is its encoded body
+func newTestEvent(topic string, body any, payload []byte) broker.Event {
+	return newPublication(context.Background(), nil,
+		kafkaGo.Message{Topic: topic, Value: payload}, &broker.Message{Body: body})
+}
+
+func TestMetrics_PublishMiddleware(t *testing.T) {
+	m, reader := newTestMetrics(t)
+
+	failing := errors.New("unavailable")
+	h := m.PublishMiddleware()(func(_ context.Context, topic string, _ *broker.Message, _ ...broker.PublishOption) error {
+		if topic == "down" {
+			return failing
+		}
+		return nil
+	})
+
+	_ = h(context.Background(), "orders", &broker.Message{Body: []byte("12345")})
+	_ = h(context.Background(), "orders", &broker.Message{Body: broker.RawBody("123")})
+	_ = h(context.Background(), "down", &broker.Message{Body: struct{}{}})
+
+	sums := collectSums(t, reader)
+	assert.Equal(t, int64(2), sums["messaging.client.sent.messages"])
+	assert.Equal(t, int64(1), sums["messaging.client.sent.messages|*errors.errorString"])
+	assert.Equal(t, int64(8), sums["messaging.client.sent.message.size"])
+	assert.Equal(t, int64(0), sums["messaging.client.sent.active"])
+}
+
+func TestMetrics_SubscriberMiddleware(t *testing.T) {
+	m, reader := newTestMetrics(t)
+
+	var calls int
+	h := m.SubscriberMiddleware()(func(context.Context, broker.Event) error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+
+	// the middleware sits inside the retries, every attempt passes it
+	h = broker.RetryHandler(h, 2, 0)
+	assert.Nil(t, h(context.Background(), newTestEvent("orders", "hello", []byte("hello"))))
+
+	sums := collectSums(t, reader)
+	assert.Equal(t, int64(1), sums["messaging.client.consumed.messages"])
+	assert.Equal(t, int64(2), sums["messaging.process.retries"])
+	assert.Equal(t, int64(2), sums["messaging.process.errors|*errors.errorString"])
+	assert.Equal(t, int64(3), sums["messaging.process.duration"]+sums["messaging.process.duration|*errors.errorString"])
+	assert.Equal(t, int64(5), sums["messaging.client.consumed.message.size"])
+	assert.Equal(t, int64(0), sums["messaging.process.active"])
+}
+
+func TestMetrics_EncodedPayloadSize(t *testing.T) {
+	m, reader := newTestMetrics(t)
+
+	// the size of a typed body is reported by the broker once encoded
+	publish := m.PublishMiddleware()(func(ctx context.Context, _ string, _ *broker.Message, _ ...broker.PublishOption) error {
+		broker.RecordPayloadSize(ctx, 11)
+		return nil
+	})
+	_ = publish(context.Background(), "orders", &broker.Message{Body: map[string]any{"id": 2}})
+
+	// the consumer reads the payload as received, before it was decoded
+	consume := m.SubscriberMiddleware()(func(context.Context, broker.Event) error { return nil })
+	_ = consume(context.Background(), newTestEvent("orders", map[string]any{"id": 2.0}, []byte(`{"id": 2}`)))
+
+	sums := collectSums(t, reader)
+	assert.Equal(t, int64(11), sums["messaging.client.sent.message.size"])
+	assert.Equal(t, int64(9), sums["messaging.client.consumed.message.size"])
+
+	// without the metrics middleware reporting a size is a no-op
+	broker.RecordPayloadSize(context.Background(), 11)
+}
+
+func TestMeterProvider_ControlledSubscriberLag(t *testing.T) {
+	cluster := newFakeCluster(t, "lag")
+	produceRecords(t, cluster.ListenAddrs(),
+		&kgo.Record{Topic: "lag", Value: []byte("m0")},
+		&kgo.Record{Topic: "lag", Value: []byte("m1")},
+	)
+
+	reader := sdkmetric.NewManualReader()
+	b := NewBroker(
+		broker.WithAddress(cluster.ListenAddrs()...),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	r := &receiver{}
+	_, err := b.(ControlSubscriber).SubscribeWithControl("lag", r.handle, nil,
+		broker.WithSubscribeQueueName("lag-group"), WithStartOffset(-2))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"m0", "m1"}, r.waitFor(t, 2))
+
+	var points []metricdata.DataPoint[int64]
+	assert.Eventually(t, func() bool {
+		points = collectGauges(t, reader, "messaging.kafka.consumer.lag")
+		return len(points) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	if assert.Len(t, points, 1) {
+		assert.Equal(t, int64(0), points[0].Value)
+		group, _ := points[0].Attributes.Value(attribute.Key("messaging.consumer.group.name"))
+		assert.Equal(t, "lag-group", group.AsString())
+		partition, _ := points[0].Attributes.Value(attribute.Key("messaging.destination.partition.id"))
+		assert.Equal(t, "0", partition.AsString())
+	}
+
+	assert.Len(t, collectGauges(t, reader, "messaging.kafka.consumer.queue.length"), 1)
+}
+
+func TestMeterProvider_NotRegisteredWhenConnectFails(t *testing.T) {
+	cluster := newFakeCluster(t)
+
+	b := NewBroker(
+		broker.WithAddress(cluster.ListenAddrs()...),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewManualReader()))),
+		// a single broker cannot hold 3 replicas
+		WithTopics(TopicSpec{Name: "orders", ReplicationFactor: 3}),
+	)
+	assert.Nil(t, b.Init())
+	assert.NotNil(t, b.Connect())
+	assert.Nil(t, b.(*kafkaBroker).readerMetrics)
+}
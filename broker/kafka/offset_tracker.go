@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+
+	kafkaGo "github.com/segmentio/kafka-go"
+)
+
+// offsetTracker commits the offsets of messages handled concurrently. The reader commits
+// whatever offset it is given, so a message finishing before an earlier one of the same
+// partition must not commit past it: the acked messages are committed once every message
+// fetched before them in their partition has finished.
+type offsetTracker struct {
+	mtx    sync.Mutex
+	reader *kafkaGo.Reader
+
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	// inflight holds the fetched messages in offset order until they and every earlier one finished
+	inflight []*trackedOffset
+	// committed is the offset of the last committed message
+	committed int64
+}
+
+type trackedOffset struct {
+	km kafkaGo.Message
+
+	finished bool
+	acked    bool
+	released bool
+}
+
+func newOffsetTracker(reader *kafkaGo.Reader) *offsetTracker {
+	return &offsetTracker{
+		reader:     reader,
+		partitions: make(map[int]*partitionOffsets),
+	}
+}
+
+// track registers a fetched message, it must be called in the order the messages are fetched
+func (t *offsetTracker) track(km kafkaGo.Message) *trackedOffset {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	p, ok := t.partitions[km.Partition]
+	if !ok {
+		p = &partitionOffsets{committed: -1}
+		t.partitions[km.Partition] = p
+	}
+
+	// the reader went back to the committed offset after a rebalance, the messages
+	// still in flight are fetched again
+	if n := len(p.inflight); n > 0 && km.Offset <= p.inflight[n-1].km.Offset {
+		p.inflight = nil
+		p.committed = km.Offset - 1
+	}
+
+	o := &trackedOffset{km: km}
+	p.inflight = append(p.inflight, o)
+	return o
+}
+
+// ack marks the message to be committed, which happens once every earlier message finished
+func (t *offsetTracker) ack(ctx context.Context, o *trackedOffset) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	o.acked = true
+	if !o.released {
+		return nil
+	}
+	// acked after its handler returned and the messages before it finished
+	return t.commit(ctx, o.km)
+}
+
+// finish marks the handling of the message as done, whether or not it succeeded, and
+// commits the last acked message below which every fetched message finished
+func (t *offsetTracker) finish(ctx context.Context, o *trackedOffset) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	o.finished = true
+
+	p := t.partitions[o.km.Partition]
+	if p == nil {
+		return nil
+	}
+
+	var last *trackedOffset
+	for len(p.inflight) > 0 && p.inflight[0].finished {
+		head := p.inflight[0]
+		p.inflight = p.inflight[1:]
+
+		head.released = true
+		if head.acked {
+			last = head
+		}
+	}
+	if last == nil {
+		return nil
+	}
+
+	return t.commit(ctx, last.km)
+}
+
+// commit commits km unless a later offset of its partition is already committed, the
+// commits are serialized so they reach the group in offset order
+func (t *offsetTracker) commit(ctx context.Context, km kafkaGo.Message) error {
+	p := t.partitions[km.Partition]
+	if p == nil || km.Offset <= p.committed {
+		return nil
+	}
+
+	if err := t.reader.CommitMessages(ctx, km); err != nil {
+		return err
+	}
+	p.committed = km.Offset
+	return nil
+}
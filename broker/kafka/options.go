@@ -5,8 +5,10 @@ import (
 	"time"
 
 	kafkaGo "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
 	"github.com/segmentio/kafka-go/sasl/plain"
 	"github.com/segmentio/kafka-go/sasl/scram"
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/tx7do/kratos-transport/broker"
 )
@@ -50,6 +52,21 @@ type readTimeoutKey struct{}
 type writeTimeoutKey struct{}
 type allowPublishAutoTopicCreationKey struct{}
 type completionKey struct{}
+type meterProviderKey struct{}
+type saslCredentialsKey struct{}
+type transactionalIDKey struct{}
+type transactionTimeoutKey struct{}
+type topicSpecsKey struct{}
+type replyTopicKey struct{}
+
+// WithMeterProvider reports the lag and the queue length of the subscribers as OpenTelemetry
+// gauges, including those of SubscribeWithControl and SubscribeTransactional, whose lag is taken
+// from their fetches. For the readers of Subscribe and SubscribeBatch, collecting them calls
+// kafkaGo.Reader.Stats, which resets the counters of the stats (messages, bytes, errors...):
+// an application reading Reader.Stats itself then only sees what happened since the last collection.
+func WithMeterProvider(provider metric.MeterProvider) broker.Option {
+	return broker.OptionContextWithValue(meterProviderKey{}, provider)
+}
 
 // WithReaderConfig .
 func WithReaderConfig(cfg kafkaGo.ReaderConfig) broker.Option {
@@ -72,7 +89,7 @@ func WithPlainMechanism(username, password string) broker.Option {
 		Username: username,
 		Password: password,
 	}
-	return broker.OptionContextWithValue(mechanismKey{}, mechanism)
+	return withSASL(mechanism, saslCredentials{Username: username, Password: password})
 }
 
 // WithScramMechanism SCRAM认证信息
@@ -91,7 +108,44 @@ func WithScramMechanism(algoName ScramAlgorithm, username, password string) brok
 		return func(o *broker.Options) {}
 	}
 
-	return broker.OptionContextWithValue(mechanismKey{}, mechanism)
+	return withSASL(mechanism, saslCredentials{Scram: algoName, Username: username, Password: password})
+}
+
+// withSASL sets the mechanism of the kafka-go reader and writers, and keeps the credentials
+// for the franz-go clients of the transactional mode.
+func withSASL(mechanism sasl.Mechanism, credentials saslCredentials) broker.Option {
+	return func(o *broker.Options) {
+		broker.OptionContextWithValue(mechanismKey{}, mechanism)(o)
+		broker.OptionContextWithValue(saslCredentialsKey{}, credentials)(o)
+	}
+}
+
+// WithTransactionalID enables the transactional producer: BeginTransaction, RunInTransaction
+// and SubscribeTransactional use franz-go clients with the transactional.id id. A new producer
+// with the same id fences the previous one, whose pending transaction is aborted.
+func WithTransactionalID(id string) broker.Option {
+	return broker.OptionContextWithValue(transactionalIDKey{}, id)
+}
+
+// WithTransactionTimeout transaction.timeout.ms, the time after which the coordinator aborts
+// a transaction that was not ended.
+//
+// default：40s
+func WithTransactionTimeout(timeout time.Duration) broker.Option {
+	return broker.OptionContextWithValue(transactionTimeoutKey{}, timeout)
+}
+
+// WithTopics provisions topics when the broker connects: missing topics are created, existing
+// ones get the missing partitions and the configs of their spec, see Admin.EnsureTopic.
+func WithTopics(specs ...TopicSpec) broker.Option {
+	return broker.OptionContextWithValue(topicSpecsKey{}, specs)
+}
+
+// WithReplyTopic sets the topic Request consumes the replies from instead of a topic generated per
+// instance. It can be shared by the instances of a service: each instance reads all the replies and
+// keeps those of its own requests. It is created when it does not exist, its retention can stay short.
+func WithReplyTopic(topic string) broker.Option {
+	return broker.OptionContextWithValue(replyTopicKey{}, topic)
 }
 
 // WithMaxAttempts .
@@ -276,6 +330,11 @@ type subscribeRetriesCountKey struct{}
 type subscribeBatchSizeKey struct{}
 type subscribeBatchIntervalKey struct{}
 
+type subscribeTransactionalIDKey struct{}
+
+type onPartitionsAssignedKey struct{}
+type onPartitionsRevokedKey struct{}
+
 func WithSubscribeAutoCreateTopic(topic string, numPartitions, replicationFactor int) broker.SubscribeOption {
 	return broker.SubscribeContextWithValue(autoSubscribeCreateTopicKey{},
 		&autoSubscribeCreateTopicValue{
@@ -389,3 +448,23 @@ func WithSubscribeBatchSize(batchSize int) broker.SubscribeOption {
 func WithSubscribeBatchInterval(batchInterval time.Duration) broker.SubscribeOption {
 	return broker.SubscribeContextWithValue(subscribeBatchIntervalKey{}, batchInterval)
 }
+
+// WithSubscribeTransactionalID sets the transactional.id of a SubscribeTransactional subscription,
+// by default the id of WithTransactionalID followed by the topic. Give each instance of a
+// pipeline its own stable id, so that a restarted instance fences its previous incarnation.
+func WithSubscribeTransactionalID(id string) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(subscribeTransactionalIDKey{}, id)
+}
+
+// WithOnPartitionsAssigned is called by a SubscribeWithControl subscription when partitions are
+// assigned to it, before messages of these partitions are delivered.
+func WithOnPartitionsAssigned(fn PartitionsHandler) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(onPartitionsAssignedKey{}, fn)
+}
+
+// WithOnPartitionsRevoked is called by a SubscribeWithControl subscription when partitions are
+// revoked or lost, after the messages already fetched from them are handled. The rebalance waits
+// for it to return, so handlers can flush the state they keep for these partitions.
+func WithOnPartitionsRevoked(fn PartitionsHandler) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(onPartitionsRevokedKey{}, fn)
+}
@@ -17,6 +17,9 @@ type publication struct {
 
 	reader *kafkaGo.Reader
 
+	// ack commits the message when it is not committed directly through the reader
+	ack func() error
+
 	ctx context.Context
 	err error
 }
@@ -45,7 +48,15 @@ func (p *publication) RawMessage() any {
 	return p.km
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	return p.km.Value
+}
+
 func (p *publication) Ack() error {
+	if p.ack != nil {
+		return p.ack()
+	}
 	if p.reader == nil {
 		return errors.New("read is nil")
 	}
@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// replyClockSkew is how far before the subscription the replies are read from, so that a reply
+// timestamped by a responder whose clock is behind is not skipped
+const replyClockSkew = time.Minute
+
+// replyTopicDeleteTimeout bounds the deletion of the reply topic of the instance on unsubscribe
+const replyTopicDeleteTimeout = 10 * time.Second
+
+// newRequester creates the requester of Request. By default every instance consumes the replies
+// from its own topic, named after the inbox prefix and the instance ID, which is deleted when
+// the broker disconnects. A topic set with WithReplyTopic is shared by the instances instead:
+// each one reads all the replies and keeps those of its own requests, found by correlation ID.
+func (b *kafkaBroker) newRequester() *broker.Requester {
+	if value, ok := b.options.Context.Value(replyTopicKey{}).(string); ok && value != "" {
+		return broker.NewRequester(&replier{kafkaBroker: b}, broker.WithInboxTopic(value))
+	}
+
+	r := &replier{kafkaBroker: b}
+	requester := broker.NewRequester(r)
+	r.inbox = requester.ReplyTopic()
+	return requester
+}
+
+// replier is the broker of the requester, it consumes the reply topic without a consumer group.
+// The replies are handled by the requester alone, the subscriber middlewares do not apply.
+type replier struct {
+	*kafkaBroker
+
+	// inbox is the reply topic generated for the instance, empty when set with WithReplyTopic
+	inbox string
+}
+
+// Subscribe creates the topic when it does not exist, then reads every partition of it from the
+// time it subscribes: no consumer group is created per instance, and the replies published while
+// the consumer starts are not skipped.
+func (r *replier) Subscribe(topic string, handler broker.Handler, _ broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	options := newSubscribeOptions(opts...)
+
+	admin, err := newAdmin(r.options)
+	if err != nil {
+		return nil, err
+	}
+	_, err = admin.EnsureTopic(options.Context, TopicSpec{Name: topic})
+	admin.Close()
+	if err != nil {
+		return nil, fmt.Errorf("provision reply topic %s: %w", topic, err)
+	}
+
+	start := time.Now().Add(-replyClockSkew)
+	client, err := kgo.NewClient(append(r.clientOptions(),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AfterMilli(start.UnixMilli())),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(options.Context)
+	sub := &replySubscriber{
+		b:       r.kafkaBroker,
+		topic:   topic,
+		options: options,
+		handler: handler,
+		client:  client,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+
+		deleteTopic: topic == r.inbox,
+	}
+	go sub.run()
+
+	r.subscribers.Add(topic, sub)
+
+	return sub, nil
+}
+
+// replySubscriber consumes the reply topic
+type replySubscriber struct {
+	b *kafkaBroker
+
+	topic   string
+	options broker.SubscribeOptions
+	handler broker.Handler
+	client  *kgo.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+
+	// deleteTopic deletes the reply topic generated for the instance on unsubscribe
+	deleteTopic bool
+}
+
+func (s *replySubscriber) Options() broker.SubscribeOptions {
+	return s.options
+}
+
+func (s *replySubscriber) Topic() string {
+	return s.topic
+}
+
+func (s *replySubscriber) Unsubscribe(removeFromManager bool) error {
+	s.once.Do(func() {
+		s.cancel()
+		<-s.done
+		s.client.Close()
+
+		if s.deleteTopic {
+			s.deleteReplyTopic()
+		}
+	})
+
+	if removeFromManager {
+		_ = s.b.subscribers.RemoveOnly(s.topic)
+	}
+
+	return nil
+}
+
+// deleteReplyTopic deletes the reply topic of the instance, the topic of an instance which
+// stopped without disconnecting is left behind
+func (s *replySubscriber) deleteReplyTopic() {
+	admin, err := newAdmin(s.b.options)
+	if err != nil {
+		LogErrorf("delete reply topic %s failed: %v", s.topic, err)
+		return
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), replyTopicDeleteTimeout)
+	defer cancel()
+
+	if err = admin.DeleteTopics(ctx, s.topic); err != nil {
+		LogErrorf("delete reply topic %s failed: %v", s.topic, err)
+	}
+}
+
+func (s *replySubscriber) run() {
+	defer close(s.done)
+
+	for {
+		fetches := s.client.PollFetches(s.ctx)
+		if s.ctx.Err() != nil || fetches.IsClientClosed() {
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			if !errors.Is(err, context.Canceled) {
+				LogErrorf("fetch topic %s partition %d failed: %v", topic, partition, err)
+			}
+		})
+
+		fetches.EachRecord(func(r *kgo.Record) {
+			ctx, span, pub, err := s.b.newRecordPublication(r, nil)
+			if err == nil {
+				err = s.handler(ctx, pub)
+			}
+			s.b.finishConsumerSpan(ctx, span, err)
+		})
+	}
+}
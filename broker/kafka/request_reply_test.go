@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+func TestRequest_SharedReplyTopic(t *testing.T) {
+	cluster := newFakeCluster(t, "rpc.echo")
+
+	newBroker := func() broker.Broker {
+		b := NewBroker(broker.WithAddress(cluster.ListenAddrs()...), WithReplyTopic("echo.replies"))
+		assert.Nil(t, b.Init())
+		assert.Nil(t, b.Connect())
+		t.Cleanup(func() { _ = b.Disconnect() })
+		return b
+	}
+
+	responder := newBroker()
+	_, err := responder.(ControlSubscriber).SubscribeWithControl("rpc.echo", func(ctx context.Context, evt broker.Event) error {
+		body := append([]byte("echo "), evt.Message().Body.([]byte)...)
+		return broker.Reply(ctx, responder, evt, broker.NewMessage(broker.RawBody(body)))
+	}, nil, WithStartOffset(-2), broker.WithSubscribeQueueName("echo"))
+	assert.Nil(t, err)
+
+	// the instances share the reply topic, each gets the replies of its own requests
+	for _, name := range []string{"a", "b"} {
+		b := newBroker()
+		reply, err := b.Request(context.Background(), "rpc.echo",
+			broker.NewMessage(broker.RawBody(name)), broker.WithRequestTimeout(10*time.Second))
+		if assert.Nil(t, err) {
+			assert.Equal(t, "echo "+name, string(reply.Body.([]byte)))
+		}
+	}
+
+	// the replies are read without a consumer group
+	admin := newTestAdmin(t, cluster.ListenAddrs())
+	groups, err := admin.ListGroups(context.Background())
+	assert.Nil(t, err)
+	if assert.Len(t, groups, 1) {
+		assert.Equal(t, "echo", groups[0].Group)
+	}
+
+	topics, err := admin.ListTopics(context.Background())
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"rpc.echo", "echo.replies"}, topics)
+}
+
+func TestRequest_PerInstanceReplyTopic(t *testing.T) {
+	cluster := newFakeCluster(t, "rpc.echo")
+
+	newBroker := func() broker.Broker {
+		b := NewBroker(broker.WithAddress(cluster.ListenAddrs()...))
+		assert.Nil(t, b.Init())
+		assert.Nil(t, b.Connect())
+		return b
+	}
+
+	responder := newBroker()
+	t.Cleanup(func() { _ = responder.Disconnect() })
+	_, err := responder.(ControlSubscriber).SubscribeWithControl("rpc.echo", func(ctx context.Context, evt broker.Event) error {
+		return broker.Reply(ctx, responder, evt, broker.NewMessage(broker.RawBody("pong")))
+	}, nil, WithStartOffset(-2), broker.WithSubscribeQueueName("echo"))
+	assert.Nil(t, err)
+
+	requesters := []broker.Broker{newBroker(), newBroker()}
+	for _, b := range requesters {
+		reply, err := b.Request(context.Background(), "rpc.echo",
+			broker.NewMessage(broker.RawBody("ping")), broker.WithRequestTimeout(10*time.Second))
+		if assert.Nil(t, err) {
+			assert.Equal(t, "pong", string(reply.Body.([]byte)))
+		}
+	}
+
+	// each instance consumes the replies from its own topic
+	admin := newTestAdmin(t, cluster.ListenAddrs())
+	inboxes := func() []string {
+		topics, err := admin.ListTopics(context.Background())
+		assert.Nil(t, err)
+
+		var out []string
+		for _, topic := range topics {
+			if strings.HasPrefix(topic, broker.DefaultInboxPrefix) {
+				out = append(out, topic)
+			}
+		}
+		return out
+	}
+	assert.Len(t, inboxes(), 2)
+
+	// and deletes it when it disconnects
+	for _, b := range requesters {
+		assert.Nil(t, b.Disconnect())
+	}
+	assert.Eventually(t, func() bool { return len(inboxes()) == 0 }, 10*time.Second, 50*time.Millisecond)
+}
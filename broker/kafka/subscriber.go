@@ -29,11 +29,14 @@ type subscriber struct {
 
 	topic string
 
-	options broker.SubscribeOptions
-	handler broker.Handler
-	binder  broker.Binder
+	options      broker.SubscribeOptions
+	handler      broker.Handler
+	batchHandler broker.BatchHandler
+	binder       broker.Binder
 
-	reader *kafkaGo.Reader
+	reader  *kafkaGo.Reader
+	offsets *offsetTracker
+	pool    *broker.WorkerPool
 
 	closed bool
 	done   chan struct{}
@@ -57,13 +60,16 @@ func newSubscriber(
 	handler broker.Handler,
 	binder broker.Binder,
 ) *subscriber {
+	reader := kafkaGo.NewReader(readerConfig)
 	sub := &subscriber{
 		b:       b,
 		options: options,
 		topic:   topic,
 		handler: handler,
 		binder:  binder,
-		reader:  kafkaGo.NewReader(readerConfig),
+		reader:  reader,
+		offsets: newOffsetTracker(reader),
+		pool:    broker.NewSubscribeWorkerPool(options),
 		done:    make(chan struct{}),
 
 		deadlineMin: defaultDeadlineMin,
@@ -90,9 +96,7 @@ func (s *subscriber) Topic() string {
 }
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
-	s.Lock()
-	err := s.closeLocked()
-	s.Unlock()
+	err := s.close()
 
 	if s.b != nil && s.b.subscribers != nil && removeFromManager {
 		_ = s.b.subscribers.RemoveOnly(s.topic)
@@ -103,28 +107,27 @@ func (s *subscriber) Unsubscribe(removeFromManager bool) error {
 
 func (s *subscriber) close() error {
 	s.Lock()
-	defer s.Unlock()
-	return s.closeLocked()
-}
-
-func (s *subscriber) closeLocked() error {
 	if s.closed {
+		s.Unlock()
 		return nil
 	}
 
 	s.closed = true
 
-	var err error
-	if s.reader != nil {
-		err = s.reader.Close()
-	}
-
+	// done is read by the fetch loop without the lock, it is closed but kept
 	if s.done != nil {
 		close(s.done)
-		s.done = nil
 	}
+	s.Unlock()
 
-	return err
+	// the handlers commit through the reader, they finish before it is closed
+	s.pool.Drain()
+
+	if s.reader != nil {
+		return s.reader.Close()
+	}
+
+	return nil
 }
 
 func (s *subscriber) IsClosed() bool {
@@ -135,7 +138,7 @@ func (s *subscriber) IsClosed() bool {
 }
 
 func (s *subscriber) isBatchMode() bool {
-	return s.batchSize > 0 || s.batchInterval > 0
+	return s.batchHandler != nil || s.batchSize > 0 || s.batchInterval > 0
 }
 
 func (s *subscriber) run() {
@@ -147,6 +150,13 @@ func (s *subscriber) run() {
 }
 
 func (s *subscriber) processBatchMessage() {
+	if s.batchSize <= 0 {
+		s.batchSize = defaultSubscribeBatchSize
+	}
+	if s.batchInterval <= 0 {
+		s.batchInterval = defaultSubscribeBatchInterval
+	}
+
 	messageBuffer := make([]kafkaGo.Message, 0, s.batchSize)
 
 	ticker := time.NewTicker(s.batchInterval)
@@ -253,22 +263,133 @@ func (s *subscriber) processSingleMessage() {
 			// 成功读取，重置退避计数
 			errorAttempt = 0
 
-			_ = s.handleMessage(km)
+			// the handlers run concurrently, the offsets are tracked in fetch order
+			o := s.offsets.track(km)
+			s.pool.Submit(func() {
+				_ = s.handleMessage(km, o)
+			})
 		}
 	}
 }
 
 func (s *subscriber) handleBatchMessage(messages []kafkaGo.Message) {
+	if s.batchHandler != nil {
+		s.handleBatch(messages)
+		return
+	}
+
 	for _, km := range messages {
-		if done := s.handleMessage(km); done {
-			LogErrorf("[batch] handleMessage failed for topic %s partition %d offset %d", km.Topic, km.Partition, km.Offset)
+		o := s.offsets.track(km)
+		s.pool.Submit(func() {
+			if done := s.handleMessage(km, o); done {
+				LogErrorf("[batch] handleMessage failed for topic %s partition %d offset %d", km.Topic, km.Partition, km.Offset)
+			}
+		})
+	}
+	// the whole batch is finished before the next one is fetched
+	s.pool.Wait()
+}
+
+// handleBatch passes the whole batch to the batch handler and, with auto-ack,
+// commits the offsets of the batch in a single call once it succeeds
+func (s *subscriber) handleBatch(messages []kafkaGo.Message) {
+	var (
+		evts  []broker.Event
+		kms   []kafkaGo.Message
+		spans []trace.Span
+		ctxs  []context.Context
+	)
+	for _, km := range messages {
+		ctx, span := s.b.startConsumerSpan(context.Background(), &km)
+
+		bm := &broker.Message{
+			Headers:   kafkaHeaderToMap(km.Headers),
+			Partition: km.Partition,
+			Offset:    km.Offset,
+		}
+
+		if s.binder != nil {
+			bm.Body = s.binder()
+
+			if err := broker.Unmarshal(s.b.options.Codec, km.Value, &bm.Body); err != nil {
+				LogErrorf("unmarshal message failed: %v", err)
+				s.b.finishConsumerSpan(ctx, span, err)
+				continue
+			}
+		} else {
+			bm.Body = km.Value
 		}
+
+		evts = append(evts, newPublication(ctx, s.reader, km, bm))
+		kms = append(kms, km)
+		spans = append(spans, span)
+		ctxs = append(ctxs, ctx)
+	}
+
+	if len(evts) == 0 {
+		return
+	}
+
+	// the group reader cannot seek back: the offset committed by a later batch would skip this
+	// one, so a failed batch is handled again until it succeeds or is dead-lettered
+	var (
+		err          error
+		deadLettered bool
+	)
+	for attempt := 0; ; attempt++ {
+		if err = s.batchHandler(s.options.Context, evts); err == nil {
+			break
+		}
+		LogErrorf("handle batch of %d messages failed: %v", len(evts), err)
+
+		// the offsets are not committed, the batch is fetched again by the next subscriber
+		if s.options.Context.Err() != nil || s.IsClosed() {
+			break
+		}
+
+		if attempts := (attempt + 1) * (s.options.MaxRetries + 1); s.options.DeadLetter.Enabled() &&
+			attempts >= s.options.DeadLetter.MaxAttempts {
+			dlErr := s.deadLetterBatch(evts, attempts, err)
+			if dlErr == nil {
+				err, deadLettered = nil, true
+				break
+			}
+			LogErrorf("dead-letter batch of %d messages failed: %v", len(evts), dlErr)
+		}
+
+		backoffSleep(attempt, s.errorMin, s.errorMax)
+	}
+
+	if err == nil && (s.options.AutoAck || deadLettered) {
+		if err = s.reader.CommitMessages(s.options.Context, kms...); err != nil {
+			LogErrorf("unable to commit batch: %v", err)
+		}
+	}
+
+	for i := range spans {
+		s.b.finishConsumerSpan(ctxs[i], spans[i], err)
 	}
 }
 
-func (s *subscriber) handleMessage(km kafkaGo.Message) bool {
+// deadLetterBatch republishes every message of a failed batch to the dead-letter topic
+func (s *subscriber) deadLetterBatch(evts []broker.Event, attempts int, cause error) error {
+	for _, evt := range evts {
+		if err := broker.PublishDeadLetter(s.options.Context, s.b, s.options.DeadLetter.Topic, evt, attempts, cause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *subscriber) handleMessage(km kafkaGo.Message, o *trackedOffset) bool {
 	var err error
 
+	defer func() {
+		if err := s.offsets.finish(s.options.Context, o); err != nil {
+			LogErrorf("unable to commit km: %v", err)
+		}
+	}()
+
 	var span trace.Span
 	var ctx context.Context
 	// Use context.Background() as base to isolate each message's span tree
@@ -294,6 +415,7 @@ func (s *subscriber) handleMessage(km kafkaGo.Message) bool {
 	}
 
 	pub := newPublication(ctx, s.reader, km, bm)
+	pub.ack = func() error { return s.offsets.ack(s.options.Context, o) }
 
 	if err = s.handler(ctx, pub); err != nil {
 		LogErrorf("handle message failed: %v", err)
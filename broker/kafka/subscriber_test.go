@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// batchReceiver collects the bodies of the delivered batches, failing those containing fail
+type batchReceiver struct {
+	mtx     sync.Mutex
+	batches [][]string
+	fail    func(bodies []string) bool
+}
+
+func (r *batchReceiver) handle(_ context.Context, evts []broker.Event) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var bodies []string
+	for _, evt := range evts {
+		bodies = append(bodies, string(evt.Message().Body.([]byte)))
+	}
+	r.batches = append(r.batches, bodies)
+
+	if r.fail(bodies) {
+		return errors.New("batch failed")
+	}
+	return nil
+}
+
+func (r *batchReceiver) delivered() [][]string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return append([][]string(nil), r.batches...)
+}
+
+func newBatchSubscriber(t *testing.T, addrs []string, topic string, handler broker.BatchHandler, opts ...broker.SubscribeOption) {
+	t.Helper()
+
+	b := NewBroker(broker.WithAddress(addrs...))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	t.Cleanup(func() { _ = b.Disconnect() })
+
+	_, err := b.(broker.BatchSubscriber).SubscribeBatch(topic, handler, nil, append([]broker.SubscribeOption{
+		broker.WithSubscribeQueueName("batch"),
+		WithStartOffset(-2),
+		WithSubscribeBatchSize(1),
+		WithSubscribeBatchInterval(50 * time.Millisecond),
+	}, opts...)...)
+	assert.Nil(t, err)
+}
+
+func waitCommitted(t *testing.T, admin *Admin, offset int64) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		lags, err := admin.GroupLag(context.Background(), "batch")
+		return err == nil && len(lags) == 1 && lags[0].Committed == offset
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestSubscribeBatch_FailedBatchNotSkipped(t *testing.T) {
+	cluster := newFakeCluster(t, "batch.orders")
+	produceRecords(t, cluster.ListenAddrs(),
+		&kgo.Record{Topic: "batch.orders", Value: []byte("0")},
+		&kgo.Record{Topic: "batch.orders", Value: []byte("1")},
+		&kgo.Record{Topic: "batch.orders", Value: []byte("2")},
+	)
+
+	failed := false
+	r := &batchReceiver{fail: func(bodies []string) bool {
+		if bodies[0] == "0" && !failed {
+			failed = true
+			return true
+		}
+		return false
+	}}
+	newBatchSubscriber(t, cluster.ListenAddrs(), "batch.orders", r.handle)
+
+	// the failed batch is handled again before the later ones commit past it
+	waitCommitted(t, newTestAdmin(t, cluster.ListenAddrs()), 3)
+	assert.Equal(t, [][]string{{"0"}, {"0"}, {"1"}, {"2"}}, r.delivered())
+}
+
+func TestSubscribeBatch_DeadLetter(t *testing.T) {
+	cluster := newFakeCluster(t, "batch.orders", "batch.orders.dlq")
+	produceRecords(t, cluster.ListenAddrs(),
+		&kgo.Record{Topic: "batch.orders", Value: []byte("poison")},
+		&kgo.Record{Topic: "batch.orders", Value: []byte("1")},
+	)
+
+	r := &batchReceiver{fail: func(bodies []string) bool { return bodies[0] == "poison" }}
+	newBatchSubscriber(t, cluster.ListenAddrs(), "batch.orders", r.handle,
+		broker.WithSubscribeDeadLetter("batch.orders.dlq", 2))
+
+	waitCommitted(t, newTestAdmin(t, cluster.ListenAddrs()), 2)
+	assert.Equal(t, [][]string{{"poison"}, {"poison"}, {"1"}}, r.delivered())
+
+	cl, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.ConsumeTopics("batch.orders.dlq"))
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	records := cl.PollFetches(ctx).Records()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "poison", string(records[0].Value))
+		headers := map[string]string{}
+		for _, h := range records[0].Headers {
+			headers[h.Key] = string(h.Value)
+		}
+		assert.Equal(t, "2", headers[broker.HeaderDeadLetterAttempts])
+		assert.Equal(t, "batch.orders", headers[broker.HeaderDeadLetterOriginalTopic])
+	}
+}
+
+func TestSubscribe_ConcurrentHandlersCommitInOrder(t *testing.T) {
+	cluster := newFakeCluster(t, "orders")
+	produceRecords(t, cluster.ListenAddrs(),
+		&kgo.Record{Topic: "orders", Value: []byte("0")},
+		&kgo.Record{Topic: "orders", Value: []byte("1")},
+		&kgo.Record{Topic: "orders", Value: []byte("2")},
+	)
+
+	b := NewBroker(broker.WithAddress(cluster.ListenAddrs()...))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	t.Cleanup(func() { _ = b.Disconnect() })
+
+	release := make(chan struct{})
+	handled := make(chan string, 3)
+	_, err := b.Subscribe("orders", func(_ context.Context, evt broker.Event) error {
+		body := string(evt.Message().Body.([]byte))
+		if body == "0" {
+			<-release
+		}
+		handled <- body
+		return nil
+	}, nil,
+		broker.WithSubscribeQueueName("batch"),
+		broker.WithSubscribeConcurrency(3),
+		WithStartOffset(-2),
+	)
+	assert.Nil(t, err)
+
+	// the later messages finish while the first one is still handled
+	var finished []string
+	for len(finished) < 2 {
+		select {
+		case body := <-handled:
+			finished = append(finished, body)
+		case <-time.After(10 * time.Second):
+			close(release)
+			t.Fatalf("messages not handled, got %v", finished)
+		}
+	}
+	assert.ElementsMatch(t, []string{"1", "2"}, finished)
+
+	// their offsets are not committed past the unfinished one
+	admin := newTestAdmin(t, cluster.ListenAddrs())
+	time.Sleep(200 * time.Millisecond)
+	lags, err := admin.GroupLag(context.Background(), "batch")
+	assert.Nil(t, err)
+	if assert.Len(t, lags, 1) {
+		assert.Less(t, lags[0].Committed, int64(1))
+	}
+
+	close(release)
+	waitCommitted(t, admin, 3)
+}
@@ -0,0 +1,589 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/trace"
+
+	kafkaGo "github.com/segmentio/kafka-go"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+var (
+	// ErrTransactionalIDRequired is returned by the transactional APIs when no transactional.id is configured
+	ErrTransactionalIDRequired = errors.New("kafka: transactional mode requires WithTransactionalID")
+
+	// ErrTransactionEnded is returned when a transaction is used after it was committed or aborted
+	ErrTransactionEnded = errors.New("kafka: transaction already ended")
+)
+
+var _ Transactor = (*kafkaBroker)(nil)
+
+// TransactionPublisher publishes messages inside a transaction: they become visible to
+// read_committed consumers only once the transaction commits.
+type TransactionPublisher interface {
+	// Publish buffers msg for topic in the transaction. Produce errors are reported by Commit,
+	// which then aborts the transaction.
+	Publish(ctx context.Context, topic string, msg *broker.Message) error
+}
+
+// Transaction is a transaction of the transactional producer.
+type Transaction interface {
+	TransactionPublisher
+
+	// Commit flushes the buffered messages and commits the transaction.
+	// It aborts the transaction instead when a message could not be produced.
+	Commit(ctx context.Context) error
+
+	// Abort discards the buffered messages and aborts the transaction.
+	Abort(ctx context.Context) error
+}
+
+// TransactionalHandler handles a message of a SubscribeTransactional subscription. The messages
+// it publishes with tx are committed atomically with the offset of evt.
+type TransactionalHandler func(ctx context.Context, evt broker.Event, tx TransactionPublisher) error
+
+// Transactor is implemented by the kafka broker when it is created with WithTransactionalID.
+type Transactor interface {
+	// BeginTransaction starts a transaction of the transactional producer. Transactions of the
+	// producer are serialized: it waits until the previous one is committed or aborted.
+	BeginTransaction(ctx context.Context) (Transaction, error)
+
+	// RunInTransaction runs fn in a transaction, which is committed when fn returns nil and aborted otherwise.
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context, tx TransactionPublisher) error) error
+
+	// SubscribeTransactional consumes topic in the consumer group of the subscription (exactly-once
+	// consume-transform-produce): the messages handler publishes and the offsets of the consumed
+	// messages are committed in one transaction per fetched batch. Partitions are handled
+	// concurrently up to Concurrency. When handler fails, the transaction is aborted and the batch
+	// is consumed again after a jittered backoff based on RetryDelay. With a dead-letter policy,
+	// once a message failed MaxRetries+1 times (or DeadLetter.MaxAttempts), it is published as
+	// received to the dead-letter topic in the transaction which commits its offset.
+	SubscribeTransactional(topic string, handler TransactionalHandler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error)
+}
+
+// transactionalClientOptions returns the options of a franz-go client with the transactional.id id
+func (b *kafkaBroker) transactionalClientOptions(id string) []kgo.Opt {
+	opts := append(b.clientOptions(), kgo.TransactionalID(id))
+
+	if value, ok := b.options.Context.Value(transactionTimeoutKey{}).(time.Duration); ok && value > 0 {
+		opts = append(opts, kgo.TransactionTimeout(value))
+	}
+
+	return opts
+}
+
+func (b *kafkaBroker) transactionalID() string {
+	id, _ := b.options.Context.Value(transactionalIDKey{}).(string)
+	return id
+}
+
+// transactionalProducer returns the client of the transactional producer, creating it if needed,
+// and the semaphore that serializes its transactions
+func (b *kafkaBroker) transactionalProducer() (*kgo.Client, chan struct{}, error) {
+	id := b.transactionalID()
+	if id == "" {
+		return nil, nil, ErrTransactionalIDRequired
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	if b.txnClient != nil {
+		return b.txnClient, b.txnSem, nil
+	}
+
+	client, err := kgo.NewClient(b.transactionalClientOptions(id)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	b.txnClient = client
+	b.txnSem = make(chan struct{}, 1)
+
+	return b.txnClient, b.txnSem, nil
+}
+
+func (b *kafkaBroker) BeginTransaction(ctx context.Context) (Transaction, error) {
+	client, sem, err := b.transactionalProducer()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if err = client.BeginTransaction(); err != nil {
+		<-sem
+		return nil, err
+	}
+
+	tx := newTransaction(b, client.Produce)
+	tx.flush = client.Flush
+	tx.end = func(ctx context.Context, commit kgo.TransactionEndTry) error {
+		defer func() { <-sem }()
+
+		if !commit {
+			if err := client.AbortBufferedRecords(ctx); err != nil {
+				return err
+			}
+		}
+		return client.EndTransaction(ctx, commit)
+	}
+
+	return tx, nil
+}
+
+func (b *kafkaBroker) RunInTransaction(ctx context.Context, fn func(ctx context.Context, tx TransactionPublisher) error) error {
+	tx, err := b.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(ctx, tx); err != nil {
+		if abortErr := tx.Abort(ctx); abortErr != nil {
+			LogErrorf("abort transaction failed: %v", abortErr)
+		}
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// closeTransactionalProducer closes the client of the transactional producer, aborting its pending transaction
+func (b *kafkaBroker) closeTransactionalProducer() {
+	if b.txnClient == nil {
+		return
+	}
+	b.txnClient.Close()
+	b.txnClient = nil
+	b.txnSem = nil
+}
+
+// transaction buffers the messages published in it and reports their produce errors when it ends
+type transaction struct {
+	b *kafkaBroker
+
+	produce func(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error))
+	flush   func(ctx context.Context) error
+	end     func(ctx context.Context, commit kgo.TransactionEndTry) error
+
+	mtx   sync.Mutex
+	errs  []error
+	ended bool
+}
+
+func newTransaction(b *kafkaBroker, produce func(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error))) *transaction {
+	return &transaction{b: b, produce: produce}
+}
+
+func (t *transaction) Publish(ctx context.Context, topic string, msg *broker.Message) error {
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+
+	t.mtx.Lock()
+	ended := t.ended
+	t.mtx.Unlock()
+	if ended {
+		return ErrTransactionEnded
+	}
+
+	buf, err := broker.Marshal(t.b.options.Codec, msg.Body)
+	if err != nil {
+		return err
+	}
+
+	// the kafka-go message carries the trace context into the record headers
+	kMsg := kafkaGo.Message{Topic: topic}
+	for k, v := range msg.Headers {
+		kMsg.Headers = append(kMsg.Headers, kafkaGo.Header{Key: k, Value: []byte(v)})
+	}
+
+	var span trace.Span
+	ctx, span = t.b.startProducerSpan(ctx, &kMsg)
+
+	record := &kgo.Record{
+		Topic: topic,
+		Value: buf,
+	}
+	if msg.Key != "" {
+		record.Key = []byte(msg.Key)
+	}
+	for _, h := range kMsg.Headers {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+
+	t.produce(ctx, record, func(r *kgo.Record, err error) {
+		t.b.finishProducerSpan(ctx, span, r.Partition, r.Offset, err)
+
+		if err != nil {
+			t.mtx.Lock()
+			t.errs = append(t.errs, err)
+			t.mtx.Unlock()
+		}
+	})
+
+	return nil
+}
+
+// produceError flushes the buffered messages and returns their produce errors
+func (t *transaction) produceError(ctx context.Context) error {
+	if err := t.flush(ctx); err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return errors.Join(t.errs...)
+}
+
+// finish marks the transaction as ended, it returns false when it already was
+func (t *transaction) finish() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.ended {
+		return false
+	}
+	t.ended = true
+	return true
+}
+
+func (t *transaction) Commit(ctx context.Context) error {
+	if err := t.produceError(ctx); err != nil {
+		if abortErr := t.Abort(ctx); abortErr != nil {
+			LogErrorf("abort transaction failed: %v", abortErr)
+		}
+		return err
+	}
+
+	if !t.finish() {
+		return ErrTransactionEnded
+	}
+	return t.end(ctx, kgo.TryCommit)
+}
+
+func (t *transaction) Abort(ctx context.Context) error {
+	if !t.finish() {
+		return ErrTransactionEnded
+	}
+	return t.end(ctx, kgo.TryAbort)
+}
+
+func (b *kafkaBroker) SubscribeTransactional(
+	topic string,
+	handler TransactionalHandler,
+	binder broker.Binder,
+	opts ...broker.SubscribeOption,
+) (broker.Subscriber, error) {
+	options := newSubscribeOptions(opts...)
+
+	id, _ := options.Context.Value(subscribeTransactionalIDKey{}).(string)
+	if id == "" {
+		if id = b.transactionalID(); id == "" {
+			return nil, ErrTransactionalIDRequired
+		}
+		id = id + "-" + topic
+	}
+
+	clientOpts := append(b.transactionalClientOptions(id), groupConsumerOptions(topic, options)...)
+	lag := new(fetchLag)
+	clientOpts = append(clientOpts,
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		kgo.OnPartitionsRevoked(lag.revoked),
+		kgo.OnPartitionsLost(lag.revoked),
+	)
+
+	session, err := kgo.NewGroupTransactSession(clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := newTransactionalSubscriber(b, topic, options, session, handler, binder)
+	sub.lag = lag
+	go sub.run()
+
+	b.subscribers.Add(topic, sub)
+
+	return sub, nil
+}
+
+// transactionalSubscriber consumes a topic with a GroupTransactSession, which commits the offsets
+// of the consumed records in the transaction of the records produced for them
+type transactionalSubscriber struct {
+	b *kafkaBroker
+
+	topic   string
+	options broker.SubscribeOptions
+	handler TransactionalHandler
+	binder  broker.Binder
+
+	session *kgo.GroupTransactSession
+	pool    *broker.WorkerPool
+	lag     *fetchLag
+
+	// failures holds the record each partition last failed on, only the run loop accesses it
+	failures map[int32]*recordFailure
+
+	errorMin time.Duration
+	errorMax time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// recordFailure counts the failed attempts of handling the record at offset of a partition
+type recordFailure struct {
+	offset   int64
+	attempts int
+	err      error
+}
+
+func newTransactionalSubscriber(
+	b *kafkaBroker,
+	topic string,
+	options broker.SubscribeOptions,
+	session *kgo.GroupTransactSession,
+	handler TransactionalHandler,
+	binder broker.Binder,
+) *transactionalSubscriber {
+	ctx, cancel := context.WithCancel(options.Context)
+	return &transactionalSubscriber{
+		b:        b,
+		topic:    topic,
+		options:  options,
+		handler:  handler,
+		binder:   binder,
+		session:  session,
+		pool:     broker.NewSubscribeWorkerPool(options),
+		lag:      new(fetchLag),
+		failures: make(map[int32]*recordFailure),
+		errorMin: defaultErrorMin,
+		errorMax: defaultErrorMax,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *transactionalSubscriber) Options() broker.SubscribeOptions {
+	return s.options
+}
+
+func (s *transactionalSubscriber) Topic() string {
+	return s.topic
+}
+
+func (s *transactionalSubscriber) Unsubscribe(removeFromManager bool) error {
+	s.once.Do(func() {
+		s.cancel()
+		<-s.done
+		s.pool.Drain()
+		s.session.Close()
+	})
+
+	if s.b != nil && s.b.subscribers != nil && removeFromManager {
+		_ = s.b.subscribers.RemoveOnly(s.topic)
+	}
+
+	return nil
+}
+
+func (s *transactionalSubscriber) run() {
+	defer close(s.done)
+
+	errorAttempt := 0
+
+	for {
+		fetches := s.session.PollFetches(s.ctx)
+		if s.ctx.Err() != nil || fetches.IsClientClosed() {
+			return
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			LogErrorf("fetch topic %s partition %d failed: %v", topic, partition, err)
+		})
+
+		records := fetches.Records()
+		if len(records) == 0 {
+			continue
+		}
+
+		if err := s.session.Begin(); err != nil {
+			LogErrorf("begin transaction failed: %v", err)
+			backoffSleep(errorAttempt, s.errorMin, s.errorMax)
+			if errorAttempt < 6 {
+				errorAttempt++
+			}
+			continue
+		}
+
+		committed, err := s.processBatch(records)
+		if err != nil {
+			LogErrorf("end transaction of %d messages failed: %v", len(records), err)
+			backoffSleep(errorAttempt, s.errorMin, s.errorMax)
+			if errorAttempt < 6 {
+				errorAttempt++
+			}
+			continue
+		}
+		if committed {
+			s.lag.update(fetches)
+			errorAttempt = 0
+			continue
+		}
+
+		// the session rewinds to the committed offsets: the batch is consumed again after a
+		// backoff based on RetryDelay
+		LogErrorf("transaction of %d messages on topic %s aborted, consuming them again", len(records), s.topic)
+		delay := s.errorMin
+		if s.options.RetryDelay > 0 {
+			delay = s.options.RetryDelay
+		}
+		backoffSleep(errorAttempt, delay, s.errorMax)
+		if errorAttempt < 6 {
+			errorAttempt++
+		}
+	}
+}
+
+// processBatch handles records in one transaction, committed only when every record is handled
+// and the messages published by the handler are produced. The partitions are handled concurrently,
+// up to Concurrency at once, and the records of a partition in order.
+func (s *transactionalSubscriber) processBatch(records []*kgo.Record) (bool, error) {
+	tx := newTransaction(s.b, s.session.Produce)
+	tx.flush = s.session.Client().Flush
+
+	var partitions [][]*kgo.Record
+	index := make(map[int32]int)
+	for _, r := range records {
+		i, ok := index[r.Partition]
+		if !ok {
+			i = len(partitions)
+			index[r.Partition] = i
+			partitions = append(partitions, nil)
+		}
+		partitions[i] = append(partitions[i], r)
+	}
+
+	var (
+		mtx    sync.Mutex
+		failed = make(map[*kgo.Record]error)
+	)
+	for _, partition := range partitions {
+		s.pool.Submit(func() {
+			for _, r := range partition {
+				mtx.Lock()
+				aborted := len(failed) > 0
+				mtx.Unlock()
+				if aborted {
+					return
+				}
+
+				if err := s.processRecord(r, tx); err != nil {
+					LogErrorf("handle message failed: %v", err)
+					mtx.Lock()
+					failed[r] = err
+					mtx.Unlock()
+					return
+				}
+			}
+		})
+	}
+	s.pool.Wait()
+
+	for r, err := range failed {
+		s.recordFailed(r, err)
+	}
+
+	commit := kgo.TransactionEndTry(len(failed) == 0)
+	if commit {
+		if err := tx.produceError(s.ctx); err != nil {
+			LogErrorf("produce transactional messages failed: %v", err)
+			commit = kgo.TryAbort
+		}
+	}
+	tx.finish()
+
+	committed, err := s.session.End(s.ctx, commit)
+	if committed {
+		clear(s.failures)
+	}
+	return committed, err
+}
+
+// processRecord handles r, or dead-letters it in the transaction once its attempts are exhausted
+func (s *transactionalSubscriber) processRecord(r *kgo.Record, tx TransactionPublisher) error {
+	if f := s.failures[r.Partition]; f != nil && f.offset == r.Offset && s.exhausted(f.attempts) {
+		return s.deadLetter(r, tx, f)
+	}
+	return s.handleRecord(r, tx)
+}
+
+// exhausted reports whether a record which failed attempts times is dead-lettered: it is after
+// MaxRetries+1 attempts, or DeadLetter.MaxAttempts when it is higher. Without a dead-letter policy
+// the record is consumed again until it is handled.
+func (s *transactionalSubscriber) exhausted(attempts int) bool {
+	policy := s.options.DeadLetter
+	if !policy.Enabled() {
+		return false
+	}
+	return attempts >= max(s.options.MaxRetries+1, policy.MaxAttempts)
+}
+
+// recordFailed counts a failed attempt of r, an attempt of another record of the partition restarts the count
+func (s *transactionalSubscriber) recordFailed(r *kgo.Record, err error) {
+	f := s.failures[r.Partition]
+	if f == nil || f.offset != r.Offset {
+		f = &recordFailure{offset: r.Offset}
+		s.failures[r.Partition] = f
+	}
+	f.attempts++
+	f.err = err
+}
+
+// deadLetter publishes the record as it was received to the dead-letter topic, in the transaction
+// which commits its offset
+func (s *transactionalSubscriber) deadLetter(r *kgo.Record, tx TransactionPublisher, f *recordFailure) error {
+	ctx, span, pub, err := s.b.newRecordPublication(r, nil)
+	if err == nil {
+		var msg *broker.Message
+		if msg, err = broker.NewDeadLetterMessage(pub, f.attempts, f.err); err == nil {
+			err = tx.Publish(ctx, s.options.DeadLetter.Topic, msg)
+		}
+	}
+	s.b.finishConsumerSpan(ctx, span, err)
+
+	if err == nil {
+		LogWarnf("message of topic %s partition %d offset %d dead-lettered after %d attempts: %v",
+			r.Topic, r.Partition, r.Offset, f.attempts, f.err)
+	}
+	return err
+}
+
+func (s *transactionalSubscriber) handleRecord(r *kgo.Record, tx TransactionPublisher) error {
+	ctx, span, pub, err := s.b.newRecordPublication(r, s.binder)
+	if err != nil {
+		s.b.finishConsumerSpan(ctx, span, err)
+		return err
+	}
+
+	// the offset is committed with the transaction, not by the event
+	pub.ack = func() error { return nil }
+
+	err = s.handler(ctx, pub, tx)
+	s.b.finishConsumerSpan(ctx, span, err)
+
+	return err
+}
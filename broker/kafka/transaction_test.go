@@ -0,0 +1,303 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+func newFakeCluster(t *testing.T, topics ...string) *kfake.Cluster {
+	t.Helper()
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, topics...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+
+	return cluster
+}
+
+func newTransactionalBroker(t *testing.T, cluster *kfake.Cluster, id string) broker.Broker {
+	t.Helper()
+
+	b := NewBroker(
+		broker.WithAddress(cluster.ListenAddrs()...),
+		broker.WithCodec("json"),
+		WithTransactionalID(id),
+	)
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	t.Cleanup(func() { _ = b.Disconnect() })
+
+	return b
+}
+
+// readCommitted returns the values of the committed records of topic
+func readCommitted(t *testing.T, cluster *kfake.Cluster, topic string, want int) []string {
+	t.Helper()
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var values []string
+	for len(values) < want+1 {
+		fetches := cl.PollFetches(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		fetches.EachRecord(func(r *kgo.Record) {
+			values = append(values, string(r.Value))
+		})
+	}
+	sort.Strings(values)
+
+	return values
+}
+
+func TestTransaction_RequiresTransactionalID(t *testing.T) {
+	b := NewBroker(broker.WithAddress("127.0.0.1:1")).(*kafkaBroker)
+	assert.Nil(t, b.Init())
+
+	_, err := b.BeginTransaction(context.Background())
+	assert.ErrorIs(t, err, ErrTransactionalIDRequired)
+
+	_, err = b.SubscribeTransactional("in", func(context.Context, broker.Event, TransactionPublisher) error { return nil }, nil)
+	assert.ErrorIs(t, err, ErrTransactionalIDRequired)
+}
+
+func TestTransaction_CommitAndAbort(t *testing.T) {
+	cluster := newFakeCluster(t, "out")
+	tr := newTransactionalBroker(t, cluster, "txn-producer").(Transactor)
+	ctx := context.Background()
+
+	tx, err := tr.BeginTransaction(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Publish(ctx, "out", broker.NewMessage("committed")))
+	assert.Nil(t, tx.Commit(ctx))
+	assert.ErrorIs(t, tx.Commit(ctx), ErrTransactionEnded)
+	assert.ErrorIs(t, tx.Publish(ctx, "out", broker.NewMessage("late")), ErrTransactionEnded)
+
+	tx, err = tr.BeginTransaction(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Publish(ctx, "out", broker.NewMessage("aborted")))
+	assert.Nil(t, tx.Abort(ctx))
+
+	errFailed := errors.New("failed")
+	err = tr.RunInTransaction(ctx, func(ctx context.Context, tx TransactionPublisher) error {
+		_ = tx.Publish(ctx, "out", broker.NewMessage("rolled back"))
+		return errFailed
+	})
+	assert.ErrorIs(t, err, errFailed)
+
+	assert.Nil(t, tr.RunInTransaction(ctx, func(ctx context.Context, tx TransactionPublisher) error {
+		return tx.Publish(ctx, "out", broker.NewMessage("run"))
+	}))
+
+	assert.Equal(t, []string{`"committed"`, `"run"`}, readCommitted(t, cluster, "out", 2))
+}
+
+func TestTransaction_Fencing(t *testing.T) {
+	cluster := newFakeCluster(t, "out")
+	ctx := context.Background()
+
+	zombie := newTransactionalBroker(t, cluster, "fenced").(Transactor)
+	tx, err := zombie.BeginTransaction(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Publish(ctx, "out", broker.NewMessage("zombie")))
+	assert.Nil(t, tx.(*transaction).flush(ctx))
+
+	// a new producer with the same transactional.id fences the previous one
+	current := newTransactionalBroker(t, cluster, "fenced").(Transactor)
+	assert.Nil(t, current.RunInTransaction(ctx, func(ctx context.Context, tx TransactionPublisher) error {
+		return tx.Publish(ctx, "out", broker.NewMessage("current"))
+	}))
+
+	assert.NotNil(t, tx.Commit(ctx))
+	assert.Equal(t, []string{`"current"`}, readCommitted(t, cluster, "out", 1))
+}
+
+func TestSubscribeTransactional_ExactlyOnce(t *testing.T) {
+	cluster := newFakeCluster(t, "in", "out")
+	ctx := context.Background()
+
+	producer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	for _, v := range []string{"a", "b", "c"} {
+		assert.Nil(t, producer.ProduceSync(ctx, &kgo.Record{Topic: "in", Value: []byte(v)}).FirstErr())
+	}
+
+	b := newTransactionalBroker(t, cluster, "pipeline")
+
+	var (
+		mtx      sync.Mutex
+		failed   bool
+		received []string
+	)
+	sub, err := b.(Transactor).SubscribeTransactional("in",
+		func(ctx context.Context, evt broker.Event, tx TransactionPublisher) error {
+			body := string(evt.Message().Body.([]byte))
+
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			// the first attempt at "b" fails, aborting the output of its batch
+			if body == "b" && !failed {
+				failed = true
+				return errors.New("transient failure")
+			}
+			received = append(received, body)
+
+			assert.Nil(t, evt.Ack())
+			return tx.Publish(ctx, "out", broker.NewMessage(body+"'"))
+		},
+		nil,
+		broker.WithSubscribeQueueName("pipeline-group"),
+		WithStartOffset(-2),
+	)
+	assert.Nil(t, err)
+	defer func() { _ = sub.Unsubscribe(true) }()
+
+	assert.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(received) > 0 && received[len(received)-1] == "c"
+	}, 10*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, []string{`"a'"`, `"b'"`, `"c'"`}, readCommitted(t, cluster, "out", 3))
+}
+
+func TestSubscribeTransactional_DeadLetter(t *testing.T) {
+	cluster := newFakeCluster(t, "in", "out", "in.dlq")
+	produceRecords(t, cluster.ListenAddrs(),
+		&kgo.Record{Topic: "in", Value: []byte("a")},
+		&kgo.Record{Topic: "in", Value: []byte("poison")},
+		&kgo.Record{Topic: "in", Value: []byte("c")},
+	)
+
+	b := newTransactionalBroker(t, cluster, "dlq-pipeline")
+
+	var (
+		mtx      sync.Mutex
+		attempts []time.Time
+		received []string
+	)
+	sub, err := b.(Transactor).SubscribeTransactional("in",
+		func(ctx context.Context, evt broker.Event, tx TransactionPublisher) error {
+			body := string(evt.Message().Body.([]byte))
+
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			if body == "poison" {
+				attempts = append(attempts, time.Now())
+				return errors.New("cannot handle")
+			}
+			received = append(received, body)
+			return tx.Publish(ctx, "out", broker.NewMessage(body+"'"))
+		},
+		nil,
+		broker.WithSubscribeQueueName("dlq-group"),
+		broker.WithSubscribeRetry(1, 200*time.Millisecond),
+		broker.WithSubscribeDeadLetter("in.dlq", 0),
+		WithStartOffset(-2),
+	)
+	assert.Nil(t, err)
+	defer func() { _ = sub.Unsubscribe(true) }()
+
+	assert.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(received) > 0 && received[len(received)-1] == "c"
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// the aborted batch is consumed again after a backoff, not in a tight loop
+	mtx.Lock()
+	if assert.Len(t, attempts, 2) {
+		assert.GreaterOrEqual(t, attempts[1].Sub(attempts[0]), 100*time.Millisecond)
+	}
+	mtx.Unlock()
+
+	assert.Equal(t, []string{`"a'"`, `"c'"`}, readCommitted(t, cluster, "out", 2))
+	assert.Equal(t, []string{"poison"}, readCommitted(t, cluster, "in.dlq", 1))
+}
+
+func TestSubscribeTransactional_ConcurrentPartitions(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, "in", "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+
+	producer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.RecordPartitioner(kgo.ManualPartitioner()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	assert.Nil(t, producer.ProduceSync(context.Background(),
+		&kgo.Record{Topic: "in", Partition: 0, Value: []byte("p0")},
+		&kgo.Record{Topic: "in", Partition: 1, Value: []byte("p1")},
+	).FirstErr())
+
+	b := newTransactionalBroker(t, cluster, "concurrent")
+
+	// each handler waits for the one of the other partition, which only returns when they run at once
+	var started sync.WaitGroup
+	started.Add(2)
+	both := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(both)
+	}()
+
+	sub, err := b.(Transactor).SubscribeTransactional("in",
+		func(ctx context.Context, evt broker.Event, tx TransactionPublisher) error {
+			started.Done()
+			select {
+			case <-both:
+			case <-time.After(5 * time.Second):
+				return errors.New("partitions handled one at a time")
+			}
+			return tx.Publish(ctx, "out", broker.NewMessage(string(evt.Message().Body.([]byte))+"'"))
+		},
+		nil,
+		broker.WithSubscribeQueueName("concurrent-group"),
+		broker.WithSubscribeConcurrency(2),
+		WithStartOffset(-2),
+	)
+	assert.Nil(t, err)
+	defer func() { _ = sub.Unsubscribe(true) }()
+
+	select {
+	case <-both:
+	case <-time.After(10 * time.Second):
+		t.Fatal("partitions handled one at a time")
+	}
+	assert.Equal(t, []string{`"p0'"`, `"p1'"`}, readCommitted(t, cluster, "out", 2))
+}
@@ -0,0 +1,307 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+const (
+	defaultAddr = "memory://"
+)
+
+// envelope is the encoded form of a message while it travels through the broker.
+type envelope struct {
+	topic   string
+	id      string
+	key     string
+	headers broker.Headers
+	body    []byte
+	offset  int64
+}
+
+type memoryBroker struct {
+	sync.RWMutex
+
+	options broker.Options
+
+	connected bool
+	queueSize int
+
+	// subscribers is the routing table: topic -> subscribers in subscription order
+	subscribers map[string][]*subscriber
+	// cursors holds the round-robin position of every queue group, keyed by topic and queue
+	cursors map[queueKey]uint64
+	// offsets holds the next offset of every topic
+	offsets map[string]int64
+
+	requester *broker.Requester
+}
+
+type queueKey struct {
+	topic string
+	queue string
+}
+
+// NewBroker creates a broker that routes messages between subscribers of the same process.
+func NewBroker(opts ...broker.Option) broker.Broker {
+	options := broker.NewOptionsAndApply(opts...)
+
+	b := &memoryBroker{
+		options:     options,
+		queueSize:   defaultQueueSize,
+		subscribers: make(map[string][]*subscriber),
+		cursors:     make(map[queueKey]uint64),
+		offsets:     make(map[string]int64),
+	}
+	b.requester = broker.NewRequester(b)
+
+	return b
+}
+
+func (b *memoryBroker) Name() string {
+	return "memory"
+}
+
+func (b *memoryBroker) Options() broker.Options {
+	return b.options
+}
+
+func (b *memoryBroker) Address() string {
+	if len(b.options.Addrs) > 0 {
+		return b.options.Addrs[0]
+	}
+	return defaultAddr
+}
+
+func (b *memoryBroker) Init(opts ...broker.Option) error {
+	b.Lock()
+	defer b.Unlock()
+
+	b.options.Apply(opts...)
+
+	if value, ok := b.options.Context.Value(queueSizeKey{}).(int); ok && value > 0 {
+		b.queueSize = value
+	}
+
+	return nil
+}
+
+func (b *memoryBroker) Connect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	b.connected = true
+
+	return nil
+}
+
+func (b *memoryBroker) Disconnect() error {
+	_ = b.requester.Close()
+
+	b.Lock()
+	var subs []*subscriber
+	for _, list := range b.subscribers {
+		subs = append(subs, list...)
+	}
+	b.subscribers = make(map[string][]*subscriber)
+	b.cursors = make(map[queueKey]uint64)
+	b.connected = false
+	b.Unlock()
+
+	for _, s := range subs {
+		_ = s.close()
+	}
+
+	return nil
+}
+
+func (b *memoryBroker) isConnected() bool {
+	b.RLock()
+	defer b.RUnlock()
+
+	return b.connected
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var finalTask = b.internalPublish
+
+	if len(b.options.PublishMiddlewares) > 0 {
+		finalTask = broker.ChainPublishMiddleware(finalTask, b.options.PublishMiddlewares)
+	}
+
+	return finalTask(ctx, topic, msg, opts...)
+}
+
+func (b *memoryBroker) internalPublish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+
+	buf, err := broker.Marshal(b.options.Codec, msg.Body)
+	if err != nil {
+		return err
+	}
+	broker.RecordPayloadSize(ctx, len(buf))
+
+	options := broker.PublishOptions{
+		Context: ctx,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		options.Context, cancel = context.WithTimeout(options.Context, options.Timeout)
+		defer cancel()
+	}
+
+	env := &envelope{
+		topic:   topic,
+		id:      msg.ID,
+		key:     msg.Key,
+		headers: copyHeaders(msg.Headers),
+		body:    buf,
+	}
+	if env.id == "" {
+		env.id = uuid.New().String()
+	}
+
+	if options.Async {
+		go func() {
+			err := b.publish(options.Context, env)
+			if options.Callback != nil {
+				options.Callback(err)
+			}
+		}()
+		return nil
+	}
+
+	err = b.publish(options.Context, env)
+	if options.Callback != nil {
+		options.Callback(err)
+	}
+	return err
+}
+
+// publish routes env to every plain subscriber of its topic and to one member of each queue group.
+func (b *memoryBroker) publish(ctx context.Context, env *envelope) error {
+	targets, err := b.route(env)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range targets {
+		if err = s.deliver(ctx, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *memoryBroker) route(env *envelope) ([]*subscriber, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.connected {
+		return nil, errors.New("not connected")
+	}
+
+	env.offset = b.offsets[env.topic]
+	b.offsets[env.topic]++
+
+	subs := b.subscribers[env.topic]
+	if len(subs) == 0 {
+		return nil, nil
+	}
+
+	var targets []*subscriber
+	groups := make(map[string][]*subscriber)
+	var order []string
+	for _, s := range subs {
+		if s.options.Queue == "" {
+			targets = append(targets, s)
+			continue
+		}
+		if _, ok := groups[s.options.Queue]; !ok {
+			order = append(order, s.options.Queue)
+		}
+		groups[s.options.Queue] = append(groups[s.options.Queue], s)
+	}
+
+	for _, queue := range order {
+		members := groups[queue]
+		key := queueKey{topic: env.topic, queue: queue}
+		cursor := b.cursors[key]
+		b.cursors[key] = cursor + 1
+		targets = append(targets, members[cursor%uint64(len(members))])
+	}
+
+	return targets, nil
+}
+
+func (b *memoryBroker) Subscribe(topic string, handler broker.Handler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	options := broker.SubscribeOptions{
+		Context: context.Background(),
+		AutoAck: true,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
+		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
+	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
+
+	if !b.isConnected() {
+		return nil, errors.New("not connected")
+	}
+
+	sub := newSubscriber(b, topic, options, handler, binder)
+
+	b.Lock()
+	b.subscribers[topic] = append(b.subscribers[topic], sub)
+	b.Unlock()
+
+	go sub.run()
+
+	return sub, nil
+}
+
+func (b *memoryBroker) removeSubscriber(sub *subscriber) {
+	b.Lock()
+	defer b.Unlock()
+
+	list := b.subscribers[sub.topic]
+	for i, s := range list {
+		if s == sub {
+			list = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+
+	if len(list) == 0 {
+		delete(b.subscribers, sub.topic)
+	} else {
+		b.subscribers[sub.topic] = list
+	}
+}
+
+func (b *memoryBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
+	return b.requester.Request(ctx, topic, msg, opts...)
+}
+
+func copyHeaders(h broker.Headers) broker.Headers {
+	cp := make(broker.Headers, len(h))
+	for k, v := range h {
+		cp[k] = v
+	}
+	return cp
+}
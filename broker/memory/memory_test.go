@@ -0,0 +1,388 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+type hygrothermograph struct {
+	Humidity    float64 `json:"humidity"`
+	Temperature float64 `json:"temperature"`
+}
+
+func newConnectedBroker(t *testing.T, opts ...broker.Option) broker.Broker {
+	t.Helper()
+
+	b := NewBroker(opts...)
+	if err := b.Init(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if err := b.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Disconnect() })
+	return b
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPublishSubscribe_CodecRoundTrip(t *testing.T) {
+	b := newConnectedBroker(t, broker.WithCodec("json"))
+
+	got := make(chan *hygrothermograph, 1)
+	_, err := broker.Subscribe(b, "sensor", func(_ context.Context, topic string, headers broker.Headers, msg *hygrothermograph) error {
+		if topic != "sensor" {
+			t.Errorf("unexpected topic: %s", topic)
+		}
+		if headers["trace"] != "abc" {
+			t.Errorf("unexpected headers: %v", headers)
+		}
+		got <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	msg := broker.NewMessage(&hygrothermograph{Humidity: 50, Temperature: 21.5}, broker.WithHeader("trace", "abc"))
+	if err = b.Publish(context.Background(), "sensor", msg); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case m := <-got:
+		if m.Humidity != 50 || m.Temperature != 21.5 {
+			t.Fatalf("unexpected payload: %+v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered")
+	}
+}
+
+func TestPublish_FanOutAndQueueGroups(t *testing.T) {
+	b := newConnectedBroker(t)
+
+	var plainA, plainB, groupA, groupB atomic.Int32
+	count := func(c *atomic.Int32) broker.Handler {
+		return func(context.Context, broker.Event) error {
+			c.Add(1)
+			return nil
+		}
+	}
+
+	for _, s := range []struct {
+		counter *atomic.Int32
+		queue   string
+	}{
+		{&plainA, ""},
+		{&plainB, ""},
+		{&groupA, "workers"},
+		{&groupB, "workers"},
+	} {
+		if _, err := b.Subscribe("jobs", count(s.counter), nil, broker.WithSubscribeQueueName(s.queue)); err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+	}
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		if err := b.Publish(context.Background(), "jobs", broker.NewMessage("job")); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	waitFor(t, func() bool {
+		return plainA.Load() == total && plainB.Load() == total && groupA.Load()+groupB.Load() == total
+	})
+
+	if groupA.Load() != total/2 || groupB.Load() != total/2 {
+		t.Fatalf("queue group not balanced: %d/%d", groupA.Load(), groupB.Load())
+	}
+}
+
+func TestSubscribe_ManualAck(t *testing.T) {
+	b := newConnectedBroker(t)
+
+	events := make(chan broker.Event, 1)
+	_, err := b.Subscribe("manual", func(_ context.Context, evt broker.Event) error {
+		events <- evt
+		return nil
+	}, nil, broker.DisableAutoAck())
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if err = b.Publish(context.Background(), "manual", broker.NewMessage("payload")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	var evt broker.Event
+	select {
+	case evt = <-events:
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered")
+	}
+
+	pub := evt.(*publication)
+	if pub.IsAcked() {
+		t.Fatal("message acknowledged without auto-ack")
+	}
+	if err = evt.Ack(); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if err = evt.Ack(); err != ErrAlreadyAcked {
+		t.Fatalf("expected ErrAlreadyAcked, got %v", err)
+	}
+}
+
+func TestUnsubscribe_StopsDelivery(t *testing.T) {
+	b := newConnectedBroker(t)
+
+	var received atomic.Int32
+	sub, err := b.Subscribe("topic", func(context.Context, broker.Event) error {
+		received.Add(1)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if err = sub.Unsubscribe(true); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+
+	if err = b.Publish(context.Background(), "topic", broker.NewMessage("payload")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if received.Load() != 0 {
+		t.Fatalf("unsubscribed handler received %d messages", received.Load())
+	}
+}
+
+func TestRequest_Reply(t *testing.T) {
+	b := newConnectedBroker(t, broker.WithCodec("json"))
+
+	_, err := b.Subscribe("rpc.echo", func(ctx context.Context, evt broker.Event) error {
+		req := evt.Message().Body.(*hygrothermograph)
+		return broker.Reply(ctx, b, evt, broker.NewMessage(&hygrothermograph{Humidity: req.Humidity * 2}))
+	}, func() any { return &hygrothermograph{} })
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			reply, err := b.Request(context.Background(), "rpc.echo",
+				broker.NewMessage(&hygrothermograph{Humidity: float64(i)}),
+				broker.WithRequestTimeout(time.Second),
+			)
+			if err != nil {
+				t.Errorf("request: %v", err)
+				return
+			}
+
+			var out hygrothermograph
+			if err = broker.Unmarshal(b.Options().Codec, reply.BodyBytes(), &out); err != nil {
+				t.Errorf("unmarshal reply: %v", err)
+				return
+			}
+			if out.Humidity != float64(i*2) {
+				t.Errorf("unexpected reply for %d: %+v", i, out)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRequest_Timeout(t *testing.T) {
+	b := newConnectedBroker(t)
+
+	_, err := b.Request(context.Background(), "nobody", broker.NewMessage("ping"), broker.WithRequestTimeout(20*time.Millisecond))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRequest_ReplyBypassesSubscriberMiddlewares(t *testing.T) {
+	var mu sync.Mutex
+	var topics []string
+	b := newConnectedBroker(t, broker.WithSubscriberMiddlewares(func(next broker.Handler) broker.Handler {
+		return func(ctx context.Context, evt broker.Event) error {
+			mu.Lock()
+			topics = append(topics, evt.Topic())
+			mu.Unlock()
+			return next(ctx, evt)
+		}
+	}))
+
+	_, err := b.Subscribe("rpc.echo", func(ctx context.Context, evt broker.Event) error {
+		return broker.Reply(ctx, b, evt, broker.NewMessage("pong"))
+	}, nil)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if _, err = b.Request(context.Background(), "rpc.echo", broker.NewMessage("ping"), broker.WithRequestTimeout(time.Second)); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	// the reply is routed to the requester without passing the middlewares of the application
+	mu.Lock()
+	defer mu.Unlock()
+	if len(topics) != 1 || topics[0] != "rpc.echo" {
+		t.Fatalf("unexpected topics seen by the middleware: %v", topics)
+	}
+}
+
+func TestPublish_NotConnected(t *testing.T) {
+	b := NewBroker()
+
+	if err := b.Publish(context.Background(), "topic", broker.NewMessage("payload")); err == nil {
+		t.Fatal("expected error when publishing on a disconnected broker")
+	}
+}
+
+func TestSubscribe_ConcurrencyAndRetry(t *testing.T) {
+	b := newConnectedBroker(t)
+
+	var running, peak, succeeded atomic.Int32
+	var seen sync.Map
+	_, err := b.Subscribe("work", func(_ context.Context, evt broker.Event) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		// every message fails once before succeeding
+		if _, retried := seen.LoadOrStore(evt.Message().ID, struct{}{}); !retried {
+			return errors.New("transient")
+		}
+		succeeded.Add(1)
+		return nil
+	}, nil,
+		broker.WithSubscribeConcurrency(4),
+		broker.WithSubscribeRetry(1, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	const total = 8
+	for i := 0; i < total; i++ {
+		if err = b.Publish(context.Background(), "work", broker.NewMessage("job")); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	waitFor(t, func() bool { return succeeded.Load() == total })
+
+	if peak.Load() < 2 || peak.Load() > 4 {
+		t.Fatalf("unexpected handler concurrency: %d", peak.Load())
+	}
+}
+
+func TestSubscribe_DeadLetter(t *testing.T) {
+	b := newConnectedBroker(t, broker.WithCodec("json"))
+
+	dead := make(chan broker.Event, 1)
+	_, err := b.Subscribe("orders.dlq", func(_ context.Context, evt broker.Event) error {
+		dead <- evt
+		return nil
+	}, func() any { return &hygrothermograph{} })
+	if err != nil {
+		t.Fatalf("subscribe dlq: %v", err)
+	}
+
+	var attempts atomic.Int32
+	_, err = b.Subscribe("orders", func(context.Context, broker.Event) error {
+		attempts.Add(1)
+		return errors.New("poison")
+	}, func() any { return &hygrothermograph{} }, broker.WithSubscribeDeadLetter("orders.dlq", 3))
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if err = b.Publish(context.Background(), "orders", broker.NewMessage(&hygrothermograph{Humidity: 42})); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case evt := <-dead:
+		msg := evt.Message()
+		if msg.Body.(*hygrothermograph).Humidity != 42 {
+			t.Fatalf("unexpected dead-letter payload: %+v", msg.Body)
+		}
+		if msg.GetHeader(broker.HeaderDeadLetterOriginalTopic) != "orders" ||
+			msg.GetHeader(broker.HeaderDeadLetterAttempts) != "3" ||
+			msg.GetHeader(broker.HeaderDeadLetterError) != "poison" {
+			t.Fatalf("unexpected dead-letter headers: %v", msg.Headers)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not dead-lettered")
+	}
+
+	if attempts.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestSubscribe_DeadLetterKeepsPayload(t *testing.T) {
+	b := newConnectedBroker(t, broker.WithCodec("json"))
+
+	dead := make(chan []byte, 1)
+	_, err := b.Subscribe("raw.dlq", func(_ context.Context, evt broker.Event) error {
+		dead <- evt.Message().Body.([]byte)
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("subscribe dlq: %v", err)
+	}
+
+	// without a binder the handler gets the payload, which is dead-lettered as is
+	_, err = b.Subscribe("raw", func(context.Context, broker.Event) error {
+		return errors.New("poison")
+	}, nil, broker.WithSubscribeDeadLetter("raw.dlq", 1))
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if err = b.Publish(context.Background(), "raw", broker.NewMessage(&hygrothermograph{Humidity: 42})); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case body := <-dead:
+		if string(body) != `{"humidity":42,"temperature":0}` {
+			t.Fatalf("unexpected dead-letter payload: %s", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not dead-lettered")
+	}
+}
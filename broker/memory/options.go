@@ -0,0 +1,21 @@
+package memory
+
+import (
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+const (
+	defaultQueueSize = 1024
+)
+
+///
+/// Option
+///
+
+type queueSizeKey struct{}
+
+// WithQueueSize sets the capacity of each subscriber's delivery queue.
+// Publish blocks (honouring its context) once a subscriber queue is full.
+func WithQueueSize(size int) broker.Option {
+	return broker.OptionContextWithValue(queueSizeKey{}, size)
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+var ErrAlreadyAcked = errors.New("message already acknowledged")
+
+type publication struct {
+	topic string
+	err   error
+
+	m   *broker.Message
+	raw *envelope
+
+	acked atomic.Bool
+}
+
+func (p *publication) Topic() string {
+	return p.topic
+}
+
+func (p *publication) Message() *broker.Message {
+	return p.m
+}
+
+func (p *publication) RawMessage() any {
+	return p.raw
+}
+
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.raw == nil {
+		return nil
+	}
+	return p.raw.body
+}
+
+func (p *publication) Ack() error {
+	if !p.acked.CompareAndSwap(false, true) {
+		return ErrAlreadyAcked
+	}
+	return nil
+}
+
+func (p *publication) Error() error {
+	return p.err
+}
+
+// IsAcked reports whether the event has been acknowledged.
+func (p *publication) IsAcked() bool {
+	return p.acked.Load()
+}
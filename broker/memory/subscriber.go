@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+type subscriber struct {
+	sync.RWMutex
+
+	b *memoryBroker
+
+	topic   string
+	options broker.SubscribeOptions
+	handler broker.Handler
+	binder  broker.Binder
+
+	queue  chan *envelope
+	pool   *broker.WorkerPool
+	done   chan struct{}
+	closed bool
+}
+
+func newSubscriber(
+	b *memoryBroker,
+	topic string,
+	options broker.SubscribeOptions,
+	handler broker.Handler,
+	binder broker.Binder,
+) *subscriber {
+	return &subscriber{
+		b:       b,
+		topic:   topic,
+		options: options,
+		handler: handler,
+		binder:  binder,
+		queue:   make(chan *envelope, b.queueSize),
+		pool:    broker.NewSubscribeWorkerPool(options),
+		done:    make(chan struct{}),
+	}
+}
+
+func (s *subscriber) Options() broker.SubscribeOptions {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.options
+}
+
+func (s *subscriber) Topic() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.topic
+}
+
+func (s *subscriber) Unsubscribe(_ bool) error {
+	// the routing table doubles as the subscriber manager, so the subscriber
+	// is always removed from it: leaving it there would keep it routable.
+	if s.b != nil {
+		s.b.removeSubscriber(s)
+	}
+
+	return s.close()
+}
+
+func (s *subscriber) close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.Unlock()
+
+	// wait for the handlers still running
+	s.pool.Drain()
+
+	return nil
+}
+
+func (s *subscriber) IsClosed() bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.closed
+}
+
+// deliver enqueues env for this subscriber, blocking while the queue is full.
+func (s *subscriber) deliver(ctx context.Context, env *envelope) error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	select {
+	case s.queue <- env:
+		return nil
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.options.Context.Done():
+			_ = s.Unsubscribe(true)
+			return
+
+		case <-s.done:
+			return
+
+		case env := <-s.queue:
+			s.pool.Submit(func() {
+				s.handleMessage(env)
+			})
+		}
+	}
+}
+
+func (s *subscriber) handleMessage(env *envelope) {
+	var err error
+
+	m := &broker.Message{
+		ID:        env.id,
+		Headers:   copyHeaders(env.headers),
+		Key:       env.key,
+		Partition: 0,
+		Offset:    env.offset,
+		Msg:       env,
+	}
+
+	pub := &publication{topic: env.topic, m: m, raw: env}
+
+	ctx := s.options.Context
+	eh := s.b.options.ErrorHandler
+
+	if s.binder != nil {
+		m.Body = s.binder()
+
+		if err = broker.Unmarshal(s.b.options.Codec, env.body, &m.Body); err != nil {
+			pub.err = err
+			LogErrorf("unmarshal message failed: %v", err)
+			if eh != nil {
+				_ = eh(s.b.options.Context, pub)
+			}
+			return
+		}
+	} else {
+		m.Body = env.body
+	}
+
+	if err = s.handler(ctx, pub); err != nil {
+		pub.err = err
+		LogErrorf("handle message failed: %v", err)
+		if eh != nil {
+			_ = eh(s.b.options.Context, pub)
+		}
+		return
+	}
+
+	if s.options.AutoAck && !pub.IsAcked() {
+		_ = pub.Ack()
+	}
+}
@@ -0,0 +1,301 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const metricsInstrumentationName = "github.com/tx7do/kratos-transport/broker"
+
+// attribute keys of the OpenTelemetry messaging semantic conventions
+const (
+	attrMessagingSystem          = attribute.Key("messaging.system")
+	attrMessagingDestinationName = attribute.Key("messaging.destination.name")
+	attrMessagingOperationName   = attribute.Key("messaging.operation.name")
+	attrErrorType                = attribute.Key("error.type")
+)
+
+// durationBuckets are the histogram boundaries, in seconds, recommended by the messaging semantic conventions.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// PayloadSizeFunc returns the size in bytes of the payload of msg, or a negative value when it is unknown.
+type PayloadSizeFunc func(msg *Message) int
+
+type metricsOptions struct {
+	meterProvider metric.MeterProvider
+	system        string
+	payloadSize   PayloadSizeFunc
+}
+
+// MetricsOption configures NewMetrics.
+type MetricsOption func(*metricsOptions)
+
+// WithMetricsMeterProvider sets the meter provider, the global one by default.
+func WithMetricsMeterProvider(provider metric.MeterProvider) MetricsOption {
+	return func(o *metricsOptions) {
+		if provider != nil {
+			o.meterProvider = provider
+		}
+	}
+}
+
+// WithMetricsSystem sets the messaging.system attribute of every measurement, e.g. "kafka".
+func WithMetricsSystem(system string) MetricsOption {
+	return func(o *metricsOptions) {
+		o.system = system
+	}
+}
+
+// WithMetricsPayloadSize sets how payload sizes are measured. By default the encoded payloads
+// are measured: the middlewares run before the broker encodes the published messages, which
+// report their size with RecordPayloadSize, and after it decodes the received ones, whose
+// payload is read with Payload. Otherwise only the bodies which are already bytes
+// ([]byte, string, RawBody) are measured.
+func WithMetricsPayloadSize(fn PayloadSizeFunc) MetricsOption {
+	return func(o *metricsOptions) {
+		if fn != nil {
+			o.payloadSize = fn
+		}
+	}
+}
+
+// payloadSizeKey carries the encoded size of the message being published, see RecordPayloadSize
+type payloadSizeKey struct{}
+
+// RecordPayloadSize reports size, the size of the encoded payload of the message published with
+// ctx, to the publish middlewares which measure it, see Metrics. Brokers call it once they have
+// encoded the message; it does nothing when no middleware measures it.
+func RecordPayloadSize(ctx context.Context, size int) {
+	if ctx == nil {
+		return
+	}
+	if encoded, ok := ctx.Value(payloadSizeKey{}).(*atomic.Int64); ok {
+		encoded.Store(int64(size))
+	}
+}
+
+func rawPayloadSize(msg *Message) int {
+	if msg == nil {
+		return -1
+	}
+	switch t := msg.Body.(type) {
+	case RawBody:
+		return len(t)
+	case []byte:
+		return len(t)
+	case string:
+		return len(t)
+	}
+	return -1
+}
+
+// Metrics records OpenTelemetry metrics of the messages going through a broker, following
+// the messaging semantic conventions. Install its middlewares with WithPublishMiddlewares
+// and WithSubscriberMiddlewares:
+//
+//	m, _ := broker.NewMetrics(broker.WithMetricsSystem("kafka"))
+//	b := kafka.NewBroker(
+//		broker.WithPublishMiddlewares(m.PublishMiddleware()),
+//		broker.WithSubscriberMiddlewares(m.SubscriberMiddleware()),
+//	)
+//
+// Every measurement carries the topic as messaging.destination.name. Failed publishes carry the
+// error.type attribute; a message retried by the broker is consumed once, and every failed
+// handler invocation is counted by messaging.process.errors.
+type Metrics struct {
+	options metricsOptions
+
+	sentMessages    metric.Int64Counter
+	sentSize        metric.Int64Histogram
+	sendDuration    metric.Float64Histogram
+	sendActive      metric.Int64UpDownCounter
+	consumedMessage metric.Int64Counter
+	consumedSize    metric.Int64Histogram
+	processDuration metric.Float64Histogram
+	processActive   metric.Int64UpDownCounter
+	processErrors   metric.Int64Counter
+	processRetries  metric.Int64Counter
+}
+
+// NewMetrics creates the instruments of Metrics.
+func NewMetrics(opts ...MetricsOption) (*Metrics, error) {
+	o := metricsOptions{
+		meterProvider: otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	meter := o.meterProvider.Meter(metricsInstrumentationName)
+	m := &Metrics{options: o}
+
+	var err error
+	if m.sentMessages, err = meter.Int64Counter("messaging.client.sent.messages",
+		metric.WithDescription("Number of messages published."),
+		metric.WithUnit("{message}")); err != nil {
+		return nil, err
+	}
+	if m.sentSize, err = meter.Int64Histogram("messaging.client.sent.message.size",
+		metric.WithDescription("Size of the payloads of the published messages."),
+		metric.WithUnit("By")); err != nil {
+		return nil, err
+	}
+	if m.sendDuration, err = meter.Float64Histogram("messaging.client.operation.duration",
+		metric.WithDescription("Duration of the publish operations."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...)); err != nil {
+		return nil, err
+	}
+	if m.sendActive, err = meter.Int64UpDownCounter("messaging.client.sent.active",
+		metric.WithDescription("Number of publish operations in flight."),
+		metric.WithUnit("{message}")); err != nil {
+		return nil, err
+	}
+	if m.consumedMessage, err = meter.Int64Counter("messaging.client.consumed.messages",
+		metric.WithDescription("Number of messages delivered to the handlers."),
+		metric.WithUnit("{message}")); err != nil {
+		return nil, err
+	}
+	if m.consumedSize, err = meter.Int64Histogram("messaging.client.consumed.message.size",
+		metric.WithDescription("Size of the payloads of the consumed messages."),
+		metric.WithUnit("By")); err != nil {
+		return nil, err
+	}
+	if m.processDuration, err = meter.Float64Histogram("messaging.process.duration",
+		metric.WithDescription("Duration of the handler invocations."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...)); err != nil {
+		return nil, err
+	}
+	if m.processActive, err = meter.Int64UpDownCounter("messaging.process.active",
+		metric.WithDescription("Number of handler invocations in flight."),
+		metric.WithUnit("{message}")); err != nil {
+		return nil, err
+	}
+	if m.processErrors, err = meter.Int64Counter("messaging.process.errors",
+		metric.WithDescription("Number of handler invocations which failed."),
+		metric.WithUnit("{message}")); err != nil {
+		return nil, err
+	}
+	if m.processRetries, err = meter.Int64Counter("messaging.process.retries",
+		metric.WithDescription("Number of handler invocations retrying a failed one (see SubscribeOptions.MaxRetries)."),
+		metric.WithUnit("{message}")); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) attributes(topic, operation string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 4)
+	if m.options.system != "" {
+		attrs = append(attrs, attrMessagingSystem.String(m.options.system))
+	}
+	return append(attrs,
+		attrMessagingDestinationName.String(topic),
+		attrMessagingOperationName.String(operation),
+	)
+}
+
+func errorType(err error) attribute.KeyValue {
+	return attrErrorType.String(fmt.Sprintf("%T", err))
+}
+
+// PublishMiddleware returns a PublishMiddleware recording the published messages,
+// their payload size, the publish duration and the publishes in flight.
+func (m *Metrics) PublishMiddleware() PublishMiddleware {
+	return func(next PublishHandler) PublishHandler {
+		return func(ctx context.Context, topic string, msg *Message, opts ...PublishOption) error {
+			attrs := m.attributes(topic, "send")
+			set := metric.WithAttributes(attrs...)
+
+			// a typed body is measured once the broker has encoded it
+			var encoded *atomic.Int64
+			if m.options.payloadSize != nil {
+				if size := m.options.payloadSize(msg); size >= 0 {
+					m.sentSize.Record(ctx, int64(size), set)
+				}
+			} else if size := rawPayloadSize(msg); size >= 0 {
+				m.sentSize.Record(ctx, int64(size), set)
+			} else {
+				encoded = new(atomic.Int64)
+				encoded.Store(-1)
+				ctx = context.WithValue(ctx, payloadSizeKey{}, encoded)
+			}
+
+			m.sendActive.Add(ctx, 1, set)
+			start := time.Now()
+
+			err := next(ctx, topic, msg, opts...)
+
+			m.sendActive.Add(ctx, -1, set)
+			if encoded != nil {
+				if size := encoded.Load(); size >= 0 {
+					m.sentSize.Record(ctx, size, set)
+				}
+			}
+			if err != nil {
+				set = metric.WithAttributes(append(attrs, errorType(err))...)
+			}
+			m.sendDuration.Record(ctx, time.Since(start).Seconds(), set)
+			m.sentMessages.Add(ctx, 1, set)
+
+			return err
+		}
+	}
+}
+
+// SubscriberMiddleware returns a SubscriberMiddleware recording the consumed messages,
+// their payload size, the handler duration, the handlers in flight, the failures and the retries.
+func (m *Metrics) SubscriberMiddleware() SubscriberMiddleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, evt Event) error {
+			if evt == nil {
+				return next(ctx, evt)
+			}
+
+			attrs := m.attributes(evt.Topic(), "process")
+			set := metric.WithAttributes(attrs...)
+
+			if AttemptFromContext(ctx) > 1 {
+				m.processRetries.Add(ctx, 1, set)
+			} else {
+				m.consumedMessage.Add(ctx, 1, set)
+				if size := m.consumedPayloadSize(evt); size >= 0 {
+					m.consumedSize.Record(ctx, int64(size), set)
+				}
+			}
+
+			m.processActive.Add(ctx, 1, set)
+			start := time.Now()
+
+			err := next(ctx, evt)
+
+			m.processActive.Add(ctx, -1, set)
+			if err != nil {
+				set = metric.WithAttributes(append(attrs, errorType(err))...)
+				m.processErrors.Add(ctx, 1, set)
+			}
+			m.processDuration.Record(ctx, time.Since(start).Seconds(), set)
+
+			return err
+		}
+	}
+}
+
+// consumedPayloadSize returns the size of the payload of evt as it was received, when it is known
+func (m *Metrics) consumedPayloadSize(evt Event) int {
+	if m.options.payloadSize != nil {
+		return m.options.payloadSize(evt.Message())
+	}
+	if payload, ok := Payload(evt); ok {
+		return len(payload)
+	}
+	return rawPayloadSize(evt.Message())
+}
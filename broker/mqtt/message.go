@@ -0,0 +1,100 @@
+package mqtt
+
+import (
+	paho5 "github.com/eclipse/paho.golang/paho"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+const (
+	// UserPropertyMessageID carries the message ID among the user properties of an MQTT 5 message.
+	UserPropertyMessageID = "x-message-id"
+
+	// headerContentType is mapped onto the content type property of an MQTT 5 message.
+	headerContentType = "content-type"
+)
+
+var _ propagation.TextMapCarrier = (*MessageCarrier)(nil)
+
+// MessageCarrier carries the trace context in the headers of a message, which reach the
+// consumers in an envelope over MQTT 3 (see broker.WithEnvelope), as user properties over MQTT 5.
+type MessageCarrier struct {
+	msg *broker.Message
+}
+
+func NewMessageCarrier(msg *broker.Message) MessageCarrier {
+	return MessageCarrier{msg: msg}
+}
+
+func (c MessageCarrier) Get(key string) string {
+	return c.msg.GetHeader(key)
+}
+
+func (c MessageCarrier) Set(key, val string) {
+	c.msg.SetHeader(key, val)
+}
+
+func (c MessageCarrier) Keys() []string {
+	out := make([]string, 0, len(c.msg.Headers))
+	for k := range c.msg.Headers {
+		out = append(out, k)
+	}
+	return out
+}
+
+// publishProperties maps the ID and headers of msg onto the properties of an MQTT 5 message:
+// the request/reply headers become the response topic and the correlation data, the content-type
+// header the content type, and the other headers user properties.
+func publishProperties(msg *broker.Message) *paho5.PublishProperties {
+	props := &paho5.PublishProperties{}
+
+	if msg.ID != "" {
+		props.User.Add(UserPropertyMessageID, msg.ID)
+	}
+
+	for k, v := range msg.Headers {
+		switch k {
+		case broker.HeaderReplyTo:
+			props.ResponseTopic = v
+		case broker.HeaderCorrelationID:
+			props.CorrelationData = []byte(v)
+		case headerContentType:
+			props.ContentType = v
+		default:
+			props.User.Add(k, v)
+		}
+	}
+
+	return props
+}
+
+// messageFromPublish builds the message received in an MQTT 5 PUBLISH packet, the reverse of publishProperties.
+func messageFromPublish(pb *paho5.Publish) *broker.Message {
+	msg := &broker.Message{}
+
+	props := pb.Properties
+	if props == nil {
+		return msg
+	}
+
+	for _, u := range props.User {
+		if u.Key == UserPropertyMessageID {
+			msg.ID = u.Value
+			continue
+		}
+		msg.SetHeader(u.Key, u.Value)
+	}
+
+	if props.ResponseTopic != "" {
+		msg.SetHeader(broker.HeaderReplyTo, props.ResponseTopic)
+	}
+	if len(props.CorrelationData) > 0 {
+		msg.SetHeader(broker.HeaderCorrelationID, string(props.CorrelationData))
+	}
+	if props.ContentType != "" {
+		msg.SetHeader(headerContentType, props.ContentType)
+	}
+
+	return msg
+}
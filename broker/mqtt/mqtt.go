@@ -18,6 +18,10 @@ type mqttBroker struct {
 
 	subscribers *broker.SubscriberSyncMap
 
+	requester *broker.Requester
+
+	tracers
+
 	OnConnectCallback    func()
 	OnDisconnectCallback func(error)
 }
@@ -136,13 +140,19 @@ func newClient(addrs []string, opts broker.Options, b *mqttBroker) paho.Client {
 func newBroker(opts ...broker.Option) broker.Broker {
 	options := broker.NewOptionsAndApply(opts...)
 
+	if protocolVersion(options) == 5 {
+		return newBroker5(options)
+	}
+
 	b := &mqttBroker{
 		options:     options,
 		addrs:       options.Addrs,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	b.requester = broker.NewRequester(b)
 
 	b.client = newClient(options.Addrs, options, b)
+	b.initTracer(options)
 
 	return b
 }
@@ -168,8 +178,13 @@ func (m *mqttBroker) Init(opts ...broker.Option) error {
 		o(&m.options)
 	}
 
+	if protocolVersion(m.options) == 5 {
+		return errProtocolVersionChanged
+	}
+
 	m.addrs = setAddrs(m.options.Addrs)
 	m.client = newClient(m.addrs, m.options, m)
+	m.initTracer(m.options)
 	return nil
 }
 
@@ -191,6 +206,9 @@ func (m *mqttBroker) Disconnect() error {
 	if !m.client.IsConnected() {
 		return nil
 	}
+
+	_ = m.requester.Close()
+
 	m.client.Disconnect(0)
 
 	m.subscribers.Clear()
@@ -199,7 +217,7 @@ func (m *mqttBroker) Disconnect() error {
 }
 
 func (m *mqttBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return m.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (m *mqttBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -217,11 +235,18 @@ func (m *mqttBroker) internalPublish(ctx context.Context, topic string, msg *bro
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
 
-	return m.publish(ctx, topic, sendMsg, opts...)
+	ctx, span := m.startProducerSpan(ctx, topic, sendMsg)
+
+	err = m.publish(ctx, topic, sendMsg, opts...)
+
+	m.finishProducerSpan(ctx, span, err)
+
+	return err
 }
 
 func (m *mqttBroker) publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -246,7 +271,14 @@ func (m *mqttBroker) publish(ctx context.Context, topic string, msg *broker.Mess
 		retained = value
 	}
 
-	ret := m.client.Publish(topic, qos, retained, msg.Body)
+	payload := msg.Body
+	// MQTT 3.1.1 has no message headers: requests and replies always carry theirs in an envelope,
+	// the other messages only with broker.WithEnvelope
+	if broker.EnvelopeEnabled(m.options) || broker.IsRequestReply(msg) {
+		payload = (&broker.Envelope{ID: msg.ID, Headers: msg.Headers, Body: msg.BodyBytes()}).Encode()
+	}
+
+	ret := m.client.Publish(topic, qos, retained, payload)
 	ret.Wait()
 	return ret.Error()
 }
@@ -265,36 +297,58 @@ func (m *mqttBroker) Subscribe(topic string, handler broker.Handler, binder brok
 		options.Context = context.Background()
 	}
 
-	if len(m.options.SubscriberMiddlewares) > 0 {
+	if len(m.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, m.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(m, handler, options)
 
 	var qos byte = 1
 	if value, ok := options.Context.Value(qosSubscribeKey{}).(byte); ok {
 		qos = value
 	}
 
-	callback := func(c paho.Client, mq paho.Message) {
+	onMessage := func(c paho.Client, mq paho.Message) {
 		var msg broker.Message
 
 		p := &publication{topic: mq.Topic(), msg: &msg, raw: mq}
 
+		payload := mq.Payload()
+		if env, err := broker.DecodeEnvelope(payload); err == nil {
+			msg.ID = env.ID
+			msg.Headers = env.Headers
+			payload = env.Body
+		}
+		p.payload = payload
+
+		ctx, span := m.startConsumerSpan(context.Background(), p.topic, &msg)
+
 		if binder != nil {
 			msg.Body = binder()
 
-			if err := broker.Unmarshal(m.options.Codec, mq.Payload(), &msg.Body); err != nil {
+			if err := broker.Unmarshal(m.options.Codec, payload, &msg.Body); err != nil {
 				p.err = err
 				LogError("unmarshal message failed:", err)
+				m.finishConsumerSpan(ctx, span, err)
 				return
 			}
 		} else {
-			msg.Body = mq.Payload()
+			msg.Body = payload
 		}
 
-		if err := handler(context.Background(), p); err != nil {
+		if err := handler(ctx, p); err != nil {
 			p.err = err
 			LogError("handle message failed:", err)
 		}
+
+		m.finishConsumerSpan(ctx, span, p.err)
+	}
+
+	// hand messages to a worker pool so that SubscribeOptions.Concurrency is honoured
+	pool := broker.NewSubscribeWorkerPool(options)
+	callback := func(c paho.Client, mq paho.Message) {
+		pool.Submit(func() {
+			onMessage(c, mq)
+		})
 	}
 
 	if err := m.doSubscribe(topic, qos, callback); err != nil {
@@ -306,6 +360,7 @@ func (m *mqttBroker) Subscribe(topic string, handler broker.Handler, binder brok
 		options:  options,
 		topic:    topic,
 		qos:      qos,
+		pool:     pool,
 		callback: callback,
 	}
 
@@ -0,0 +1,486 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	paho5 "github.com/eclipse/paho.golang/paho"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+const (
+	defaultKeepAlive5            = 30 * time.Second
+	defaultConnectTimeout5       = 10 * time.Second
+	defaultConnectRetryInterval5 = 1 * time.Second
+	defaultMaxReconnectInterval5 = 30 * time.Second
+	defaultDisconnectTimeout5    = 5 * time.Second
+)
+
+var (
+	errProtocolVersionChanged = errors.New("the protocol version cannot be changed by Init, pass WithProtocolVersion to NewBroker")
+	errConnectionLost         = errors.New("connection lost")
+)
+
+// mqtt5Broker speaks MQTT 5 through paho.golang, it is selected with WithProtocolVersion(5).
+//
+// The message ID and headers travel as user properties; the reply topic and correlation ID set by
+// broker.Requester travel as the response topic and the correlation data of the message, so that
+// requests can be answered by any MQTT 5 client and broker.Reply answers the requests of any MQTT 5 client.
+type mqtt5Broker struct {
+	sync.RWMutex
+
+	addrs   []string
+	options broker.Options
+
+	cm     *autopaho.ConnectionManager
+	cancel context.CancelFunc
+
+	subscribers *broker.SubscriberSyncMap
+
+	requester *broker.Requester
+
+	tracers
+
+	OnConnectCallback    func()
+	OnDisconnectCallback func(error)
+}
+
+func newBroker5(options broker.Options) broker.Broker {
+	b := &mqtt5Broker{
+		options:     options,
+		addrs:       options.Addrs,
+		subscribers: broker.NewSubscriberSyncMap(),
+	}
+	b.requester = broker.NewRequester(b)
+
+	b.initCallbacks()
+	b.initTracer(options)
+
+	return b
+}
+
+func (m *mqtt5Broker) Name() string {
+	return "mqtt"
+}
+
+func (m *mqtt5Broker) Options() broker.Options {
+	return m.options
+}
+
+func (m *mqtt5Broker) Address() string {
+	return strings.Join(m.addrs, ",")
+}
+
+func (m *mqtt5Broker) Init(opts ...broker.Option) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.cm != nil {
+		return errors.New("cannot init while connected")
+	}
+
+	for _, o := range opts {
+		o(&m.options)
+	}
+
+	if protocolVersion(m.options) != 5 {
+		return errProtocolVersionChanged
+	}
+
+	m.addrs = setAddrs(m.options.Addrs)
+	m.initCallbacks()
+	m.initTracer(m.options)
+	return nil
+}
+
+func (m *mqtt5Broker) initCallbacks() {
+	if cb, ok := m.options.Context.Value(onConnectedKey{}).(func()); ok {
+		m.SetOnConnectCallback(cb)
+	}
+	if cb, ok := m.options.Context.Value(onDisconnectKey{}).(func(error)); ok {
+		m.SetOnDisconnectCallback(cb)
+	}
+}
+
+func (m *mqtt5Broker) Connect() error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.cm != nil {
+		return nil
+	}
+
+	cfg, err := m.clientConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cm, err := autopaho.NewConnection(ctx, cfg)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	awaitCtx, awaitCancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer awaitCancel()
+
+	if err = cm.AwaitConnection(awaitCtx); err != nil {
+		cancel()
+		<-cm.Done()
+		return fmt.Errorf("connect to %s: %w", m.Address(), err)
+	}
+
+	m.cm = cm
+	m.cancel = cancel
+
+	return nil
+}
+
+// clientConfig builds the autopaho configuration from the options shared with the MQTT 3 broker.
+func (m *mqtt5Broker) clientConfig() (autopaho.ClientConfig, error) {
+	opts := m.options
+
+	cfg := autopaho.ClientConfig{
+		TlsCfg:                        opts.TLSConfig,
+		KeepAlive:                     uint16(defaultKeepAlive5 / time.Second),
+		CleanStartOnInitialConnection: true,
+		ConnectTimeout:                defaultConnectTimeout5,
+		OnConnectionUp:                m.onConnectionUp,
+		OnConnectionDown:              m.onConnectionDown,
+		OnConnectError: func(err error) {
+			LogError("connect failed:", err)
+		},
+		ClientConfig: paho5.ClientConfig{
+			ClientID:          generateClientId(),
+			OnPublishReceived: []func(paho5.PublishReceived) (bool, error){m.onPublishReceived},
+			OnClientError: func(err error) {
+				LogError("client error:", err)
+			},
+		},
+	}
+
+	for _, addr := range setAddrs(m.addrs) {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.ServerUrls = append(cfg.ServerUrls, u)
+	}
+
+	if auth, ok := opts.Context.Value(authKey{}).(*AuthRecord); ok && auth != nil {
+		cfg.ConnectUsername = auth.Username
+		cfg.ConnectPassword = []byte(auth.Password)
+	}
+	if clientId, ok := opts.Context.Value(clientIdKey{}).(string); ok && clientId != "" {
+		cfg.ClientID = clientId
+	}
+
+	if k, ok := opts.Context.Value(keepAliveKey{}).(time.Duration); ok {
+		cfg.KeepAlive = uint16(k / time.Second)
+	}
+	if k, ok := opts.Context.Value(connectTimeoutKey{}).(time.Duration); ok && k > 0 {
+		cfg.ConnectTimeout = k
+	}
+	if k, ok := opts.Context.Value(writeTimeoutKey{}).(time.Duration); ok {
+		cfg.PacketTimeout = k
+	}
+	if enabled, ok := opts.Context.Value(cleanSessionKey{}).(bool); ok {
+		cfg.CleanStartOnInitialConnection = enabled
+	}
+	if k, ok := opts.Context.Value(sessionExpiryIntervalKey{}).(time.Duration); ok {
+		cfg.SessionExpiryInterval = uint32(k / time.Second)
+	}
+
+	retryInterval := defaultConnectRetryInterval5
+	maxInterval := defaultMaxReconnectInterval5
+	if k, ok := opts.Context.Value(connectRetryIntervalKey{}).(time.Duration); ok && k > 0 {
+		retryInterval = k
+	}
+	if k, ok := opts.Context.Value(maxReconnectIntervalKey{}).(time.Duration); ok && k > 0 {
+		maxInterval = k
+	}
+	if maxInterval > retryInterval {
+		cfg.ReconnectBackoff = autopaho.NewExponentialBackoff(retryInterval, maxInterval, retryInterval, 2)
+	} else {
+		cfg.ReconnectBackoff = autopaho.NewConstantBackoff(retryInterval)
+	}
+
+	var errorLogger, debugLogger bool
+	_, errorLogger = opts.Context.Value(errorLoggerKey{}).(bool)
+	_, debugLogger = opts.Context.Value(debugLoggerKey{}).(bool)
+	if opt, ok := opts.Context.Value(loggerKey{}).(LoggerOptions); ok {
+		errorLogger = errorLogger || opt.Error
+		debugLogger = debugLogger || opt.Debug
+	}
+	if errorLogger {
+		cfg.Errors = ErrorLogger{}
+		cfg.PahoErrors = ErrorLogger{}
+	}
+	if debugLogger {
+		cfg.Debug = DebugLogger{}
+		cfg.PahoDebug = DebugLogger{}
+	}
+
+	return cfg, nil
+}
+
+func (m *mqtt5Broker) Disconnect() error {
+	cm := m.connection()
+	if cm == nil {
+		return nil
+	}
+
+	_ = m.requester.Close()
+
+	m.subscribers.Clear()
+
+	m.Lock()
+	cancel := m.cancel
+	m.cm, m.cancel = nil, nil
+	m.Unlock()
+
+	ctx, cancelDisconnect := context.WithTimeout(context.Background(), defaultDisconnectTimeout5)
+	defer cancelDisconnect()
+
+	err := cm.Disconnect(ctx)
+	cancel()
+
+	return err
+}
+
+func (m *mqtt5Broker) connection() *autopaho.ConnectionManager {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.cm
+}
+
+func (m *mqtt5Broker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
+	return m.requester.Request(ctx, topic, msg, opts...)
+}
+
+func (m *mqtt5Broker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	var finalTask = m.internalPublish
+
+	if len(m.options.PublishMiddlewares) > 0 {
+		finalTask = broker.ChainPublishMiddleware(finalTask, m.options.PublishMiddlewares)
+	}
+
+	return finalTask(ctx, topic, msg, opts...)
+}
+
+func (m *mqtt5Broker) internalPublish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	buf, err := broker.Marshal(m.options.Codec, msg.Body)
+	if err != nil {
+		return err
+	}
+	broker.RecordPayloadSize(ctx, len(buf))
+
+	sendMsg := msg.Clone()
+	sendMsg.Body = buf
+
+	ctx, span := m.startProducerSpan(ctx, topic, sendMsg)
+
+	err = m.publish(ctx, topic, sendMsg, opts...)
+
+	m.finishProducerSpan(ctx, span, err)
+
+	return err
+}
+
+func (m *mqtt5Broker) publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	cm := m.connection()
+	if cm == nil {
+		return errors.New("not connected")
+	}
+
+	options := broker.PublishOptions{
+		Context: ctx,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var qos byte = 1
+	var retained = false
+
+	if value, ok := options.Context.Value(qosPublishKey{}).(byte); ok {
+		qos = value
+	}
+	if value, ok := options.Context.Value(retainedPublishKey{}).(bool); ok {
+		retained = value
+	}
+
+	pb := &paho5.Publish{
+		QoS:        qos,
+		Retain:     retained,
+		Topic:      topic,
+		Payload:    msg.BodyBytes(),
+		Properties: publishProperties(msg),
+	}
+
+	if value, ok := options.Context.Value(messageExpiryPublishKey{}).(time.Duration); ok && value > 0 {
+		expiry := uint32(value / time.Second)
+		pb.Properties.MessageExpiry = &expiry
+	}
+
+	_, err := cm.Publish(ctx, pb)
+	return err
+}
+
+// Subscribe subscribes to topic, through the shared subscription $share/<queue>/<topic>
+// when SubscribeOptions.Queue is set.
+func (m *mqtt5Broker) Subscribe(topic string, handler broker.Handler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	cm := m.connection()
+	if cm == nil {
+		return nil, errors.New("not connected")
+	}
+
+	var options broker.SubscribeOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Context == nil {
+		options.Context = context.Background()
+	}
+
+	if len(m.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
+		handler = broker.ChainSubscriberMiddleware(handler, m.options.SubscriberMiddlewares)
+	}
+	handler = broker.WrapSubscribeHandler(m, handler, options)
+
+	var qos byte = 1
+	if value, ok := options.Context.Value(qosSubscribeKey{}).(byte); ok {
+		qos = value
+	}
+
+	filter := topic
+	if options.Queue != "" {
+		filter = "$share/" + options.Queue + "/" + topic
+	}
+
+	// hand messages to a worker pool so that SubscribeOptions.Concurrency is honoured
+	pool := broker.NewSubscribeWorkerPool(options)
+
+	sub := &subscriber5{
+		m:       m,
+		options: options,
+		topic:   topic,
+		filter:  filter,
+		qos:     qos,
+		pool:    pool,
+	}
+	sub.callback = func(pb *paho5.Publish) {
+		pool.Submit(func() {
+			m.onMessage(pb, handler, binder)
+		})
+	}
+
+	// register the subscriber first, the retained messages are sent right after the SUBACK
+	m.subscribers.Add(topic, sub)
+
+	if err := m.doSubscribe(options.Context, cm, sub); err != nil {
+		_ = m.subscribers.RemoveOnly(topic)
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (m *mqtt5Broker) doSubscribe(ctx context.Context, cm *autopaho.ConnectionManager, sub *subscriber5) error {
+	_, err := cm.Subscribe(ctx, &paho5.Subscribe{
+		Subscriptions: []paho5.SubscribeOptions{
+			{Topic: sub.filter, QoS: sub.qos},
+		},
+	})
+	return err
+}
+
+func (m *mqtt5Broker) onMessage(pb *paho5.Publish, handler broker.Handler, binder broker.Binder) {
+	msg := messageFromPublish(pb)
+
+	p := &publication{topic: pb.Topic, msg: msg, raw: pb, payload: pb.Payload}
+
+	ctx, span := m.startConsumerSpan(context.Background(), p.topic, msg)
+
+	if binder != nil {
+		msg.Body = binder()
+
+		if err := broker.Unmarshal(m.options.Codec, pb.Payload, &msg.Body); err != nil {
+			p.err = err
+			LogError("unmarshal message failed:", err)
+			m.finishConsumerSpan(ctx, span, err)
+			return
+		}
+	} else {
+		msg.Body = pb.Payload
+	}
+
+	if err := handler(ctx, p); err != nil {
+		p.err = err
+		LogError("handle message failed:", err)
+	}
+
+	m.finishConsumerSpan(ctx, span, p.err)
+}
+
+// onPublishReceived routes a received message to the subscribers whose filter matches its topic.
+func (m *mqtt5Broker) onPublishReceived(pr paho5.PublishReceived) (bool, error) {
+	var handled bool
+
+	m.subscribers.Foreach(func(_ string, sub broker.Subscriber) {
+		aSub := sub.(*subscriber5)
+		if aSub.IsClosed() || !matchTopic(aSub.filter, pr.Packet.Topic) {
+			return
+		}
+		handled = true
+		aSub.callback(pr.Packet)
+	})
+
+	return handled, nil
+}
+
+func (m *mqtt5Broker) onConnectionUp(cm *autopaho.ConnectionManager, _ *paho5.Connack) {
+	LogDebug("on connect")
+
+	// the callback must not block, and the first connection has no subscriber yet
+	go func() {
+		if m.OnConnectCallback != nil {
+			m.OnConnectCallback()
+		}
+
+		m.subscribers.Foreach(func(topic string, sub broker.Subscriber) {
+			aSub := sub.(*subscriber5)
+			if err := m.doSubscribe(context.Background(), cm, aSub); err != nil {
+				LogError("mqtt broker subscribe message failed:", err)
+			}
+		})
+	}()
+}
+
+func (m *mqtt5Broker) onConnectionDown() bool {
+	LogDebug("on connect lost, try to reconnect")
+	if m.OnDisconnectCallback != nil {
+		go m.OnDisconnectCallback(errConnectionLost)
+	}
+	return true
+}
+
+// SetOnConnectCallback sets the callback to be called when connected.
+func (m *mqtt5Broker) SetOnConnectCallback(cb func()) {
+	m.OnConnectCallback = cb
+}
+
+// SetOnDisconnectCallback sets the callback to be called when disconnected.
+func (m *mqtt5Broker) SetOnDisconnectCallback(cb func(error)) {
+	m.OnDisconnectCallback = cb
+}
@@ -0,0 +1,164 @@
+package mqtt
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// startEmbeddedBroker starts an in-process MQTT 5 server on a free port and returns its address.
+func startEmbeddedBroker(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	server := mochi.New(&mochi.Options{})
+	if err = server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("add hook: %v", err)
+	}
+	if err = server.AddListener(listeners.NewTCP(listeners.Config{ID: "tcp", Address: addr})); err != nil {
+		t.Fatalf("add listener: %v", err)
+	}
+	if err = server.Serve(); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	return "tcp://" + addr
+}
+
+func newConnectedBroker5(t *testing.T, addr string, opts ...broker.Option) broker.Broker {
+	t.Helper()
+
+	b := NewBroker(append([]broker.Option{
+		broker.WithAddress(addr),
+		WithProtocolVersion(5),
+	}, opts...)...)
+
+	if err := b.Init(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if err := b.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Disconnect() })
+
+	return b
+}
+
+func Test_MQTT5_HeadersAsUserProperties(t *testing.T) {
+	addr := startEmbeddedBroker(t)
+	b := newConnectedBroker5(t, addr)
+
+	got := make(chan broker.Event, 1)
+	_, err := b.Subscribe("sensors/+/temperature", func(_ context.Context, evt broker.Event) error {
+		got <- evt
+		return nil
+	}, nil, WithSubscribeQos(1))
+	assert.Nil(t, err)
+
+	msg := broker.NewMessage(broker.RawBody(`{"t":21.5}`), broker.WithID("m-1"))
+	msg.SetHeader("tenant", "acme")
+	msg.SetHeader("content-type", "application/json")
+	err = b.Publish(context.Background(), "sensors/kitchen/temperature", msg, WithPublishMessageExpiry(time.Minute))
+	assert.Nil(t, err)
+
+	select {
+	case evt := <-got:
+		assert.Equal(t, "sensors/kitchen/temperature", evt.Topic())
+		assert.Equal(t, "m-1", evt.Message().ID)
+		assert.Equal(t, "acme", evt.Message().GetHeader("tenant"))
+		assert.Equal(t, "application/json", evt.Message().GetHeader("content-type"))
+		assert.Equal(t, `{"t":21.5}`, string(evt.Message().Body.([]byte)))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+}
+
+func Test_MQTT5_Request(t *testing.T) {
+	addr := startEmbeddedBroker(t)
+	responder := newConnectedBroker5(t, addr)
+	requester := newConnectedBroker5(t, addr)
+
+	_, err := responder.Subscribe("svc/echo", func(ctx context.Context, evt broker.Event) error {
+		req := evt.Message()
+		if req.GetHeader(broker.HeaderReplyTo) == "" || req.GetHeader(broker.HeaderCorrelationID) == "" {
+			t.Error("expected the response topic and the correlation data")
+		}
+		return broker.Reply(ctx, responder, evt, broker.NewMessage(broker.RawBody("echo: "+string(req.Body.([]byte)))))
+	}, nil)
+	assert.Nil(t, err)
+
+	resp, err := requester.Request(context.Background(), "svc/echo", broker.NewMessage(broker.RawBody("hello")),
+		broker.WithRequestTimeout(5*time.Second))
+	assert.Nil(t, err)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, "echo: hello", string(resp.Body.([]byte)))
+	}
+}
+
+func Test_MQTT5_SharedSubscription(t *testing.T) {
+	addr := startEmbeddedBroker(t)
+	b := newConnectedBroker5(t, addr)
+
+	got := make(chan string, 4)
+	for _, name := range []string{"a", "b"} {
+		_, err := b.Subscribe("jobs/"+name, func(_ context.Context, evt broker.Event) error {
+			got <- evt.Topic()
+			return nil
+		}, nil, broker.WithSubscribeQueueName("workers"))
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, b.Publish(context.Background(), "jobs/a", broker.NewMessage(broker.RawBody("1"))))
+
+	select {
+	case topic := <-got:
+		assert.Equal(t, "jobs/a", topic)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+}
+
+func Test_MQTT5_InitCannotChangeProtocolVersion(t *testing.T) {
+	b := NewBroker(WithProtocolVersion(5))
+	assert.ErrorIs(t, b.Init(WithProtocolVersion(4)), errProtocolVersionChanged)
+
+	b = NewBroker()
+	assert.ErrorIs(t, b.Init(WithProtocolVersion(5)), errProtocolVersionChanged)
+}
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "$SYS/uptime", false},
+		{"$share/g/a/+", "a/b", true},
+		{"$share/g/a/+", "b/b", false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, matchTopic(c.filter, c.topic), "%s %s", c.filter, c.topic)
+	}
+}
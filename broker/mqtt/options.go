@@ -30,6 +30,7 @@ type debugLoggerKey struct{}
 type loggerKey struct{}
 type onConnectedKey struct{}
 type onDisconnectKey struct{}
+type sessionExpiryIntervalKey struct{}
 
 type AuthRecord struct {
 	Username string
@@ -106,11 +107,26 @@ func WithPingTimeout(k time.Duration) broker.Option {
 	return broker.OptionContextWithValue(pingTimeoutKey{}, k)
 }
 
-// WithProtocolVersion .
+// WithProtocolVersion sets the MQTT protocol version: 3 (3.1), 4 (3.1.1) or 5.
+// Version 5 selects the MQTT 5 implementation, it must be given to NewBroker and cannot be changed by Init.
 func WithProtocolVersion(pv uint) broker.Option {
 	return broker.OptionContextWithValue(protocolVersionKey{}, pv)
 }
 
+// WithSessionExpiryInterval sets how long the server keeps the session after the connection is closed, MQTT 5 only.
+func WithSessionExpiryInterval(d time.Duration) broker.Option {
+	return broker.OptionContextWithValue(sessionExpiryIntervalKey{}, d)
+}
+
+// protocolVersion returns the version set by WithProtocolVersion, 0 when unset.
+func protocolVersion(opts broker.Options) uint {
+	if opts.Context == nil {
+		return 0
+	}
+	pv, _ := opts.Context.Value(protocolVersionKey{}).(uint)
+	return pv
+}
+
 func WithErrorLogger() broker.Option {
 	return broker.OptionContextWithValue(errorLoggerKey{}, true)
 }
@@ -156,6 +172,7 @@ func WithSubscribeQos(qos byte) broker.SubscribeOption {
 
 type qosPublishKey struct{}
 type retainedPublishKey struct{}
+type messageExpiryPublishKey struct{}
 
 // WithPublishQos QOS
 func WithPublishQos(qos byte) broker.PublishOption {
@@ -166,3 +183,8 @@ func WithPublishQos(qos byte) broker.PublishOption {
 func WithPublishRetained(retained bool) broker.PublishOption {
 	return broker.PublishContextWithValue(retainedPublishKey{}, retained)
 }
+
+// WithPublishMessageExpiry sets the lifetime of the message on the server, MQTT 5 only.
+func WithPublishMessageExpiry(d time.Duration) broker.PublishOption {
+	return broker.PublishContextWithValue(messageExpiryPublishKey{}, d)
+}
@@ -1,15 +1,17 @@
 package mqtt
 
 import (
-	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/tx7do/kratos-transport/broker"
 )
 
 type publication struct {
 	topic string
 	msg   *broker.Message
-	raw   paho.Message
-	err   error
+	// raw is the paho.Message received over MQTT 3, the *paho5.Publish received over MQTT 5
+	raw any
+	// payload is the body of the message, without the envelope of MQTT 3
+	payload []byte
+	err     error
 }
 
 func (p *publication) Ack() error {
@@ -31,3 +33,8 @@ func (p *publication) Message() *broker.Message {
 func (p *publication) RawMessage() any {
 	return p.raw
 }
+
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	return p.payload
+}
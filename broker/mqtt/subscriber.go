@@ -13,6 +13,7 @@ type subscriber struct {
 	options broker.SubscribeOptions
 	m       *mqttBroker
 
+	pool   *broker.WorkerPool
 	closed bool
 	topic  string
 	qos    byte
@@ -36,7 +37,6 @@ func (s *subscriber) Topic() string {
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
 	s.Lock()
-	defer s.Unlock()
 
 	var err error
 
@@ -46,6 +46,10 @@ func (s *subscriber) Unsubscribe(removeFromManager bool) error {
 	}
 
 	s.closed = true
+	s.Unlock()
+
+	// no message is delivered once unsubscribed, the handlers still running are waited for
+	s.pool.Drain()
 
 	if s.m != nil && s.m.subscribers != nil && removeFromManager {
 		_ = s.m.subscribers.RemoveOnly(s.topic)
@@ -0,0 +1,69 @@
+package mqtt
+
+import (
+	"context"
+	"sync"
+
+	paho5 "github.com/eclipse/paho.golang/paho"
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+type subscriber5 struct {
+	sync.RWMutex
+
+	options broker.SubscribeOptions
+	m       *mqtt5Broker
+
+	pool   *broker.WorkerPool
+	closed bool
+	topic  string
+	filter string
+	qos    byte
+
+	callback func(*paho5.Publish)
+}
+
+func (s *subscriber5) Options() broker.SubscribeOptions {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.options
+}
+
+func (s *subscriber5) Topic() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.topic
+}
+
+func (s *subscriber5) Unsubscribe(removeFromManager bool) error {
+	s.Lock()
+
+	var err error
+
+	if s.m != nil {
+		if cm := s.m.connection(); cm != nil {
+			_, err = cm.Unsubscribe(context.Background(), &paho5.Unsubscribe{Topics: []string{s.filter}})
+		}
+	}
+
+	s.closed = true
+	s.Unlock()
+
+	// no message is delivered once unsubscribed, the handlers still running are waited for
+	s.pool.Drain()
+
+	if s.m != nil && s.m.subscribers != nil && removeFromManager {
+		_ = s.m.subscribers.RemoveOnly(s.topic)
+	}
+
+	return err
+}
+
+func (s *subscriber5) IsClosed() bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.closed
+}
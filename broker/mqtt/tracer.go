@@ -0,0 +1,94 @@
+package mqtt
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semConv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tx7do/kratos-transport/broker"
+	"github.com/tx7do/kratos-transport/tracing"
+)
+
+const (
+	TracerMessageSystemKey = "mqtt"
+	SpanNameProducer       = "mqtt-producer"
+	SpanNameConsumer       = "mqtt-consumer"
+)
+
+// tracers holds the producer and consumer tracers shared by the MQTT 3 and MQTT 5 brokers.
+type tracers struct {
+	producerTracer *tracing.Tracer
+	consumerTracer *tracing.Tracer
+}
+
+func (m *tracers) initTracer(options broker.Options) {
+	if len(options.Tracings) > 0 {
+		m.producerTracer = tracing.NewTracer(trace.SpanKindProducer, SpanNameProducer, options.Tracings...)
+		m.consumerTracer = tracing.NewTracer(trace.SpanKindConsumer, SpanNameConsumer, options.Tracings...)
+	}
+}
+
+// startProducerSpan injects the trace context into the headers of msg, which only reach
+// the consumers over MQTT 3 when the message is enveloped (see broker.WithEnvelope),
+// and as user properties over MQTT 5.
+func (m *tracers) startProducerSpan(ctx context.Context, topic string, msg *broker.Message) (context.Context, trace.Span) {
+	if m.producerTracer == nil {
+		return ctx, nil
+	}
+
+	if msg == nil {
+		return ctx, nil
+	}
+
+	carrier := NewMessageCarrier(msg)
+
+	attrs := []attribute.KeyValue{
+		semConv.MessagingSystemKey.String(TracerMessageSystemKey),
+		semConv.MessagingDestinationKindTopic,
+		semConv.MessagingDestinationKey.String(topic),
+	}
+
+	return m.producerTracer.Start(ctx, carrier, attrs...)
+}
+
+func (m *tracers) finishProducerSpan(ctx context.Context, span trace.Span, err error) {
+	if m.producerTracer == nil {
+		return
+	}
+
+	m.producerTracer.End(ctx, span, err)
+}
+
+func (m *tracers) startConsumerSpan(ctx context.Context, topic string, msg *broker.Message) (context.Context, trace.Span) {
+	if m.consumerTracer == nil {
+		return ctx, nil
+	}
+
+	if msg == nil {
+		return ctx, nil
+	}
+
+	carrier := NewMessageCarrier(msg)
+
+	attrs := []attribute.KeyValue{
+		semConv.MessagingSystemKey.String(TracerMessageSystemKey),
+		semConv.MessagingDestinationKindTopic,
+		semConv.MessagingDestinationKey.String(topic),
+		semConv.MessagingOperationReceive,
+	}
+	if msg.ID != "" {
+		attrs = append(attrs, semConv.MessagingMessageIDKey.String(msg.ID))
+	}
+
+	return m.consumerTracer.Start(ctx, carrier, attrs...)
+}
+
+func (m *tracers) finishConsumerSpan(ctx context.Context, span trace.Span, err error) {
+	if m.consumerTracer == nil {
+		return
+	}
+
+	m.consumerTracer.End(ctx, span, err)
+}
@@ -101,3 +101,37 @@ func checkClientToken(token paho.Token) (bool, error) {
 func SetClientIdPrefix(prefix string) {
 	clientIdPrefix = prefix
 }
+
+// matchTopic reports whether topic matches the MQTT topic filter, which may contain
+// the + and # wildcards and be a shared subscription ($share/<group>/<filter>).
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(filter, "$share/") {
+		parts := strings.SplitN(filter, "/", 3)
+		if len(parts) < 3 {
+			return false
+		}
+		filter = parts[2]
+	}
+
+	// wildcards at the first level do not match the topics starting with $
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
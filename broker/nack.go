@@ -0,0 +1,67 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotSupported is returned when the broker of an event cannot perform an operation.
+var ErrNotSupported = errors.New("not supported by the broker")
+
+// Nacker is implemented by the events of brokers which can negatively acknowledge a message
+// (RabbitMQ, Pulsar, SQS, NATS JetStream, GCP Pub/Sub, Azure Service Bus).
+//
+// A nacked message is settled: with auto-ack the broker no longer acknowledges it once the
+// handler returns, nor nacks it again when the handler fails.
+type Nacker interface {
+	// Nack negatively acknowledges the message. With requeue the message is redelivered
+	// as soon as possible; without it the message is not redelivered, and goes to the
+	// dead-letter destination of the backend when one is configured. Backends which cannot
+	// drop a message without acknowledging it return ErrNotSupported for requeue=false.
+	Nack(requeue bool) error
+
+	// NackWithDelay negatively acknowledges the message, which is redelivered after delay.
+	NackWithDelay(delay time.Duration) error
+}
+
+// DeadlineExtender is implemented by the events of brokers which lease messages to a consumer
+// for a limited time (SQS visibility timeout, JetStream ack wait, Azure Service Bus lock),
+// so that a long-running handler keeps the message from being redelivered.
+type DeadlineExtender interface {
+	// ExtendDeadline extends the lease of the message, to d from now when the backend
+	// supports it, by the lease duration of the subscription otherwise.
+	ExtendDeadline(d time.Duration) error
+}
+
+// Nack negatively acknowledges evt when its broker supports it, see Nacker.
+func Nack(evt Event, requeue bool) error {
+	n, ok := evt.(Nacker)
+	if !ok {
+		return fmt.Errorf("nack: %w", ErrNotSupported)
+	}
+	return n.Nack(requeue)
+}
+
+// Reject negatively acknowledges evt without redelivery, i.e. Nack(evt, false).
+func Reject(evt Event) error {
+	return Nack(evt, false)
+}
+
+// NackWithDelay negatively acknowledges evt for redelivery after delay when its broker supports it, see Nacker.
+func NackWithDelay(evt Event, delay time.Duration) error {
+	n, ok := evt.(Nacker)
+	if !ok {
+		return fmt.Errorf("nack with delay: %w", ErrNotSupported)
+	}
+	return n.NackWithDelay(delay)
+}
+
+// ExtendDeadline extends the lease of evt when its broker supports it, see DeadlineExtender.
+func ExtendDeadline(evt Event, d time.Duration) error {
+	e, ok := evt.(DeadlineExtender)
+	if !ok {
+		return fmt.Errorf("extend deadline: %w", ErrNotSupported)
+	}
+	return e.ExtendDeadline(d)
+}
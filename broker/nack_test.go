@@ -0,0 +1,69 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type nackEvent struct {
+	testEvent
+
+	requeue  *bool
+	delay    time.Duration
+	deadline time.Duration
+}
+
+func (e *nackEvent) Nack(requeue bool) error {
+	e.requeue = &requeue
+	return nil
+}
+
+func (e *nackEvent) NackWithDelay(delay time.Duration) error {
+	e.delay = delay
+	return nil
+}
+
+func (e *nackEvent) ExtendDeadline(d time.Duration) error {
+	e.deadline = d
+	return nil
+}
+
+func TestNack_Supported(t *testing.T) {
+	evt := &nackEvent{}
+
+	if err := Reject(evt); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if evt.requeue == nil || *evt.requeue {
+		t.Fatal("expected a nack without requeue")
+	}
+
+	if err := NackWithDelay(evt, time.Second); err != nil {
+		t.Fatalf("nack with delay: %v", err)
+	}
+	if evt.delay != time.Second {
+		t.Fatalf("unexpected delay: %v", evt.delay)
+	}
+
+	if err := ExtendDeadline(evt, time.Minute); err != nil {
+		t.Fatalf("extend deadline: %v", err)
+	}
+	if evt.deadline != time.Minute {
+		t.Fatalf("unexpected deadline: %v", evt.deadline)
+	}
+}
+
+func TestNack_NotSupported(t *testing.T) {
+	evt := &testEvent{}
+
+	if err := Nack(evt, true); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if err := NackWithDelay(evt, time.Second); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if err := ExtendDeadline(evt, time.Second); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
@@ -49,6 +49,8 @@ type jetStreamBroker struct {
 	consumerTracer *tracing.Tracer
 }
 
+var _ broker.BatchSubscriber = (*jetStreamBroker)(nil)
+
 // NewJetStreamBroker creates a new NATS JetStream broker.
 func NewJetStreamBroker(opts ...broker.Option) broker.Broker {
 	options := broker.NewOptionsAndApply(opts...)
@@ -131,6 +133,14 @@ func (b *jetStreamBroker) Connect() error {
 			return fmt.Errorf("failed to create JetStream context: %w", err)
 		}
 		b.js = js
+
+		if err = b.provision(); err != nil {
+			c.Close()
+			b.conn = nil
+			b.js = nil
+			return err
+		}
+
 		b.connected = true
 
 		LogInfof("connected to NATS JetStream at %s", b.Address())
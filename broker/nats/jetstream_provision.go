@@ -0,0 +1,234 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	natsGo "github.com/nats-io/nats.go"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+/// JetStream Stream / Consumer Provisioning
+///////////////////////////////////////////////////////////////////////////////
+
+// DriftError is returned when an existing stream or consumer differs from its declared config
+// and the server refused to update it, e.g. because the storage or retention of a stream
+// cannot be changed. Fields describes each difference as "name: current -> declared".
+type DriftError struct {
+	Stream   string
+	Consumer string
+	Fields   []string
+	Err      error
+}
+
+func (e *DriftError) Error() string {
+	target := "stream " + e.Stream
+	if e.Consumer != "" {
+		target = fmt.Sprintf("consumer %s of stream %s", e.Consumer, e.Stream)
+	}
+	return fmt.Sprintf("nats: %s differs from its declared config (%s): %v", target, strings.Join(e.Fields, ", "), e.Err)
+}
+
+func (e *DriftError) Unwrap() error {
+	return e.Err
+}
+
+// consumerDecl is a consumer declared with WithConsumers
+type consumerDecl struct {
+	stream string
+	config natsGo.ConsumerConfig
+}
+
+// provision creates or updates the streams and consumers declared with WithStreams and WithConsumers
+func (b *jetStreamBroker) provision() error {
+	if streams, ok := b.options.Context.Value(streamsKey{}).([]natsGo.StreamConfig); ok {
+		for i := range streams {
+			if err := ensureStream(b.js, streams[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if consumers, ok := b.options.Context.Value(consumersKey{}).([]consumerDecl); ok {
+		for _, c := range consumers {
+			if err := ensureConsumer(b.js, c.stream, c.config); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ensureSubscribeStream creates or updates the stream of WithSubscribeStream
+func (b *jetStreamBroker) ensureSubscribeStream(options broker.SubscribeOptions) error {
+	cfg, ok := options.Context.Value(subStreamKey{}).(natsGo.StreamConfig)
+	if !ok {
+		return nil
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	return ensureStream(b.js, cfg)
+}
+
+// ensureStream creates the stream of cfg, or updates it when its config differs from cfg.
+// Zero fields of cfg are left as they are on the server.
+func ensureStream(js natsGo.JetStreamManager, cfg natsGo.StreamConfig) error {
+	info, err := js.StreamInfo(cfg.Name)
+	if errors.Is(err, natsGo.ErrStreamNotFound) {
+		if _, err = js.AddStream(&cfg); err != nil {
+			return fmt.Errorf("failed to create stream %s: %w", cfg.Name, err)
+		}
+		LogInfof("created JetStream stream: %s", cfg.Name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get stream %s: %w", cfg.Name, err)
+	}
+
+	merged, drift := mergeStreamConfig(info.Config, cfg)
+	if len(drift) == 0 {
+		return nil
+	}
+
+	if _, err = js.UpdateStream(&merged); err != nil {
+		return &DriftError{Stream: cfg.Name, Fields: drift, Err: err}
+	}
+	LogInfof("updated JetStream stream %s: %s", cfg.Name, strings.Join(drift, ", "))
+
+	return nil
+}
+
+// ensureConsumer creates the durable consumer of cfg on stream, or updates it when its config
+// differs from cfg. Zero fields of cfg are left as they are on the server.
+func ensureConsumer(js natsGo.JetStreamManager, stream string, cfg natsGo.ConsumerConfig) error {
+	name := cfg.Durable
+	if name == "" {
+		name = cfg.Name
+	}
+	if name == "" {
+		return fmt.Errorf("consumer of stream %s must be durable", stream)
+	}
+
+	info, err := js.ConsumerInfo(stream, name)
+	if errors.Is(err, natsGo.ErrConsumerNotFound) {
+		if _, err = js.AddConsumer(stream, &cfg); err != nil {
+			return fmt.Errorf("failed to create consumer %s of stream %s: %w", name, stream, err)
+		}
+		LogInfof("created JetStream consumer %s of stream %s", name, stream)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get consumer %s of stream %s: %w", name, stream, err)
+	}
+
+	merged, drift := mergeConsumerConfig(info.Config, cfg)
+	if len(drift) == 0 {
+		return nil
+	}
+
+	if _, err = js.UpdateConsumer(stream, &merged); err != nil {
+		return &DriftError{Stream: stream, Consumer: name, Fields: drift, Err: err}
+	}
+	LogInfof("updated JetStream consumer %s of stream %s: %s", name, stream, strings.Join(drift, ", "))
+
+	return nil
+}
+
+// mergeStreamConfig applies the non-zero fields of want to have, and describes the fields that changed
+func mergeStreamConfig(have, want natsGo.StreamConfig) (natsGo.StreamConfig, []string) {
+	var drift []string
+
+	mergeField("description", &have.Description, want.Description, &drift)
+	mergeSet("subjects", &have.Subjects, want.Subjects, &drift)
+	mergeField("retention", &have.Retention, want.Retention, &drift)
+	mergeField("max_consumers", &have.MaxConsumers, want.MaxConsumers, &drift)
+	mergeField("max_msgs", &have.MaxMsgs, want.MaxMsgs, &drift)
+	mergeField("max_bytes", &have.MaxBytes, want.MaxBytes, &drift)
+	mergeField("discard", &have.Discard, want.Discard, &drift)
+	mergeField("discard_new_per_subject", &have.DiscardNewPerSubject, want.DiscardNewPerSubject, &drift)
+	mergeField("max_age", &have.MaxAge, want.MaxAge, &drift)
+	mergeField("max_msgs_per_subject", &have.MaxMsgsPerSubject, want.MaxMsgsPerSubject, &drift)
+	mergeField("max_msg_size", &have.MaxMsgSize, want.MaxMsgSize, &drift)
+	mergeField("storage", &have.Storage, want.Storage, &drift)
+	mergeField("num_replicas", &have.Replicas, want.Replicas, &drift)
+	mergeField("no_ack", &have.NoAck, want.NoAck, &drift)
+	mergeField("duplicate_window", &have.Duplicates, want.Duplicates, &drift)
+	mergeField("deny_delete", &have.DenyDelete, want.DenyDelete, &drift)
+	mergeField("deny_purge", &have.DenyPurge, want.DenyPurge, &drift)
+	mergeField("allow_rollup_hdrs", &have.AllowRollup, want.AllowRollup, &drift)
+	mergeField("compression", &have.Compression, want.Compression, &drift)
+	mergeField("allow_direct", &have.AllowDirect, want.AllowDirect, &drift)
+	mergeField("allow_msg_ttl", &have.AllowMsgTTL, want.AllowMsgTTL, &drift)
+
+	return have, drift
+}
+
+// mergeConsumerConfig applies the non-zero fields of want to have, and describes the fields that changed
+func mergeConsumerConfig(have, want natsGo.ConsumerConfig) (natsGo.ConsumerConfig, []string) {
+	var drift []string
+
+	mergeField("description", &have.Description, want.Description, &drift)
+	mergeField("deliver_policy", &have.DeliverPolicy, want.DeliverPolicy, &drift)
+	mergeField("opt_start_seq", &have.OptStartSeq, want.OptStartSeq, &drift)
+	mergeField("ack_policy", &have.AckPolicy, want.AckPolicy, &drift)
+	mergeField("ack_wait", &have.AckWait, want.AckWait, &drift)
+	mergeField("max_deliver", &have.MaxDeliver, want.MaxDeliver, &drift)
+	mergeList("backoff", &have.BackOff, want.BackOff, &drift)
+	mergeField("filter_subject", &have.FilterSubject, want.FilterSubject, &drift)
+	mergeSet("filter_subjects", &have.FilterSubjects, want.FilterSubjects, &drift)
+	mergeField("replay_policy", &have.ReplayPolicy, want.ReplayPolicy, &drift)
+	mergeField("max_waiting", &have.MaxWaiting, want.MaxWaiting, &drift)
+	mergeField("max_ack_pending", &have.MaxAckPending, want.MaxAckPending, &drift)
+	mergeField("idle_heartbeat", &have.Heartbeat, want.Heartbeat, &drift)
+	mergeField("deliver_subject", &have.DeliverSubject, want.DeliverSubject, &drift)
+	mergeField("deliver_group", &have.DeliverGroup, want.DeliverGroup, &drift)
+	mergeField("inactive_threshold", &have.InactiveThreshold, want.InactiveThreshold, &drift)
+	mergeField("num_replicas", &have.Replicas, want.Replicas, &drift)
+
+	return have, drift
+}
+
+// mergeField sets *have to want unless want is the zero value
+func mergeField[T comparable](name string, have *T, want T, drift *[]string) {
+	var zero T
+	if want == zero || want == *have {
+		return
+	}
+	*drift = append(*drift, fmt.Sprintf("%s: %v -> %v", name, *have, want))
+	*have = want
+}
+
+// mergeList sets *have to want unless want is empty
+func mergeList(name string, have *[]time.Duration, want []time.Duration, drift *[]string) {
+	if len(want) == 0 || slices.Equal(*have, want) {
+		return
+	}
+	*drift = append(*drift, fmt.Sprintf("%s: %v -> %v", name, *have, want))
+	*have = want
+}
+
+// mergeSet sets *have to want unless want is empty, the order of the subjects is not significant
+func mergeSet(name string, have *[]string, want []string, drift *[]string) {
+	if len(want) == 0 {
+		return
+	}
+
+	a := slices.Clone(*have)
+	b := slices.Clone(want)
+	slices.Sort(a)
+	slices.Sort(b)
+	if slices.Equal(a, b) {
+		return
+	}
+
+	*drift = append(*drift, fmt.Sprintf("%s: %v -> %v", name, *have, want))
+	*have = want
+}
@@ -31,6 +31,7 @@ func (b *jetStreamBroker) internalPublish(ctx context.Context, topic string, msg
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
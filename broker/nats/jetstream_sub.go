@@ -12,6 +12,8 @@ import (
 	natsGo "github.com/nats-io/nats.go"
 
 	"github.com/tx7do/kratos-transport/broker"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -28,9 +30,14 @@ func (b *jetStreamBroker) Subscribe(topic string, handler broker.Handler, binder
 
 	options := broker.NewSubscribeOptions(opts...)
 
+	if err := b.ensureSubscribeStream(options); err != nil {
+		return nil, err
+	}
+
 	if len(b.options.SubscriberMiddlewares) > 0 {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	// Build JetStream subscribe options
 	subOpts := buildSubOpts(options)
@@ -41,44 +48,28 @@ func (b *jetStreamBroker) Subscribe(topic string, handler broker.Handler, binder
 		remover: b,
 		s:       nil,
 		options: options,
+		// hand messages to a worker pool so that SubscribeOptions.Concurrency is honoured
+		pool: broker.NewSubscribeWorkerPool(options),
 	}
 
 	// Message handler callback
 	fn := func(msg *natsGo.Msg) {
 		var errSub error
 
-		m := &broker.Message{
-			Headers: natsHeaderToMap(msg.Header),
-			Body:    nil,
-			Msg:     msg,
-		}
-
-		pub := &publication{t: msg.Subject, m: m}
-
 		// Use context.Background() as base to isolate each message's span tree
 		ctx, span := b.startConsumerSpan(context.Background(), msg)
 
 		eh := b.options.ErrorHandler
 
-		if binder != nil {
-			if b.options.Codec.Name() == kProto.Name {
-				m.Body = binder().(proto.Message)
-			} else {
-				m.Body = binder()
-			}
-
-			if errSub = broker.Unmarshal(b.options.Codec, msg.Data, &m.Body); errSub != nil {
-				pub.err = errSub
-				LogErrorf("unmarshal message failed: %v", errSub)
-				if eh != nil {
-					_ = eh(b.options.Context, pub)
-				}
-				_ = msg.Nak()
-				b.finishConsumerSpan(ctx, span, errSub)
-				return
+		var pub *publication
+		if pub, errSub = b.newPublication(msg, binder); errSub != nil {
+			LogErrorf("unmarshal message failed: %v", errSub)
+			if eh != nil {
+				_ = eh(b.options.Context, pub)
 			}
-		} else {
-			m.Body = msg.Data
+			_ = msg.Nak()
+			b.finishConsumerSpan(ctx, span, errSub)
+			return
 		}
 
 		if errSub = handler(ctx, pub); errSub != nil {
@@ -87,7 +78,10 @@ func (b *jetStreamBroker) Subscribe(topic string, handler broker.Handler, binder
 			if eh != nil {
 				_ = eh(b.options.Context, pub)
 			}
-			_ = msg.Nak()
+			// unless the handler settled the message itself
+			if !pub.settled {
+				_ = msg.Nak()
+			}
 			b.finishConsumerSpan(ctx, span, errSub)
 			return
 		}
@@ -101,6 +95,12 @@ func (b *jetStreamBroker) Subscribe(topic string, handler broker.Handler, binder
 		b.finishConsumerSpan(ctx, span, errSub)
 	}
 
+	dispatch := func(msg *natsGo.Msg) {
+		jsSub.pool.Submit(func() {
+			fn(msg)
+		})
+	}
+
 	var sub *natsGo.Subscription
 	var err error
 
@@ -114,7 +114,7 @@ func (b *jetStreamBroker) Subscribe(topic string, handler broker.Handler, binder
 		if v, ok := options.Context.Value(subDurableKey{}).(string); ok && v != "" {
 			durableName = v
 		}
-		sub, err = b.js.PullSubscribe(topic, durableName, subOpts...)
+		sub, err = b.js.PullSubscribe(consumerSubject(topic, options), durableName, subOpts...)
 		if err != nil {
 			b.RUnlock()
 			return nil, fmt.Errorf("failed to create pull subscription: %w", err)
@@ -125,13 +125,17 @@ func (b *jetStreamBroker) Subscribe(topic string, handler broker.Handler, binder
 		if v, ok := options.Context.Value(subPullBatchSizeKey{}).(int); ok && v > 0 {
 			batchSize = v
 		}
-		go b.pullLoop(sub, fn, batchSize, jsSub)
+		go b.pullLoop(sub, func(msgs []*natsGo.Msg) {
+			for _, msg := range msgs {
+				dispatch(msg)
+			}
+		}, batchSize, jsSub)
 	} else {
 		// Push subscribe
 		if len(options.Queue) > 0 {
-			sub, err = b.js.QueueSubscribe(topic, options.Queue, fn, subOpts...)
+			sub, err = b.js.QueueSubscribe(consumerSubject(topic, options), options.Queue, dispatch, subOpts...)
 		} else {
-			sub, err = b.js.Subscribe(topic, fn, subOpts...)
+			sub, err = b.js.Subscribe(consumerSubject(topic, options), dispatch, subOpts...)
 		}
 	}
 	b.RUnlock()
@@ -153,7 +157,7 @@ func (b *jetStreamBroker) Subscribe(topic string, handler broker.Handler, binder
 /// Pull Subscribe Loop
 ///////////////////////////////////////////////////////////////////////////////
 
-func (b *jetStreamBroker) pullLoop(sub *natsGo.Subscription, handler func(*natsGo.Msg), batchSize int, jsSub *subscriber) {
+func (b *jetStreamBroker) pullLoop(sub *natsGo.Subscription, handler func([]*natsGo.Msg), batchSize int, jsSub *subscriber) {
 	for {
 		if jsSub.IsClosed() {
 			return
@@ -171,16 +175,160 @@ func (b *jetStreamBroker) pullLoop(sub *natsGo.Subscription, handler func(*natsG
 			continue
 		}
 
+		if len(msgs) > 0 {
+			handler(msgs)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+/// JetStream Batch Subscribe
+///////////////////////////////////////////////////////////////////////////////
+
+// SubscribeBatch creates a pull subscription and delivers the messages of each Fetch
+// (up to WithPullBatchSize) to handler as one batch. A failed batch is Nak'ed as a whole.
+func (b *jetStreamBroker) SubscribeBatch(topic string, handler broker.BatchHandler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	b.RLock()
+	if b.js == nil {
+		b.RUnlock()
+		return nil, errors.New("not connected")
+	}
+	b.RUnlock()
+
+	options := broker.NewSubscribeOptions(opts...)
+
+	if err := b.ensureSubscribeStream(options); err != nil {
+		return nil, err
+	}
+	handler = broker.WrapSubscribeBatchHandler(handler, options)
+
+	subOpts := buildSubOpts(options)
+
+	manualAck := options.Context.Value(subManualAckKey{}) != nil
+
+	jsSub := &subscriber{
+		remover: b,
+		s:       nil,
+		options: options,
+	}
+
+	fn := func(msgs []*natsGo.Msg) {
+		evts := make([]broker.Event, 0, len(msgs))
+		pubs := make([]*publication, 0, len(msgs))
+		ctxs := make([]context.Context, 0, len(msgs))
+		spans := make([]trace.Span, 0, len(msgs))
+
 		for _, msg := range msgs {
-			handler(msg)
+			ctx, span := b.startConsumerSpan(context.Background(), msg)
+
+			pub, err := b.newPublication(msg, binder)
+			if err != nil {
+				LogErrorf("unmarshal message failed: %v", err)
+				_ = msg.Nak()
+				b.finishConsumerSpan(ctx, span, err)
+				continue
+			}
+
+			evts = append(evts, pub)
+			pubs = append(pubs, pub)
+			ctxs = append(ctxs, ctx)
+			spans = append(spans, span)
+		}
+
+		if len(evts) == 0 {
+			return
+		}
+
+		err := handler(context.Background(), evts)
+		if err != nil {
+			LogErrorf("handle batch failed: %v", err)
+		}
+
+		for i, pub := range pubs {
+			msg := pub.m.Msg.(*natsGo.Msg)
+			if err != nil {
+				pub.err = err
+				if !pub.settled {
+					_ = msg.Nak()
+				}
+			} else if options.AutoAck && !manualAck {
+				if ackErr := pub.Ack(); ackErr != nil {
+					LogErrorf("unable to ack msg: %v", ackErr)
+				}
+			}
+			b.finishConsumerSpan(ctxs[i], spans[i], err)
+		}
+	}
+
+	durableName := ""
+	if v, ok := options.Context.Value(subDurableKey{}).(string); ok && v != "" {
+		durableName = v
+	}
+
+	batchSize := 10
+	if v, ok := options.Context.Value(subPullBatchSizeKey{}).(int); ok && v > 0 {
+		batchSize = v
+	}
+
+	b.RLock()
+	sub, err := b.js.PullSubscribe(consumerSubject(topic, options), durableName, subOpts...)
+	b.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pull subscription: %w", err)
+	}
+
+	jsSub.s = sub
+
+	go b.pullLoop(sub, fn, batchSize, jsSub)
+
+	b.subscribers.Add(topic, jsSub)
+
+	LogInfof("subscribed to JetStream subject: %s (batch=%d)", topic, batchSize)
+
+	return jsSub, nil
+}
+
+// newPublication decodes msg into a publication, using binder to create the body.
+func (b *jetStreamBroker) newPublication(msg *natsGo.Msg, binder broker.Binder) (*publication, error) {
+	m := &broker.Message{
+		Headers: natsHeaderToMap(msg.Header),
+		Body:    nil,
+		Msg:     msg,
+	}
+
+	pub := &publication{t: msg.Subject, m: m}
+
+	if binder != nil {
+		if b.options.Codec.Name() == kProto.Name {
+			m.Body = binder().(proto.Message)
+		} else {
+			m.Body = binder()
+		}
+
+		if err := broker.Unmarshal(b.options.Codec, msg.Data, &m.Body); err != nil {
+			pub.err = err
+			return pub, err
 		}
+	} else {
+		m.Body = msg.Data
 	}
+
+	return pub, nil
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 /// Subscribe Options Builder
 ///////////////////////////////////////////////////////////////////////////////
 
+// consumerSubject is the subject the consumer filters on: consumers with WithFilterSubjects
+// have none, they receive the filter subjects of the bound stream instead.
+func consumerSubject(topic string, options broker.SubscribeOptions) string {
+	if v, ok := options.Context.Value(subFilterSubjectsKey{}).([]string); ok && len(v) > 0 {
+		return ""
+	}
+	return topic
+}
+
 func buildSubOpts(options broker.SubscribeOptions) []natsGo.SubOpt {
 	var subOpts []natsGo.SubOpt
 
@@ -220,6 +368,18 @@ func buildSubOpts(options broker.SubscribeOptions) []natsGo.SubOpt {
 	if options.Context.Value(subManualAckKey{}) != nil {
 		subOpts = append(subOpts, natsGo.ManualAck())
 	}
+	if v, ok := options.Context.Value(subStreamKey{}).(natsGo.StreamConfig); ok && v.Name != "" {
+		subOpts = append(subOpts, natsGo.BindStream(v.Name))
+	}
+	if v, ok := options.Context.Value(subFilterSubjectsKey{}).([]string); ok && len(v) > 0 {
+		subOpts = append(subOpts, natsGo.ConsumerFilterSubjects(v...))
+	}
+	if v, ok := options.Context.Value(subBackOffKey{}).([]time.Duration); ok && len(v) > 0 {
+		subOpts = append(subOpts, natsGo.BackOff(v))
+	}
+	if v, ok := options.Context.Value(subMaxDeliverKey{}).(int); ok && v != 0 {
+		subOpts = append(subOpts, natsGo.MaxDeliver(v))
+	}
 	if v, ok := options.Context.Value(subRawOptsKey{}).([]natsGo.SubOpt); ok {
 		subOpts = append(subOpts, v...)
 	}
@@ -326,6 +326,169 @@ func TestJetStream_Subscribe_Pull(t *testing.T) {
 	<-interrupt
 }
 
+///////////////////////////////////////////////////////////////////////////////
+/// Stream / Consumer Provisioning
+///////////////////////////////////////////////////////////////////////////////
+
+func TestJetStream_MergeStreamConfig(t *testing.T) {
+	have := natsGo.StreamConfig{
+		Name:       "ORDERS",
+		Subjects:   []string{"orders.created", "orders.paid"},
+		MaxMsgs:    -1,
+		MaxAge:     time.Hour,
+		Duplicates: 2 * time.Minute,
+		Storage:    natsGo.FileStorage,
+		Replicas:   1,
+	}
+
+	// zero fields and reordered subjects are not drift
+	merged, drift := mergeStreamConfig(have, natsGo.StreamConfig{
+		Name:     "ORDERS",
+		Subjects: []string{"orders.paid", "orders.created"},
+		MaxAge:   time.Hour,
+	})
+	assert.Empty(t, drift)
+	assert.Equal(t, have, merged)
+
+	merged, drift = mergeStreamConfig(have, natsGo.StreamConfig{
+		Name:       "ORDERS",
+		MaxAge:     24 * time.Hour,
+		Duplicates: time.Minute,
+		Retention:  natsGo.WorkQueuePolicy,
+	})
+	assert.Equal(t, []string{
+		"retention: Limits -> WorkQueue",
+		"max_age: 1h0m0s -> 24h0m0s",
+		"duplicate_window: 2m0s -> 1m0s",
+	}, drift)
+	assert.Equal(t, 24*time.Hour, merged.MaxAge)
+	assert.Equal(t, int64(-1), merged.MaxMsgs)
+	assert.Equal(t, have.Subjects, merged.Subjects)
+}
+
+func TestJetStream_ProvisionStreamsAndConsumers(t *testing.T) {
+	stream := natsGo.StreamConfig{
+		Name:       "PROVISION",
+		Subjects:   []string{"provision.>"},
+		MaxAge:     time.Hour,
+		Duplicates: time.Minute,
+	}
+	consumer := natsGo.ConsumerConfig{
+		Durable:        "billing",
+		AckPolicy:      natsGo.AckExplicitPolicy,
+		FilterSubjects: []string{"provision.created", "provision.paid"},
+		BackOff:        []time.Duration{time.Second, 5 * time.Second},
+		MaxDeliver:     3,
+	}
+
+	b := NewJetStreamBroker(
+		broker.WithAddress(localBroker),
+		WithStreams(stream),
+		WithConsumers("PROVISION", consumer),
+	)
+	_ = b.Init()
+
+	if err := b.Connect(); err != nil {
+		t.Logf("cant connect to broker, skip: %v", err)
+		t.Skip()
+	}
+	defer b.Disconnect()
+
+	js := GetJetStreamContext(b)
+	defer func() { _ = js.DeleteStream("PROVISION") }()
+
+	info, err := js.StreamInfo("PROVISION")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, info.Config.MaxAge)
+	assert.Equal(t, time.Minute, info.Config.Duplicates)
+
+	ci, err := js.ConsumerInfo("PROVISION", "billing")
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{time.Second, 5 * time.Second}, ci.Config.BackOff)
+
+	// connecting again with the same declarations changes nothing, a changed limit is updated
+	stream.MaxAge = 2 * time.Hour
+	consumer.MaxDeliver = 5
+	b2 := NewJetStreamBroker(
+		broker.WithAddress(localBroker),
+		WithStreams(stream),
+		WithConsumers("PROVISION", consumer),
+	)
+	_ = b2.Init()
+	assert.Nil(t, b2.Connect())
+	_ = b2.Disconnect()
+
+	info, err = js.StreamInfo("PROVISION")
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Hour, info.Config.MaxAge)
+	ci, err = js.ConsumerInfo("PROVISION", "billing")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, ci.Config.MaxDeliver)
+
+	// the storage of a stream cannot be changed
+	stream.Storage = natsGo.MemoryStorage
+	b3 := NewJetStreamBroker(
+		broker.WithAddress(localBroker),
+		WithStreams(stream),
+	)
+	_ = b3.Init()
+	err = b3.Connect()
+	var drift *DriftError
+	if assert.ErrorAs(t, err, &drift) {
+		assert.Equal(t, "PROVISION", drift.Stream)
+		assert.Equal(t, []string{"storage: File -> Memory"}, drift.Fields)
+	}
+}
+
+func TestJetStream_Subscribe_WithSubscribeStream(t *testing.T) {
+	ctx := context.Background()
+
+	b := NewJetStreamBroker(
+		broker.WithAddress(localBroker),
+		broker.WithCodec("json"),
+	)
+	_ = b.Init()
+
+	if err := b.Connect(); err != nil {
+		t.Logf("cant connect to broker, skip: %v", err)
+		t.Skip()
+	}
+	defer b.Disconnect()
+
+	defer func() { _ = GetJetStreamContext(b).DeleteStream("SUBSCRIBE_STREAM") }()
+
+	received := make(chan string, 10)
+	_, err := b.Subscribe("subscribe_stream.*",
+		func(_ context.Context, evt broker.Event) error {
+			received <- evt.Message().Body.(string)
+			return nil
+		},
+		func() any { return "" },
+		WithSubscribeStream(natsGo.StreamConfig{
+			Name:     "SUBSCRIBE_STREAM",
+			Subjects: []string{"subscribe_stream.*"},
+		}),
+		WithDurable("subscribe-stream"),
+		WithFilterSubjects("subscribe_stream.a", "subscribe_stream.b"),
+		WithBackOff(time.Second, 2*time.Second),
+		WithMaxDeliver(3),
+	)
+	assert.Nil(t, err)
+
+	assert.Nil(t, b.Publish(ctx, "subscribe_stream.a", broker.NewMessage("a")))
+	assert.Nil(t, b.Publish(ctx, "subscribe_stream.c", broker.NewMessage("c")))
+	assert.Nil(t, b.Publish(ctx, "subscribe_stream.b", broker.NewMessage("b")))
+
+	for _, want := range []string{"a", "b"} {
+		select {
+		case got := <-received:
+			assert.Equal(t, want, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 /// Tracing
 ///////////////////////////////////////////////////////////////////////////////
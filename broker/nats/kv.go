@@ -0,0 +1,268 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/encoding"
+	natsGo "github.com/nats-io/nats.go"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+/// JetStream Key-Value
+///////////////////////////////////////////////////////////////////////////////
+
+// ErrNoJetStream is returned when the broker has no JetStream context: it is not a
+// JetStream broker or it is not connected.
+var ErrNoJetStream = errors.New("nats: broker has no JetStream context")
+
+// KeyValueEntry is a value of a key-value bucket.
+type KeyValueEntry[T any] struct {
+	Key      string
+	Value    T
+	Revision uint64
+	Created  time.Time
+}
+
+// KeyValue is a key-value bucket whose values are T, encoded with the codec of the broker.
+type KeyValue[T any] struct {
+	b     broker.Broker
+	kv    natsGo.KeyValue
+	codec encoding.Codec
+}
+
+// NewKeyValue returns the key-value bucket cfg.Bucket of the JetStream broker b, which is
+// created with cfg when it does not exist. The config of an existing bucket is not changed.
+func NewKeyValue[T any](b broker.Broker, cfg natsGo.KeyValueConfig) (*KeyValue[T], error) {
+	js := GetJetStreamContext(b)
+	if js == nil {
+		return nil, ErrNoJetStream
+	}
+
+	kv, err := js.KeyValue(cfg.Bucket)
+	if errors.Is(err, natsGo.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key-value bucket %s: %w", cfg.Bucket, err)
+	}
+
+	return &KeyValue[T]{b: b, kv: kv, codec: b.Options().Codec}, nil
+}
+
+// Bucket returns the name of the bucket.
+func (k *KeyValue[T]) Bucket() string {
+	return k.kv.Bucket()
+}
+
+// KeyValue returns the underlying NATS key-value bucket.
+func (k *KeyValue[T]) KeyValue() natsGo.KeyValue {
+	return k.kv
+}
+
+// Get returns the latest value of key, or natsGo.ErrKeyNotFound when key has no value.
+func (k *KeyValue[T]) Get(key string) (*KeyValueEntry[T], error) {
+	entry, err := k.kv.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return k.newEntry(entry)
+}
+
+// Put sets the value of key and returns its revision.
+func (k *KeyValue[T]) Put(key string, value T) (uint64, error) {
+	data, err := broker.Marshal(k.codec, value)
+	if err != nil {
+		return 0, err
+	}
+	return k.kv.Put(key, data)
+}
+
+// Create sets the value of key only when key has no value, it fails with natsGo.ErrKeyExists otherwise.
+func (k *KeyValue[T]) Create(key string, value T) (uint64, error) {
+	data, err := broker.Marshal(k.codec, value)
+	if err != nil {
+		return 0, err
+	}
+	return k.kv.Create(key, data)
+}
+
+// Update sets the value of key only when revision is its latest revision, it fails with
+// natsGo.ErrKeyExists when key was changed since revision was read.
+func (k *KeyValue[T]) Update(key string, value T, revision uint64) (uint64, error) {
+	data, err := broker.Marshal(k.codec, value)
+	if err != nil {
+		return 0, err
+	}
+	return k.kv.Update(key, data, revision)
+}
+
+// Delete deletes the value of key, keeping its history. natsGo.LastRevision makes it conditional.
+func (k *KeyValue[T]) Delete(key string, opts ...natsGo.DeleteOpt) error {
+	return k.kv.Delete(key, opts...)
+}
+
+// Purge deletes the value of key and its history.
+func (k *KeyValue[T]) Purge(key string, opts ...natsGo.DeleteOpt) error {
+	return k.kv.Purge(key, opts...)
+}
+
+// Keys returns the keys that have a value.
+func (k *KeyValue[T]) Keys() ([]string, error) {
+	keys, err := k.kv.Keys()
+	if errors.Is(err, natsGo.ErrNoKeysFound) {
+		return nil, nil
+	}
+	return keys, err
+}
+
+// Watch delivers the changes of the keys matching keys, which may contain wildcards, to handler.
+// The latest values are delivered first, unless WithWatchUpdatesOnly is given. The topic of the
+// events is the key, their body is the value T, or nil for a deletion, and their offset the
+// revision; KeyValueEntryFromEvent returns the entry. Changes are delivered one at a time, in order.
+func (k *KeyValue[T]) Watch(keys string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	options := broker.NewSubscribeOptions(opts...)
+
+	if mws := k.b.Options().SubscriberMiddlewares; len(mws) > 0 {
+		handler = broker.ChainSubscriberMiddleware(handler, mws)
+	}
+	handler = broker.WrapSubscribeHandler(k.b, handler, options)
+
+	watcher, err := k.kv.Watch(keys, buildWatchOpts(options)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", keys, err)
+	}
+
+	w := &kvWatcher{
+		keys:    keys,
+		options: options,
+		watcher: watcher,
+		done:    make(chan struct{}),
+	}
+	go w.run(func(entry natsGo.KeyValueEntry) {
+		k.handleEntry(options.Context, entry, handler)
+	})
+
+	return w, nil
+}
+
+func (k *KeyValue[T]) handleEntry(ctx context.Context, entry natsGo.KeyValueEntry, handler broker.Handler) {
+	m := &broker.Message{
+		Key:    entry.Key(),
+		Offset: int64(entry.Revision()),
+		Msg:    entry,
+	}
+
+	if entry.Operation() == natsGo.KeyValuePut {
+		value, err := k.decode(entry.Value())
+		if err != nil {
+			LogErrorf("unmarshal value of key %s failed: %v", entry.Key(), err)
+			return
+		}
+		m.Body = value
+	}
+
+	if err := handler(ctx, &publication{t: entry.Key(), m: m}); err != nil {
+		LogErrorf("handle change of key %s failed: %v", entry.Key(), err)
+	}
+}
+
+func (k *KeyValue[T]) newEntry(entry natsGo.KeyValueEntry) (*KeyValueEntry[T], error) {
+	value, err := k.decode(entry.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyValueEntry[T]{
+		Key:      entry.Key(),
+		Value:    value,
+		Revision: entry.Revision(),
+		Created:  entry.Created(),
+	}, nil
+}
+
+// decode unmarshals data into a T, allocating the value when T is a pointer, e.g. to a proto message
+func (k *KeyValue[T]) decode(data []byte) (T, error) {
+	var value T
+
+	if t := reflect.TypeFor[T](); t.Kind() == reflect.Pointer {
+		ptr := reflect.New(t.Elem()).Interface()
+		if err := broker.Unmarshal(k.codec, data, ptr); err != nil {
+			return value, err
+		}
+		return ptr.(T), nil
+	}
+
+	err := broker.Unmarshal(k.codec, data, &value)
+	return value, err
+}
+
+// KeyValueEntryFromEvent extracts the key-value entry from an event delivered by KeyValue.Watch,
+// e.g. to tell deletions from puts with its Operation.
+func KeyValueEntryFromEvent(evt broker.Event) (natsGo.KeyValueEntry, bool) {
+	if evt == nil || evt.Message() == nil {
+		return nil, false
+	}
+	entry, ok := evt.Message().Msg.(natsGo.KeyValueEntry)
+	return entry, ok
+}
+
+// kvWatcher is the subscription of a key-value watch
+type kvWatcher struct {
+	keys    string
+	options broker.SubscribeOptions
+	watcher natsGo.KeyWatcher
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (w *kvWatcher) run(handle func(natsGo.KeyValueEntry)) {
+	defer close(w.done)
+
+	for entry := range w.watcher.Updates() {
+		// nil marks the end of the initial values
+		if entry == nil {
+			continue
+		}
+		handle(entry)
+	}
+}
+
+func (w *kvWatcher) Options() broker.SubscribeOptions {
+	return w.options
+}
+
+func (w *kvWatcher) Topic() string {
+	return w.keys
+}
+
+func (w *kvWatcher) Unsubscribe(_ bool) error {
+	var err error
+	w.once.Do(func() {
+		err = w.watcher.Stop()
+		<-w.done
+	})
+	return err
+}
+
+func buildWatchOpts(options broker.SubscribeOptions) []natsGo.WatchOpt {
+	var watchOpts []natsGo.WatchOpt
+
+	if options.Context.Value(watchUpdatesOnlyKey{}) != nil {
+		watchOpts = append(watchOpts, natsGo.UpdatesOnly())
+	}
+	if options.Context.Value(watchIncludeHistoryKey{}) != nil {
+		watchOpts = append(watchOpts, natsGo.IncludeHistory())
+	}
+	if options.Context.Value(watchIgnoreDeletesKey{}) != nil {
+		watchOpts = append(watchOpts, natsGo.IgnoreDeletes())
+	}
+
+	return watchOpts
+}
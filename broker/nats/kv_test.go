@@ -0,0 +1,189 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	natsGo "github.com/nats-io/nats.go"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+type testSettings struct {
+	Theme    string `json:"theme"`
+	PageSize int    `json:"page_size"`
+}
+
+func newTestJetStreamBroker(t *testing.T) broker.Broker {
+	t.Helper()
+
+	b := NewJetStreamBroker(
+		broker.WithAddress(localBroker),
+		broker.WithCodec("json"),
+	)
+	_ = b.Init()
+
+	if err := b.Connect(); err != nil {
+		t.Logf("cant connect to broker, skip: %v", err)
+		t.Skip()
+	}
+	t.Cleanup(func() { _ = b.Disconnect() })
+
+	return b
+}
+
+func TestKeyValue_CRUD(t *testing.T) {
+	b := newTestJetStreamBroker(t)
+	defer func() { _ = GetJetStreamContext(b).DeleteKeyValue("test_settings") }()
+
+	kv, err := NewKeyValue[testSettings](b, natsGo.KeyValueConfig{Bucket: "test_settings", History: 5})
+	assert.Nil(t, err)
+	assert.Equal(t, "test_settings", kv.Bucket())
+
+	_, err = kv.Get("alice")
+	assert.ErrorIs(t, err, natsGo.ErrKeyNotFound)
+
+	rev, err := kv.Create("alice", testSettings{Theme: "dark", PageSize: 20})
+	assert.Nil(t, err)
+
+	_, err = kv.Create("alice", testSettings{Theme: "light"})
+	assert.ErrorIs(t, err, natsGo.ErrKeyExists)
+
+	entry, err := kv.Get("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, testSettings{Theme: "dark", PageSize: 20}, entry.Value)
+	assert.Equal(t, rev, entry.Revision)
+
+	// compare-and-set on the revision
+	updated, err := kv.Update("alice", testSettings{Theme: "light", PageSize: 20}, entry.Revision)
+	assert.Nil(t, err)
+	_, err = kv.Update("alice", testSettings{Theme: "stale"}, entry.Revision)
+	assert.ErrorIs(t, err, natsGo.ErrKeyExists)
+
+	_, err = kv.Put("bob", testSettings{Theme: "dark"})
+	assert.Nil(t, err)
+
+	keys, err := kv.Keys()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"alice", "bob"}, keys)
+
+	assert.NotNil(t, kv.Delete("alice", natsGo.LastRevision(entry.Revision)))
+	assert.Nil(t, kv.Delete("alice", natsGo.LastRevision(updated)))
+	_, err = kv.Get("alice")
+	assert.ErrorIs(t, err, natsGo.ErrKeyNotFound)
+
+	// a bucket that exists is bound
+	again, err := NewKeyValue[*testSettings](b, natsGo.KeyValueConfig{Bucket: "test_settings"})
+	assert.Nil(t, err)
+	ptr, err := again.Get("bob")
+	assert.Nil(t, err)
+	assert.Equal(t, &testSettings{Theme: "dark"}, ptr.Value)
+}
+
+func TestKeyValue_Watch(t *testing.T) {
+	b := newTestJetStreamBroker(t)
+	defer func() { _ = GetJetStreamContext(b).DeleteKeyValue("test_watch") }()
+
+	kv, err := NewKeyValue[testSettings](b, natsGo.KeyValueConfig{Bucket: "test_watch"})
+	assert.Nil(t, err)
+
+	_, err = kv.Put("users.alice", testSettings{Theme: "dark"})
+	assert.Nil(t, err)
+
+	type change struct {
+		key   string
+		value any
+		op    natsGo.KeyValueOp
+	}
+	changes := make(chan change, 10)
+	sub, err := kv.Watch("users.*", func(_ context.Context, evt broker.Event) error {
+		entry, ok := KeyValueEntryFromEvent(evt)
+		assert.True(t, ok)
+		assert.Equal(t, int64(entry.Revision()), evt.Message().Offset)
+		changes <- change{key: evt.Topic(), value: evt.Message().Body, op: entry.Operation()}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "users.*", sub.Topic())
+
+	_, err = kv.Put("users.bob", testSettings{Theme: "light"})
+	assert.Nil(t, err)
+	_, err = kv.Put("groups.admins", testSettings{})
+	assert.Nil(t, err)
+	assert.Nil(t, kv.Delete("users.alice"))
+
+	want := []change{
+		{key: "users.alice", value: testSettings{Theme: "dark"}, op: natsGo.KeyValuePut},
+		{key: "users.bob", value: testSettings{Theme: "light"}, op: natsGo.KeyValuePut},
+		{key: "users.alice", op: natsGo.KeyValueDelete},
+	}
+	for _, w := range want {
+		select {
+		case got := <-changes:
+			assert.Equal(t, w, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s", w.key)
+		}
+	}
+
+	assert.Nil(t, sub.Unsubscribe(true))
+	_, err = kv.Put("users.carol", testSettings{})
+	assert.Nil(t, err)
+	select {
+	case got := <-changes:
+		t.Fatalf("change delivered after unsubscribe: %v", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestObjectStore_ChunkedPutGet(t *testing.T) {
+	b := newTestJetStreamBroker(t)
+	defer func() { _ = GetJetStreamContext(b).DeleteObjectStore("test_blobs") }()
+
+	store, err := NewObjectStore(b, natsGo.ObjectStoreConfig{Bucket: "test_blobs"})
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	blob := make([]byte, 1<<20+123)
+	_, _ = rand.Read(blob)
+
+	info, err := store.PutMeta(ctx, natsGo.ObjectMeta{
+		Name: "reports/2024.bin",
+		Opts: &natsGo.ObjectMetaOptions{ChunkSize: 64 * 1024},
+	}, bytes.NewReader(blob))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(len(blob)), info.Size)
+	assert.Equal(t, uint32(17), info.Chunks)
+
+	var buf bytes.Buffer
+	info, err = store.GetTo(ctx, "reports/2024.bin", &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "reports/2024.bin", info.Name)
+	assert.True(t, bytes.Equal(blob, buf.Bytes()))
+
+	infos, err := store.List(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, infos, 1)
+
+	assert.Nil(t, store.Delete("reports/2024.bin"))
+	_, err = store.Info(ctx, "reports/2024.bin")
+	assert.True(t, errors.Is(err, natsGo.ErrObjectNotFound))
+
+	infos, err = store.List(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, infos)
+}
+
+func TestKeyValue_RequiresJetStream(t *testing.T) {
+	_, err := NewKeyValue[string](NewBroker(), natsGo.KeyValueConfig{Bucket: "core"})
+	assert.ErrorIs(t, err, ErrNoJetStream)
+
+	_, err = NewObjectStore(NewBroker(), natsGo.ObjectStoreConfig{Bucket: "core"})
+	assert.ErrorIs(t, err, ErrNoJetStream)
+}
@@ -222,6 +222,7 @@ func (b *natsBroker) internalPublish(ctx context.Context, topic string, msg *bro
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -284,11 +285,14 @@ func (b *natsBroker) Subscribe(topic string, handler broker.Handler, binder brok
 	if len(b.options.SubscriberMiddlewares) > 0 {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	subs := &subscriber{
 		remover: b,
 		s:       nil,
 		options: options,
+		// hand messages to a worker pool so that SubscribeOptions.Concurrency is honoured
+		pool: broker.NewSubscribeWorkerPool(options),
 	}
 
 	fn := func(msg *natsGo.Msg) {
@@ -347,14 +351,20 @@ func (b *natsBroker) Subscribe(topic string, handler broker.Handler, binder brok
 		b.finishConsumerSpan(ctx, span, errSub)
 	}
 
+	dispatch := func(msg *natsGo.Msg) {
+		subs.pool.Submit(func() {
+			fn(msg)
+		})
+	}
+
 	var sub *natsGo.Subscription
 	var err error
 
 	b.RLock()
 	if len(options.Queue) > 0 {
-		sub, err = b.conn.QueueSubscribe(topic, options.Queue, fn)
+		sub, err = b.conn.QueueSubscribe(topic, options.Queue, dispatch)
 	} else {
-		sub, err = b.conn.Subscribe(topic, fn)
+		sub, err = b.conn.Subscribe(topic, dispatch)
 	}
 	b.RUnlock()
 	if err != nil {
@@ -0,0 +1,103 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	natsGo "github.com/nats-io/nats.go"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+/// JetStream Object Store
+///////////////////////////////////////////////////////////////////////////////
+
+// ObjectStore stores large blobs in a JetStream object store bucket. Objects are split into
+// chunks when they are put and read back chunk by chunk, neither side holds a whole object in memory.
+type ObjectStore struct {
+	bucket string
+	store  natsGo.ObjectStore
+}
+
+// NewObjectStore returns the object store bucket cfg.Bucket of the JetStream broker b, which is
+// created with cfg when it does not exist. The config of an existing bucket is not changed.
+func NewObjectStore(b broker.Broker, cfg natsGo.ObjectStoreConfig) (*ObjectStore, error) {
+	js := GetJetStreamContext(b)
+	if js == nil {
+		return nil, ErrNoJetStream
+	}
+
+	store, err := js.ObjectStore(cfg.Bucket)
+	if errors.Is(err, natsGo.ErrStreamNotFound) {
+		store, err = js.CreateObjectStore(&cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object store %s: %w", cfg.Bucket, err)
+	}
+
+	return &ObjectStore{bucket: cfg.Bucket, store: store}, nil
+}
+
+// Bucket returns the name of the bucket.
+func (s *ObjectStore) Bucket() string {
+	return s.bucket
+}
+
+// ObjectStore returns the underlying NATS object store.
+func (s *ObjectStore) ObjectStore() natsGo.ObjectStore {
+	return s.store
+}
+
+// Put streams the content of r into the object name, replacing its previous content.
+func (s *ObjectStore) Put(ctx context.Context, name string, r io.Reader) (*natsGo.ObjectInfo, error) {
+	return s.PutMeta(ctx, natsGo.ObjectMeta{Name: name}, r)
+}
+
+// PutMeta streams the content of r into the object meta.Name, with the description, headers
+// and metadata of meta. meta.Opts.ChunkSize sets the chunk size, 128KB by default.
+func (s *ObjectStore) PutMeta(ctx context.Context, meta natsGo.ObjectMeta, r io.Reader) (*natsGo.ObjectInfo, error) {
+	return s.store.Put(&meta, r, natsGo.Context(ctx))
+}
+
+// Get returns a reader of the content of the object name, fetched chunk by chunk as it is read.
+// Its Read fails with natsGo.ErrDigestMismatch at the end of a corrupted object. It must be closed.
+func (s *ObjectStore) Get(ctx context.Context, name string) (natsGo.ObjectResult, error) {
+	return s.store.Get(name, natsGo.Context(ctx))
+}
+
+// GetTo copies the content of the object name to w and returns its info.
+func (s *ObjectStore) GetTo(ctx context.Context, name string, w io.Writer) (*natsGo.ObjectInfo, error) {
+	result, err := s.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = result.Close() }()
+
+	if _, err = io.Copy(w, result); err != nil {
+		return nil, err
+	}
+
+	return result.Info()
+}
+
+// Info returns the info of the object name, or natsGo.ErrObjectNotFound.
+func (s *ObjectStore) Info(ctx context.Context, name string) (*natsGo.ObjectInfo, error) {
+	return s.store.GetInfo(name, natsGo.Context(ctx))
+}
+
+// List returns the info of the objects of the bucket, it is empty when there is none.
+func (s *ObjectStore) List(ctx context.Context) ([]*natsGo.ObjectInfo, error) {
+	infos, err := s.store.List(natsGo.Context(ctx))
+	if errors.Is(err, natsGo.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	return infos, err
+}
+
+// Delete deletes the object name and its chunks.
+func (s *ObjectStore) Delete(name string) error {
+	return s.store.Delete(name)
+}
@@ -1,6 +1,7 @@
 package nats
 
 import (
+	"slices"
 	"time"
 
 	natsGo "github.com/nats-io/nats.go"
@@ -58,6 +59,32 @@ func JetStreamContextOptions(opts ...natsGo.JSOpt) broker.Option {
 	return broker.OptionContextWithValue(jetStreamContextOptsKey{}, opts)
 }
 
+type streamsKey struct{}
+type consumersKey struct{}
+
+// WithStreams declares streams that are created on Connect, or updated when their config
+// differs from the declared one. Zero fields are left as they are on the server. Connect fails
+// with a *DriftError when a stream cannot be updated to its declared config.
+func WithStreams(cfgs ...natsGo.StreamConfig) broker.Option {
+	return func(o *broker.Options) {
+		streams, _ := o.Context.Value(streamsKey{}).([]natsGo.StreamConfig)
+		broker.OptionContextWithValue(streamsKey{}, append(slices.Clone(streams), cfgs...))(o)
+	}
+}
+
+// WithConsumers declares durable consumers of stream that are created or updated on Connect,
+// after the streams of WithStreams. Subscribe binds to them with WithDurable and WithBindStream.
+func WithConsumers(stream string, cfgs ...natsGo.ConsumerConfig) broker.Option {
+	return func(o *broker.Options) {
+		consumers, _ := o.Context.Value(consumersKey{}).([]consumerDecl)
+		consumers = slices.Clone(consumers)
+		for _, cfg := range cfgs {
+			consumers = append(consumers, consumerDecl{stream: stream, config: cfg})
+		}
+		broker.OptionContextWithValue(consumersKey{}, consumers)(o)
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 /// JetStream Publish Options
 ///////////////////////////////////////////////////////////////////////////////
@@ -118,6 +145,10 @@ type subManualAckKey struct{}
 type subPullKey struct{}
 type subPullBatchSizeKey struct{}
 type subRawOptsKey struct{}
+type subStreamKey struct{}
+type subFilterSubjectsKey struct{}
+type subBackOffKey struct{}
+type subMaxDeliverKey struct{}
 
 // WithDurable sets the durable consumer name for the subscription.
 func WithDurable(name string) broker.SubscribeOption {
@@ -189,11 +220,56 @@ func WithPullBatchSize(n int) broker.SubscribeOption {
 	return broker.SubscribeContextWithValue(subPullBatchSizeKey{}, n)
 }
 
+// WithSubscribeStream declares the stream of the subscription: it is created or updated like
+// the streams of WithStreams before subscribing, and the subscription is bound to it.
+func WithSubscribeStream(cfg natsGo.StreamConfig) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(subStreamKey{}, cfg)
+}
+
+// WithFilterSubjects sets the subjects of the stream the consumer receives, instead of the subscribed
+// subject. The stream must be given with WithBindStream or WithSubscribeStream.
+func WithFilterSubjects(subjects ...string) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(subFilterSubjectsKey{}, subjects)
+}
+
+// WithBackOff sets the redelivery delays of a message that is not acknowledged, one per delivery attempt.
+func WithBackOff(delays ...time.Duration) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(subBackOffKey{}, delays)
+}
+
+// WithMaxDeliver sets the maximum number of delivery attempts of a message.
+func WithMaxDeliver(n int) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(subMaxDeliverKey{}, n)
+}
+
 // WithSubscribeRawOpts allows passing raw NATS SubOpt options directly.
 func WithSubscribeRawOpts(opts ...natsGo.SubOpt) broker.SubscribeOption {
 	return broker.SubscribeContextWithValue(subRawOptsKey{}, opts)
 }
 
+///////////////////////////////////////////////////////////////////////////////
+/// JetStream Key-Value Watch Options
+///////////////////////////////////////////////////////////////////////////////
+
+type watchUpdatesOnlyKey struct{}
+type watchIncludeHistoryKey struct{}
+type watchIgnoreDeletesKey struct{}
+
+// WithWatchUpdatesOnly delivers only the changes made after KeyValue.Watch, not the latest values.
+func WithWatchUpdatesOnly() broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(watchUpdatesOnlyKey{}, true)
+}
+
+// WithWatchIncludeHistory delivers the history of the keys before their changes, instead of their latest values.
+func WithWatchIncludeHistory() broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(watchIncludeHistoryKey{}, true)
+}
+
+// WithWatchIgnoreDeletes does not deliver deletions and purges.
+func WithWatchIgnoreDeletes() broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(watchIgnoreDeletesKey{}, true)
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 /// JetStream Utility Functions
 ///////////////////////////////////////////////////////////////////////////////
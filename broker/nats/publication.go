@@ -1,15 +1,26 @@
 package nats
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	natsGo "github.com/nats-io/nats.go"
 
 	"github.com/tx7do/kratos-transport/broker"
 )
 
+var (
+	_ broker.Nacker           = (*publication)(nil)
+	_ broker.DeadlineExtender = (*publication)(nil)
+)
+
 type publication struct {
 	t   string
 	err error
 	m   *broker.Message
+
+	settled bool
 }
 
 func (p *publication) Topic() string {
@@ -24,21 +35,91 @@ func (p *publication) RawMessage() any {
 	return p.m
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.m == nil {
+		return nil
+	}
+	switch msg := p.m.Msg.(type) {
+	case *natsGo.Msg:
+		return msg.Data
+	case natsGo.KeyValueEntry:
+		return msg.Value()
+	}
+	return nil
+}
+
 // Ack acknowledges the message.
-// For JetStream messages (identified by having a Reply subject), this calls msg.Ack().
+// For JetStream messages (identified by their acknowledgement reply subject), this calls msg.Ack().
 // For core NATS messages, this is a no-op since core NATS does not support acknowledgments.
 func (p *publication) Ack() error {
-	if p.m != nil {
-		if msg, ok := p.m.Msg.(*natsGo.Msg); ok {
-			// Only Ack JetStream messages (they have a reply subject for acking)
-			if msg.Reply != "" {
-				return msg.Ack()
-			}
-		}
+	if msg := p.jsMsg(); msg != nil && !p.settled {
+		p.settled = true
+		return msg.Ack()
 	}
 	return nil
 }
 
+// Nack redelivers a JetStream message at once with Nak or, without requeue,
+// terminates its delivery with Term.
+func (p *publication) Nack(requeue bool) error {
+	msg, err := p.settle()
+	if err != nil {
+		return err
+	}
+	if !requeue {
+		return msg.Term()
+	}
+	return msg.Nak()
+}
+
+// NackWithDelay redelivers a JetStream message after delay with NakWithDelay.
+func (p *publication) NackWithDelay(delay time.Duration) error {
+	msg, err := p.settle()
+	if err != nil {
+		return err
+	}
+	return msg.NakWithDelay(delay)
+}
+
+// ExtendDeadline resets the ack wait of a JetStream message with InProgress:
+// JetStream extends it by the AckWait of the consumer whatever d.
+func (p *publication) ExtendDeadline(_ time.Duration) error {
+	msg := p.jsMsg()
+	if msg == nil {
+		return fmt.Errorf("core NATS has no acknowledgements: %w", broker.ErrNotSupported)
+	}
+	return msg.InProgress()
+}
+
+func (p *publication) settle() (*natsGo.Msg, error) {
+	msg := p.jsMsg()
+	if msg == nil {
+		return nil, fmt.Errorf("core NATS has no acknowledgements: %w", broker.ErrNotSupported)
+	}
+	if p.settled {
+		return nil, errors.New("the message is already settled")
+	}
+	p.settled = true
+	return msg, nil
+}
+
+// jsMsg returns the JetStream message of the publication, whose reply subject is
+// an acknowledgement subject, or nil for a core NATS message.
+func (p *publication) jsMsg() *natsGo.Msg {
+	if p.m == nil {
+		return nil
+	}
+	msg, ok := p.m.Msg.(*natsGo.Msg)
+	if !ok || msg.Reply == "" {
+		return nil
+	}
+	if _, err := msg.Metadata(); err != nil {
+		return nil
+	}
+	return msg
+}
+
 func (p *publication) Error() error {
 	return p.err
 }
@@ -36,6 +36,7 @@ type subscriber struct {
 	remover subscriberRemover
 	s       *natsGo.Subscription
 	options broker.SubscribeOptions
+	pool    *broker.WorkerPool
 	closed  bool
 }
 
@@ -58,6 +59,11 @@ func (s *subscriber) Topic() string {
 }
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
+	// the handlers ack through the subscription, they finish before it is removed
+	if s.pool != nil {
+		s.pool.Drain()
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -0,0 +1,35 @@
+package nsq
+
+import (
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+var _ propagation.TextMapCarrier = (*MessageCarrier)(nil)
+
+// MessageCarrier carries the trace context in the headers of a message, which reach the
+// consumers in an envelope (see broker.WithEnvelope).
+type MessageCarrier struct {
+	msg *broker.Message
+}
+
+func NewMessageCarrier(msg *broker.Message) MessageCarrier {
+	return MessageCarrier{msg: msg}
+}
+
+func (c MessageCarrier) Get(key string) string {
+	return c.msg.GetHeader(key)
+}
+
+func (c MessageCarrier) Set(key, val string) {
+	c.msg.SetHeader(key, val)
+}
+
+func (c MessageCarrier) Keys() []string {
+	out := make([]string, 0, len(c.msg.Headers))
+	for k := range c.msg.Headers {
+		out = append(out, k)
+	}
+	return out
+}
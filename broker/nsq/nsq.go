@@ -12,6 +12,7 @@ import (
 	NSQ "github.com/nsqio/go-nsq"
 
 	"github.com/tx7do/kratos-transport/broker"
+	"github.com/tx7do/kratos-transport/tracing"
 )
 
 var (
@@ -36,6 +37,11 @@ type nsqBroker struct {
 	producers []*NSQ.Producer
 
 	subscribers *broker.SubscriberSyncMap
+
+	requester *broker.Requester
+
+	producerTracer *tracing.Tracer
+	consumerTracer *tracing.Tracer
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
@@ -49,6 +55,10 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	// an ephemeral reply topic is dropped by nsqd once its last channel goes away
+	b.requester = broker.NewRequester(b,
+		broker.WithInboxTopic(broker.DefaultInboxPrefix+uuid.New().String()+"#ephemeral"),
+	)
 
 	return b
 }
@@ -88,6 +98,7 @@ func (b *nsqBroker) Init(opts ...broker.Option) error {
 
 	b.addrs = addrs
 	b.configure(b.options.Context)
+	b.initTracer()
 
 	return nil
 }
@@ -161,6 +172,8 @@ func (b *nsqBroker) Connect() error {
 }
 
 func (b *nsqBroker) Disconnect() error {
+	_ = b.requester.Close()
+
 	b.Lock()
 	defer b.Unlock()
 
@@ -196,7 +209,7 @@ func (b *nsqBroker) Disconnect() error {
 }
 
 func (b *nsqBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *nsqBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -214,11 +227,18 @@ func (b *nsqBroker) internalPublish(ctx context.Context, topic string, msg *brok
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
 
-	return b.publish(ctx, topic, sendMsg, opts...)
+	ctx, span := b.startProducerSpan(ctx, topic, sendMsg)
+
+	err = b.publish(ctx, topic, sendMsg, opts...)
+
+	b.finishProducerSpan(ctx, span, err)
+
+	return err
 }
 
 func (b *nsqBroker) publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -247,16 +267,23 @@ func (b *nsqBroker) publish(ctx context.Context, topic string, msg *broker.Messa
 		return errors.New("producer is null")
 	}
 
+	body := msg.BodyBytes()
+	// NSQ has no message headers: requests and replies always carry theirs in an envelope,
+	// the other messages only with broker.WithEnvelope
+	if broker.EnvelopeEnabled(b.options) || broker.IsRequestReply(msg) {
+		body = (&broker.Envelope{ID: msg.ID, Headers: msg.Headers, Body: body}).Encode()
+	}
+
 	if doneChan != nil {
 		if delay > 0 {
-			return p.DeferredPublishAsync(topic, delay, msg.BodyBytes(), doneChan)
+			return p.DeferredPublishAsync(topic, delay, body, doneChan)
 		}
-		return p.PublishAsync(topic, msg.BodyBytes(), doneChan)
+		return p.PublishAsync(topic, body, doneChan)
 	} else {
 		if delay > 0 {
-			return p.DeferredPublish(topic, delay, msg.BodyBytes())
+			return p.DeferredPublish(topic, delay, body)
 		}
-		return p.Publish(topic, msg.BodyBytes())
+		return p.Publish(topic, body)
 	}
 }
 
@@ -277,11 +304,16 @@ func (b *nsqBroker) Subscribe(topic string, handler broker.Handler, binder broke
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	concurrency, maxInFlight := DefaultConcurrentHandlers, DefaultConcurrentHandlers
+	// NSQ runs concurrent handlers natively, the generic option maps straight onto them
+	if options.Concurrency > 1 {
+		maxInFlight, concurrency = options.Concurrency, options.Concurrency
+	}
 	if options.Context != nil {
 		if v, ok := options.Context.Value(concurrentHandlerKey{}).(int); ok {
 			maxInFlight, concurrency = v, v
@@ -314,19 +346,31 @@ func (b *nsqBroker) Subscribe(topic string, handler broker.Handler, binder broke
 		var m broker.Message
 		var errSub error
 
+		payload := nm.Body
+		if env, err := broker.DecodeEnvelope(payload); err == nil {
+			m.ID = env.ID
+			m.Headers = env.Headers
+			payload = env.Body
+		}
+
+		ctx, span := b.startConsumerSpan(b.options.Context, topic, &m)
+		defer func() {
+			b.finishConsumerSpan(ctx, span, errSub)
+		}()
+
 		if binder != nil {
 			m.Body = binder()
 
-			if errSub = broker.Unmarshal(b.options.Codec, nm.Body, &m.Body); errSub != nil {
+			if errSub = broker.Unmarshal(b.options.Codec, payload, &m.Body); errSub != nil {
 				return errSub
 			}
 		} else {
-			m.Body = nm.Body
+			m.Body = payload
 		}
 
-		p := &publication{topic: topic, nsqMsg: nm, msg: &m}
+		p := &publication{topic: topic, nsqMsg: nm, msg: &m, payload: payload}
 
-		if errSub = handler(b.options.Context, p); errSub != nil {
+		if errSub = handler(ctx, p); errSub != nil {
 			p.err = errSub
 			return errSub
 		}
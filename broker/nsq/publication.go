@@ -11,6 +11,7 @@ type publication struct {
 	topic   string
 	msg     *broker.Message
 	nsqMsg  *NSQ.Message
+	payload []byte
 	options broker.PublishOptions
 	err     error
 }
@@ -27,6 +28,11 @@ func (p *publication) RawMessage() any {
 	return p.nsqMsg
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	return p.payload
+}
+
 func (p *publication) Ack() error {
 	if p.nsqMsg == nil {
 		p.err = errors.New("nsq message is nil")
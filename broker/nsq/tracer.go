@@ -0,0 +1,87 @@
+package nsq
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semConv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tx7do/kratos-transport/broker"
+	"github.com/tx7do/kratos-transport/tracing"
+)
+
+const (
+	TracerMessageSystemKey = "nsq"
+	SpanNameProducer       = "nsq-producer"
+	SpanNameConsumer       = "nsq-consumer"
+)
+
+func (b *nsqBroker) initTracer() {
+	if len(b.options.Tracings) > 0 {
+		b.producerTracer = tracing.NewTracer(trace.SpanKindProducer, SpanNameProducer, b.options.Tracings...)
+		b.consumerTracer = tracing.NewTracer(trace.SpanKindConsumer, SpanNameConsumer, b.options.Tracings...)
+	}
+}
+
+// startProducerSpan injects the trace context into the headers of msg, which only reach
+// the consumers when the message is enveloped (see broker.WithEnvelope).
+func (b *nsqBroker) startProducerSpan(ctx context.Context, topic string, msg *broker.Message) (context.Context, trace.Span) {
+	if b.producerTracer == nil {
+		return ctx, nil
+	}
+
+	if msg == nil {
+		return ctx, nil
+	}
+
+	carrier := NewMessageCarrier(msg)
+
+	attrs := []attribute.KeyValue{
+		semConv.MessagingSystemKey.String(TracerMessageSystemKey),
+		semConv.MessagingDestinationKindTopic,
+		semConv.MessagingDestinationKey.String(topic),
+	}
+
+	return b.producerTracer.Start(ctx, carrier, attrs...)
+}
+
+func (b *nsqBroker) finishProducerSpan(ctx context.Context, span trace.Span, err error) {
+	if b.producerTracer == nil {
+		return
+	}
+
+	b.producerTracer.End(ctx, span, err)
+}
+
+func (b *nsqBroker) startConsumerSpan(ctx context.Context, topic string, msg *broker.Message) (context.Context, trace.Span) {
+	if b.consumerTracer == nil {
+		return ctx, nil
+	}
+
+	if msg == nil {
+		return ctx, nil
+	}
+
+	carrier := NewMessageCarrier(msg)
+
+	attrs := []attribute.KeyValue{
+		semConv.MessagingSystemKey.String(TracerMessageSystemKey),
+		semConv.MessagingDestinationKindTopic,
+		semConv.MessagingDestinationKey.String(topic),
+		semConv.MessagingOperationReceive,
+	}
+	if msg.ID != "" {
+		attrs = append(attrs, semConv.MessagingMessageIDKey.String(msg.ID))
+	}
+
+	return b.consumerTracer.Start(ctx, carrier, attrs...)
+}
+
+func (b *nsqBroker) finishConsumerSpan(ctx context.Context, span trace.Span, err error) {
+	if b.consumerTracer == nil {
+		return
+	}
+
+	b.consumerTracer.End(ctx, span, err)
+}
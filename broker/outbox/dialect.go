@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect adapts the SQL issued by the outbox to a database.
+type Dialect interface {
+	// Placeholder returns the bind parameter for the n-th argument, starting at 1
+	Placeholder(n int) string
+
+	// CreateTable returns the statements creating the outbox table and its indexes, each of
+	// them a no-op when the object exists
+	CreateTable(table string) []string
+}
+
+var (
+	// SQLite is the dialect of SQLite.
+	SQLite Dialect = sqliteDialect{}
+	// Postgres is the dialect of PostgreSQL.
+	Postgres Dialect = postgresDialect{}
+	// MySQL is the dialect of MySQL and MariaDB.
+	MySQL Dialect = mysqlDialect{}
+)
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (sqliteDialect) CreateTable(table string) []string {
+	return append([]string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+	id              TEXT    NOT NULL,
+	topic           TEXT    NOT NULL,
+	msg_key         TEXT    NOT NULL DEFAULT '',
+	headers         TEXT,
+	body            BLOB,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT  NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	created_at      BIGINT  NOT NULL,
+	delivered_at    BIGINT
+)`, table)}, createIndexes(table)...)
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgresDialect) CreateTable(table string) []string {
+	return append([]string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	seq             BIGSERIAL PRIMARY KEY,
+	id              TEXT   NOT NULL,
+	topic           TEXT   NOT NULL,
+	msg_key         TEXT   NOT NULL DEFAULT '',
+	headers         TEXT,
+	body            BYTEA,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	created_at      BIGINT NOT NULL,
+	delivered_at    BIGINT
+)`, table)}, createIndexes(table)...)
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+// CreateTable declares the indexes with the table, MySQL has no CREATE INDEX IF NOT EXISTS.
+func (mysqlDialect) CreateTable(table string) []string {
+	return []string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+	seq             BIGINT AUTO_INCREMENT PRIMARY KEY,
+	id              VARCHAR(64)  NOT NULL,
+	topic           VARCHAR(255) NOT NULL,
+	msg_key         VARCHAR(255) NOT NULL DEFAULT '',
+	headers         TEXT,
+	body            LONGBLOB,
+	attempts        INT    NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	created_at      BIGINT NOT NULL,
+	delivered_at    BIGINT NULL,
+	INDEX %[2]s (msg_key, seq),
+	INDEX %[3]s (delivered_at, next_attempt_at)
+)`, table, indexName(table, "key_seq"), indexName(table, "due"))}
+}
+
+// createIndexes returns the statements creating the indexes the poll relies on: due messages
+// are looked up by delivered_at and next_attempt_at, the earlier messages of a key by msg_key and seq.
+func createIndexes(table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (msg_key, seq)`, indexName(table, "key_seq"), table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (delivered_at, next_attempt_at)`, indexName(table, "due"), table),
+	}
+}
+
+// indexName derives the name of an index from the table, which may be qualified by a schema.
+func indexName(table, suffix string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '.' || r == '"' || r == '`' {
+			return '_'
+		}
+		return r
+	}, table)
+	return strings.Trim(name, "_") + "_" + suffix + "_idx"
+}
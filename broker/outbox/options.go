@@ -0,0 +1,89 @@
+package outbox
+
+import "time"
+
+const (
+	defaultTable        = "broker_outbox"
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultMinBackoff   = time.Second
+	defaultMaxBackoff   = time.Minute
+)
+
+type options struct {
+	table   string
+	dialect Dialect
+
+	pollInterval time.Duration
+	batchSize    int
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option configures an Outbox.
+type Option func(*options)
+
+func newOptions(opts ...Option) options {
+	o := options{
+		table:        defaultTable,
+		dialect:      SQLite,
+		pollInterval: defaultPollInterval,
+		batchSize:    defaultBatchSize,
+		minBackoff:   defaultMinBackoff,
+		maxBackoff:   defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTable sets the name of the outbox table.
+func WithTable(table string) Option {
+	return func(o *options) {
+		if table != "" {
+			o.table = table
+		}
+	}
+}
+
+// WithDialect sets the SQL dialect of the database, SQLite by default.
+func WithDialect(dialect Dialect) Option {
+	return func(o *options) {
+		if dialect != nil {
+			o.dialect = dialect
+		}
+	}
+}
+
+// WithPollInterval sets how often the dispatcher looks for pending messages.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.pollInterval = interval
+		}
+	}
+}
+
+// WithBatchSize sets the maximum number of messages loaded by a single dispatch.
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.batchSize = size
+		}
+	}
+}
+
+// WithBackoff sets the delay before retrying a failed publish. It doubles on every
+// failed attempt, starting at min and capped at max.
+func WithBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		if min > 0 {
+			o.minBackoff = min
+		}
+		if max >= o.minBackoff {
+			o.maxBackoff = max
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+// Outbox stores messages in a database table within the caller's transaction and relays
+// them to a broker.Broker once the transaction has committed.
+//
+// Delivery is at-least-once: a message is marked delivered after Publish returns, so a crash
+// in between publishes it again. Messages sharing a non-empty key are published in the order
+// they were stored; a failed message holds back the following messages of its key until it
+// is published. Run a single dispatcher per table, and configure the broker to publish
+// synchronously (e.g. without the Kafka async writer) so that a nil error means delivered.
+type Outbox struct {
+	sync.Mutex
+
+	db      *sql.DB
+	b       broker.Broker
+	options options
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type record struct {
+	seq      int64
+	id       string
+	topic    string
+	key      string
+	headers  sql.NullString
+	body     []byte
+	attempts int
+}
+
+// New creates an Outbox storing messages in db and publishing them through b.
+func New(db *sql.DB, b broker.Broker, opts ...Option) *Outbox {
+	return &Outbox{
+		db:      db,
+		b:       b,
+		options: newOptions(opts...),
+	}
+}
+
+// CreateTable creates the outbox table and its indexes if they do not exist yet.
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	for _, stmt := range o.options.dialect.CreateTable(o.options.table) {
+		if _, err := o.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Store encodes msg with the broker codec and inserts it into the outbox within tx.
+// The message is published only if tx commits. An ID is generated when msg has none.
+func (o *Outbox) Store(ctx context.Context, tx *sql.Tx, topic string, msg *broker.Message) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+	if topic == "" {
+		return errors.New("topic is empty")
+	}
+
+	body, err := broker.Marshal(o.b.Options().Codec, msg.Body)
+	if err != nil {
+		return err
+	}
+
+	var headers sql.NullString
+	if len(msg.Headers) > 0 {
+		buf, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return err
+		}
+		headers = sql.NullString{String: string(buf), Valid: true}
+	}
+
+	id := msg.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (id, topic, msg_key, headers, body, created_at) VALUES (%s)",
+		o.options.table, o.placeholders(1, 6))
+	_, err = tx.ExecContext(ctx, query, id, topic, msg.Key, headers, body, time.Now().UnixNano())
+	return err
+}
+
+// Dispatch publishes the pending messages that are due, up to the batch size, and reports how
+// many were delivered. A failed publish is recorded on its row and retried after a backoff.
+func (o *Outbox) Dispatch(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	records, err := o.pending(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	// keys held back by a message of the batch which failed
+	blocked := make(map[string]bool)
+
+	var delivered int
+	for _, r := range records {
+		if r.key != "" && blocked[r.key] {
+			continue
+		}
+
+		if pubErr := o.publish(ctx, r); pubErr != nil {
+			// the dispatcher is shutting down: the row stays pending untouched
+			if ctx.Err() != nil {
+				return delivered, ctx.Err()
+			}
+
+			LogWarnf("publish message [%s] to topic [%s] failed, attempt %d: %s", r.id, r.topic, r.attempts+1, pubErr.Error())
+
+			blocked[r.key] = true
+			if err = o.markFailed(ctx, r, now, pubErr); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		if err = o.markDelivered(ctx, r); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+// Start runs the dispatcher in the background until Stop is called.
+func (o *Outbox) Start(_ context.Context) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.cancel != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+	o.done = make(chan struct{})
+
+	go o.run(ctx, o.done)
+
+	LogInfo("dispatcher started")
+	return nil
+}
+
+// Stop stops the dispatcher and waits for the running dispatch to return, or for ctx to be done.
+func (o *Outbox) Stop(ctx context.Context) error {
+	o.Lock()
+	cancel, done := o.cancel, o.done
+	o.cancel, o.done = nil, nil
+	o.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	select {
+	case <-done:
+		LogInfo("dispatcher stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Purge deletes the messages delivered before the given time and reports how many were deleted.
+func (o *Outbox) Purge(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE delivered_at IS NOT NULL AND delivered_at < %s",
+		o.options.table, o.options.dialect.Placeholder(1))
+	res, err := o.db.ExecContext(ctx, query, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (o *Outbox) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(o.options.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// drain the backlog before waiting for the next tick
+		for {
+			n, err := o.Dispatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					LogErrorf("dispatch failed: %s", err.Error())
+				}
+				break
+			}
+			if n < o.options.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pending returns the oldest messages which are due at now. The messages held back by an earlier
+// message of their key waiting for its backoff are skipped, so that they do not fill the batch
+// and starve the other keys.
+func (o *Outbox) pending(ctx context.Context, now time.Time) ([]record, error) {
+	query := fmt.Sprintf(`SELECT o.seq, o.id, o.topic, o.msg_key, o.headers, o.body, o.attempts
+FROM %[1]s o WHERE o.delivered_at IS NULL AND o.next_attempt_at <= %[2]s
+AND (o.msg_key = '' OR NOT EXISTS (SELECT 1 FROM %[1]s p
+	WHERE p.msg_key = o.msg_key AND p.seq < o.seq AND p.delivered_at IS NULL AND p.next_attempt_at > %[3]s))
+ORDER BY o.seq LIMIT %[4]d`,
+		o.options.table, o.options.dialect.Placeholder(1), o.options.dialect.Placeholder(2), o.options.batchSize)
+
+	rows, err := o.db.QueryContext(ctx, query, now.UnixNano(), now.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []record
+	for rows.Next() {
+		var r record
+		if err = rows.Scan(&r.seq, &r.id, &r.topic, &r.key, &r.headers, &r.body, &r.attempts); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+func (o *Outbox) publish(ctx context.Context, r record) error {
+	opts := []broker.MessageOption{broker.WithID(r.id)}
+	if r.key != "" {
+		opts = append(opts, broker.WithKey(r.key))
+	}
+	if r.headers.Valid {
+		var headers broker.Headers
+		if err := json.Unmarshal([]byte(r.headers.String), &headers); err != nil {
+			return err
+		}
+		opts = append(opts, broker.WithHeaders(headers))
+	}
+
+	return o.b.Publish(ctx, r.topic, broker.NewMessage(broker.RawBody(r.body), opts...))
+}
+
+func (o *Outbox) markDelivered(ctx context.Context, r record) error {
+	query := fmt.Sprintf("UPDATE %s SET delivered_at = %s WHERE seq = %s",
+		o.options.table, o.options.dialect.Placeholder(1), o.options.dialect.Placeholder(2))
+	_, err := o.db.ExecContext(ctx, query, time.Now().UnixNano(), r.seq)
+	return err
+}
+
+func (o *Outbox) markFailed(ctx context.Context, r record, now time.Time, cause error) error {
+	attempts := r.attempts + 1
+	next := now.Add(o.backoff(attempts))
+
+	query := fmt.Sprintf("UPDATE %s SET attempts = %s, next_attempt_at = %s, last_error = %s WHERE seq = %s",
+		o.options.table,
+		o.options.dialect.Placeholder(1), o.options.dialect.Placeholder(2),
+		o.options.dialect.Placeholder(3), o.options.dialect.Placeholder(4))
+	_, err := o.db.ExecContext(ctx, query, attempts, next.UnixNano(), cause.Error(), r.seq)
+	return err
+}
+
+// backoff returns the delay before the next attempt of a message which failed attempts times.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.options.minBackoff
+	for i := 1; i < attempts && d < o.options.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > o.options.maxBackoff {
+		d = o.options.maxBackoff
+	}
+	return d
+}
+
+func (o *Outbox) placeholders(from, count int) string {
+	ps := make([]string, count)
+	for i := range ps {
+		ps[i] = o.options.dialect.Placeholder(from + i)
+	}
+	return strings.Join(ps, ", ")
+}
@@ -0,0 +1,333 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/tx7do/kratos-transport/broker"
+	"github.com/tx7do/kratos-transport/broker/memory"
+)
+
+type hygrothermograph struct {
+	Humidity    float64 `json:"humidity"`
+	Temperature float64 `json:"temperature"`
+}
+
+// flakyBroker fails the publishes of the topics listed in failing.
+type flakyBroker struct {
+	broker.Broker
+
+	sync.Mutex
+	failing map[string]bool
+}
+
+func (b *flakyBroker) setFailing(topic string, fail bool) {
+	b.Lock()
+	defer b.Unlock()
+	b.failing[topic] = fail
+}
+
+func (b *flakyBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	b.Lock()
+	fail := b.failing[topic]
+	b.Unlock()
+
+	if fail {
+		return errors.New("broker unavailable")
+	}
+	return b.Broker.Publish(ctx, topic, msg, opts...)
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err = db.Exec("CREATE TABLE orders (id TEXT PRIMARY KEY)"); err != nil {
+		t.Fatalf("create orders: %v", err)
+	}
+	return db
+}
+
+func newTestBroker(t *testing.T) *flakyBroker {
+	t.Helper()
+
+	b := memory.NewBroker(broker.WithCodec("json"))
+	if err := b.Init(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if err := b.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Disconnect() })
+
+	return &flakyBroker{Broker: b, failing: make(map[string]bool)}
+}
+
+func newTestOutbox(t *testing.T, db *sql.DB, b broker.Broker, opts ...Option) *Outbox {
+	t.Helper()
+
+	o := New(db, b, opts...)
+	if err := o.CreateTable(context.Background()); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	return o
+}
+
+func store(t *testing.T, db *sql.DB, o *Outbox, commit bool, topic string, msg *broker.Message) {
+	t.Helper()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err = tx.Exec("INSERT INTO orders (id) VALUES (?)", msg.ID); err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+	if err = o.Store(context.Background(), tx, topic, msg); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	if commit {
+		err = tx.Commit()
+	} else {
+		err = tx.Rollback()
+	}
+	if err != nil {
+		t.Fatalf("end transaction: %v", err)
+	}
+}
+
+func subscribe(t *testing.T, b broker.Broker, topic string) <-chan *broker.Message {
+	t.Helper()
+
+	ch := make(chan *broker.Message, 16)
+	_, err := b.Subscribe(topic, func(_ context.Context, evt broker.Event) error {
+		ch <- evt.Message()
+		return nil
+	}, func() any { return &hygrothermograph{} })
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	return ch
+}
+
+func receive(t *testing.T, ch <-chan *broker.Message) *broker.Message {
+	t.Helper()
+
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered")
+		return nil
+	}
+}
+
+func TestDispatch_CommittedOnly(t *testing.T) {
+	db := newTestDB(t)
+	b := newTestBroker(t)
+	o := newTestOutbox(t, db, b)
+	received := subscribe(t, b, "orders.created")
+
+	store(t, db, o, true, "orders.created", broker.NewMessage(&hygrothermograph{Humidity: 1},
+		broker.WithID("committed"), broker.WithKey("device-1"), broker.WithHeader("trace", "abc")))
+	store(t, db, o, false, "orders.created", broker.NewMessage(&hygrothermograph{Humidity: 2},
+		broker.WithID("rolled-back")))
+
+	n, err := o.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 delivered message, got %d", n)
+	}
+
+	msg := receive(t, received)
+	if msg.ID != "committed" || msg.Key != "device-1" || msg.GetHeader("trace") != "abc" {
+		t.Fatalf("unexpected message: id=%s key=%s headers=%v", msg.ID, msg.Key, msg.Headers)
+	}
+	if msg.Body.(*hygrothermograph).Humidity != 1 {
+		t.Fatalf("unexpected payload: %+v", msg.Body)
+	}
+
+	// delivered messages are not published again
+	if n, err = o.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected nothing to dispatch, got %d, %v", n, err)
+	}
+
+	purged, err := o.Purge(context.Background(), time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("expected 1 purged message, got %d", purged)
+	}
+}
+
+func TestDispatch_RetryWithBackoff(t *testing.T) {
+	db := newTestDB(t)
+	b := newTestBroker(t)
+	o := newTestOutbox(t, db, b, WithBackoff(50*time.Millisecond, time.Second))
+	received := subscribe(t, b, "orders.created")
+
+	store(t, db, o, true, "orders.created", broker.NewMessage(&hygrothermograph{Humidity: 1}, broker.WithID("order-1")))
+
+	b.setFailing("orders.created", true)
+	if n, err := o.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected failed dispatch, got %d, %v", n, err)
+	}
+
+	var (
+		attempts  int
+		lastError string
+	)
+	if err := db.QueryRow("SELECT attempts, last_error FROM broker_outbox WHERE id = 'order-1'").Scan(&attempts, &lastError); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if attempts != 1 || lastError != "broker unavailable" {
+		t.Fatalf("failure not recorded: attempts=%d error=%q", attempts, lastError)
+	}
+
+	// the broker is back, but the message waits for its backoff
+	b.setFailing("orders.created", false)
+	if n, err := o.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("message dispatched before its backoff elapsed: %d, %v", n, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n, err := o.Dispatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected 1 delivered message, got %d, %v", n, err)
+	}
+	if msg := receive(t, received); msg.ID != "order-1" {
+		t.Fatalf("unexpected message: %s", msg.ID)
+	}
+}
+
+func TestDispatch_OrderedPerKey(t *testing.T) {
+	db := newTestDB(t)
+	b := newTestBroker(t)
+	o := newTestOutbox(t, db, b, WithBackoff(time.Millisecond, time.Millisecond))
+	received := subscribe(t, b, "orders")
+	others := subscribe(t, b, "audit")
+
+	store(t, db, o, true, "failing", broker.NewMessage(&hygrothermograph{}, broker.WithID("a-1"), broker.WithKey("a")))
+	store(t, db, o, true, "orders", broker.NewMessage(&hygrothermograph{}, broker.WithID("a-2"), broker.WithKey("a")))
+	store(t, db, o, true, "audit", broker.NewMessage(&hygrothermograph{}, broker.WithID("b-1"), broker.WithKey("b")))
+
+	// the first message of key "a" fails: the second one waits, key "b" is not affected
+	b.setFailing("failing", true)
+	if n, err := o.Dispatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected 1 delivered message, got %d, %v", n, err)
+	}
+	if msg := receive(t, others); msg.ID != "b-1" {
+		t.Fatalf("unexpected message: %s", msg.ID)
+	}
+	select {
+	case msg := <-received:
+		t.Fatalf("message %s overtook the failed message of its key", msg.ID)
+	default:
+	}
+
+	b.setFailing("failing", false)
+	time.Sleep(5 * time.Millisecond)
+	if n, err := o.Dispatch(context.Background()); err != nil || n != 2 {
+		t.Fatalf("expected 2 delivered messages, got %d, %v", n, err)
+	}
+	if msg := receive(t, received); msg.ID != "a-2" {
+		t.Fatalf("unexpected message: %s", msg.ID)
+	}
+}
+
+func TestDispatch_BlockedKeyDoesNotStarveOthers(t *testing.T) {
+	db := newTestDB(t)
+	b := newTestBroker(t)
+	o := newTestOutbox(t, db, b, WithBatchSize(2), WithBackoff(time.Hour, time.Hour))
+	others := subscribe(t, b, "audit")
+
+	// more messages than the batch size queue behind the failing message of key "a"
+	store(t, db, o, true, "failing", broker.NewMessage(&hygrothermograph{}, broker.WithID("a-1"), broker.WithKey("a")))
+	for _, id := range []string{"a-2", "a-3", "a-4"} {
+		store(t, db, o, true, "orders", broker.NewMessage(&hygrothermograph{}, broker.WithID(id), broker.WithKey("a")))
+	}
+	store(t, db, o, true, "audit", broker.NewMessage(&hygrothermograph{}, broker.WithID("b-1"), broker.WithKey("b")))
+
+	b.setFailing("failing", true)
+	if n, err := o.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected failed dispatch, got %d, %v", n, err)
+	}
+
+	// while "a-1" waits for its backoff, the messages of key "a" are paged past
+	if n, err := o.Dispatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected 1 delivered message, got %d, %v", n, err)
+	}
+	if msg := receive(t, others); msg.ID != "b-1" {
+		t.Fatalf("unexpected message: %s", msg.ID)
+	}
+
+	var pending int
+	if err := db.QueryRow("SELECT COUNT(*) FROM broker_outbox WHERE delivered_at IS NULL").Scan(&pending); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if pending != 4 {
+		t.Fatalf("expected the 4 messages of key \"a\" to be pending, got %d", pending)
+	}
+}
+
+func TestCreateTable_Indexes(t *testing.T) {
+	db := newTestDB(t)
+	o := newTestOutbox(t, db, newTestBroker(t))
+
+	// creating the table again is a no-op
+	if err := o.CreateTable(context.Background()); err != nil {
+		t.Fatalf("create table again: %v", err)
+	}
+
+	for _, name := range []string{"broker_outbox_key_seq_idx", "broker_outbox_due_idx"} {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?", name).Scan(&n); err != nil {
+			t.Fatalf("query index: %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("expected index %s", name)
+		}
+	}
+
+	if name := indexName("public.outbox", "due"); name != "public_outbox_due_idx" {
+		t.Fatalf("unexpected index name: %s", name)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	db := newTestDB(t)
+	b := newTestBroker(t)
+	o := newTestOutbox(t, db, b, WithPollInterval(10*time.Millisecond))
+	received := subscribe(t, b, "orders.created")
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	store(t, db, o, true, "orders.created", broker.NewMessage(&hygrothermograph{Humidity: 3}, broker.WithID("order-1")))
+	if msg := receive(t, received); msg.ID != "order-1" {
+		t.Fatalf("unexpected message: %s", msg.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := o.Stop(ctx); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+}
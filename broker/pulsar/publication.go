@@ -3,10 +3,16 @@ package pulsar
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
 	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/tx7do/kratos-transport/broker"
 )
 
+var _ broker.Nacker = (*publication)(nil)
+
 type publication struct {
 	topic     string
 	err       error
@@ -14,6 +20,10 @@ type publication struct {
 	reader    pulsar.Consumer
 	msg       *broker.Message
 	pulsarMsg *pulsar.Message
+
+	// retryEnable is set when the subscription has a retry topic, see WithSubscriptionRetryEnable
+	retryEnable bool
+	settled     atomic.Bool
 }
 
 func (p *publication) Topic() string {
@@ -28,13 +38,64 @@ func (p *publication) RawMessage() any {
 	return p.pulsarMsg
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.pulsarMsg == nil || *p.pulsarMsg == nil {
+		return nil
+	}
+	return (*p.pulsarMsg).Payload()
+}
+
 func (p *publication) Ack() error {
 	if p.reader == nil {
 		return errors.New("reader is nil")
 	}
+	if !p.settled.CompareAndSwap(false, true) {
+		return nil
+	}
 	return p.reader.Ack(*p.pulsarMsg)
 }
 
+// Nack requests the redelivery of the message after the NackRedeliveryDelay of the
+// subscription. Pulsar cannot drop a message without acknowledging it: without requeue,
+// it returns broker.ErrNotSupported; a DLQ policy dead-letters messages nacked too often.
+func (p *publication) Nack(requeue bool) error {
+	if !requeue {
+		return fmt.Errorf("pulsar cannot reject a message: %w", broker.ErrNotSupported)
+	}
+	if err := p.settle(); err != nil {
+		return err
+	}
+	p.reader.Nack(*p.pulsarMsg)
+	return nil
+}
+
+// NackWithDelay sends the message to the retry topic to be redelivered after delay when the
+// subscription has one, and nacks it once delay has elapsed otherwise.
+func (p *publication) NackWithDelay(delay time.Duration) error {
+	if err := p.settle(); err != nil {
+		return err
+	}
+	if p.retryEnable {
+		p.reader.ReconsumeLater(*p.pulsarMsg, delay)
+		return nil
+	}
+	time.AfterFunc(delay, func() {
+		p.reader.Nack(*p.pulsarMsg)
+	})
+	return nil
+}
+
+func (p *publication) settle() error {
+	if p.reader == nil {
+		return errors.New("reader is nil")
+	}
+	if !p.settled.CompareAndSwap(false, true) {
+		return errors.New("the message is already settled")
+	}
+	return nil
+}
+
 func (p *publication) Error() error {
 	return p.err
 }
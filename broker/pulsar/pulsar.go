@@ -30,6 +30,8 @@ const (
 	SpanNameConsumer       = "pulsar-consumer"
 )
 
+var _ broker.BatchPublisher = (*pulsarBroker)(nil)
+
 type pulsarBroker struct {
 	sync.RWMutex
 
@@ -41,6 +43,8 @@ type pulsarBroker struct {
 
 	subscribers *broker.SubscriberSyncMap
 
+	requester *broker.Requester
+
 	producerTracer *tracing.Tracer
 	consumerTracer *tracing.Tracer
 }
@@ -53,6 +57,7 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		producers:   make(map[string]pulsar.Producer),
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	b.requester = broker.NewRequester(b)
 
 	return b
 }
@@ -161,6 +166,8 @@ func (pb *pulsarBroker) Disconnect() error {
 	}
 	pb.RUnlock()
 
+	_ = pb.requester.Close()
+
 	pb.Lock()
 	defer pb.Unlock()
 
@@ -177,7 +184,7 @@ func (pb *pulsarBroker) Disconnect() error {
 }
 
 func (pb *pulsarBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return pb.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (pb *pulsarBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -195,6 +202,7 @@ func (pb *pulsarBroker) internalPublish(ctx context.Context, topic string, msg *
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -210,49 +218,12 @@ func (pb *pulsarBroker) publish(ctx context.Context, topic string, msg *broker.M
 		o(&options)
 	}
 
-	pulsarOptions := pulsar.ProducerOptions{
-		Topic:           topic,
-		DisableBatching: false,
-	}
+	pulsarOptions := producerOptions(topic, options)
 
-	if v, ok := options.Context.Value(producerNameKey{}).(string); ok {
-		pulsarOptions.Name = v
-	}
-	if v, ok := options.Context.Value(producerPropertiesKey{}).(map[string]string); ok {
-		pulsarOptions.Properties = v
-	}
-	if v, ok := options.Context.Value(sendTimeoutKey{}).(time.Duration); ok {
-		pulsarOptions.SendTimeout = v
-	}
-	if v, ok := options.Context.Value(disableBatchingKey{}).(bool); ok {
-		pulsarOptions.DisableBatching = v
-	}
-	if v, ok := options.Context.Value(batchingMaxPublishDelayKey{}).(time.Duration); ok {
-		pulsarOptions.BatchingMaxPublishDelay = v
-	}
-	if v, ok := options.Context.Value(batchingMaxMessagesKey{}).(uint); ok {
-		pulsarOptions.BatchingMaxMessages = v
-	}
-	if v, ok := options.Context.Value(batchingMaxSizeKey{}).(uint); ok {
-		pulsarOptions.BatchingMaxSize = v
-	}
-
-	var cached bool
-	pb.Lock()
-	producer, ok := pb.producers[topic]
-	if !ok {
-		var err error
-		producer, err = pb.client.CreateProducer(pulsarOptions)
-		if err != nil {
-			pb.Unlock()
-			return err
-		}
-
-		pb.producers[topic] = producer
-	} else {
-		cached = true
+	producer, cached, err := pb.producer(topic, pulsarOptions)
+	if err != nil {
+		return err
 	}
-	pb.Unlock()
 
 	pulsarMsg := pulsar.ProducerMessage{
 		Payload:    msg.BodyBytes(),
@@ -291,7 +262,6 @@ func (pb *pulsarBroker) publish(ctx context.Context, topic string, msg *broker.M
 	var span trace.Span
 	ctx, span = pb.startProducerSpan(options.Context, topic, &pulsarMsg)
 
-	var err error
 	var messageId pulsar.MessageID
 	messageId, err = producer.Send(pb.options.Context, &pulsarMsg)
 	if err != nil {
@@ -326,6 +296,127 @@ func (pb *pulsarBroker) publish(ctx context.Context, topic string, msg *broker.M
 	return err
 }
 
+// PublishBatch publishes msgs to topic with SendAsync, letting the producer batch them,
+// then flushes the producer and waits for every send receipt.
+func (pb *pulsarBroker) PublishBatch(ctx context.Context, topic string, msgs []*broker.Message, opts ...broker.PublishOption) ([]broker.PublishResult, error) {
+	options := broker.PublishOptions{
+		Context: ctx,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	producer, _, err := pb.producer(topic, producerOptions(topic, options))
+	if err != nil {
+		return nil, err
+	}
+
+	results := broker.NewPublishResults(msgs)
+
+	var wg sync.WaitGroup
+	for i, msg := range msgs {
+		if msg == nil {
+			results[i].Err = errors.New("message is nil")
+			continue
+		}
+
+		buf, err := broker.Marshal(pb.options.Codec, msg.Body)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		pulsarMsg := &pulsar.ProducerMessage{
+			Payload:    buf,
+			Key:        msg.Key,
+			Properties: msg.HeadersCopy(),
+		}
+		if v, ok := options.Context.Value(messageDeliverAfterKey{}).(time.Duration); ok {
+			pulsarMsg.DeliverAfter = v
+		}
+		if v, ok := options.Context.Value(messageDeliverAtKey{}).(time.Time); ok {
+			pulsarMsg.DeliverAt = v
+		}
+		if v, ok := options.Context.Value(messageEventTimeKey{}).(time.Time); ok {
+			pulsarMsg.EventTime = v
+		}
+		if v, ok := options.Context.Value(messageDisableReplication{}).(bool); ok {
+			pulsarMsg.DisableReplication = v
+		}
+
+		spanCtx, span := pb.startProducerSpan(options.Context, topic, pulsarMsg)
+
+		wg.Add(1)
+		producer.SendAsync(options.Context, pulsarMsg, func(messageId pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+			defer wg.Done()
+
+			var msgId string
+			if messageId != nil {
+				msgId = strconv.FormatInt(messageId.EntryID(), 10)
+			}
+			// every callback writes its own result
+			results[i].Err = err
+			pb.finishProducerSpan(spanCtx, span, msgId, err)
+		})
+	}
+
+	if err = producer.FlushWithCtx(options.Context); err != nil {
+		LogErrorf("flush producer error: %s", err)
+	}
+	wg.Wait()
+
+	return results, broker.JoinPublishResults(results)
+}
+
+// producer returns the cached producer of topic, creating it if needed, and reports whether it was cached
+func (pb *pulsarBroker) producer(topic string, pulsarOptions pulsar.ProducerOptions) (pulsar.Producer, bool, error) {
+	pb.Lock()
+	defer pb.Unlock()
+
+	if producer, ok := pb.producers[topic]; ok {
+		return producer, true, nil
+	}
+
+	producer, err := pb.client.CreateProducer(pulsarOptions)
+	if err != nil {
+		return nil, false, err
+	}
+	pb.producers[topic] = producer
+
+	return producer, false, nil
+}
+
+func producerOptions(topic string, options broker.PublishOptions) pulsar.ProducerOptions {
+	pulsarOptions := pulsar.ProducerOptions{
+		Topic:           topic,
+		DisableBatching: false,
+	}
+
+	if v, ok := options.Context.Value(producerNameKey{}).(string); ok {
+		pulsarOptions.Name = v
+	}
+	if v, ok := options.Context.Value(producerPropertiesKey{}).(map[string]string); ok {
+		pulsarOptions.Properties = v
+	}
+	if v, ok := options.Context.Value(sendTimeoutKey{}).(time.Duration); ok {
+		pulsarOptions.SendTimeout = v
+	}
+	if v, ok := options.Context.Value(disableBatchingKey{}).(bool); ok {
+		pulsarOptions.DisableBatching = v
+	}
+	if v, ok := options.Context.Value(batchingMaxPublishDelayKey{}).(time.Duration); ok {
+		pulsarOptions.BatchingMaxPublishDelay = v
+	}
+	if v, ok := options.Context.Value(batchingMaxMessagesKey{}).(uint); ok {
+		pulsarOptions.BatchingMaxMessages = v
+	}
+	if v, ok := options.Context.Value(batchingMaxSizeKey{}).(uint); ok {
+		pulsarOptions.BatchingMaxSize = v
+	}
+
+	return pulsarOptions
+}
+
 func (pb *pulsarBroker) Subscribe(topic string, handler broker.Handler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
 	options := broker.SubscribeOptions{
 		Context: context.Background(),
@@ -336,9 +427,10 @@ func (pb *pulsarBroker) Subscribe(topic string, handler broker.Handler, binder b
 		o(&options)
 	}
 
-	if len(pb.options.SubscriberMiddlewares) > 0 {
+	if len(pb.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, pb.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(pb, handler, options)
 
 	pulsarOptions := pulsar.ConsumerOptions{
 		Topic:            topic,
@@ -389,44 +481,49 @@ func (pb *pulsarBroker) Subscribe(topic string, handler broker.Handler, binder b
 		handler: handler,
 		reader:  c,
 		channel: channel,
+		// hand messages to a worker pool so that SubscribeOptions.Concurrency is honoured
+		pool: broker.NewSubscribeWorkerPool(options),
 	}
 
 	go func() {
-		var err error
-		var m broker.Message
 		for cm := range channel {
-			p := &publication{topic: cm.Topic(), reader: sub.reader, msg: &m, pulsarMsg: &cm.Message, ctx: options.Context}
-			m.Headers = cm.Properties()
+			sub.pool.Submit(func() {
+				var err error
+				var m broker.Message
 
-			ctx, span := pb.startConsumerSpan(sub.options.Context, &cm)
+				p := &publication{topic: cm.Topic(), reader: sub.reader, msg: &m, pulsarMsg: &cm.Message, ctx: options.Context, retryEnable: pulsarOptions.RetryEnable}
+				m.Headers = cm.Properties()
 
-			if binder != nil {
-				m.Body = binder()
+				ctx, span := pb.startConsumerSpan(sub.options.Context, &cm)
 
-				if err = broker.Unmarshal(pb.options.Codec, cm.Payload(), &m.Body); err != nil {
-					LogErrorf("unmarshal message failed: %v", err)
-					pb.finishConsumerSpan(ctx, span, err)
-					continue
-				}
-			} else {
-				m.Body = cm.Payload()
-			}
+				if binder != nil {
+					m.Body = binder()
 
-			if err = sub.handler(ctx, p); err != nil {
-				p.err = err
-				LogErrorf("handle message failed: %v", err)
-				pb.finishConsumerSpan(ctx, span, err)
-				continue
-			}
+					if err = broker.Unmarshal(pb.options.Codec, cm.Payload(), &m.Body); err != nil {
+						LogErrorf("unmarshal message failed: %v", err)
+						pb.finishConsumerSpan(ctx, span, err)
+						return
+					}
+				} else {
+					m.Body = cm.Payload()
+				}
 
-			if sub.options.AutoAck {
-				if err = p.Ack(); err != nil {
+				if err = sub.handler(ctx, p); err != nil {
 					p.err = err
-					LogErrorf("unable to commit msg: %v", err)
+					LogErrorf("handle message failed: %v", err)
+					pb.finishConsumerSpan(ctx, span, err)
+					return
 				}
-			}
 
-			pb.finishConsumerSpan(ctx, span, err)
+				if sub.options.AutoAck {
+					if err = p.Ack(); err != nil {
+						p.err = err
+						LogErrorf("unable to commit msg: %v", err)
+					}
+				}
+
+				pb.finishConsumerSpan(ctx, span, err)
+			})
 		}
 	}()
 
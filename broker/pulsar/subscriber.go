@@ -15,6 +15,7 @@ type subscriber struct {
 	options broker.SubscribeOptions
 	handler broker.Handler
 	reader  pulsar.Consumer
+	pool    *broker.WorkerPool
 	closed  bool
 	channel chan pulsar.ConsumerMessage
 	done    chan struct{}
@@ -35,6 +36,9 @@ func (s *subscriber) Topic() string {
 }
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
+	// the handlers ack through the consumer, they finish before it is closed
+	s.pool.Drain()
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -51,6 +51,15 @@ func (r *rabbitChannel) Publish(ctx context.Context, exchangeName, key string, m
 	return r.channel.PublishWithContext(ctx, exchangeName, key, mandatory, false, message)
 }
 
+// PublishWithDeferredConfirm publishes message and returns its pending confirmation.
+// The confirmation is nil unless the channel is in confirm mode.
+func (r *rabbitChannel) PublishWithDeferredConfirm(ctx context.Context, exchangeName, key string, mandatory bool, message amqp.Publishing) (*amqp.DeferredConfirmation, error) {
+	if r.channel == nil {
+		return nil, errors.New("channel is nil")
+	}
+	return r.channel.PublishWithDeferredConfirmWithContext(ctx, exchangeName, key, mandatory, false, message)
+}
+
 func (r *rabbitChannel) DeclareExchange(exchangeName, kind string, durable, autoDelete bool) error {
 	return r.channel.ExchangeDeclare(
 		exchangeName,
@@ -75,7 +84,7 @@ func (r *rabbitChannel) DeclareQueue(queueName string, args amqp.Table, durable,
 	return err
 }
 
-func (r *rabbitChannel) ConsumeQueue(queueName string, autoAck bool) (<-chan amqp.Delivery, error) {
+func (r *rabbitChannel) ConsumeQueue(queueName string, autoAck bool, args amqp.Table) (<-chan amqp.Delivery, error) {
 	return r.channel.Consume(
 		queueName,
 		r.uuid,
@@ -83,7 +92,7 @@ func (r *rabbitChannel) ConsumeQueue(queueName string, autoAck bool) (<-chan amq
 		false,
 		false,
 		false,
-		nil,
+		args,
 	)
 }
 
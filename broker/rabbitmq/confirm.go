@@ -0,0 +1,30 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPublishNacked is returned by a confirmed publish when the broker nacks the message,
+// or when the channel closes before the confirmation arrives.
+var ErrPublishNacked = errors.New("rabbitmq: message nacked by the broker")
+
+var errConfirmModeDisabled = errors.New("rabbitmq: WithPublishConfirm requires WithConfirmMode")
+
+// deferredConfirmation is the part of amqp.DeferredConfirmation a confirmed publish waits on.
+type deferredConfirmation interface {
+	WaitContext(ctx context.Context) (bool, error)
+}
+
+// waitConfirm blocks until the broker acks or nacks the delivery, or ctx is done.
+func waitConfirm(ctx context.Context, confirmation deferredConfirmation) error {
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("rabbitmq: waiting for publisher confirm: %w", err)
+	}
+	if !acked {
+		return ErrPublishNacked
+	}
+	return nil
+}
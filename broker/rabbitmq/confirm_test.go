@@ -0,0 +1,54 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+type fakeConfirmation struct {
+	done chan struct{}
+	ack  bool
+}
+
+func (c *fakeConfirmation) WaitContext(ctx context.Context) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-c.done:
+	}
+	return c.ack, nil
+}
+
+func settledConfirmation(ack bool) *fakeConfirmation {
+	c := &fakeConfirmation{done: make(chan struct{}), ack: ack}
+	close(c.done)
+	return c
+}
+
+func TestWaitConfirm(t *testing.T) {
+	assert.Nil(t, waitConfirm(context.Background(), settledConfirmation(true)))
+	assert.ErrorIs(t, waitConfirm(context.Background(), settledConfirmation(false)), ErrPublishNacked)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := waitConfirm(ctx, &fakeConfirmation{done: make(chan struct{})})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestPublishWithConfirm_RequiresConfirmMode(t *testing.T) {
+	conn := newRabbitMQConnection(broker.NewOptionsAndApply())
+
+	_, err := conn.PublishWithConfirm(context.Background(), "", "key", false, newPublishing(nil, nil, broker.NewPublishOptions()))
+	assert.ErrorIs(t, err, errConfirmModeDisabled)
+}
+
+func TestConfirmPublish(t *testing.T) {
+	assert.False(t, confirmPublish(broker.NewPublishOptions()))
+	assert.True(t, confirmPublish(broker.NewPublishOptions(WithPublishConfirm())))
+}
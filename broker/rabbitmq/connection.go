@@ -70,6 +70,9 @@ type rabbitConnection struct {
 	onReturn    ReturnHandler
 	onConfirm   ConfirmHandler
 
+	topology       *Topology
+	topologyDryRun bool
+
 	connected      bool
 	close          chan bool
 	waitConnection chan struct{}
@@ -156,6 +159,13 @@ func (r *rabbitConnection) init() {
 	if val, ok := r.options.Context.Value(onConfirmKey{}).(ConfirmHandler); ok {
 		r.onConfirm = val
 	}
+
+	if val, ok := r.options.Context.Value(topologyKey{}).(*Topology); ok {
+		r.topology = val
+	}
+	if val, ok := r.options.Context.Value(topologyDryRunKey{}).(bool); ok {
+		r.topologyDryRun = val
+	}
 }
 
 func (r *rabbitConnection) connect(secure bool, config *amqp.Config) error {
@@ -285,6 +295,12 @@ func (r *rabbitConnection) tryConnect(secure bool, config *amqp.Config) error {
 		}
 	}
 
+	// declare the topology on every (re)connect, before subscribers consume again
+	if err = r.declareTopology(); err != nil {
+		_ = r.Connection.Close()
+		return err
+	}
+
 	if !EnableLazyInitPublishChannel {
 		r.ExchangeChannel, err = newRabbitChannel(r.Connection, r.qos)
 	}
@@ -292,6 +308,26 @@ func (r *rabbitConnection) tryConnect(secure bool, config *amqp.Config) error {
 	return err
 }
 
+// declareTopology applies the configured topology, or only logs its diff in dry-run mode.
+func (r *rabbitConnection) declareTopology() error {
+	if r.topology == nil {
+		return nil
+	}
+
+	if !r.topologyDryRun {
+		return applyTopology(r.Connection, r.topology)
+	}
+
+	changes, err := diffTopology(r.Connection, r.topology)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		LogInfof("topology dry-run: %s", change)
+	}
+	return nil
+}
+
 func (r *rabbitConnection) GetExchange(name string) (*Exchange, error) {
 	if name == "" {
 		name = r.defaultExchangeName
@@ -303,13 +339,13 @@ func (r *rabbitConnection) GetExchange(name string) (*Exchange, error) {
 	return ex, nil
 }
 
-func (r *rabbitConnection) Consume(exchangeName, queueName, routingKey string, bindArgs amqp.Table, qArgs amqp.Table, autoAck, durableQueue, autoDel bool) (*rabbitChannel, <-chan amqp.Delivery, error) {
+func (r *rabbitConnection) Consume(exchangeName, queueName, routingKey string, bindArgs, qArgs, consumeArgs amqp.Table, qos Qos, autoAck, durableQueue, autoDel bool) (*rabbitChannel, <-chan amqp.Delivery, error) {
 	ex, err := r.GetExchange(exchangeName)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	consumerChannel, err := newRabbitChannel(r.Connection, r.qos)
+	consumerChannel, err := newRabbitChannel(r.Connection, qos)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -322,7 +358,7 @@ func (r *rabbitConnection) Consume(exchangeName, queueName, routingKey string, b
 		return nil, nil, err
 	}
 
-	deliveries, err := consumerChannel.ConsumeQueue(queueName, autoAck)
+	deliveries, err := consumerChannel.ConsumeQueue(queueName, autoAck, consumeArgs)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -334,6 +370,15 @@ func (r *rabbitConnection) Consume(exchangeName, queueName, routingKey string, b
 	return consumerChannel, deliveries, nil
 }
 
+// streamQos is the qos of stream consumers, which RabbitMQ requires to have a prefetch.
+func (r *rabbitConnection) streamQos() Qos {
+	qos := r.qos
+	if qos.PrefetchCount == 0 {
+		qos.PrefetchCount = DefaultStreamPrefetchCount
+	}
+	return qos
+}
+
 func (r *rabbitConnection) DeclarePublishQueue(exchangeName, queueName, routingKey string, bindArgs amqp.Table, queueArgs amqp.Table, durableQueue, autoDel bool) error {
 	if err := r.lazyInitPublishChannel(); err != nil {
 		return err
@@ -368,6 +413,27 @@ func (r *rabbitConnection) Publish(ctx context.Context, exchangeName, routingKey
 	return r.ExchangeChannel.Publish(ctx, exchangeName, routingKey, mandatory, msg)
 }
 
+// PublishWithConfirm publishes msg on the confirm-mode publish channel and returns the
+// confirmation of its delivery tag.
+func (r *rabbitConnection) PublishWithConfirm(ctx context.Context, exchangeName, routingKey string, mandatory bool, msg amqp.Publishing) (*amqp.DeferredConfirmation, error) {
+	if !r.confirmMode {
+		return nil, errConfirmModeDisabled
+	}
+
+	if err := r.lazyInitPublishChannel(); err != nil {
+		return nil, err
+	}
+
+	confirmation, err := r.ExchangeChannel.PublishWithDeferredConfirm(ctx, exchangeName, routingKey, mandatory, msg)
+	if err != nil {
+		return nil, err
+	}
+	if confirmation == nil {
+		return nil, errConfirmModeDisabled
+	}
+	return confirmation, nil
+}
+
 func (r *rabbitConnection) lazyInitPublishChannel() error {
 	r.Lock()
 	defer r.Unlock()
@@ -84,6 +84,7 @@ type ConfirmHandler func(amqp.Confirmation)
 
 // WithConfirmMode enables publisher confirms on the publish channel.
 // When enabled, the broker will acknowledge each published message.
+// Use WithPublishConfirm to wait for the confirmation of a single publish.
 func WithConfirmMode() broker.Option {
 	return broker.OptionContextWithValue(confirmModeKey{}, true)
 }
@@ -100,6 +101,20 @@ func WithOnConfirm(handler ConfirmHandler) broker.Option {
 	return broker.OptionContextWithValue(onConfirmKey{}, handler)
 }
 
+type topologyKey struct{}
+type topologyDryRunKey struct{}
+
+// WithTopology declares t when connecting and again after every reconnect.
+// Connecting fails when the topology cannot be declared.
+func WithTopology(t *Topology) broker.Option {
+	return broker.OptionContextWithValue(topologyKey{}, t)
+}
+
+// WithTopologyDryRun only logs what WithTopology would change instead of declaring it.
+func WithTopologyDryRun() broker.Option {
+	return broker.OptionContextWithValue(topologyDryRunKey{}, true)
+}
+
 ///
 /// SubscribeOption
 ///
@@ -140,6 +155,36 @@ func WithAckOnSuccess() broker.SubscribeOption {
 	return broker.SubscribeContextWithValue(ackSuccessKey{}, true)
 }
 
+type streamQueueKey struct{}
+type streamOffsetKey struct{}
+type streamOffsetStoreKey struct{}
+type streamConsumerNameKey struct{}
+
+// WithStreamQueue consumes from a RabbitMQ stream queue: the queue set with broker.WithSubscribeQueueName
+// is declared with x-queue-type=stream, deliveries are acknowledged after the handler and
+// Message.Offset carries the stream offset of each message.
+func WithStreamQueue() broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(streamQueueKey{}, true)
+}
+
+// WithStreamOffset sets where a stream consumer starts when no offset is stored for it,
+// StreamOffsetNext by default.
+func WithStreamOffset(offset StreamOffset) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(streamOffsetKey{}, offset)
+}
+
+// WithStreamOffsetStore stores the offset of every message the handler processed successfully,
+// so the consumer resumes after it. Without it the offsets are kept in memory for the subscription.
+func WithStreamOffsetStore(store StreamOffsetStore) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(streamOffsetStoreKey{}, store)
+}
+
+// WithStreamConsumerName names the stream consumer its offsets are stored under,
+// the routing key of the subscription by default.
+func WithStreamConsumerName(name string) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(streamConsumerNameKey{}, name)
+}
+
 type subscribeExchangeKey struct{}
 
 // WithSubscribeExchange specifies which exchange to bind the queue to for this subscription.
@@ -260,6 +305,16 @@ func WithMandatory() broker.PublishOption {
 	return broker.PublishContextWithValue(mandatoryKey{}, true)
 }
 
+type publishConfirmKey struct{}
+
+// WithPublishConfirm makes Publish wait until the broker acks or nacks the message, returning
+// ErrPublishNacked on a nack. The wait is bounded by broker.WithPublishTimeout and the publish context.
+// With broker.WithPublishAsync, Publish returns once the message is written and the callback
+// receives the confirmation result. Requires WithConfirmMode.
+func WithPublishConfirm() broker.PublishOption {
+	return broker.PublishContextWithValue(publishConfirmKey{}, true)
+}
+
 type publishExchangeKey struct{}
 
 // WithPublishExchange specifies which exchange to publish to.
@@ -1,21 +1,68 @@
 package rabbitmq
 
 import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/tx7do/kratos-transport/broker"
 )
 
+var _ broker.Nacker = (*publication)(nil)
+
 type publication struct {
 	d       amqp.Delivery
 	message *broker.Message
 	topic   string
 	err     error
+
+	// autoAck is set when the server acknowledged the delivery when sending it
+	autoAck bool
+	settled atomic.Bool
 }
 
 func (p *publication) Ack() error {
+	if !p.settled.CompareAndSwap(false, true) {
+		return nil
+	}
 	return p.d.Ack(false)
 }
 
+// Nack rejects the delivery, which the server requeues or, without requeue,
+// dead-letters to the dead-letter exchange of the queue or drops.
+func (p *publication) Nack(requeue bool) error {
+	if err := p.settle(); err != nil {
+		return err
+	}
+	return p.d.Nack(false, requeue)
+}
+
+// NackWithDelay keeps the delivery unacknowledged for delay, counting against the prefetch
+// of the channel, then requeues it: RabbitMQ has no native delayed redelivery.
+func (p *publication) NackWithDelay(delay time.Duration) error {
+	if err := p.settle(); err != nil {
+		return err
+	}
+	time.AfterFunc(delay, func() {
+		if err := p.d.Nack(false, true); err != nil {
+			LogErrorf("delayed nack failed: %v", err)
+		}
+	})
+	return nil
+}
+
+func (p *publication) settle() error {
+	if p.autoAck {
+		return fmt.Errorf("the delivery was acknowledged on receipt, subscribe with broker.DisableAutoAck() or WithAckOnSuccess(): %w", broker.ErrNotSupported)
+	}
+	if !p.settled.CompareAndSwap(false, true) {
+		return errors.New("the delivery is already settled")
+	}
+	return nil
+}
+
 func (p *publication) Error() error {
 	return p.err
 }
@@ -31,3 +78,8 @@ func (p *publication) Message() *broker.Message {
 func (p *publication) RawMessage() any {
 	return p.d
 }
+
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	return p.d.Body
+}
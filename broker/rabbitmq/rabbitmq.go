@@ -26,6 +26,11 @@ const (
 	Protocol        = "AMQP"
 )
 
+var (
+	_ broker.BatchPublisher = (*rabbitBroker)(nil)
+	_ TopologyDeclarer      = (*rabbitBroker)(nil)
+)
+
 type rabbitBroker struct {
 	mtx sync.Mutex
 	wg  sync.WaitGroup
@@ -35,6 +40,8 @@ type rabbitBroker struct {
 
 	subscribers *broker.SubscriberSyncMap
 
+	requester *broker.Requester
+
 	producerTracer *tracing.Tracer
 	consumerTracer *tracing.Tracer
 }
@@ -46,6 +53,8 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		options:     options,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	// replies are consumed from a server-named queue which is removed with its consumer
+	b.requester = broker.NewRequester(b, broker.WithReplySubscribeOptions(WithAutoDeleteQueue()))
 
 	return b
 }
@@ -112,6 +121,8 @@ func (b *rabbitBroker) Disconnect() error {
 		return errors.New("connection is nil")
 	}
 
+	_ = b.requester.Close()
+
 	b.subscribers.Clear()
 
 	ret := b.conn.Close()
@@ -120,8 +131,25 @@ func (b *rabbitBroker) Disconnect() error {
 	return ret
 }
 
+// ApplyTopology declares t on the current connection. Use WithTopology to have it
+// re-declared after reconnects as well.
+func (b *rabbitBroker) ApplyTopology(t *Topology) error {
+	if b.conn == nil || b.conn.Connection == nil {
+		return errors.New("not connected")
+	}
+	return applyTopology(b.conn.Connection, t)
+}
+
+// DiffTopology reports what ApplyTopology would change, without declaring anything.
+func (b *rabbitBroker) DiffTopology(t *Topology) ([]TopologyChange, error) {
+	if b.conn == nil || b.conn.Connection == nil {
+		return nil, errors.New("not connected")
+	}
+	return diffTopology(b.conn.Connection, t)
+}
+
 func (b *rabbitBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *rabbitBroker) Publish(ctx context.Context, routingKey string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -139,6 +167,7 @@ func (b *rabbitBroker) internalPublish(ctx context.Context, topic string, msg *b
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -158,12 +187,176 @@ func (b *rabbitBroker) publish(ctx context.Context, routingKey string, msg *brok
 		o(&options)
 	}
 
+	rMsg := newPublishing(msg.BodyBytes(), msg.Headers, options)
+
+	exchangeName, mandatory, err := b.preparePublish(routingKey, options)
+	if err != nil {
+		return err
+	}
+
+	var span trace.Span
+	ctx, span = b.startProducerSpan(options.Context, routingKey, &rMsg)
+
+	if confirmPublish(options) {
+		return b.publishConfirmed(ctx, span, exchangeName, routingKey, mandatory, rMsg, options)
+	}
+
+	err = b.conn.Publish(ctx, exchangeName, routingKey, mandatory, rMsg)
+
+	b.finishProducerSpan(ctx, span, routingKey, err)
+
+	return err
+}
+
+// publishConfirmed publishes rMsg and waits for the broker to confirm its delivery tag,
+// in the background when the publish is asynchronous
+func (b *rabbitBroker) publishConfirmed(ctx context.Context, span trace.Span, exchangeName, routingKey string, mandatory bool, rMsg amqp.Publishing, options broker.PublishOptions) error {
+	cancel := func() {}
+	if options.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+	}
+
+	confirmation, err := b.conn.PublishWithConfirm(ctx, exchangeName, routingKey, mandatory, rMsg)
+	if err != nil {
+		cancel()
+		b.finishProducerSpan(ctx, span, routingKey, err)
+		return err
+	}
+
+	wait := func() error {
+		defer cancel()
+
+		err := waitConfirm(ctx, confirmation)
+		b.finishProducerSpan(ctx, span, routingKey, err)
+
+		if options.Callback != nil {
+			options.Callback(err)
+		}
+		return err
+	}
+
+	if options.Async {
+		go func() { _ = wait() }()
+		return nil
+	}
+
+	return wait()
+}
+
+func confirmPublish(options broker.PublishOptions) bool {
+	val, ok := options.Context.Value(publishConfirmKey{}).(bool)
+	return ok && val
+}
+
+// PublishBatch publishes msgs back to back on the publish channel, resolving the exchange
+// and declaring the publish queue once for the whole batch. With WithPublishConfirm the
+// confirmations are awaited after the whole batch is written.
+func (b *rabbitBroker) PublishBatch(ctx context.Context, routingKey string, msgs []*broker.Message, opts ...broker.PublishOption) ([]broker.PublishResult, error) {
+	if b.conn == nil {
+		return nil, errors.New("connection is nil")
+	}
+
+	options := broker.PublishOptions{
+		Context: ctx,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	exchangeName, mandatory, err := b.preparePublish(routingKey, options)
+	if err != nil {
+		return nil, err
+	}
+
+	confirm := confirmPublish(options)
+	if confirm && options.Timeout > 0 {
+		var cancel context.CancelFunc
+		options.Context, cancel = context.WithTimeout(options.Context, options.Timeout)
+		defer cancel()
+	}
+
+	// confirmations of the published messages, awaited once the whole batch is written
+	type pending struct {
+		index        int
+		ctx          context.Context
+		span         trace.Span
+		confirmation *amqp.DeferredConfirmation
+	}
+	var pendings []pending
+
+	results := broker.NewPublishResults(msgs)
+	for i, msg := range msgs {
+		if msg == nil {
+			results[i].Err = errors.New("message is nil")
+			continue
+		}
+
+		buf, err := broker.Marshal(b.options.Codec, msg.Body)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		rMsg := newPublishing(buf, msg.Headers, options)
+
+		spanCtx, span := b.startProducerSpan(options.Context, routingKey, &rMsg)
+
+		if confirm {
+			var confirmation *amqp.DeferredConfirmation
+			if confirmation, results[i].Err = b.conn.PublishWithConfirm(spanCtx, exchangeName, routingKey, mandatory, rMsg); results[i].Err == nil {
+				pendings = append(pendings, pending{index: i, ctx: spanCtx, span: span, confirmation: confirmation})
+				continue
+			}
+		} else {
+			results[i].Err = b.conn.Publish(spanCtx, exchangeName, routingKey, mandatory, rMsg)
+		}
+
+		b.finishProducerSpan(spanCtx, span, routingKey, results[i].Err)
+	}
+
+	for _, p := range pendings {
+		results[p.index].Err = waitConfirm(p.ctx, p.confirmation)
+		b.finishProducerSpan(p.ctx, p.span, routingKey, results[p.index].Err)
+	}
+
+	return results, broker.JoinPublishResults(results)
+}
+
+// preparePublish resolves the target exchange and the mandatory flag of a publish,
+// and declares the publish queue when requested
+func (b *rabbitBroker) preparePublish(routingKey string, options broker.PublishOptions) (string, bool, error) {
+	// determine target exchange
+	var exchangeName string
+	if val, ok := options.Context.Value(publishExchangeKey{}).(string); ok && val != "" {
+		exchangeName = val
+	} else {
+		exchangeName = b.conn.defaultExchangeName
+	}
+
+	if val, ok := options.Context.Value(publishDeclareQueueKey{}).(*DeclarePublishQueueInfo); ok {
+		if val.Durable {
+			val.AutoDelete = false
+		}
+		if err := b.conn.DeclarePublishQueue(exchangeName, val.Queue, routingKey, val.BindArguments, val.QueueArguments, val.Durable, val.AutoDelete); err != nil {
+			return "", false, err
+		}
+	}
+
+	var mandatory bool
+	if val, ok := options.Context.Value(mandatoryKey{}).(bool); ok {
+		mandatory = val
+	}
+
+	return exchangeName, mandatory, nil
+}
+
+func newPublishing(body []byte, msgHeaders broker.Headers, options broker.PublishOptions) amqp.Publishing {
 	rMsg := amqp.Publishing{
-		Body:    msg.BodyBytes(),
+		Body:    body,
 		Headers: amqp.Table{},
 	}
 
-	for k, v := range msg.Headers {
+	for k, v := range msgHeaders {
 		rMsg.Headers[k] = v
 	}
 
@@ -221,36 +414,7 @@ func (b *rabbitBroker) publish(ctx context.Context, routingKey string, msg *brok
 		}
 	}
 
-	// determine target exchange
-	var exchangeName string
-	if val, ok := options.Context.Value(publishExchangeKey{}).(string); ok && val != "" {
-		exchangeName = val
-	} else {
-		exchangeName = b.conn.defaultExchangeName
-	}
-
-	if val, ok := options.Context.Value(publishDeclareQueueKey{}).(*DeclarePublishQueueInfo); ok {
-		if val.Durable {
-			val.AutoDelete = false
-		}
-		if err := b.conn.DeclarePublishQueue(exchangeName, val.Queue, routingKey, val.BindArguments, val.QueueArguments, val.Durable, val.AutoDelete); err != nil {
-			return err
-		}
-	}
-
-	var span trace.Span
-	ctx, span = b.startProducerSpan(options.Context, routingKey, &rMsg)
-
-	var mandatory bool
-	if val, ok := options.Context.Value(mandatoryKey{}).(bool); ok {
-		mandatory = val
-	}
-
-	err := b.conn.Publish(ctx, exchangeName, routingKey, mandatory, rMsg)
-
-	b.finishProducerSpan(ctx, span, routingKey, err)
-
-	return err
+	return rMsg
 }
 
 func (b *rabbitBroker) Subscribe(routingKey string, handler broker.Handler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
@@ -266,9 +430,10 @@ func (b *rabbitBroker) Subscribe(routingKey string, handler broker.Handler, bind
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	var requeueOnError = false
 	if val, ok := options.Context.Value(requeueOnErrorKey{}).(bool); ok {
@@ -281,15 +446,32 @@ func (b *rabbitBroker) Subscribe(routingKey string, handler broker.Handler, bind
 		ackSuccess = true
 	}
 
+	var stream *streamConsumer
+	if val, ok := options.Context.Value(streamQueueKey{}).(bool); ok && val {
+		if options.Queue == "" {
+			return nil, errors.New("stream queue requires a queue name, set it with broker.WithSubscribeQueueName")
+		}
+		stream = newStreamConsumer(routingKey, options)
+
+		// stream queues only deliver to consumers which acknowledge
+		options.AutoAck = false
+		ackSuccess = true
+	}
+
 	fn := func(msg amqp.Delivery) {
 		m := &broker.Message{
 			Headers: rabbitHeaderToMap(msg.Headers),
 			Body:    nil,
 		}
 
+		offset, hasOffset := deliveryStreamOffset(msg)
+		if stream != nil && hasOffset {
+			m.Offset = offset
+		}
+
 		ctx, span := b.startConsumerSpan(options.Context, options.Queue, &msg)
 
-		p := &publication{d: msg, message: m, topic: msg.RoutingKey}
+		p := &publication{d: msg, message: m, topic: msg.RoutingKey, autoAck: options.AutoAck}
 
 		if binder != nil {
 			m.Body = binder()
@@ -302,10 +484,15 @@ func (b *rabbitBroker) Subscribe(routingKey string, handler broker.Handler, bind
 		}
 
 		p.err = handler(ctx, p)
+		// deliveries settled by the handler with Ack or Nack are left as they are
 		if p.err == nil && ackSuccess && !options.AutoAck {
-			_ = msg.Ack(false)
-		} else if p.err != nil && !options.AutoAck {
-			_ = msg.Nack(false, requeueOnError)
+			_ = p.Ack()
+		} else if p.err != nil && !options.AutoAck && !p.settled.Load() {
+			_ = p.Nack(requeueOnError)
+		}
+
+		if stream != nil && hasOffset {
+			stream.done(ctx, offset, p.err == nil)
 		}
 
 		b.finishConsumerSpan(ctx, span, p.err)
@@ -329,6 +516,8 @@ func (b *rabbitBroker) Subscribe(routingKey string, handler broker.Handler, bind
 		fn:           fn,
 		headers:      nil,
 		queueArgs:    nil,
+		pool:         broker.NewSubscribeWorkerPool(options),
+		stream:       stream,
 	}
 
 	if val, ok := options.Context.Value(durableQueueKey{}).(bool); ok {
@@ -349,6 +538,16 @@ func (b *rabbitBroker) Subscribe(routingKey string, handler broker.Handler, bind
 		sub.queueArgs = val
 	}
 
+	if stream != nil {
+		queueArgs := map[string]any{amqp.QueueTypeArg: amqp.QueueTypeStream}
+		for k, v := range sub.queueArgs {
+			queueArgs[k] = v
+		}
+		sub.queueArgs = queueArgs
+		sub.durableQueue = true
+		sub.autoDelete = false
+	}
+
 	b.subscribers.Add(routingKey, sub)
 
 	go sub.resubscribe()
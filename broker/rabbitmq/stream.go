@@ -0,0 +1,212 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+const (
+	// streamOffsetArg selects where a consumer of a stream queue starts reading
+	streamOffsetArg = "x-stream-offset"
+
+	// DefaultStreamPrefetchCount is the prefetch of stream consumers when WithPrefetchCount is not set:
+	// RabbitMQ requires a prefetch to consume from a stream queue.
+	DefaultStreamPrefetchCount = 100
+)
+
+// StreamOffset is the position a stream consumer starts reading from when no offset is stored for it.
+type StreamOffset struct {
+	spec any
+}
+
+// StreamOffsetFirst starts from the first message still in the stream.
+func StreamOffsetFirst() StreamOffset {
+	return StreamOffset{spec: "first"}
+}
+
+// StreamOffsetLast starts from the last chunk of the stream.
+func StreamOffsetLast() StreamOffset {
+	return StreamOffset{spec: "last"}
+}
+
+// StreamOffsetNext only receives messages published after the consumer attached, the default.
+func StreamOffsetNext() StreamOffset {
+	return StreamOffset{spec: "next"}
+}
+
+// StreamOffsetAt starts from the message with the given offset.
+func StreamOffsetAt(offset int64) StreamOffset {
+	return StreamOffset{spec: offset}
+}
+
+// StreamOffsetTimestamp starts from the first chunk published at or after t, at second precision.
+func StreamOffsetTimestamp(t time.Time) StreamOffset {
+	return StreamOffset{spec: t}
+}
+
+func (o StreamOffset) argument() any {
+	if o.spec == nil {
+		return "next"
+	}
+	return o.spec
+}
+
+// StreamOffsetStore keeps the offset up to which a stream consumer processed every message,
+// so it resumes after that message when it (re)subscribes.
+type StreamOffsetStore interface {
+	// Load returns the stored offset of consumer on queue, ok is false when none is stored.
+	Load(ctx context.Context, queue, consumer string) (offset int64, ok bool, err error)
+	// Store records offset as the last processed message of consumer on queue.
+	Store(ctx context.Context, queue, consumer string, offset int64) error
+}
+
+type memoryStreamOffsetStore struct {
+	sync.Mutex
+	offsets map[string]int64
+}
+
+// NewMemoryStreamOffsetStore returns a StreamOffsetStore that lives as long as the process.
+// It lets a consumer resume after a reconnect, but not after a restart.
+func NewMemoryStreamOffsetStore() StreamOffsetStore {
+	return &memoryStreamOffsetStore{offsets: make(map[string]int64)}
+}
+
+func (s *memoryStreamOffsetStore) Load(_ context.Context, queue, consumer string) (int64, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	offset, ok := s.offsets[queue+"/"+consumer]
+	return offset, ok, nil
+}
+
+func (s *memoryStreamOffsetStore) Store(_ context.Context, queue, consumer string, offset int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.offsets[queue+"/"+consumer] = offset
+	return nil
+}
+
+// streamConsumer tracks the offset of a subscription to a stream queue.
+type streamConsumer struct {
+	mtx sync.Mutex
+
+	queue string
+	name  string
+	start StreamOffset
+	store StreamOffsetStore
+
+	// last is the offset up to which every delivery was processed, valid when tracked is set
+	last    int64
+	tracked bool
+
+	// inflight holds the offsets delivered since the last subscribe in delivery order,
+	// processed whether each of them was handled successfully
+	inflight  []int64
+	processed map[int64]bool
+}
+
+func newStreamConsumer(routingKey string, options broker.SubscribeOptions) *streamConsumer {
+	c := &streamConsumer{
+		queue: options.Queue,
+		name:  routingKey,
+		start: StreamOffsetNext(),
+
+		processed: make(map[int64]bool),
+	}
+
+	if val, ok := options.Context.Value(streamOffsetKey{}).(StreamOffset); ok {
+		c.start = val
+	}
+	if val, ok := options.Context.Value(streamConsumerNameKey{}).(string); ok && val != "" {
+		c.name = val
+	}
+	if val, ok := options.Context.Value(streamOffsetStoreKey{}).(StreamOffsetStore); ok && val != nil {
+		c.store = val
+	} else {
+		c.store = NewMemoryStreamOffsetStore()
+	}
+
+	return c
+}
+
+// consumeArguments returns the consumer arguments for the next subscribe: the message after the
+// last processed one, or the configured start offset when nothing was processed yet.
+func (c *streamConsumer) consumeArguments(ctx context.Context) amqp.Table {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// the deliveries still in flight are delivered again by the new subscription
+	c.inflight = nil
+	clear(c.processed)
+
+	if !c.tracked {
+		offset, ok, err := c.store.Load(ctx, c.queue, c.name)
+		if err != nil {
+			LogErrorf("load offset of stream consumer '%s' on '%s' failed: %v", c.name, c.queue, err)
+		} else if ok {
+			c.last, c.tracked = offset, true
+		}
+	}
+
+	if c.tracked {
+		return amqp.Table{streamOffsetArg: c.last + 1}
+	}
+	return amqp.Table{streamOffsetArg: c.start.argument()}
+}
+
+// deliver records offset as delivered, it must be called in the order of the deliveries.
+func (c *streamConsumer) deliver(offset int64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.inflight = append(c.inflight, offset)
+	c.processed[offset] = false
+}
+
+// done records the delivery at offset as handled. Deliveries handled concurrently may finish
+// out of order: the stored offset only advances over deliveries which, like every one before
+// them, were handled successfully. A failed delivery holds it back, so the next subscribe
+// delivers it again.
+func (c *streamConsumer) done(ctx context.Context, offset int64, ok bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if _, found := c.processed[offset]; !found || !ok {
+		return
+	}
+	c.processed[offset] = true
+
+	advanced := false
+	for len(c.inflight) > 0 && c.processed[c.inflight[0]] {
+		c.last, c.tracked = c.inflight[0], true
+		delete(c.processed, c.inflight[0])
+		c.inflight = c.inflight[1:]
+		advanced = true
+	}
+	if !advanced {
+		return
+	}
+
+	if err := c.store.Store(ctx, c.queue, c.name, c.last); err != nil {
+		LogErrorf("store offset %d of stream consumer '%s' on '%s' failed: %v", c.last, c.name, c.queue, err)
+	}
+}
+
+// deliveryStreamOffset reads the offset the broker attaches to deliveries from a stream queue.
+func deliveryStreamOffset(d amqp.Delivery) (int64, bool) {
+	switch v := d.Headers[streamOffsetArg].(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+func TestStreamConsumer_ResumesAfterLastProcessedOffset(t *testing.T) {
+	store := NewMemoryStreamOffsetStore()
+	options := broker.NewSubscribeOptions(
+		broker.WithSubscribeQueueName("events.stream"),
+		WithStreamOffset(StreamOffsetFirst()),
+		WithStreamOffsetStore(store),
+		WithStreamConsumerName("billing"),
+	)
+
+	c := newStreamConsumer("events.#", options)
+	assert.Equal(t, amqp.Table{"x-stream-offset": "first"}, c.consumeArguments(context.Background()))
+
+	for offset := int64(40); offset < 44; offset++ {
+		c.deliver(offset)
+	}
+
+	// a delivery finishing before an earlier one is not stored past it
+	c.done(context.Background(), 41, true)
+	_, ok, err := store.Load(context.Background(), "events.stream", "billing")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	c.done(context.Background(), 40, true)
+	// a failed delivery holds the offset back, the later ones are delivered again
+	c.done(context.Background(), 42, false)
+	c.done(context.Background(), 43, true)
+	assert.Equal(t, amqp.Table{"x-stream-offset": int64(42)}, c.consumeArguments(context.Background()))
+
+	offset, ok, err := store.Load(context.Background(), "events.stream", "billing")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(41), offset)
+
+	// a new subscription with the same store and name resumes from the stored offset
+	resumed := newStreamConsumer("events.#", options)
+	assert.Equal(t, amqp.Table{"x-stream-offset": int64(42)}, resumed.consumeArguments(context.Background()))
+}
+
+func TestStreamOffsetArguments(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	assert.Equal(t, "next", StreamOffset{}.argument())
+	assert.Equal(t, "next", StreamOffsetNext().argument())
+	assert.Equal(t, "last", StreamOffsetLast().argument())
+	assert.Equal(t, int64(7), StreamOffsetAt(7).argument())
+	assert.Equal(t, ts, StreamOffsetTimestamp(ts).argument())
+
+	c := newStreamConsumer("events.#", broker.NewSubscribeOptions(broker.WithSubscribeQueueName("q")))
+	assert.Equal(t, "events.#", c.name)
+	assert.Equal(t, amqp.Table{"x-stream-offset": "next"}, c.consumeArguments(context.Background()))
+}
+
+func TestDeliveryStreamOffset(t *testing.T) {
+	offset, ok := deliveryStreamOffset(amqp.Delivery{Headers: amqp.Table{"x-stream-offset": int64(12)}})
+	assert.True(t, ok)
+	assert.Equal(t, int64(12), offset)
+
+	_, ok = deliveryStreamOffset(amqp.Delivery{})
+	assert.False(t, ok)
+}
+
+func TestSubscribe_StreamQueueRequiresQueueName(t *testing.T) {
+	b := NewBroker().(*rabbitBroker)
+	b.conn = newRabbitMQConnection(b.options)
+
+	_, err := b.Subscribe("events.#", func(context.Context, broker.Event) error { return nil }, nil, WithStreamQueue())
+	assert.NotNil(t, err)
+}
+
+func TestStreamQos(t *testing.T) {
+	conn := newRabbitMQConnection(broker.NewOptionsAndApply())
+	assert.Equal(t, DefaultStreamPrefetchCount, conn.streamQos().PrefetchCount)
+
+	conn = newRabbitMQConnection(broker.NewOptionsAndApply(WithPrefetchCount(10)))
+	assert.Equal(t, 10, conn.streamQos().PrefetchCount)
+}
@@ -21,6 +21,10 @@ type subscriber struct {
 	queueArgs    map[string]any
 	fn           func(msg amqp.Delivery)
 	headers      map[string]any
+	pool         *broker.WorkerPool
+
+	// stream is set when consuming from a stream queue
+	stream *streamConsumer
 
 	durableQueue bool
 	autoDelete   bool
@@ -42,6 +46,9 @@ func (s *subscriber) Topic() string {
 }
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
+	// the handlers ack on the channel, they finish before it is closed
+	s.pool.Drain()
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -84,12 +91,22 @@ func (s *subscriber) resubscribe() {
 			continue
 		}
 
+		qos := s.r.conn.qos
+		var consumeArgs amqp.Table
+		if s.stream != nil {
+			// resume after the last processed offset
+			qos = s.r.conn.streamQos()
+			consumeArgs = s.stream.consumeArguments(s.options.Context)
+		}
+
 		ch, sub, err := s.r.conn.Consume(
 			s.exchangeName,
 			s.options.Queue,
 			s.topic,
 			s.headers,
 			s.queueArgs,
+			consumeArgs,
+			qos,
 			s.options.AutoAck,
 			s.durableQueue,
 			s.autoDelete,
@@ -111,9 +128,18 @@ func (s *subscriber) resubscribe() {
 			continue
 		}
 		for d := range sub {
+			if s.stream != nil {
+				// the handlers run concurrently, the offsets are tracked in delivery order
+				if offset, ok := deliveryStreamOffset(d); ok {
+					s.stream.deliver(offset)
+				}
+			}
+
 			s.r.wg.Add(1)
-			s.fn(d)
-			s.r.wg.Done()
+			s.pool.Submit(func() {
+				defer s.r.wg.Done()
+				s.fn(d)
+			})
 		}
 	}
 }
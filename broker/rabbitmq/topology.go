@@ -0,0 +1,365 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// QueueType is the x-queue-type of a declared queue.
+type QueueType string
+
+const (
+	QueueTypeClassic QueueType = "classic"
+	QueueTypeQuorum  QueueType = "quorum"
+	QueueTypeStream  QueueType = "stream"
+)
+
+// Topology declares the exchanges, queues and bindings an application relies on.
+// It is applied in order: exchanges, queues, exchange-to-exchange bindings, then queue bindings.
+// All declarations are idempotent, so the same Topology is safely re-applied after every reconnect.
+type Topology struct {
+	Exchanges        []ExchangeDeclaration
+	Queues           []QueueDeclaration
+	ExchangeBindings []Binding
+	QueueBindings    []Binding
+}
+
+// ExchangeDeclaration describes an exchange to declare.
+type ExchangeDeclaration struct {
+	Name       string
+	Type       string // "direct", "fanout", "topic", "headers"
+	Durable    bool
+	AutoDelete bool
+	// Internal exchanges only receive messages from other exchanges
+	Internal  bool
+	Arguments amqp.Table
+}
+
+// QueueDeclaration describes a queue to declare. The typed fields are translated to their
+// x-arguments and take precedence over the same keys in Arguments.
+type QueueDeclaration struct {
+	Name       string
+	Type       QueueType
+	Durable    bool
+	AutoDelete bool
+	Exclusive  bool
+
+	// DeadLetterExchange receives messages that are rejected, expire or overflow
+	DeadLetterExchange   string
+	DeadLetterRoutingKey string
+
+	// MessageTTL expires messages that stay in the queue longer than the given duration
+	MessageTTL time.Duration
+	// MaxLength and MaxLengthBytes bound the queue, Overflow chooses what happens when it is full:
+	// "drop-head" (default), "reject-publish" or "reject-publish-dlx"
+	MaxLength      int64
+	MaxLengthBytes int64
+	Overflow       string
+
+	// DeliveryLimit dead-letters a message of a quorum queue after that many redeliveries
+	DeliveryLimit int
+	// MaxAge discards segments of a stream queue older than the given duration
+	MaxAge time.Duration
+
+	Arguments amqp.Table
+}
+
+// Binding binds Destination (a queue or an exchange) to the Source exchange.
+type Binding struct {
+	Source      string
+	Destination string
+	RoutingKey  string
+	Arguments   amqp.Table
+}
+
+// TopologyAction is what applying a Topology would do to one declaration.
+type TopologyAction string
+
+const (
+	TopologyActionCreate    TopologyAction = "create"
+	TopologyActionUnchanged TopologyAction = "unchanged"
+	// TopologyActionConflict means the entity exists with different properties, applying fails
+	TopologyActionConflict TopologyAction = "conflict"
+	// TopologyActionUnverified is reported for bindings between existing entities,
+	// AMQP has no way to check whether a binding exists
+	TopologyActionUnverified TopologyAction = "unverified"
+)
+
+// TopologyChange is one entry of a dry-run diff.
+type TopologyChange struct {
+	Kind   string // "exchange", "queue", "exchange-binding" or "queue-binding"
+	Name   string
+	Action TopologyAction
+	Detail string
+}
+
+func (c TopologyChange) String() string {
+	if c.Detail == "" {
+		return fmt.Sprintf("%s %s: %s", c.Kind, c.Name, c.Action)
+	}
+	return fmt.Sprintf("%s %s: %s (%s)", c.Kind, c.Name, c.Action, c.Detail)
+}
+
+// TopologyDeclarer is implemented by the RabbitMQ broker.
+type TopologyDeclarer interface {
+	// ApplyTopology declares t on the connected broker.
+	ApplyTopology(t *Topology) error
+	// DiffTopology reports what ApplyTopology would change without changing anything.
+	DiffTopology(t *Topology) ([]TopologyChange, error)
+}
+
+// Validate checks the declarations for mistakes the broker would only report at apply time.
+func (t *Topology) Validate() error {
+	var errs []error
+
+	for _, ex := range t.Exchanges {
+		if ex.Name == "" {
+			errs = append(errs, errors.New("exchange without name"))
+		}
+		if ex.Type == "" {
+			errs = append(errs, fmt.Errorf("exchange '%s' without type", ex.Name))
+		}
+	}
+
+	for _, q := range t.Queues {
+		if q.Name == "" {
+			errs = append(errs, errors.New("queue without name: server-named queues cannot be part of a topology"))
+		}
+		switch q.Type {
+		case "", QueueTypeClassic:
+		case QueueTypeQuorum, QueueTypeStream:
+			if !q.Durable || q.AutoDelete || q.Exclusive {
+				errs = append(errs, fmt.Errorf("%s queue '%s' must be durable, not auto-delete and not exclusive", q.Type, q.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("queue '%s' has unknown type '%s'", q.Name, q.Type))
+		}
+		if q.DeliveryLimit > 0 && q.Type != QueueTypeQuorum {
+			errs = append(errs, fmt.Errorf("queue '%s': delivery limit requires a quorum queue", q.Name))
+		}
+		if q.MaxAge > 0 && q.Type != QueueTypeStream {
+			errs = append(errs, fmt.Errorf("queue '%s': max age requires a stream queue", q.Name))
+		}
+	}
+
+	for _, b := range append(append([]Binding{}, t.ExchangeBindings...), t.QueueBindings...) {
+		if b.Source == "" || b.Destination == "" {
+			errs = append(errs, fmt.Errorf("binding '%s' -> '%s' needs a source and a destination", b.Source, b.Destination))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (q QueueDeclaration) arguments() amqp.Table {
+	args := amqp.Table{}
+	for k, v := range q.Arguments {
+		args[k] = v
+	}
+
+	if q.Type != "" {
+		args[amqp.QueueTypeArg] = string(q.Type)
+	}
+	if q.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = q.DeadLetterExchange
+	}
+	if q.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = q.DeadLetterRoutingKey
+	}
+	if q.MessageTTL > 0 {
+		args[amqp.QueueMessageTTLArg] = q.MessageTTL.Milliseconds()
+	}
+	if q.MaxLength > 0 {
+		args[amqp.QueueMaxLenArg] = q.MaxLength
+	}
+	if q.MaxLengthBytes > 0 {
+		args[amqp.QueueMaxLenBytesArg] = q.MaxLengthBytes
+	}
+	if q.Overflow != "" {
+		args[amqp.QueueOverflowArg] = q.Overflow
+	}
+	if q.DeliveryLimit > 0 {
+		args["x-delivery-limit"] = int64(q.DeliveryLimit)
+	}
+	if q.MaxAge > 0 {
+		args[amqp.StreamMaxAgeArg] = strconv.FormatInt(int64(q.MaxAge/time.Second), 10) + "s"
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+// applyTopology declares t on conn, using a dedicated channel so a failed declaration
+// does not close a channel in use.
+func applyTopology(conn *amqp.Connection, t *Topology) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	for _, ex := range t.Exchanges {
+		if err = ch.ExchangeDeclare(ex.Name, ex.Type, ex.Durable, ex.AutoDelete, ex.Internal, false, ex.Arguments); err != nil {
+			return fmt.Errorf("declare exchange '%s': %w", ex.Name, err)
+		}
+	}
+
+	for _, q := range t.Queues {
+		if _, err = ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, q.arguments()); err != nil {
+			return fmt.Errorf("declare queue '%s': %w", q.Name, err)
+		}
+	}
+
+	for _, b := range t.ExchangeBindings {
+		if err = ch.ExchangeBind(b.Destination, b.RoutingKey, b.Source, false, b.Arguments); err != nil {
+			return fmt.Errorf("bind exchange '%s' to '%s': %w", b.Destination, b.Source, err)
+		}
+	}
+
+	for _, b := range t.QueueBindings {
+		if err = ch.QueueBind(b.Destination, b.RoutingKey, b.Source, false, b.Arguments); err != nil {
+			return fmt.Errorf("bind queue '%s' to '%s': %w", b.Destination, b.Source, err)
+		}
+	}
+
+	return nil
+}
+
+// diffTopology compares t with the entities on the broker without creating any of them.
+// Each entity is first looked up with a passive declare. An existing entity is then declared again
+// with the wanted properties: the broker accepts this as a no-op when they match and rejects it
+// with PRECONDITION_FAILED when they differ. Every check runs on its own channel because a failed
+// declaration closes the channel.
+func diffTopology(conn *amqp.Connection, t *Topology) ([]TopologyChange, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	var changes []TopologyChange
+	created := make(map[string]bool)
+
+	for _, ex := range t.Exchanges {
+		change, err := diffEntity(conn, "exchange", ex.Name,
+			func(ch *amqp.Channel) error {
+				return ch.ExchangeDeclarePassive(ex.Name, ex.Type, ex.Durable, ex.AutoDelete, ex.Internal, false, ex.Arguments)
+			},
+			func(ch *amqp.Channel) error {
+				return ch.ExchangeDeclare(ex.Name, ex.Type, ex.Durable, ex.AutoDelete, ex.Internal, false, ex.Arguments)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		created["exchange:"+ex.Name] = change.Action == TopologyActionCreate
+		changes = append(changes, change)
+	}
+
+	for _, q := range t.Queues {
+		args := q.arguments()
+		change, err := diffEntity(conn, "queue", q.Name,
+			func(ch *amqp.Channel) error {
+				_, err := ch.QueueDeclarePassive(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, args)
+				return err
+			},
+			func(ch *amqp.Channel) error {
+				_, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, args)
+				return err
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		created["queue:"+q.Name] = change.Action == TopologyActionCreate
+		changes = append(changes, change)
+	}
+
+	diffBinding := func(kind, destinationKind string, b Binding) TopologyChange {
+		change := TopologyChange{
+			Kind:   kind,
+			Name:   fmt.Sprintf("%s -> %s [%s]", b.Source, b.Destination, b.RoutingKey),
+			Action: TopologyActionUnverified,
+		}
+		if created["exchange:"+b.Source] || created[destinationKind+":"+b.Destination] {
+			change.Action = TopologyActionCreate
+		}
+		return change
+	}
+
+	for _, b := range t.ExchangeBindings {
+		changes = append(changes, diffBinding("exchange-binding", "exchange", b))
+	}
+	for _, b := range t.QueueBindings {
+		changes = append(changes, diffBinding("queue-binding", "queue", b))
+	}
+
+	return changes, nil
+}
+
+func diffEntity(conn *amqp.Connection, kind, name string, passive, declare func(ch *amqp.Channel) error) (TopologyChange, error) {
+	change := TopologyChange{Kind: kind, Name: name}
+
+	exists, err := tryOnChannel(conn, passive)
+	if err != nil {
+		return change, err
+	}
+	if !exists.ok {
+		if exists.code != amqp.NotFound {
+			return change, fmt.Errorf("check %s '%s': %w", kind, name, exists.err)
+		}
+		change.Action = TopologyActionCreate
+		return change, nil
+	}
+
+	matches, err := tryOnChannel(conn, declare)
+	if err != nil {
+		return change, err
+	}
+	switch {
+	case matches.ok:
+		change.Action = TopologyActionUnchanged
+	case matches.code == amqp.PreconditionFailed:
+		change.Action = TopologyActionConflict
+		change.Detail = matches.err.Reason
+	default:
+		return change, fmt.Errorf("check %s '%s': %w", kind, name, matches.err)
+	}
+
+	return change, nil
+}
+
+type channelResult struct {
+	ok   bool
+	code int
+	err  *amqp.Error
+}
+
+// tryOnChannel runs fn on a new channel and reports the AMQP error that closed it, if any.
+// Errors that are not AMQP channel errors are returned as err.
+func tryOnChannel(conn *amqp.Connection, fn func(ch *amqp.Channel) error) (channelResult, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return channelResult{}, err
+	}
+	defer func() { _ = ch.Close() }()
+
+	err = fn(ch)
+	if err == nil {
+		return channelResult{ok: true}, nil
+	}
+
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		return channelResult{code: amqpErr.Code, err: amqpErr}, nil
+	}
+	return channelResult{}, err
+}
@@ -0,0 +1,138 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+func testTopology() *Topology {
+	return &Topology{
+		Exchanges: []ExchangeDeclaration{
+			{Name: "topology.events", Type: "topic", Durable: true},
+			{Name: "topology.orders", Type: "topic", Durable: true},
+			{Name: "topology.dlx", Type: "fanout", Durable: true},
+		},
+		Queues: []QueueDeclaration{
+			{
+				Name:               "topology.orders.q",
+				Type:               QueueTypeQuorum,
+				Durable:            true,
+				DeadLetterExchange: "topology.dlx",
+				MessageTTL:         time.Minute,
+				MaxLength:          1000,
+				Overflow:           "reject-publish-dlx",
+				DeliveryLimit:      5,
+			},
+			{Name: "topology.dlq", Durable: true},
+		},
+		ExchangeBindings: []Binding{
+			{Source: "topology.events", Destination: "topology.orders", RoutingKey: "orders.#"},
+		},
+		QueueBindings: []Binding{
+			{Source: "topology.orders", Destination: "topology.orders.q", RoutingKey: "orders.#"},
+			{Source: "topology.dlx", Destination: "topology.dlq"},
+		},
+	}
+}
+
+func TestQueueDeclarationArguments(t *testing.T) {
+	q := QueueDeclaration{
+		Name:                 "q",
+		Type:                 QueueTypeQuorum,
+		DeadLetterExchange:   "dlx",
+		DeadLetterRoutingKey: "dead",
+		MessageTTL:           1500 * time.Millisecond,
+		MaxLength:            10,
+		MaxLengthBytes:       1024,
+		Overflow:             "reject-publish",
+		DeliveryLimit:        3,
+		Arguments:            amqp.Table{"x-queue-type": "classic", "x-custom": "v"},
+	}
+
+	assert.Equal(t, amqp.Table{
+		"x-queue-type":              "quorum",
+		"x-dead-letter-exchange":    "dlx",
+		"x-dead-letter-routing-key": "dead",
+		"x-message-ttl":             int64(1500),
+		"x-max-length":              int64(10),
+		"x-max-length-bytes":        int64(1024),
+		"x-overflow":                "reject-publish",
+		"x-delivery-limit":          int64(3),
+		"x-custom":                  "v",
+	}, q.arguments())
+
+	stream := QueueDeclaration{Name: "s", Type: QueueTypeStream, Durable: true, MaxAge: 72 * time.Hour}
+	assert.Equal(t, amqp.Table{"x-queue-type": "stream", "x-max-age": "259200s"}, stream.arguments())
+
+	assert.Nil(t, QueueDeclaration{Name: "plain"}.arguments())
+}
+
+func TestTopologyValidate(t *testing.T) {
+	assert.Nil(t, testTopology().Validate())
+
+	invalid := &Topology{
+		Exchanges: []ExchangeDeclaration{{Name: "ex"}},
+		Queues: []QueueDeclaration{
+			{},
+			{Name: "quorum", Type: QueueTypeQuorum},
+			{Name: "classic", DeliveryLimit: 1, MaxAge: time.Hour},
+			{Name: "unknown", Type: "lazy"},
+		},
+		QueueBindings: []Binding{{Source: "ex"}},
+	}
+	err := invalid.Validate()
+	if assert.NotNil(t, err) {
+		for _, want := range []string{
+			"exchange 'ex' without type",
+			"queue without name",
+			"quorum queue 'quorum' must be durable",
+			"delivery limit requires a quorum queue",
+			"max age requires a stream queue",
+			"unknown type 'lazy'",
+			"needs a source and a destination",
+		} {
+			assert.Contains(t, err.Error(), want)
+		}
+	}
+}
+
+func TestTopologyChangeString(t *testing.T) {
+	assert.Equal(t, "queue q: create", TopologyChange{Kind: "queue", Name: "q", Action: TopologyActionCreate}.String())
+	assert.Equal(t, "exchange ex: conflict (inequivalent arg 'type')",
+		TopologyChange{Kind: "exchange", Name: "ex", Action: TopologyActionConflict, Detail: "inequivalent arg 'type'"}.String())
+}
+
+func Test_Topology_ApplyAndDiff(t *testing.T) {
+	b := NewBroker(broker.WithAddress(testBroker))
+	_ = b.Init()
+
+	if err := b.Connect(); err != nil {
+		t.Logf("cant connect to broker, skip: %v", err)
+		t.Skip()
+	}
+	defer b.Disconnect()
+
+	declarer := b.(TopologyDeclarer)
+	topology := testTopology()
+
+	assert.Nil(t, declarer.ApplyTopology(topology))
+
+	changes, err := declarer.DiffTopology(topology)
+	assert.Nil(t, err)
+	for _, change := range changes {
+		assert.NotEqual(t, TopologyActionCreate, change.Action, change.String())
+		assert.NotEqual(t, TopologyActionConflict, change.Action, change.String())
+	}
+
+	// the same exchange with a different type conflicts with the declared one
+	changed := testTopology()
+	changed.Exchanges[0].Type = "direct"
+	changes, err = declarer.DiffTopology(changed)
+	assert.Nil(t, err)
+	assert.Equal(t, TopologyActionConflict, changes[0].Action)
+}
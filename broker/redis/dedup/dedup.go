@@ -0,0 +1,175 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/tx7do/kratos-transport/broker"
+	redisOption "github.com/tx7do/kratos-transport/broker/redis/option"
+)
+
+const (
+	defaultAddr      = "redis://127.0.0.1:6379"
+	defaultKeyPrefix = "kratos:dedup:"
+)
+
+var _ broker.DedupStore = (*Store)(nil)
+
+// Store is a broker.DedupStore keeping the processed keys in Redis, shared by every consumer
+// connected to the same server. Keys expire with SET PX, so no cleanup is needed.
+type Store struct {
+	pool      *redis.Pool
+	ownPool   bool
+	keyPrefix string
+}
+
+type options struct {
+	addr      string
+	keyPrefix string
+	poolOpts  []broker.Option
+}
+
+// Option configures a Store.
+type Option func(*options)
+
+// WithAddress sets the address of the Redis server, "redis://127.0.0.1:6379" by default.
+func WithAddress(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// WithKeyPrefix sets the prefix of the Redis keys, "kratos:dedup:" by default.
+func WithKeyPrefix(prefix string) Option {
+	return func(o *options) {
+		o.keyPrefix = prefix
+	}
+}
+
+// WithPoolOptions configures the connection pool with the connection options of broker/redis,
+// such as redis.WithConnectTimeout or redis.WithMaxIdle.
+func WithPoolOptions(opts ...broker.Option) Option {
+	return func(o *options) {
+		o.poolOpts = append(o.poolOpts, opts...)
+	}
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		addr:      defaultAddr,
+		keyPrefix: defaultKeyPrefix,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// NewStore creates a Store with its own connection pool.
+func NewStore(opts ...Option) *Store {
+	o := newOptions(opts...)
+
+	addr := o.addr
+	if !strings.HasPrefix(addr, "redis://") && !strings.HasPrefix(addr, "rediss://") {
+		addr = "redis://" + addr
+	}
+
+	commonOpts := &redisOption.CommonOptions{
+		MaxIdle:        redisOption.DefaultMaxIdle,
+		MaxActive:      redisOption.DefaultMaxActive,
+		IdleTimeout:    redisOption.DefaultIdleTimeout,
+		ConnectTimeout: redisOption.DefaultConnectTimeout,
+		ReadTimeout:    redisOption.DefaultReadTimeout,
+		WriteTimeout:   redisOption.DefaultWriteTimeout,
+	}
+	if len(o.poolOpts) > 0 {
+		brokerOpts := broker.NewOptionsAndApply(o.poolOpts...)
+		if v, ok := brokerOpts.Context.Value(redisOption.OptionsKey).(*redisOption.CommonOptions); ok {
+			commonOpts = v
+		}
+	}
+
+	pool := &redis.Pool{
+		MaxIdle:     commonOpts.MaxIdle,
+		MaxActive:   commonOpts.MaxActive,
+		IdleTimeout: commonOpts.IdleTimeout,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(
+				addr,
+				redis.DialConnectTimeout(commonOpts.ConnectTimeout),
+				redis.DialReadTimeout(commonOpts.ReadTimeout),
+				redis.DialWriteTimeout(commonOpts.WriteTimeout),
+			)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			if nil != err {
+				redisOption.LogError("ping error:" + err.Error())
+			}
+			return err
+		},
+	}
+
+	return &Store{
+		pool:      pool,
+		ownPool:   true,
+		keyPrefix: o.keyPrefix,
+	}
+}
+
+// NewStoreWithPool creates a Store on an existing connection pool, which Close leaves open.
+// WithAddress and WithPoolOptions are ignored.
+func NewStoreWithPool(pool *redis.Pool, opts ...Option) *Store {
+	o := newOptions(opts...)
+	return &Store{
+		pool:      pool,
+		keyPrefix: o.keyPrefix,
+	}
+}
+
+// Claim implements broker.DedupStore with SET NX PX.
+func (s *Store) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	args := redis.Args{s.keyPrefix + key, 1, "NX"}
+	if ms := ttl.Milliseconds(); ms > 0 {
+		args = args.Add("PX", ms)
+	}
+
+	_, err = redis.String(redis.DoContext(conn, ctx, "SET", args...))
+	if errors.Is(err, redis.ErrNil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release implements broker.DedupStore.
+func (s *Store) Release(ctx context.Context, key string) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "DEL", s.keyPrefix+key)
+	return err
+}
+
+// Close closes the connection pool created by NewStore.
+func (s *Store) Close() error {
+	if !s.ownPool {
+		return nil
+	}
+	return s.pool.Close()
+}
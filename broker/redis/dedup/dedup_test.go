@@ -0,0 +1,73 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+func TestStore_ClaimRelease(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	s := NewStore(WithAddress(srv.Addr()), WithKeyPrefix("test:"))
+	defer s.Close()
+
+	ctx := context.Background()
+
+	ok, err := s.Claim(ctx, "orders:1", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, srv.Exists("test:orders:1"))
+
+	ok, err = s.Claim(ctx, "orders:1", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, s.Release(ctx, "orders:1"))
+	ok, err = s.Claim(ctx, "orders:1", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	srv.FastForward(2 * time.Minute)
+	ok, err = s.Claim(ctx, "orders:1", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+type testEvent struct {
+	msg *broker.Message
+}
+
+func (e *testEvent) Topic() string            { return "orders" }
+func (e *testEvent) Message() *broker.Message { return e.msg }
+func (e *testEvent) RawMessage() any          { return nil }
+func (e *testEvent) Ack() error               { return nil }
+func (e *testEvent) Error() error             { return nil }
+
+func TestStore_Middleware(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	// 两个消费者实例共享同一个 Redis
+	s1 := NewStore(WithAddress(srv.Addr()))
+	defer s1.Close()
+	s2 := NewStore(WithAddress(srv.Addr()))
+	defer s2.Close()
+
+	var calls int
+	handler := func(context.Context, broker.Event) error {
+		calls++
+		return nil
+	}
+	h1 := broker.DedupMiddleware(s1)(handler)
+	h2 := broker.DedupMiddleware(s2)(handler)
+
+	evt := &testEvent{msg: &broker.Message{ID: "1"}}
+	assert.Nil(t, h1(context.Background(), evt))
+	assert.Nil(t, h2(context.Background(), evt))
+	assert.Equal(t, 1, calls)
+}
@@ -33,6 +33,16 @@ const (
 	DefaultStreamBlockTime = 5 * time.Second
 	DefaultStreamCount     = 10
 	DefaultStreamMaxLen    = 0
+
+	// StreamStartBeginning 新建消费组从 Stream 的第一条消息开始消费
+	StreamStartBeginning = "0"
+	// StreamStartLatest 新建消费组只消费此后写入的消息（默认）
+	StreamStartLatest = "$"
+
+	// DefaultStreamClaimInterval 待确认消息认领与空闲消费者清理的执行间隔
+	DefaultStreamClaimInterval = 30 * time.Second
+	// DefaultStreamDeadLetterSuffix 未指定死信 Stream 时，在原 Stream 名称后追加的后缀
+	DefaultStreamDeadLetterSuffix = ".dlq"
 )
 
 var OptionsKey = OptionsKeyType{}
@@ -209,6 +219,28 @@ func LogFatalf(format string, args ...any) {
 	log.Fatalf("%s %s", logKey, fmt.Sprintf(format, args...))
 }
 
+///
+/// Pub/Sub Option
+///
+
+type PubSubPatternKey struct{}
+type PubSubShardedKey struct{}
+
+// WithPubSubPattern 以 PSUBSCRIBE 订阅，主题作为通配模式（如 news.*），Event.Topic() 为实际匹配到的频道
+func WithPubSubPattern() broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(PubSubPatternKey{}, true)
+}
+
+// WithPubSubShardedSubscribe 以 SSUBSCRIBE 订阅分片频道（Redis 7.0+），不能与 WithPubSubPattern 同时使用
+func WithPubSubShardedSubscribe() broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(PubSubShardedKey{}, true)
+}
+
+// WithPubSubShardedPublish 以 SPUBLISH 发布到分片频道（Redis 7.0+）
+func WithPubSubShardedPublish() broker.PublishOption {
+	return broker.PublishContextWithValue(PubSubShardedKey{}, true)
+}
+
 ///
 /// Stream SubscribeOption
 ///
@@ -218,6 +250,14 @@ type StreamConsumerKey struct{}
 type StreamBlockTimeKey struct{}
 type StreamCountKey struct{}
 type StreamMaxLenKey struct{}
+type StreamMinIDKey struct{}
+type StreamRetentionKey struct{}
+type StreamStartIDKey struct{}
+type StreamClaimMinIdleKey struct{}
+type StreamClaimIntervalKey struct{}
+type StreamMaxDeliveriesKey struct{}
+type StreamDeadLetterKey struct{}
+type StreamConsumerIdleTimeoutKey struct{}
 
 // WithStreamGroup Redis Stream 消费组名称
 func WithStreamGroup(group string) broker.SubscribeOption {
@@ -239,7 +279,50 @@ func WithStreamCount(n int) broker.SubscribeOption {
 	return broker.SubscribeContextWithValue(StreamCountKey{}, n)
 }
 
+// WithStreamClaimMinIdle 启用待确认消息认领：定期使用 XAUTOCLAIM 认领组内空闲超过 d 的待确认消息（PEL）并重新投递，
+// 使崩溃消费者未确认的消息得以继续处理
+func WithStreamClaimMinIdle(d time.Duration) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(StreamClaimMinIdleKey{}, d)
+}
+
+// WithStreamClaimInterval 待确认消息认领与空闲消费者清理的执行间隔，默认 DefaultStreamClaimInterval
+func WithStreamClaimInterval(d time.Duration) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(StreamClaimIntervalKey{}, d)
+}
+
+// WithStreamMaxDeliveries 消息的最大投递次数，认领时投递次数已达到 n 的消息不再重新投递，而是转入死信 Stream 并确认，
+// 需与 WithStreamClaimMinIdle 一同使用
+func WithStreamMaxDeliveries(n int) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(StreamMaxDeliveriesKey{}, n)
+}
+
+// WithStreamDeadLetter 死信 Stream 名称，默认为原 Stream 名称加 DefaultStreamDeadLetterSuffix
+func WithStreamDeadLetter(stream string) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(StreamDeadLetterKey{}, stream)
+}
+
+// WithStreamConsumerIdleTimeout 定期使用 XGROUP DELCONSUMER 删除组内空闲超过 d 且没有待确认消息的其他消费者
+func WithStreamConsumerIdleTimeout(d time.Duration) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(StreamConsumerIdleTimeoutKey{}, d)
+}
+
+// WithStreamStartID 消费组不存在时的起始位置：StreamStartBeginning、StreamStartLatest（默认）或指定的消息 ID，
+// 已存在的消费组不受影响
+func WithStreamStartID(id string) broker.SubscribeOption {
+	return broker.SubscribeContextWithValue(StreamStartIDKey{}, id)
+}
+
 // WithStreamMaxLen Redis Stream XADD 时的 MAXLEN 限制，0 表示不限制
 func WithStreamMaxLen(n int64) broker.PublishOption {
 	return broker.PublishContextWithValue(StreamMaxLenKey{}, n)
 }
+
+// WithStreamMinID 发布后使用 XTRIM MINID 删除 ID 小于 id 的消息
+func WithStreamMinID(id string) broker.PublishOption {
+	return broker.PublishContextWithValue(StreamMinIDKey{}, id)
+}
+
+// WithStreamRetention 发布后使用 XTRIM MINID 删除早于 d 之前写入的消息
+func WithStreamRetention(d time.Duration) broker.PublishOption {
+	return broker.PublishContextWithValue(StreamRetentionKey{}, d)
+}
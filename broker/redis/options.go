@@ -15,6 +15,12 @@ const (
 	DriverTypeStream = option.DriverTypeStream
 )
 
+// Stream 消费组的起始位置，见 WithStreamStartID
+const (
+	StreamStartBeginning = option.StreamStartBeginning
+	StreamStartLatest    = option.StreamStartLatest
+)
+
 // WithConnectTimeout 连接Redis超时时间
 func WithConnectTimeout(d time.Duration) broker.Option {
 	return option.WithConnectTimeout(d)
@@ -94,6 +100,25 @@ func LogFatalf(format string, args ...any) {
 	option.LogFatalf(format, args...)
 }
 
+///
+/// Pub/Sub 专属配置 转发
+///
+
+// WithPubSubPattern Redis Pub/Sub 以 PSUBSCRIBE 按通配模式订阅
+func WithPubSubPattern() broker.SubscribeOption {
+	return option.WithPubSubPattern()
+}
+
+// WithPubSubShardedSubscribe Redis Pub/Sub 以 SSUBSCRIBE 订阅分片频道
+func WithPubSubShardedSubscribe() broker.SubscribeOption {
+	return option.WithPubSubShardedSubscribe()
+}
+
+// WithPubSubShardedPublish Redis Pub/Sub 以 SPUBLISH 发布到分片频道
+func WithPubSubShardedPublish() broker.PublishOption {
+	return option.WithPubSubShardedPublish()
+}
+
 ///
 /// Stream 专属配置 转发
 ///
@@ -118,7 +143,47 @@ func WithStreamCount(n int) broker.SubscribeOption {
 	return option.WithStreamCount(n)
 }
 
+// WithStreamClaimMinIdle 定期使用 XAUTOCLAIM 认领组内空闲超过 d 的待确认消息并重新投递
+func WithStreamClaimMinIdle(d time.Duration) broker.SubscribeOption {
+	return option.WithStreamClaimMinIdle(d)
+}
+
+// WithStreamClaimInterval 待确认消息认领与空闲消费者清理的执行间隔
+func WithStreamClaimInterval(d time.Duration) broker.SubscribeOption {
+	return option.WithStreamClaimInterval(d)
+}
+
+// WithStreamMaxDeliveries 消息的最大投递次数，超过后转入死信 Stream
+func WithStreamMaxDeliveries(n int) broker.SubscribeOption {
+	return option.WithStreamMaxDeliveries(n)
+}
+
+// WithStreamDeadLetter 死信 Stream 名称
+func WithStreamDeadLetter(stream string) broker.SubscribeOption {
+	return option.WithStreamDeadLetter(stream)
+}
+
+// WithStreamConsumerIdleTimeout 删除组内空闲超过 d 且没有待确认消息的其他消费者
+func WithStreamConsumerIdleTimeout(d time.Duration) broker.SubscribeOption {
+	return option.WithStreamConsumerIdleTimeout(d)
+}
+
+// WithStreamStartID Redis Stream 新建消费组的起始位置
+func WithStreamStartID(id string) broker.SubscribeOption {
+	return option.WithStreamStartID(id)
+}
+
 // WithStreamMaxLen Redis Stream XADD 时的 MAXLEN 限制
 func WithStreamMaxLen(n int64) broker.PublishOption {
 	return option.WithStreamMaxLen(n)
 }
+
+// WithStreamMinID Redis Stream 发布后以 XTRIM MINID 删除 ID 小于 id 的消息
+func WithStreamMinID(id string) broker.PublishOption {
+	return option.WithStreamMinID(id)
+}
+
+// WithStreamRetention Redis Stream 发布后以 XTRIM MINID 删除早于 d 之前写入的消息
+func WithStreamRetention(d time.Duration) broker.PublishOption {
+	return option.WithStreamRetention(d)
+}
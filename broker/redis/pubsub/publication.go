@@ -5,6 +5,7 @@ import "github.com/tx7do/kratos-transport/broker"
 type publication struct {
 	topic   string
 	message *broker.Message
+	payload []byte
 	err     error
 }
 
@@ -20,6 +21,11 @@ func (p *publication) RawMessage() any {
 	return p.message
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	return p.payload
+}
+
 func (p *publication) Ack() error {
 	return nil
 }
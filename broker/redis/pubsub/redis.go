@@ -22,6 +22,8 @@ type pubsubBroker struct {
 	commonOpts *redisOption.CommonOptions
 
 	subscribers *broker.SubscriberSyncMap
+
+	requester *broker.Requester
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
@@ -36,11 +38,14 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 
 	options := broker.NewOptionsAndApply(opts...)
 
-	return &pubsubBroker{
+	b := &pubsubBroker{
 		options:     options,
 		commonOpts:  commonOpts,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	b.requester = broker.NewRequester(b)
+
+	return b
 }
 
 func (b *pubsubBroker) Name() string {
@@ -113,17 +118,23 @@ func (b *pubsubBroker) Connect() error {
 }
 
 func (b *pubsubBroker) Disconnect() error {
-	err := b.pool.Close()
-	b.pool = nil
-	b.addr = ""
+	if b.pool == nil {
+		return nil
+	}
 
+	// 订阅者停止接收并处理完已收到的消息后，才能关闭连接池
 	b.subscribers.Clear()
+	_ = b.requester.Close()
+
+	err := b.pool.Close()
+	b.addr = ""
+	b.pool = nil
 
 	return err
 }
 
 func (b *pubsubBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *pubsubBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -141,6 +152,7 @@ func (b *pubsubBroker) internalPublish(ctx context.Context, topic string, msg *b
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -148,9 +160,27 @@ func (b *pubsubBroker) internalPublish(ctx context.Context, topic string, msg *b
 	return b.publish(ctx, topic, sendMsg, opts...)
 }
 
-func (b *pubsubBroker) publish(_ context.Context, topic string, msg *broker.Message, _ ...broker.PublishOption) error {
+func (b *pubsubBroker) publish(_ context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	publishOpts := broker.PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&publishOpts)
+	}
+
+	command := "PUBLISH"
+	if v, ok := publishOpts.Context.Value(redisOption.PubSubShardedKey{}).(bool); ok && v {
+		command = "SPUBLISH"
+	}
+
+	body := msg.BodyBytes()
+	// Pub/Sub messages have no headers: requests and replies carry theirs in an envelope
+	if broker.IsRequestReply(msg) {
+		body = (&broker.Envelope{ID: msg.ID, Headers: msg.Headers, Body: body}).Encode()
+	}
+
 	conn := b.pool.Get()
-	_, err := redis.Int(conn.Do("PUBLISH", topic, msg.BodyBytes()))
+	_, err := redis.Int(conn.Do(command, topic, body))
 	_ = conn.Close()
 	return err
 }
@@ -163,20 +193,30 @@ func (b *pubsubBroker) Subscribe(topic string, handler broker.Handler, binder br
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	pattern, _ := options.Context.Value(redisOption.PubSubPatternKey{}).(bool)
+	sharded, _ := options.Context.Value(redisOption.PubSubShardedKey{}).(bool)
+	if pattern && sharded {
+		return nil, errors.New("redis: sharded pub/sub does not support pattern subscriptions")
+	}
+
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	sub := &subscriber{
 		b:       b,
 		conn:    &redis.PubSubConn{Conn: b.pool.Get()},
 		topic:   topic,
+		pattern: pattern,
+		sharded: sharded,
 		handler: handler,
 		binder:  binder,
 		options: options,
+		workers: broker.NewSubscribeWorkerPool(options),
 	}
 
-	if err := sub.conn.Subscribe(sub.topic); err != nil {
+	if err := sub.subscribe(sub.conn); err != nil {
 		_ = sub.conn.Close()
 		return nil, err
 	}
@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+	redisOption "github.com/tx7do/kratos-transport/broker/redis/option"
+)
+
+func newConnectedBroker(t *testing.T, addr string) broker.Broker {
+	t.Helper()
+
+	b := NewBroker(broker.WithAddress(addr))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	t.Cleanup(func() { _ = b.Disconnect() })
+
+	return b
+}
+
+// waitSubscribers 等待服务端登记订阅，避免在订阅生效前发布
+func waitSubscribers(t *testing.T, srv *miniredis.Miniredis, n int) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		return srv.PubSubNumPat()+len(srv.PubSubChannels("*")) >= n
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscribe_Pattern(t *testing.T) {
+	srv := miniredis.RunT(t)
+	b := newConnectedBroker(t, srv.Addr())
+
+	got := make(chan broker.Event, 1)
+	_, err := b.Subscribe("news.*", func(_ context.Context, evt broker.Event) error {
+		got <- evt
+		return nil
+	}, nil, redisOption.WithPubSubPattern())
+	assert.Nil(t, err)
+	waitSubscribers(t, srv, 1)
+
+	assert.Nil(t, b.Publish(context.Background(), "news.sport", broker.NewMessage(broker.RawBody("goal"))))
+
+	select {
+	case evt := <-got:
+		assert.Equal(t, "news.sport", evt.Topic())
+		assert.Equal(t, "goal", string(evt.Message().Body.([]byte)))
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not delivered")
+	}
+}
+
+func TestSubscribe_ResubscribeAfterConnectionLoss(t *testing.T) {
+	srv := miniredis.RunT(t)
+	b := newConnectedBroker(t, srv.Addr())
+
+	got := make(chan string, 1)
+	_, err := b.Subscribe("events", func(_ context.Context, evt broker.Event) error {
+		got <- string(evt.Message().Body.([]byte))
+		return nil
+	}, nil)
+	assert.Nil(t, err)
+	waitSubscribers(t, srv, 1)
+
+	// 重启服务端断开全部连接，订阅应自动恢复
+	srv.Close()
+	assert.Nil(t, srv.Restart())
+	waitSubscribers(t, srv, 1)
+
+	assert.Nil(t, b.Publish(context.Background(), "events", broker.NewMessage(broker.RawBody("after restart"))))
+
+	select {
+	case body := <-got:
+		assert.Equal(t, "after restart", body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber did not resubscribe")
+	}
+}
+
+func TestSubscribe_ShardedPatternRejected(t *testing.T) {
+	srv := miniredis.RunT(t)
+	b := newConnectedBroker(t, srv.Addr())
+
+	_, err := b.Subscribe("news.*", func(context.Context, broker.Event) error { return nil }, nil,
+		redisOption.WithPubSubPattern(), redisOption.WithPubSubShardedSubscribe())
+	assert.NotNil(t, err)
+}
+
+func TestParsePush_Sharded(t *testing.T) {
+	msg := parsePush([]any{[]byte("smessage"), []byte("orders{1}"), []byte("payload")}, nil)
+	assert.Equal(t, redis.Message{Channel: "orders{1}", Data: []byte("payload")}, msg)
+
+	sub := parsePush([]any{[]byte("ssubscribe"), []byte("orders{1}"), int64(1)}, nil)
+	assert.Equal(t, redis.Subscription{Kind: "ssubscribe", Channel: "orders{1}", Count: 1}, sub)
+
+	pmsg := parsePush([]any{[]byte("pmessage"), []byte("news.*"), []byte("news.sport"), []byte("goal")}, nil)
+	assert.Equal(t, redis.Message{Pattern: "news.*", Channel: "news.sport", Data: []byte("goal")}, pmsg)
+}
@@ -18,17 +18,29 @@ type subscriber struct {
 	topic  string
 	closed bool
 
+	// pattern 以 PSUBSCRIBE 订阅，sharded 以 SSUBSCRIBE 订阅
+	pattern bool
+	sharded bool
+
 	handler broker.Handler
 	binder  broker.Binder
 
 	options broker.SubscribeOptions
 
 	conn *redis.PubSubConn
+
+	workers *broker.WorkerPool
 }
 
 func (s *subscriber) onMessage(channel string, data []byte) error {
 	var m broker.Message
 
+	if env, err := broker.DecodeEnvelope(data); err == nil {
+		m.ID = env.ID
+		m.Headers = env.Headers
+		data = env.Body
+	}
+
 	if s.binder != nil {
 		m.Body = s.binder()
 
@@ -42,6 +54,7 @@ func (s *subscriber) onMessage(channel string, data []byte) error {
 	p := publication{
 		topic:   channel,
 		message: &m,
+		payload: data,
 	}
 
 	if p.err = s.handler(s.options.Context, &p); p.err != nil {
@@ -162,14 +175,16 @@ func (s *subscriber) receiveLoop() error {
 			return errors.New("connection is nil")
 		}
 
-		switch x := conn.Receive().(type) {
+		switch x := receive(conn.Conn).(type) {
 		case error:
 			return x
 
 		case redis.Message:
-			if err := s.onMessage(x.Channel, x.Data); err != nil {
-				redisOption.LogErrorf("onMessage error: %s", err.Error())
-			}
+			s.workers.Submit(func() {
+				if err := s.onMessage(x.Channel, x.Data); err != nil {
+					redisOption.LogErrorf("onMessage error: %s", err.Error())
+				}
+			})
 
 		case redis.Subscription:
 			if x.Count == 0 {
@@ -196,7 +211,7 @@ func (s *subscriber) reconnect() bool {
 		return false
 	}
 
-	if err := conn.Subscribe(s.topic); err != nil {
+	if err := s.subscribe(conn); err != nil {
 		_ = conn.Close()
 		redisOption.LogErrorf("resubscribe error: %s", err.Error())
 		return false
@@ -207,6 +222,83 @@ func (s *subscriber) reconnect() bool {
 	return true
 }
 
+// subscribe 按订阅方式发送 SUBSCRIBE、PSUBSCRIBE 或 SSUBSCRIBE
+func (s *subscriber) subscribe(conn *redis.PubSubConn) error {
+	switch {
+	case s.pattern:
+		return conn.PSubscribe(s.topic)
+	case s.sharded:
+		return sendFlush(conn.Conn, "SSUBSCRIBE", s.topic)
+	default:
+		return conn.Subscribe(s.topic)
+	}
+}
+
+func (s *subscriber) unsubscribe(conn *redis.PubSubConn) error {
+	switch {
+	case s.pattern:
+		return conn.PUnsubscribe()
+	case s.sharded:
+		return sendFlush(conn.Conn, "SUNSUBSCRIBE")
+	default:
+		return conn.Unsubscribe()
+	}
+}
+
+func sendFlush(conn redis.Conn, command string, args ...any) error {
+	if err := conn.Send(command, args...); err != nil {
+		return err
+	}
+	return conn.Flush()
+}
+
+// receive 读取一条推送消息，返回 redis.Message、redis.Subscription、redis.Pong 或 error。
+// redigo 的 PubSubConn.Receive 不识别分片频道的 smessage/ssubscribe/sunsubscribe，因此在此自行解析
+func receive(conn redis.Conn) any {
+	return parsePush(conn.Receive())
+}
+
+func parsePush(replyArg any, errArg error) any {
+	reply, err := redis.Values(replyArg, errArg)
+	if err != nil {
+		return err
+	}
+
+	var kind string
+	reply, err = redis.Scan(reply, &kind)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case "message", "smessage":
+		var m redis.Message
+		if _, err = redis.Scan(reply, &m.Channel, &m.Data); err != nil {
+			return err
+		}
+		return m
+	case "pmessage":
+		var m redis.Message
+		if _, err = redis.Scan(reply, &m.Pattern, &m.Channel, &m.Data); err != nil {
+			return err
+		}
+		return m
+	case "subscribe", "psubscribe", "ssubscribe", "unsubscribe", "punsubscribe", "sunsubscribe":
+		sub := redis.Subscription{Kind: kind}
+		if _, err = redis.Scan(reply, &sub.Channel, &sub.Count); err != nil {
+			return err
+		}
+		return sub
+	case "pong":
+		var p redis.Pong
+		if _, err = redis.Scan(reply, &p.Data); err != nil {
+			return err
+		}
+		return p
+	}
+	return errors.New("redis: unknown pubsub notification: " + kind)
+}
+
 func (s *subscriber) Options() broker.SubscribeOptions {
 	s.RLock()
 	defer s.RUnlock()
@@ -223,14 +315,21 @@ func (s *subscriber) Topic() string {
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
 	s.Lock()
-	defer s.Unlock()
-
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
 	s.closed = true
 
+	// 先退订，接收循环收到退订确认后退出，不再提交新的消息
 	var err error
 	if s.conn != nil {
-		err = s.conn.Unsubscribe()
+		err = s.unsubscribe(s.conn)
 	}
+	s.Unlock()
+
+	// 等待处理中的消息完成
+	s.workers.Drain()
 
 	if s.b != nil && s.b.subscribers != nil && removeFromManager {
 		_ = s.b.subscribers.RemoveOnly(s.topic)
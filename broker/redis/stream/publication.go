@@ -10,6 +10,7 @@ type publication struct {
 	group   string
 	msgID   string
 	message *broker.Message
+	payload []byte
 	err     error
 
 	pool  *redis.Pool
@@ -28,6 +29,11 @@ func (p *publication) RawMessage() any {
 	return p.message
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	return p.payload
+}
+
 // Ack 使用 XACK 确认消息已处理
 func (p *publication) Ack() error {
 	if p.acked {
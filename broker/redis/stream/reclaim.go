@@ -0,0 +1,315 @@
+package stream
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/tx7do/kratos-transport/broker"
+	redisOption "github.com/tx7do/kratos-transport/broker/redis/option"
+)
+
+// HeaderDeadLetterOriginalID 携带转入死信 Stream 的消息在原 Stream 中的 ID
+const HeaderDeadLetterOriginalID = "x-dead-letter-original-id"
+
+// reclaim 每隔 claimInterval 处理一次消费组的待确认消息（PEL）：
+// 投递次数达到 maxDeliveries 的消息转入死信 Stream，其余空闲超过 claimMinIdle 的消息由本消费者认领并重新投递；
+// 并删除空闲超过 consumerIdleTimeout 的消费者
+func (s *subscriber) reclaim() {
+	ticker := time.NewTicker(s.claimInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.IsClosed() {
+			return
+		}
+
+		if err := s.reclaimOnce(); err != nil {
+			redisOption.LogErrorf("stream reclaim error [stream=%s group=%s]: %s", s.topic, s.group, err.Error())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		case <-s.options.Context.Done():
+			return
+		}
+	}
+}
+
+func (s *subscriber) reclaimOnce() error {
+	if s.claimMinIdle > 0 {
+		if s.maxDeliveries > 0 {
+			if err := s.deadLetterPending(); err != nil {
+				return err
+			}
+		}
+
+		if err := s.claimPending(); err != nil {
+			return err
+		}
+	}
+
+	if s.consumerIdleTimeout > 0 {
+		return s.deleteIdleConsumers()
+	}
+
+	return nil
+}
+
+// claimPending 使用 XAUTOCLAIM 分页认领空闲超过 claimMinIdle 的待确认消息并重新投递
+func (s *subscriber) claimPending() error {
+	cursor := "0-0"
+
+	for {
+		if s.IsClosed() {
+			return nil
+		}
+
+		conn := s.b.pool.Get()
+		// XAUTOCLAIM stream group consumer min-idle-time start COUNT count
+		reply, err := redis.Values(conn.Do("XAUTOCLAIM",
+			s.topic, s.group, s.consumer,
+			s.claimMinIdle.Milliseconds(),
+			cursor,
+			"COUNT", s.count,
+		))
+		_ = conn.Close()
+		if err != nil {
+			return err
+		}
+
+		// reply 格式: [next-cursor, [ [id, [field, value, ...]], ... ], [deleted-id, ...]]
+		if len(reply) < 2 {
+			return nil
+		}
+
+		cursor, err = redis.String(reply[0], nil)
+		if err != nil {
+			return err
+		}
+
+		messages, _ := reply[1].([]any)
+		entries := s.parseEntries(messages)
+		if s.maxDeliveries > 0 {
+			if entries, err = s.deadLetterClaimed(entries); err != nil {
+				return err
+			}
+		}
+		s.dispatch(entries)
+
+		if cursor == "0-0" {
+			return nil
+		}
+	}
+}
+
+// deadLetterPending 将投递次数达到 maxDeliveries 的待确认消息写入死信 Stream 并确认。
+// 消息先以 XCLAIM 认领，并发执行的其他消费者认领后消息不再空闲，因此每条消息只会转入死信 Stream 一次
+func (s *subscriber) deadLetterPending() error {
+	start := "-"
+
+	for {
+		if s.IsClosed() {
+			return nil
+		}
+
+		conn := s.b.pool.Get()
+		// XPENDING stream group IDLE min-idle-time start end count
+		reply, err := redis.Values(conn.Do("XPENDING",
+			s.topic, s.group,
+			"IDLE", s.claimMinIdle.Milliseconds(),
+			start, "+", s.count,
+		))
+		_ = conn.Close()
+		if err != nil {
+			return err
+		}
+
+		deliveries := make(map[string]int64)
+		var ids []any
+		var lastID string
+		for _, item := range reply {
+			// [id, consumer, idle, deliveries]
+			fields, err := redis.Values(item, nil)
+			if err != nil || len(fields) < 4 {
+				continue
+			}
+			id, _ := redis.String(fields[0], nil)
+			count, _ := redis.Int64(fields[3], nil)
+
+			lastID = id
+			if count >= int64(s.maxDeliveries) {
+				deliveries[id] = count
+				ids = append(ids, id)
+			}
+		}
+
+		if len(ids) > 0 {
+			if err = s.deadLetter(ids, deliveries); err != nil {
+				return err
+			}
+		}
+
+		if len(reply) < s.count || lastID == "" {
+			return nil
+		}
+		start = nextStreamID(lastID)
+	}
+}
+
+func (s *subscriber) deadLetter(ids []any, deliveries map[string]int64) error {
+	conn := s.b.pool.Get()
+	defer conn.Close()
+
+	// XCLAIM stream group consumer min-idle-time id [id ...]
+	args := append([]any{s.topic, s.group, s.consumer, s.claimMinIdle.Milliseconds()}, ids...)
+	reply, err := redis.Values(conn.Do("XCLAIM", args...))
+	if err != nil {
+		return err
+	}
+
+	return s.writeDeadLetters(conn, s.parseEntries(reply), deliveries)
+}
+
+// deadLetterClaimed 检查 XAUTOCLAIM 认领的消息的投递次数，将超过 maxDeliveries 的消息转入死信 Stream，
+// 返回其余需要重新投递的消息。deadLetterPending 与 XAUTOCLAIM 各自判断空闲时间，
+// 消息可能在两者之间达到 claimMinIdle 而被直接认领
+func (s *subscriber) deadLetterClaimed(entries []streamEntry) ([]streamEntry, error) {
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	conn := s.b.pool.Get()
+	defer conn.Close()
+
+	// XPENDING stream group start end count
+	for _, entry := range entries {
+		if err := conn.Send("XPENDING", s.topic, s.group, entry.msgID, entry.msgID, 1); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+
+	deliveries := make(map[string]int64)
+	var exceeded []streamEntry
+	remaining := make([]streamEntry, 0, len(entries))
+	for _, entry := range entries {
+		reply, err := redis.Values(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+
+		// [[id, consumer, idle, deliveries]]，投递次数已包含本次认领
+		var count int64
+		if len(reply) > 0 {
+			if fields, _ := redis.Values(reply[0], nil); len(fields) >= 4 {
+				count, _ = redis.Int64(fields[3], nil)
+			}
+		}
+
+		if count-1 >= int64(s.maxDeliveries) {
+			deliveries[entry.msgID] = count - 1
+			exceeded = append(exceeded, entry)
+		} else {
+			remaining = append(remaining, entry)
+		}
+	}
+
+	if err := s.writeDeadLetters(conn, exceeded, deliveries); err != nil {
+		return nil, err
+	}
+
+	return remaining, nil
+}
+
+// writeDeadLetters 将已由本消费者认领的消息写入死信 Stream 并确认
+func (s *subscriber) writeDeadLetters(conn redis.Conn, entries []streamEntry, deliveries map[string]int64) error {
+	var err error
+	for _, entry := range entries {
+		headers := entry.headers
+		headers[broker.HeaderDeadLetterOriginalTopic] = s.topic
+		headers[broker.HeaderDeadLetterAttempts] = strconv.FormatInt(deliveries[entry.msgID], 10)
+		headers[broker.HeaderDeadLetterError] = fmt.Sprintf("exceeded %d deliveries", s.maxDeliveries)
+		headers[HeaderDeadLetterOriginalID] = entry.msgID
+
+		if _, err = conn.Do("XADD", xaddArgs(s.deadLetterStream, 0, entry.data, entry.key, headers)...); err != nil {
+			return err
+		}
+		if _, err = conn.Do("XACK", s.topic, s.group, entry.msgID); err != nil {
+			return err
+		}
+
+		redisOption.LogWarnf("stream message dead-lettered [stream=%s id=%s deliveries=%d dead-letter=%s]",
+			s.topic, entry.msgID, deliveries[entry.msgID], s.deadLetterStream)
+	}
+
+	return nil
+}
+
+// deleteIdleConsumers 使用 XINFO CONSUMERS 查找空闲超过 consumerIdleTimeout 且没有待确认消息的其他消费者，
+// 并以 XGROUP DELCONSUMER 删除。仍有待确认消息的消费者会在其消息被认领后删除
+func (s *subscriber) deleteIdleConsumers() error {
+	conn := s.b.pool.Get()
+	defer conn.Close()
+
+	reply, err := redis.Values(conn.Do("XINFO", "CONSUMERS", s.topic, s.group))
+	if err != nil {
+		return err
+	}
+
+	for _, item := range reply {
+		// [name, <name>, pending, <n>, idle, <ms>, ...]
+		fields, err := redis.Values(item, nil)
+		if err != nil {
+			continue
+		}
+
+		var name string
+		var pending, idle int64
+		for i := 0; i+1 < len(fields); i += 2 {
+			key, _ := redis.String(fields[i], nil)
+			switch key {
+			case "name":
+				name, _ = redis.String(fields[i+1], nil)
+			case "pending":
+				pending, _ = redis.Int64(fields[i+1], nil)
+			case "idle":
+				idle, _ = redis.Int64(fields[i+1], nil)
+			}
+		}
+
+		if name == "" || name == s.consumer || pending > 0 || idle < s.consumerIdleTimeout.Milliseconds() {
+			continue
+		}
+
+		if _, err = conn.Do("XGROUP", "DELCONSUMER", s.topic, s.group, name); err != nil {
+			return err
+		}
+
+		redisOption.LogInfof("idle stream consumer deleted [stream=%s group=%s consumer=%s idle=%dms]", s.topic, s.group, name, idle)
+	}
+
+	return nil
+}
+
+// nextStreamID 返回紧随 id 之后的消息 ID，用于 XPENDING 分页
+func nextStreamID(id string) string {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return id
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return id
+	}
+
+	return ms + "-" + strconv.FormatUint(n+1, 10)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +17,20 @@ const (
 	defaultBroker = "redis://127.0.0.1:6379"
 )
 
+// HeaderFieldPrefix 消息头写入 Stream 字段时使用的前缀
+const HeaderFieldPrefix = "h:"
+
+// Stream 消息的保留字段
+const (
+	fieldBody = "body"
+	fieldKey  = "key"
+)
+
+var (
+	_ broker.BatchPublisher  = (*streamBroker)(nil)
+	_ broker.BatchSubscriber = (*streamBroker)(nil)
+)
+
 type streamBroker struct {
 	addr       string
 	pool       *redis.Pool
@@ -23,6 +38,8 @@ type streamBroker struct {
 	commonOpts *redisOption.CommonOptions
 
 	subscribers *broker.SubscriberSyncMap
+
+	requester *broker.Requester
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
@@ -37,11 +54,14 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 
 	options := broker.NewOptionsAndApply(opts...)
 
-	return &streamBroker{
+	b := &streamBroker{
 		options:     options,
 		commonOpts:  commonOpts,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	b.requester = broker.NewRequester(b)
+
+	return b
 }
 
 func (b *streamBroker) Name() string {
@@ -117,17 +137,20 @@ func (b *streamBroker) Disconnect() error {
 	if b.pool == nil {
 		return nil
 	}
-	err := b.pool.Close()
-	b.pool = nil
-	b.addr = ""
 
+	// 订阅者停止消费并确认完处理中的消息后，才能关闭连接池
 	b.subscribers.Clear()
+	_ = b.requester.Close()
+
+	err := b.pool.Close()
+	b.addr = ""
+	b.pool = nil
 
 	return err
 }
 
 func (b *streamBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *streamBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -145,6 +168,7 @@ func (b *streamBroker) internalPublish(ctx context.Context, topic string, msg *b
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -164,41 +188,189 @@ func (b *streamBroker) publish(_ context.Context, stream string, msg *broker.Mes
 	conn := b.pool.Get()
 	defer conn.Close()
 
-	args := []any{stream}
+	args := xaddArgs(stream, streamMaxLen(publishOpts), msg.BodyBytes(), msg.Key, msg.Headers)
+
+	if _, err := conn.Do("XADD", args...); err != nil {
+		return err
+	}
+
+	if minID := streamMinID(publishOpts); minID != "" {
+		if _, err := conn.Do("XTRIM", stream, "MINID", "~", minID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishBatch 以管道方式（pipeline）发送全部 XADD 命令，只需一次网络往返
+func (b *streamBroker) PublishBatch(ctx context.Context, stream string, msgs []*broker.Message, opts ...broker.PublishOption) ([]broker.PublishResult, error) {
+	if b.pool == nil {
+		return nil, errors.New("redis: not connected")
+	}
+
+	publishOpts := broker.PublishOptions{
+		Context: context.Background(),
+	}
+	for _, o := range opts {
+		o(&publishOpts)
+	}
+	maxLen := streamMaxLen(publishOpts)
+	minID := streamMinID(publishOpts)
+
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	results := broker.NewPublishResults(msgs)
+
+	// 已写入管道的消息下标
+	var sent []int
+	for i, msg := range msgs {
+		if msg == nil {
+			results[i].Err = errors.New("message is nil")
+			continue
+		}
+
+		buf, err := broker.Marshal(b.options.Codec, msg.Body)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		if err = conn.Send("XADD", xaddArgs(stream, maxLen, buf, msg.Key, msg.Headers)...); err != nil {
+			results[i].Err = err
+			continue
+		}
+		sent = append(sent, i)
+	}
+
+	// 整批写入后只裁剪一次
+	trim := minID != "" && len(sent) > 0
+	if trim {
+		if err = conn.Send("XTRIM", stream, "MINID", "~", minID); err != nil {
+			trim = false
+			redisOption.LogErrorf("stream trim error [stream=%s]: %s", stream, err.Error())
+		}
+	}
+
+	if err = conn.Flush(); err != nil {
+		for _, i := range sent {
+			results[i].Err = err
+		}
+		return results, broker.JoinPublishResults(results)
+	}
+
+	for _, i := range sent {
+		if _, err = conn.Receive(); err != nil {
+			results[i].Err = err
+		}
+	}
+
+	if trim {
+		if _, err = conn.Receive(); err != nil {
+			redisOption.LogErrorf("stream trim error [stream=%s]: %s", stream, err.Error())
+		}
+	}
 
-	// MAXLEN 限制
-	var maxLen int64
+	return results, broker.JoinPublishResults(results)
+}
+
+// streamMaxLen 读取 MAXLEN 限制
+func streamMaxLen(publishOpts broker.PublishOptions) int64 {
 	if v, ok := publishOpts.Context.Value(redisOption.StreamMaxLenKey{}).(int64); ok {
-		maxLen = v
+		return v
+	}
+	return 0
+}
+
+// streamMinID 读取 XTRIM MINID 阈值，WithStreamMinID 优先于 WithStreamRetention
+func streamMinID(publishOpts broker.PublishOptions) string {
+	if v, ok := publishOpts.Context.Value(redisOption.StreamMinIDKey{}).(string); ok && v != "" {
+		return v
+	}
+	if v, ok := publishOpts.Context.Value(redisOption.StreamRetentionKey{}).(time.Duration); ok && v > 0 {
+		return strconv.FormatInt(time.Now().Add(-v).UnixMilli(), 10) + "-0"
 	}
+	return ""
+}
+
+// xaddArgs 构造 XADD 命令参数：消息体写入 body 字段，非空的 Key 写入 key 字段，
+// 消息头以 HeaderFieldPrefix 为前缀写入，避免与保留字段冲突
+func xaddArgs(stream string, maxLen int64, body []byte, key string, headers broker.Headers) []any {
+	args := []any{stream}
+
 	if maxLen > 0 {
 		args = append(args, "MAXLEN", "~", maxLen)
 	}
 
 	args = append(args, "*")
-	args = append(args, "body", msg.BodyBytes())
+	args = append(args, fieldBody, body)
 
-	// 附加消息头（值转为 string）
-	for k, v := range msg.Headers {
-		args = append(args, k, fmt.Sprintf("%v", v))
+	if key != "" {
+		args = append(args, fieldKey, key)
 	}
 
-	_, err := conn.Do("XADD", args...)
-	return err
+	// 附加消息头（值转为 string）
+	for k, v := range headers {
+		args = append(args, HeaderFieldPrefix+k, fmt.Sprintf("%v", v))
+	}
+	return args
 }
 
 func (b *streamBroker) Subscribe(topic string, handler broker.Handler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
 	subOpts := broker.SubscribeOptions{
 		Context: context.Background(),
+		AutoAck: true,
 	}
 	for _, o := range opts {
 		o(&subOpts)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(subOpts) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, subOpts)
+
+	sub, err := b.newSubscriber(topic, binder, subOpts)
+	if err != nil {
+		return nil, err
+	}
+	sub.handler = handler
+
+	b.subscribers.Add(topic, sub)
+
+	sub.start()
 
+	return sub, nil
+}
+
+// SubscribeBatch 将每次 XREADGROUP 读取到的消息（最多 WithStreamCount 条）作为一个批次交给 handler，
+// 自动确认时批次处理成功后使用一条 XACK 命令确认全部消息
+func (b *streamBroker) SubscribeBatch(topic string, handler broker.BatchHandler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	subOpts := broker.SubscribeOptions{
+		Context: context.Background(),
+		AutoAck: true,
+	}
+	for _, o := range opts {
+		o(&subOpts)
+	}
+
+	sub, err := b.newSubscriber(topic, binder, subOpts)
+	if err != nil {
+		return nil, err
+	}
+	sub.batchHandler = broker.WrapSubscribeBatchHandler(handler, subOpts)
+
+	b.subscribers.Add(topic, sub)
+
+	sub.start()
+
+	return sub, nil
+}
+
+func (b *streamBroker) newSubscriber(topic string, binder broker.Binder, subOpts broker.SubscribeOptions) (*subscriber, error) {
 	// 提取 Stream 专属配置
 	group := redisOption.DefaultStreamGroup
 	if v, ok := subOpts.Context.Value(redisOption.StreamGroupKey{}).(string); ok && v != "" {
@@ -220,37 +392,70 @@ func (b *streamBroker) Subscribe(topic string, handler broker.Handler, binder br
 		count = v
 	}
 
+	var claimMinIdle time.Duration
+	if v, ok := subOpts.Context.Value(redisOption.StreamClaimMinIdleKey{}).(time.Duration); ok && v > 0 {
+		claimMinIdle = v
+	}
+
+	claimInterval := redisOption.DefaultStreamClaimInterval
+	if v, ok := subOpts.Context.Value(redisOption.StreamClaimIntervalKey{}).(time.Duration); ok && v > 0 {
+		claimInterval = v
+	}
+
+	var maxDeliveries int
+	if v, ok := subOpts.Context.Value(redisOption.StreamMaxDeliveriesKey{}).(int); ok && v > 0 {
+		maxDeliveries = v
+	}
+
+	deadLetterStream := topic + redisOption.DefaultStreamDeadLetterSuffix
+	if v, ok := subOpts.Context.Value(redisOption.StreamDeadLetterKey{}).(string); ok && v != "" {
+		deadLetterStream = v
+	}
+
+	var consumerIdleTimeout time.Duration
+	if v, ok := subOpts.Context.Value(redisOption.StreamConsumerIdleTimeoutKey{}).(time.Duration); ok && v > 0 {
+		consumerIdleTimeout = v
+	}
+
+	startID := redisOption.StreamStartLatest
+	if v, ok := subOpts.Context.Value(redisOption.StreamStartIDKey{}).(string); ok && v != "" {
+		startID = v
+	}
+
 	// 确保消费组存在
-	if err := b.ensureGroup(topic, group); err != nil {
+	if err := b.ensureGroup(topic, group, startID); err != nil {
 		return nil, err
 	}
 
-	sub := &subscriber{
+	return &subscriber{
 		b:         b,
 		topic:     topic,
 		group:     group,
 		consumer:  consumer,
 		blockTime: blockTime,
 		count:     count,
-		handler:   handler,
-		binder:    binder,
-		options:   subOpts,
-	}
-
-	b.subscribers.Add(topic, sub)
-
-	go sub.recv()
-
-	return sub, nil
+		startID:   startID,
+
+		claimMinIdle:        claimMinIdle,
+		claimInterval:       claimInterval,
+		maxDeliveries:       maxDeliveries,
+		deadLetterStream:    deadLetterStream,
+		consumerIdleTimeout: consumerIdleTimeout,
+
+		binder:  binder,
+		options: subOpts,
+		workers: broker.NewSubscribeWorkerPool(subOpts),
+		done:    make(chan struct{}),
+	}, nil
 }
 
-// ensureGroup 确保消费组存在，不存在则创建
-func (b *streamBroker) ensureGroup(stream, group string) error {
+// ensureGroup 确保消费组存在，不存在则从 startID 处创建
+func (b *streamBroker) ensureGroup(stream, group, startID string) error {
 	conn := b.pool.Get()
 	defer conn.Close()
 
-	// XGROUP CREATE stream group $ MKSTREAM
-	_, err := conn.Do("XGROUP", "CREATE", stream, group, "$", "MKSTREAM")
+	// XGROUP CREATE stream group <startID> MKSTREAM
+	_, err := conn.Do("XGROUP", "CREATE", stream, group, startID, "MKSTREAM")
 	if err != nil {
 		// BUSYGROUP: Consumer Group name already exists 是正常的
 		if strings.Contains(err.Error(), "BUSYGROUP") {
@@ -0,0 +1,398 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tx7do/kratos-transport/broker"
+	redisOption "github.com/tx7do/kratos-transport/broker/redis/option"
+)
+
+func TestPublishBatch_Pipeline(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	b := NewBroker(broker.WithAddress(srv.Addr()), broker.WithCodec("json"))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	msgs := []*broker.Message{
+		broker.NewMessage(map[string]int{"n": 1}, broker.WithHeader("trace", "a")),
+		nil,
+		broker.NewMessage(map[string]int{"n": 2}),
+	}
+
+	results, err := b.(broker.BatchPublisher).PublishBatch(context.Background(), "orders", msgs)
+	assert.NotNil(t, err)
+	assert.Len(t, results, 3)
+	assert.Nil(t, results[0].Err)
+	assert.NotNil(t, results[1].Err)
+	assert.Nil(t, results[2].Err)
+
+	entries, err := srv.Stream("orders")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, []string{"body", `{"n":1}`, HeaderFieldPrefix + "trace", "a"}, entries[0].Values)
+	assert.Equal(t, []string{"body", `{"n":2}`}, entries[1].Values)
+}
+
+func TestSubscribeBatch_AckWholeBatch(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	b := NewBroker(broker.WithAddress(srv.Addr()), broker.WithCodec("json"))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	type payload struct {
+		N int `json:"n"`
+	}
+
+	batches := make(chan []*payload, 4)
+	_, err := broker.SubscribeBatch(b, "orders", func(_ context.Context, topic string, headers []broker.Headers, msgs []*payload) error {
+		assert.Equal(t, "orders", topic)
+		assert.Len(t, headers, len(msgs))
+		batches <- msgs
+		return nil
+	}, redisOption.WithStreamCount(10), redisOption.WithStreamBlockTime(50*time.Millisecond))
+	assert.Nil(t, err)
+
+	_, err = b.(broker.BatchPublisher).PublishBatch(context.Background(), "orders", []*broker.Message{
+		broker.NewMessage(&payload{N: 1}),
+		broker.NewMessage(&payload{N: 2}),
+		broker.NewMessage(&payload{N: 3}),
+	})
+	assert.Nil(t, err)
+
+	var received []int
+	for len(received) < 3 {
+		select {
+		case msgs := <-batches:
+			for _, m := range msgs {
+				received = append(received, m.N)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("batch not delivered, got %v", received)
+		}
+	}
+	assert.Equal(t, []int{1, 2, 3}, received)
+
+	conn, err := redis.Dial("tcp", srv.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	// 整个批次使用一条 XACK 确认
+	assert.Eventually(t, func() bool {
+		pending, err := redis.Values(conn.Do("XPENDING", "orders", redisOption.DefaultStreamGroup))
+		return err == nil && len(pending) > 0 && pending[0] == int64(0)
+	}, time.Second, 10*time.Millisecond)
+}
+
+// readAsCrashedConsumer 以另一个消费者读取消息但不确认，模拟崩溃的消费者
+func readAsCrashedConsumer(t *testing.T, conn redis.Conn, stream, consumer string) {
+	t.Helper()
+
+	_, err := conn.Do("XGROUP", "CREATE", stream, redisOption.DefaultStreamGroup, "$", "MKSTREAM")
+	assert.Nil(t, err)
+	_, err = conn.Do("XADD", stream, "*", "body", `{"n":1}`, "trace", "a")
+	assert.Nil(t, err)
+	_, err = conn.Do("XREADGROUP", "GROUP", redisOption.DefaultStreamGroup, consumer, "COUNT", 10, "STREAMS", stream, ">")
+	assert.Nil(t, err)
+}
+
+func pendingCount(conn redis.Conn, stream string) int64 {
+	pending, err := redis.Values(conn.Do("XPENDING", stream, redisOption.DefaultStreamGroup))
+	if err != nil || len(pending) == 0 {
+		return -1
+	}
+	n, _ := redis.Int64(pending[0], nil)
+	return n
+}
+
+func TestReclaim_ClaimsPendingOfCrashedConsumer(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	conn, err := redis.Dial("tcp", srv.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	readAsCrashedConsumer(t, conn, "orders", "crashed")
+
+	b := NewBroker(broker.WithAddress(srv.Addr()))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	got := make(chan broker.Event, 1)
+	_, err = b.Subscribe("orders", func(_ context.Context, evt broker.Event) error {
+		got <- evt
+		return nil
+	}, nil,
+		redisOption.WithStreamConsumer("alive"),
+		redisOption.WithStreamBlockTime(50*time.Millisecond),
+		redisOption.WithStreamClaimMinIdle(50*time.Millisecond),
+		redisOption.WithStreamClaimInterval(50*time.Millisecond),
+	)
+	assert.Nil(t, err)
+
+	select {
+	case evt := <-got:
+		assert.Equal(t, `{"n":1}`, string(evt.Message().Body.([]byte)))
+		assert.Equal(t, "a", evt.Message().GetHeader("trace"))
+	case <-time.After(2 * time.Second):
+		t.Fatal("pending message not reclaimed")
+	}
+
+	assert.Eventually(t, func() bool {
+		return pendingCount(conn, "orders") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestReclaim_DeadLettersAfterMaxDeliveries(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	conn, err := redis.Dial("tcp", srv.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	readAsCrashedConsumer(t, conn, "orders", "crashed")
+
+	b := NewBroker(broker.WithAddress(srv.Addr()))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	// 消息第 2 次投递时仍处理失败，第 3 次认领时转入死信 Stream
+	_, err = b.Subscribe("orders", func(_ context.Context, evt broker.Event) error {
+		return errors.New("poison message")
+	}, nil,
+		redisOption.WithStreamConsumer("alive"),
+		redisOption.WithStreamBlockTime(50*time.Millisecond),
+		redisOption.WithStreamClaimMinIdle(50*time.Millisecond),
+		redisOption.WithStreamClaimInterval(50*time.Millisecond),
+		redisOption.WithStreamMaxDeliveries(2),
+	)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		entries, err := srv.Stream("orders" + redisOption.DefaultStreamDeadLetterSuffix)
+		return err == nil && len(entries) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	entries, _ := srv.Stream("orders" + redisOption.DefaultStreamDeadLetterSuffix)
+	fields := make(map[string]string)
+	for i := 0; i+1 < len(entries[0].Values); i += 2 {
+		fields[entries[0].Values[i]] = entries[0].Values[i+1]
+	}
+	assert.Equal(t, `{"n":1}`, fields["body"])
+	assert.Equal(t, "a", fields[HeaderFieldPrefix+"trace"])
+	assert.Equal(t, "orders", fields[HeaderFieldPrefix+broker.HeaderDeadLetterOriginalTopic])
+	assert.Equal(t, "2", fields[HeaderFieldPrefix+broker.HeaderDeadLetterAttempts])
+	assert.NotEmpty(t, fields[HeaderFieldPrefix+HeaderDeadLetterOriginalID])
+
+	assert.Eventually(t, func() bool {
+		return pendingCount(conn, "orders") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestReclaim_ClaimedEntryOverMaxDeliveriesDeadLettered(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	conn, err := redis.Dial("tcp", srv.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	readAsCrashedConsumer(t, conn, "orders", "crashed")
+	// 第 2 次投递
+	pending, err := redis.Values(conn.Do("XPENDING", "orders", redisOption.DefaultStreamGroup, "-", "+", 1))
+	assert.Nil(t, err)
+	fields, _ := redis.Values(pending[0], nil)
+	_, err = conn.Do("XCLAIM", "orders", redisOption.DefaultStreamGroup, "crashed", 0, fields[0])
+	assert.Nil(t, err)
+
+	b := NewBroker(broker.WithAddress(srv.Addr()))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	sub, err := b.(*streamBroker).newSubscriber("orders", nil, broker.NewSubscribeOptions(
+		redisOption.WithStreamConsumer("alive"),
+		redisOption.WithStreamClaimMinIdle(time.Millisecond),
+		redisOption.WithStreamMaxDeliveries(2),
+	))
+	assert.Nil(t, err)
+	sub.handler = func(context.Context, broker.Event) error {
+		t.Error("message over the delivery limit dispatched")
+		return nil
+	}
+
+	// 消息在 deadLetterPending 之后才达到 claimMinIdle，由 XAUTOCLAIM 直接认领
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, sub.claimPending())
+	sub.workers.Wait()
+
+	entries, err := srv.Stream("orders" + redisOption.DefaultStreamDeadLetterSuffix)
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 1) {
+		values := make(map[string]string)
+		for i := 0; i+1 < len(entries[0].Values); i += 2 {
+			values[entries[0].Values[i]] = entries[0].Values[i+1]
+		}
+		assert.Equal(t, "2", values[HeaderFieldPrefix+broker.HeaderDeadLetterAttempts])
+	}
+	assert.Equal(t, int64(0), pendingCount(conn, "orders"))
+}
+
+func TestNextStreamID(t *testing.T) {
+	assert.Equal(t, "1526919030474-56", nextStreamID("1526919030474-55"))
+	assert.Equal(t, "0-1", nextStreamID("0-0"))
+}
+
+func TestSubscribe_MessageFidelity(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	b := NewBroker(broker.WithAddress(srv.Addr()))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	got := make(chan broker.Event, 1)
+	_, err := b.Subscribe("orders", func(_ context.Context, evt broker.Event) error {
+		got <- evt
+		return nil
+	}, nil, redisOption.WithStreamBlockTime(50*time.Millisecond))
+	assert.Nil(t, err)
+
+	msg := broker.NewMessage(broker.RawBody("payload"), broker.WithKey("order-1"))
+	msg.SetHeader("traceparent", "00-abc-def-01")
+	// 与保留字段同名的消息头不会覆盖消息体
+	msg.SetHeader("body", "header")
+	assert.Nil(t, b.Publish(context.Background(), "orders", msg))
+
+	select {
+	case evt := <-got:
+		entries, _ := srv.Stream("orders")
+		assert.Len(t, entries, 1)
+
+		m := evt.Message()
+		assert.Equal(t, entries[0].ID, m.ID)
+		assert.Equal(t, "order-1", m.Key)
+		assert.Equal(t, "payload", string(m.Body.([]byte)))
+		assert.Equal(t, "00-abc-def-01", m.GetHeader("traceparent"))
+		assert.Equal(t, "header", m.GetHeader("body"))
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not delivered")
+	}
+}
+
+func TestSubscribe_StartFromBeginning(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	b := NewBroker(broker.WithAddress(srv.Addr()))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	// 消费组创建前写入的消息
+	assert.Nil(t, b.Publish(context.Background(), "orders", broker.NewMessage(broker.RawBody("old"))))
+
+	got := make(chan string, 1)
+	_, err := b.Subscribe("orders", func(_ context.Context, evt broker.Event) error {
+		got <- string(evt.Message().Body.([]byte))
+		return nil
+	}, nil,
+		redisOption.WithStreamStartID(redisOption.StreamStartBeginning),
+		redisOption.WithStreamBlockTime(50*time.Millisecond),
+	)
+	assert.Nil(t, err)
+
+	select {
+	case body := <-got:
+		assert.Equal(t, "old", body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("existing message not delivered")
+	}
+}
+
+func TestPublish_TrimMinID(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	conn, err := redis.Dial("tcp", srv.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, err = conn.Do("XADD", "orders", "1000-0", "body", "old")
+	assert.Nil(t, err)
+
+	b := NewBroker(broker.WithAddress(srv.Addr()))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+	defer b.Disconnect()
+
+	assert.Nil(t, b.Publish(context.Background(), "orders", broker.NewMessage(broker.RawBody("new")),
+		redisOption.WithStreamRetention(time.Hour)))
+
+	entries, err := srv.Stream("orders")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, []string{"body", "new"}, entries[0].Values)
+
+	_, err = b.(broker.BatchPublisher).PublishBatch(context.Background(), "orders", []*broker.Message{
+		broker.NewMessage(broker.RawBody("a")),
+		broker.NewMessage(broker.RawBody("b")),
+	}, redisOption.WithStreamMinID("99999999999999-0"))
+	assert.Nil(t, err)
+
+	entries, err = srv.Stream("orders")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestDisconnect_FinishesInFlightMessages(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	conn, err := redis.Dial("tcp", srv.Addr())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	b := NewBroker(broker.WithAddress(srv.Addr()))
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.Connect())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	_, err = b.Subscribe("orders", func(_ context.Context, _ broker.Event) error {
+		close(started)
+		<-release
+		return nil
+	}, nil,
+		redisOption.WithStreamBlockTime(50*time.Millisecond),
+		redisOption.WithStreamClaimMinIdle(50*time.Millisecond),
+		redisOption.WithStreamClaimInterval(10*time.Millisecond),
+	)
+	assert.Nil(t, err)
+
+	assert.Nil(t, b.Publish(context.Background(), "orders", broker.NewMessage(broker.RawBody("a"))))
+	<-started
+
+	disconnected := make(chan error, 1)
+	go func() { disconnected <- b.Disconnect() }()
+
+	// the pool stays open until the handler returns and its message is acked
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	select {
+	case err = <-disconnected:
+		assert.Nil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("disconnect did not return")
+	}
+	assert.Equal(t, int64(0), pendingCount(conn, "orders"))
+}
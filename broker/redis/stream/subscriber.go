@@ -1,9 +1,12 @@
 package stream
 
 import (
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/gomodule/redigo/redis"
+
 	"github.com/tx7do/kratos-transport/broker"
 	redisOption "github.com/tx7do/kratos-transport/broker/redis/option"
 )
@@ -19,37 +22,72 @@ type subscriber struct {
 
 	blockTime time.Duration
 	count     int
+	startID   string
+
+	// 待确认消息认领与死信，见 reclaim
+	claimMinIdle        time.Duration
+	claimInterval       time.Duration
+	maxDeliveries       int
+	deadLetterStream    string
+	consumerIdleTimeout time.Duration
 
-	handler broker.Handler
-	binder  broker.Binder
+	handler      broker.Handler
+	batchHandler broker.BatchHandler
+	binder       broker.Binder
 
 	options broker.SubscribeOptions
 
+	workers *broker.WorkerPool
+
+	// done 在取消订阅时关闭，wg 等待消费与认领协程退出
+	done chan struct{}
+	wg   sync.WaitGroup
+
 	closed bool
 }
 
-func (s *subscriber) onMessage(msgID string, data []byte) error {
-	var m broker.Message
+// streamEntry 是一条已读取、尚未解码的 Stream 消息
+type streamEntry struct {
+	msgID   string
+	key     string
+	data    []byte
+	headers broker.Headers
+}
+
+func (s *subscriber) newPublication(entry streamEntry) (*publication, error) {
+	m := broker.Message{
+		ID:      entry.msgID,
+		Key:     entry.key,
+		Headers: entry.headers,
+	}
 
 	if s.binder != nil {
 		m.Body = s.binder()
 
-		if err := broker.Unmarshal(s.b.options.Codec, data, &m.Body); err != nil {
-			return err
+		if err := broker.Unmarshal(s.b.options.Codec, entry.data, &m.Body); err != nil {
+			return nil, err
 		}
 	} else {
-		m.Body = data
+		m.Body = entry.data
 	}
 
-	p := publication{
+	return &publication{
 		topic:   s.topic,
 		group:   s.group,
-		msgID:   msgID,
+		msgID:   entry.msgID,
 		message: &m,
+		payload: entry.data,
 		pool:    s.b.pool,
+	}, nil
+}
+
+func (s *subscriber) onMessage(entry streamEntry) error {
+	p, err := s.newPublication(entry)
+	if err != nil {
+		return err
 	}
 
-	if p.err = s.handler(s.options.Context, &p); p.err != nil {
+	if p.err = s.handler(s.options.Context, p); p.err != nil {
 		return p.err
 	}
 
@@ -62,6 +100,66 @@ func (s *subscriber) onMessage(msgID string, data []byte) error {
 	return nil
 }
 
+// onBatch 将一次读取到的消息作为一个批次处理，成功后使用一条 XACK 确认全部消息
+func (s *subscriber) onBatch(entries []streamEntry) error {
+	evts := make([]broker.Event, 0, len(entries))
+	pubs := make([]*publication, 0, len(entries))
+	for _, entry := range entries {
+		p, err := s.newPublication(entry)
+		if err != nil {
+			redisOption.LogErrorf("decode message error [stream=%s id=%s]: %s", s.topic, entry.msgID, err.Error())
+			continue
+		}
+		evts = append(evts, p)
+		pubs = append(pubs, p)
+	}
+
+	if len(evts) == 0 {
+		return nil
+	}
+
+	if err := s.batchHandler(s.options.Context, evts); err != nil {
+		return err
+	}
+
+	if !s.options.AutoAck {
+		return nil
+	}
+
+	args := []any{s.topic, s.group}
+	for _, p := range pubs {
+		args = append(args, p.msgID)
+	}
+
+	conn := s.b.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("XACK", args...); err != nil {
+		return err
+	}
+	for _, p := range pubs {
+		p.acked = true
+	}
+	return nil
+}
+
+// start 启动消费协程，以及按需启动待确认消息认领协程
+func (s *subscriber) start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.recv()
+	}()
+
+	if s.claimMinIdle > 0 || s.consumerIdleTimeout > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.reclaim()
+		}()
+	}
+}
+
 func (s *subscriber) recv() {
 	reconnectDelay := 1 * time.Second
 	maxReconnectDelay := 30 * time.Second
@@ -81,12 +179,14 @@ func (s *subscriber) recv() {
 
 		select {
 		case <-time.After(reconnectDelay):
+		case <-s.done:
+			return
 		case <-s.options.Context.Done():
 			return
 		}
 
 		// 重连后确保消费组存在
-		if reErr := s.b.ensureGroup(s.topic, s.group); reErr != nil {
+		if reErr := s.b.ensureGroup(s.topic, s.group, s.startID); reErr != nil {
 			redisOption.LogWarnf("re-ensure group: %v", reErr)
 		}
 
@@ -104,6 +204,8 @@ func (s *subscriber) receiveLoop() error {
 		}
 
 		select {
+		case <-s.done:
+			return nil
 		case <-s.options.Context.Done():
 			return nil
 		default:
@@ -137,8 +239,8 @@ func (s *subscriber) receiveLoop() error {
 			continue
 		}
 
-		for _, streamEntry := range streams {
-			streamData, ok := streamEntry.([]any)
+		for _, streamReply := range streams {
+			streamData, ok := streamReply.([]any)
 			if !ok || len(streamData) < 2 {
 				continue
 			}
@@ -148,35 +250,79 @@ func (s *subscriber) receiveLoop() error {
 				continue
 			}
 
-			for _, msgEntry := range messages {
-				if s.IsClosed() {
-					return nil
-				}
-
-				msgData, ok := msgEntry.([]any)
-				if !ok || len(msgData) < 2 {
-					continue
-				}
-
-				msgID, _ := msgData[0].(string)
-				fields, _ := msgData[1].([]any)
-
-				// 提取 body 字段
-				body := s.extractField(fields, "body")
-				if body == nil {
-					continue
-				}
-
-				data, ok := body.([]byte)
-				if !ok {
-					continue
-				}
-
-				if err := s.onMessage(msgID, data); err != nil {
-					redisOption.LogErrorf("onMessage error [stream=%s id=%s]: %s", s.topic, msgID, err.Error())
-				}
-			}
+			s.dispatch(s.parseEntries(messages))
+		}
+	}
+}
+
+// parseEntries 解析 XREADGROUP/XAUTOCLAIM/XCLAIM 返回的消息列表: [ [id, [field, value, ...]], ... ]，
+// 跳过已被删除（字段为空）或没有 body 字段的消息
+func (s *subscriber) parseEntries(messages []any) []streamEntry {
+	entries := make([]streamEntry, 0, len(messages))
+
+	for _, msgEntry := range messages {
+		msgData, ok := msgEntry.([]any)
+		if !ok || len(msgData) < 2 {
+			continue
+		}
+
+		// redigo 以 []byte 返回消息 ID
+		msgID, err := redis.String(msgData[0], nil)
+		if err != nil {
+			continue
+		}
+		fields, _ := msgData[1].([]any)
+
+		// 提取 body 字段
+		body := s.extractField(fields, fieldBody)
+		if body == nil {
+			continue
 		}
+
+		data, ok := body.([]byte)
+		if !ok {
+			continue
+		}
+
+		var key string
+		if v, ok := s.extractField(fields, fieldKey).([]byte); ok {
+			key = string(v)
+		}
+
+		entries = append(entries, streamEntry{
+			msgID:   msgID,
+			key:     key,
+			data:    data,
+			headers: s.extractHeaders(fields),
+		})
+	}
+
+	return entries
+}
+
+// dispatch 将消息交给 handler（经工作池）或作为一个批次交给 batchHandler
+func (s *subscriber) dispatch(entries []streamEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	if s.batchHandler != nil {
+		if err := s.onBatch(entries); err != nil {
+			redisOption.LogErrorf("onBatch error [stream=%s count=%d]: %s", s.topic, len(entries), err.Error())
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if s.IsClosed() {
+			return
+		}
+
+		s.workers.Submit(func() {
+			if err := s.onMessage(entry); err != nil {
+				redisOption.LogErrorf("onMessage error [stream=%s id=%s]: %s", s.topic, entry.msgID, err.Error())
+			}
+		})
 	}
 }
 
@@ -191,6 +337,35 @@ func (s *subscriber) extractField(fields []any, key string) any {
 	return nil
 }
 
+// extractHeaders 将带 HeaderFieldPrefix 前缀的字段还原为消息头。
+// 兼容旧版本写入的无前缀字段，同名时以带前缀的字段为准
+func (s *subscriber) extractHeaders(fields []any) broker.Headers {
+	headers := make(broker.Headers, len(fields)/2)
+	for i := 0; i < len(fields)-1; i += 2 {
+		k, ok := fields[i].([]byte)
+		if !ok {
+			continue
+		}
+		v, ok := fields[i+1].([]byte)
+		if !ok {
+			continue
+		}
+
+		name := string(k)
+		if h, found := strings.CutPrefix(name, HeaderFieldPrefix); found {
+			headers[h] = string(v)
+			continue
+		}
+		if name == fieldBody || name == fieldKey {
+			continue
+		}
+		if _, exists := headers[name]; !exists {
+			headers[name] = string(v)
+		}
+	}
+	return headers
+}
+
 func (s *subscriber) Options() broker.SubscribeOptions {
 	s.RLock()
 	defer s.RUnlock()
@@ -207,9 +382,18 @@ func (s *subscriber) Topic() string {
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
 	s.Lock()
-	defer s.Unlock()
-
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
 	s.closed = true
+	close(s.done)
+	s.Unlock()
+
+	// 先停止消费与认领协程，不再提交新的消息；XREADGROUP 最多阻塞 blockTime
+	s.wg.Wait()
+	// 处理中的消息经连接池确认，需在 broker 关闭连接池之前完成
+	s.workers.Drain()
 
 	if s.b != nil && s.b.subscribers != nil && removeFromManager {
 		_ = s.b.subscribers.RemoveOnly(s.topic)
@@ -0,0 +1,251 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// HeaderReplyTo carries the topic a request expects its reply on.
+	HeaderReplyTo = "x-reply-to"
+	// HeaderCorrelationID links a reply to the request that caused it.
+	HeaderCorrelationID = "x-correlation-id"
+)
+
+const (
+	// DefaultInboxPrefix is the prefix of the reply topic generated for every Requester.
+	DefaultInboxPrefix = "_INBOX."
+
+	defaultRequestTimeout = 10 * time.Second
+)
+
+type replySubscriptionKey struct{}
+
+// IsReplySubscription reports whether options are those of the reply subscription of a Requester.
+// Brokers do not apply their subscriber middlewares to it: the replies are routed to the waiting
+// callers, the deduplication, retry or dead-letter middlewares of the application do not apply.
+func IsReplySubscription(options SubscribeOptions) bool {
+	if options.Context == nil {
+		return false
+	}
+	v, _ := options.Context.Value(replySubscriptionKey{}).(bool)
+	return v
+}
+
+// ErrRequesterClosed is returned to the requests still waiting for a reply when the Requester is closed.
+var ErrRequesterClosed = errors.New("requester closed")
+
+// Requester implements Broker.Request on top of Publish and Subscribe for brokers
+// without a native request/reply primitive.
+//
+// Every request carries a correlation ID and the reply topic in its headers. Replies are
+// consumed from a reply topic owned by the Requester instance and routed to the waiting
+// caller by correlation ID. Responders answer with Reply.
+type Requester struct {
+	sync.Mutex
+
+	b Broker
+
+	replyTopic    string
+	subscribeOpts []SubscribeOption
+
+	// subscribers holds one reply subscription per reply topic, created on first use
+	subscribers map[string]Subscriber
+	// pending holds the callers waiting for a reply, keyed by correlation ID
+	pending map[string]chan *Message
+}
+
+// RequesterOption configures a Requester.
+type RequesterOption func(*Requester)
+
+// WithInboxPrefix sets the prefix of the generated per-instance reply topic,
+// for backends whose topic names cannot start with DefaultInboxPrefix.
+func WithInboxPrefix(prefix string) RequesterOption {
+	return func(r *Requester) {
+		r.replyTopic = prefix + uuid.New().String()
+	}
+}
+
+// WithInboxTopic sets the per-instance reply topic. It must not be shared with other instances.
+func WithInboxTopic(topic string) RequesterOption {
+	return func(r *Requester) {
+		r.replyTopic = topic
+	}
+}
+
+// WithReplySubscribeOptions sets the options used to subscribe to reply topics.
+func WithReplySubscribeOptions(opts ...SubscribeOption) RequesterOption {
+	return func(r *Requester) {
+		r.subscribeOpts = append(r.subscribeOpts, opts...)
+	}
+}
+
+// NewRequester creates a Requester sending requests and receiving replies through b.
+func NewRequester(b Broker, opts ...RequesterOption) *Requester {
+	r := &Requester{
+		b:           b,
+		replyTopic:  DefaultInboxPrefix + uuid.New().String(),
+		subscribers: make(map[string]Subscriber),
+		pending:     make(map[string]chan *Message),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// ReplyTopic returns the per-instance reply topic used when RequestOptions.ReplyTopic is empty.
+func (r *Requester) ReplyTopic() string {
+	return r.replyTopic
+}
+
+// Request publishes msg to topic and waits for the reply carrying the same correlation ID,
+// until RequestOptions.Timeout elapses or ctx is done.
+// The reply body is returned undecoded, as the bytes produced by the responder's codec.
+func (r *Requester) Request(ctx context.Context, topic string, msg *Message, opts ...RequestOption) (*Message, error) {
+	if msg == nil {
+		return nil, errors.New("message is nil")
+	}
+
+	options := RequestOptions{
+		Context: ctx,
+		Timeout: defaultRequestTimeout,
+	}
+	options.Apply(opts...)
+	if options.Context == nil {
+		options.Context = context.Background()
+	}
+
+	replyTopic := options.ReplyTopic
+	if replyTopic == "" {
+		replyTopic = r.replyTopic
+	}
+
+	correlationID := uuid.New().String()
+	replyCh, err := r.register(replyTopic, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	defer r.unregister(correlationID)
+
+	reqCtx := options.Context
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(reqCtx, options.Timeout)
+		defer cancel()
+	}
+
+	req := msg.Clone()
+	req.SetHeader(HeaderReplyTo, replyTopic)
+	req.SetHeader(HeaderCorrelationID, correlationID)
+
+	if err = r.b.Publish(reqCtx, topic, req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply, ok := <-replyCh:
+		if !ok {
+			return nil, ErrRequesterClosed
+		}
+		return reply, nil
+	case <-reqCtx.Done():
+		return nil, reqCtx.Err()
+	}
+}
+
+// Close removes the reply subscriptions and fails the pending requests with ErrRequesterClosed.
+// The Requester may be used again afterwards, e.g. once its broker has reconnected.
+func (r *Requester) Close() error {
+	r.Lock()
+	subs := r.subscribers
+	pending := r.pending
+	r.subscribers = make(map[string]Subscriber)
+	r.pending = make(map[string]chan *Message)
+	r.Unlock()
+
+	for _, ch := range pending {
+		close(ch)
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		if err := sub.Unsubscribe(true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Requester) register(replyTopic, correlationID string) (chan *Message, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.subscribers[replyTopic]; !ok {
+		opts := append([]SubscribeOption{SubscribeContextWithValue(replySubscriptionKey{}, true)}, r.subscribeOpts...)
+		sub, err := r.b.Subscribe(replyTopic, r.onReply, nil, opts...)
+		if err != nil {
+			return nil, err
+		}
+		r.subscribers[replyTopic] = sub
+	}
+
+	ch := make(chan *Message, 1)
+	r.pending[correlationID] = ch
+	return ch, nil
+}
+
+func (r *Requester) unregister(correlationID string) {
+	r.Lock()
+	defer r.Unlock()
+
+	delete(r.pending, correlationID)
+}
+
+func (r *Requester) onReply(_ context.Context, evt Event) error {
+	if evt == nil || evt.Message() == nil {
+		return nil
+	}
+
+	correlationID := evt.Message().GetHeader(HeaderCorrelationID)
+	if correlationID == "" {
+		return nil
+	}
+
+	r.Lock()
+	ch, ok := r.pending[correlationID]
+	if ok {
+		delete(r.pending, correlationID)
+	}
+	r.Unlock()
+
+	// late replies of requests that already timed out are dropped
+	if ok {
+		ch <- evt.Message()
+	}
+	return nil
+}
+
+// Reply publishes resp through b to the reply topic of the request carried by evt.
+func Reply(ctx context.Context, b Broker, evt Event, resp *Message) error {
+	if evt == nil || evt.Message() == nil {
+		return errors.New("event or message is nil")
+	}
+	if resp == nil {
+		return errors.New("message is nil")
+	}
+
+	replyTo := evt.Message().GetHeader(HeaderReplyTo)
+	if replyTo == "" {
+		return errors.New("message is not a request: reply topic is missing")
+	}
+
+	out := resp.Clone()
+	out.SetHeader(HeaderCorrelationID, evt.Message().GetHeader(HeaderCorrelationID))
+
+	return b.Publish(ctx, replyTo, out)
+}
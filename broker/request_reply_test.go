@@ -0,0 +1,131 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// loopbackBroker 在 Publish 时异步调用同主题的订阅 Handler
+type loopbackBroker struct {
+	recordingBroker
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+}
+
+func newLoopbackBroker() *loopbackBroker {
+	return &loopbackBroker{handlers: make(map[string]Handler)}
+}
+
+func (l *loopbackBroker) Subscribe(topic string, h Handler, _ Binder, _ ...SubscribeOption) (Subscriber, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.handlers[topic] = h
+	return &mockSubscriber{name: topic}, nil
+}
+
+func (l *loopbackBroker) Publish(ctx context.Context, topic string, msg *Message, _ ...PublishOption) error {
+	l.mu.Lock()
+	h := l.handlers[topic]
+	l.mu.Unlock()
+
+	if h != nil {
+		go func() { _ = h(ctx, &ackEvent{msg: msg}) }()
+	}
+	return nil
+}
+
+func TestRequester_RoundTrip(t *testing.T) {
+	b := newLoopbackBroker()
+	r := NewRequester(b)
+
+	_, _ = b.Subscribe("rpc.echo", func(ctx context.Context, evt Event) error {
+		return Reply(ctx, b, evt, NewMessage(evt.Message().Body))
+	}, nil)
+
+	reply, err := r.Request(context.Background(), "rpc.echo", NewMessage("ping"), WithRequestTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if reply.Body != "ping" {
+		t.Fatalf("unexpected reply: %v", reply.Body)
+	}
+	if reply.GetHeader(HeaderCorrelationID) == "" {
+		t.Fatal("reply has no correlation ID")
+	}
+}
+
+func TestRequester_ReplyTopicOption(t *testing.T) {
+	b := newLoopbackBroker()
+	r := NewRequester(b, WithInboxPrefix("inbox-"))
+
+	if r.ReplyTopic()[:6] != "inbox-" {
+		t.Fatalf("unexpected reply topic: %s", r.ReplyTopic())
+	}
+
+	var gotReplyTo string
+	_, _ = b.Subscribe("rpc", func(ctx context.Context, evt Event) error {
+		gotReplyTo = evt.Message().GetHeader(HeaderReplyTo)
+		return Reply(ctx, b, evt, NewMessage("pong"))
+	}, nil)
+
+	if _, err := r.Request(context.Background(), "rpc", NewMessage("ping"), WithReplyTopic("custom.replies")); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if gotReplyTo != "custom.replies" {
+		t.Fatalf("unexpected reply-to header: %s", gotReplyTo)
+	}
+}
+
+func TestRequester_Timeout(t *testing.T) {
+	r := NewRequester(newLoopbackBroker())
+
+	_, err := r.Request(context.Background(), "nobody", NewMessage("ping"), WithRequestTimeout(20*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRequester_CloseFailsPending(t *testing.T) {
+	r := NewRequester(newLoopbackBroker())
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := r.Request(context.Background(), "nobody", NewMessage("ping"), WithRequestTimeout(time.Minute))
+		errCh <- err
+	}()
+
+	// 等待请求注册完成
+	for {
+		r.Lock()
+		n := len(r.pending)
+		r.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, ErrRequesterClosed) {
+			t.Fatalf("expected ErrRequesterClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending request not released by Close")
+	}
+}
+
+func TestReply_RequiresReplyTopic(t *testing.T) {
+	if err := Reply(context.Background(), &recordingBroker{}, &ackEvent{msg: NewMessage("x")}, NewMessage("y")); err == nil {
+		t.Fatal("expected error for a message without reply topic")
+	}
+}
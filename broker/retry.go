@@ -0,0 +1,65 @@
+package broker
+
+import (
+	"context"
+	"time"
+)
+
+type attemptKey struct{}
+
+// AttemptFromContext returns the number of the handler attempt running with ctx,
+// 1 for the first one and for the handlers which are not retried.
+func AttemptFromContext(ctx context.Context) int {
+	if ctx != nil {
+		if attempt, ok := ctx.Value(attemptKey{}).(int); ok && attempt > 0 {
+			return attempt
+		}
+	}
+	return 1
+}
+
+// RetryHandler wraps h so that a failed invocation is retried up to maxRetries times,
+// waiting delay between attempts. The last error is returned once all attempts fail
+// or the context is cancelled while waiting.
+func RetryHandler(h Handler, maxRetries int, delay time.Duration) Handler {
+	if h == nil || maxRetries <= 0 {
+		return h
+	}
+
+	return func(ctx context.Context, evt Event) error {
+		_, err := retry(ctx, evt, h, maxRetries, delay)
+		return err
+	}
+}
+
+// retry invokes h until it succeeds or maxRetries retries are spent,
+// and reports how many attempts were made. The attempt number is passed to h in the context.
+func retry(ctx context.Context, evt Event, h Handler, maxRetries int, delay time.Duration) (int, error) {
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 && delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return attempt, err
+			case <-timer.C:
+			}
+		}
+
+		if err = h(context.WithValue(ctx, attemptKey{}, attempt+1), evt); err == nil {
+			return attempt + 1, nil
+		}
+	}
+	return maxRetries + 1, err
+}
+
+// WrapSubscribeHandler applies the generic handler policies of SubscribeOptions
+// (MaxRetries, RetryDelay and DeadLetter) to h. Brokers call it after chaining subscriber
+// middlewares, passing themselves so that dead letters are published through the same broker.
+func WrapSubscribeHandler(b Broker, h Handler, options SubscribeOptions) Handler {
+	if options.DeadLetter.Enabled() && b != nil {
+		return DeadLetterHandler(b, h, options)
+	}
+	return RetryHandler(h, options.MaxRetries, options.RetryDelay)
+}
@@ -0,0 +1,72 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryHandler_SucceedsAfterFailures(t *testing.T) {
+	calls := 0
+	h := RetryHandler(func(ctx context.Context, evt Event) error {
+		calls++
+		if calls < 3 {
+			return errors.New("boom")
+		}
+		return nil
+	}, 3, time.Millisecond)
+
+	if err := h(context.Background(), dummyEvent{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryHandler_ReturnsLastError(t *testing.T) {
+	calls := 0
+	h := RetryHandler(func(ctx context.Context, evt Event) error {
+		calls++
+		return errors.New("boom")
+	}, 2, 0)
+
+	if err := h(context.Background(), dummyEvent{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 3 {
+		t.Fatalf("expected 1 attempt + 2 retries, got %d calls", calls)
+	}
+}
+
+func TestRetryHandler_StopsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	h := RetryHandler(func(ctx context.Context, evt Event) error {
+		calls++
+		cancel()
+		return errors.New("boom")
+	}, 5, time.Hour)
+
+	if err := h(ctx, dummyEvent{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single call, got %d", calls)
+	}
+}
+
+func TestWrapSubscribeHandler_NoRetries(t *testing.T) {
+	calls := 0
+	h := WrapSubscribeHandler(nil, func(ctx context.Context, evt Event) error {
+		calls++
+		return errors.New("boom")
+	}, NewSubscribeOptions())
+
+	_ = h(context.Background(), dummyEvent{})
+	if calls != 1 {
+		t.Fatalf("expected a single call, got %d", calls)
+	}
+}
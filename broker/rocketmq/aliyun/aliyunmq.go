@@ -47,18 +47,23 @@ type aliyunmqBroker struct {
 
 	subscribers *broker.SubscriberSyncMap
 
+	requester *broker.Requester
+
 	producerTracer *tracing.Tracer
 	consumerTracer *tracing.Tracer
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
 	options := broker.NewOptionsAndApply(opts...)
-	return &aliyunmqBroker{
+	b := &aliyunmqBroker{
 		producers:   make(map[string]aliyun.MQProducer),
 		options:     options,
 		retryCount:  2,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	b.requester = broker.NewRequester(b, broker.WithInboxPrefix(rocketmqOption.DefaultInboxPrefix))
+
+	return b
 }
 
 func (b *aliyunmqBroker) Name() string {
@@ -147,6 +152,8 @@ func (b *aliyunmqBroker) Disconnect() error {
 	}
 	b.RUnlock()
 
+	_ = b.requester.Close()
+
 	b.Lock()
 	defer b.Unlock()
 
@@ -157,7 +164,7 @@ func (b *aliyunmqBroker) Disconnect() error {
 }
 
 func (b *aliyunmqBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *aliyunmqBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -175,6 +182,7 @@ func (b *aliyunmqBroker) internalPublish(ctx context.Context, topic string, msg
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -263,9 +271,10 @@ func (b *aliyunmqBroker) Subscribe(topic string, handler broker.Handler, binder
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	mqConsumer := b.client.GetConsumer(b.instanceName, topic, options.Queue, "")
 
@@ -275,6 +284,7 @@ func (b *aliyunmqBroker) Subscribe(topic string, handler broker.Handler, binder
 		handler: handler,
 		binder:  binder,
 		reader:  mqConsumer,
+		workers: broker.NewSubscribeWorkerPool(options),
 		done:    make(chan struct{}),
 	}
 
@@ -292,63 +302,13 @@ func (b *aliyunmqBroker) doConsume(sub *Subscriber) {
 			select {
 			case resp := <-respChan:
 				{
-					var err error
 					for _, msg := range resp.Messages {
-						var m broker.Message
-
-						ctx, span := b.startConsumerSpan(sub.options.Context, &msg)
-
-						p := &Publication{
-							topic:  msg.Message,
-							reader: sub.reader,
-							m:      &m,
-							rm:     []string{msg.ReceiptHandle},
-							ctx:    b.options.Context,
-						}
-
-						m.Headers = msg.Properties
-
-						if sub.binder != nil {
-							m.Body = sub.binder()
-
-							if err = broker.Unmarshal(b.options.Codec, []byte(msg.MessageBody), &m.Body); err != nil {
-								LogErrorf("unmarshal message failed: %v", err)
-								b.finishConsumerSpan(ctx, span, err)
-								continue
-							}
-						} else {
-							m.Body = msg.MessageBody
-						}
-
-						if err = sub.handler(ctx, p); err != nil {
-							LogErrorf("process message failed: %v", err)
-							b.finishConsumerSpan(ctx, span, err)
-							continue
-						}
-
-						if sub.options.AutoAck {
-							if err = p.Ack(); err != nil {
-								// 某些消息的句柄可能超时，会导致消息消费状态确认不成功。
-								if errCode, ok := err.(gogapErrors.ErrCode); ok {
-									if errAckItems, ok := errCode.Context()["Detail"].([]aliyun.ErrAckItem); ok {
-										for _, errAckItem := range errAckItems {
-											LogErrorf("ErrorHandle:%s, ErrorCode:%s, ErrorMsg:%s\n",
-												errAckItem.ErrorHandle, errAckItem.ErrorCode, errAckItem.ErrorMsg)
-										}
-									} else {
-										LogErrorf("ack err: %v", err)
-									}
-								} else {
-									LogErrorf("ack err: %v", err)
-								}
-								b.finishConsumerSpan(ctx, span, err)
-								time.Sleep(time.Duration(3) * time.Second)
-								continue
-							}
-						}
-
-						b.finishConsumerSpan(ctx, span, nil)
+						sub.workers.Submit(func() {
+							b.handleMessage(sub, msg)
+						})
 					}
+					// the next long poll starts once the whole batch is handled
+					sub.workers.Wait()
 
 					endChan <- 1
 				}
@@ -387,6 +347,64 @@ func (b *aliyunmqBroker) doConsume(sub *Subscriber) {
 	}
 }
 
+func (b *aliyunmqBroker) handleMessage(sub *Subscriber, msg aliyun.ConsumeMessageEntry) {
+	var err error
+	var m broker.Message
+
+	ctx, span := b.startConsumerSpan(sub.options.Context, &msg)
+
+	p := &Publication{
+		topic:  msg.Message,
+		reader: sub.reader,
+		m:      &m,
+		rm:     []string{msg.ReceiptHandle},
+		ctx:    b.options.Context,
+	}
+
+	m.Headers = msg.Properties
+
+	if sub.binder != nil {
+		m.Body = sub.binder()
+
+		if err = broker.Unmarshal(b.options.Codec, []byte(msg.MessageBody), &m.Body); err != nil {
+			LogErrorf("unmarshal message failed: %v", err)
+			b.finishConsumerSpan(ctx, span, err)
+			return
+		}
+	} else {
+		m.Body = msg.MessageBody
+	}
+
+	if err = sub.handler(ctx, p); err != nil {
+		LogErrorf("process message failed: %v", err)
+		b.finishConsumerSpan(ctx, span, err)
+		return
+	}
+
+	if sub.options.AutoAck {
+		if err = p.Ack(); err != nil {
+			// 某些消息的句柄可能超时，会导致消息消费状态确认不成功。
+			if errCode, ok := err.(gogapErrors.ErrCode); ok {
+				if errAckItems, ok := errCode.Context()["Detail"].([]aliyun.ErrAckItem); ok {
+					for _, errAckItem := range errAckItems {
+						LogErrorf("ErrorHandle:%s, ErrorCode:%s, ErrorMsg:%s\n",
+							errAckItem.ErrorHandle, errAckItem.ErrorCode, errAckItem.ErrorMsg)
+					}
+				} else {
+					LogErrorf("ack err: %v", err)
+				}
+			} else {
+				LogErrorf("ack err: %v", err)
+			}
+			b.finishConsumerSpan(ctx, span, err)
+			time.Sleep(time.Duration(3) * time.Second)
+			return
+		}
+	}
+
+	b.finishConsumerSpan(ctx, span, nil)
+}
+
 func (b *aliyunmqBroker) startProducerSpan(
 	ctx context.Context,
 	topicName string,
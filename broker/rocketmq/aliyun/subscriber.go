@@ -16,6 +16,7 @@ type Subscriber struct {
 	handler broker.Handler
 	binder  broker.Binder
 	reader  aliyun.MQConsumer
+	workers *broker.WorkerPool
 	closed  bool
 	done    chan struct{}
 }
@@ -29,6 +30,9 @@ func (s *Subscriber) Topic() string {
 }
 
 func (s *Subscriber) Unsubscribe(removeFromManager bool) error {
+	// the handlers ack through the consumer, they finish before it stops consuming
+	s.workers.Drain()
+
 	s.Lock()
 	defer s.Unlock()
 
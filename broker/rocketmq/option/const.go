@@ -2,6 +2,9 @@ package rocketmqOption
 
 const (
 	DefaultAddr = "127.0.0.1:9876"
+
+	// DefaultInboxPrefix is the prefix of generated reply topics, topic names do not allow dots
+	DefaultInboxPrefix = "inbox-"
 )
 
 type DriverType string
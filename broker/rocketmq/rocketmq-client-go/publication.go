@@ -29,6 +29,14 @@ func (p *publication) RawMessage() any {
 	return p.rm
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.rm == nil {
+		return nil
+	}
+	return p.rm.Body
+}
+
 func (p *publication) Ack() error {
 	return nil
 }
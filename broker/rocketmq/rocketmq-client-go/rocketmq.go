@@ -17,6 +17,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
 
 	"github.com/tx7do/kratos-transport/broker"
 	rocketmqOption "github.com/tx7do/kratos-transport/broker/rocketmq/option"
@@ -51,6 +52,8 @@ type rocketmqBroker struct {
 	producers   map[string]rocketmq.Producer
 	subscribers *broker.SubscriberSyncMap
 
+	requester *broker.Requester
+
 	producerTracer *tracing.Tracer
 	consumerTracer *tracing.Tracer
 
@@ -60,7 +63,7 @@ type rocketmqBroker struct {
 func NewBroker(opts ...broker.Option) broker.Broker {
 	options := broker.NewOptionsAndApply(opts...)
 
-	return &rocketmqBroker{
+	b := &rocketmqBroker{
 		options:     options,
 		retryCount:  2,
 		producers:   make(map[string]rocketmq.Producer),
@@ -69,6 +72,15 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 			level: log.LevelInfo,
 		},
 	}
+
+	// the reply topic is consumed by a group of its own, so that every instance receives its replies
+	inbox := rocketmqOption.DefaultInboxPrefix + uuid.New().String()
+	b.requester = broker.NewRequester(b,
+		broker.WithInboxTopic(inbox),
+		broker.WithReplySubscribeOptions(broker.WithSubscribeQueueName(inbox)),
+	)
+
+	return b
 }
 
 func (b *rocketmqBroker) Name() string {
@@ -162,6 +174,8 @@ func (b *rocketmqBroker) Disconnect() error {
 	}
 	b.RUnlock()
 
+	_ = b.requester.Close()
+
 	b.Lock()
 	defer b.Unlock()
 	for _, p := range b.producers {
@@ -258,6 +272,11 @@ func (b *rocketmqBroker) createConsumer(options *broker.SubscribeOptions) (rocke
 		consumer.WithInstance(b.instanceName),
 	}
 
+	// the push consumer runs callbacks on its own goroutines, the generic option maps onto them
+	if options.Concurrency > 0 {
+		consumerOptions = append(consumerOptions, consumer.WithConsumeGoroutineNums(options.Concurrency))
+	}
+
 	credentials := b.makeCredentials()
 	consumerOptions = append(consumerOptions, consumer.WithCredentials(*credentials))
 
@@ -300,7 +319,7 @@ func (b *rocketmqBroker) createConsumer(options *broker.SubscribeOptions) (rocke
 }
 
 func (b *rocketmqBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *rocketmqBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -318,6 +337,7 @@ func (b *rocketmqBroker) internalPublish(ctx context.Context, topic string, msg
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -436,9 +456,10 @@ func (b *rocketmqBroker) Subscribe(topic string, handler broker.Handler, binder
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	c, err := b.createConsumer(&options)
 	if err != nil {
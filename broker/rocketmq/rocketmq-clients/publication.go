@@ -31,6 +31,14 @@ func (p *publication) RawMessage() any {
 	return p.rmqMessage
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.rmqMessage == nil {
+		return nil
+	}
+	return p.rmqMessage.GetBody()
+}
+
 func (p *publication) Ack() error {
 	if p.reader == nil {
 		p.err = errors.New("reader is nil")
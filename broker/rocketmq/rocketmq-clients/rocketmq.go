@@ -53,6 +53,8 @@ type rocketmqBroker struct {
 	subscribers *broker.SubscriberSyncMap
 	done        chan struct{}
 
+	requester *broker.Requester
+
 	subscriptionExpressions map[string]*rmqClient.FilterExpression
 	awaitDuration           time.Duration
 	maxMessageNum           int32
@@ -66,7 +68,7 @@ type rocketmqBroker struct {
 func NewBroker(opts ...broker.Option) broker.Broker {
 	rocketmqOptions := broker.NewOptionsAndApply(opts...)
 
-	return &rocketmqBroker{
+	b := &rocketmqBroker{
 		options:           rocketmqOptions,
 		retryCount:        2,
 		awaitDuration:     defaultAwaitDuration,
@@ -78,6 +80,9 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		credentials:       rocketmqOption.Credentials{},
 		done:              make(chan struct{}),
 	}
+	b.requester = broker.NewRequester(b, broker.WithInboxPrefix(rocketmqOption.DefaultInboxPrefix))
+
+	return b
 }
 
 func (b *rocketmqBroker) Name() string {
@@ -203,6 +208,8 @@ func (b *rocketmqBroker) Disconnect() error {
 	}
 	b.RUnlock()
 
+	_ = b.requester.Close()
+
 	b.Lock()
 	defer b.Unlock()
 	close(b.done)
@@ -224,7 +231,7 @@ func (b *rocketmqBroker) Disconnect() error {
 }
 
 func (b *rocketmqBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *rocketmqBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -242,6 +249,7 @@ func (b *rocketmqBroker) internalPublish(ctx context.Context, topic string, msg
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -386,9 +394,10 @@ func (b *rocketmqBroker) Subscribe(topic string, handler broker.Handler, binder
 		o(rocketmqOptions)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(*rocketmqOptions) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, *rocketmqOptions)
 
 	if b.consumer == nil {
 		c, err := b.createConsumer(rocketmqOptions)
@@ -405,6 +414,7 @@ func (b *rocketmqBroker) Subscribe(topic string, handler broker.Handler, binder
 		handler: handler,
 		binder:  binder,
 		reader:  b.consumer,
+		workers: broker.NewSubscribeWorkerPool(*rocketmqOptions),
 		done:    make(chan error),
 	}
 
@@ -526,13 +536,15 @@ func (b *rocketmqBroker) run() {
 
 			aSub := sub.(*subscriber)
 
-			if err = aSub.onMessage(newCtx, mv); err != nil {
-				LogErrorf("[%s] onMessage failed: %s", mv.GetTopic(), err.Error())
-				b.finishConsumerSpan(newCtx, span, err)
-				continue
-			}
+			aSub.workers.Submit(func() {
+				if err := aSub.onMessage(newCtx, mv); err != nil {
+					LogErrorf("[%s] onMessage failed: %s", mv.GetTopic(), err.Error())
+					b.finishConsumerSpan(newCtx, span, err)
+					return
+				}
 
-			b.finishConsumerSpan(newCtx, span, nil)
+				b.finishConsumerSpan(newCtx, span, nil)
+			})
 		}
 
 		time.Sleep(b.receiveInterval)
@@ -22,7 +22,8 @@ type subscriber struct {
 	closed bool
 	done   chan error
 
-	reader rmqClient.SimpleConsumer
+	reader  rmqClient.SimpleConsumer
+	workers *broker.WorkerPool
 }
 
 func (s *subscriber) Options() broker.SubscribeOptions {
@@ -40,6 +41,9 @@ func (s *subscriber) Topic() string {
 }
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
+	// the handlers ack through the consumer, they finish before the topic is unsubscribed
+	s.workers.Drain()
+
 	s.Lock()
 	defer s.Unlock()
 
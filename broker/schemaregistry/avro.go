@@ -0,0 +1,114 @@
+package schemaregistry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/hamba/avro/v2"
+)
+
+// AvroCodecName is the name of AvroCodec, to pass to broker.WithCodec once registered with encoding.RegisterCodec.
+const AvroCodecName = "avro"
+
+// AvroCodec encodes messages in Avro, framed in the Confluent wire format.
+//
+// Marshal encodes a value with the schema registered for its type. Unmarshal fetches the
+// writer schema of every payload from the registry and, when the target type has a schema
+// of its own, resolves the writer schema against it, so that consumers keep decoding the
+// messages of older and newer producers as the schema evolves.
+type AvroCodec struct {
+	*codec
+
+	compat *avro.SchemaCompatibility
+
+	mu       sync.RWMutex
+	resolved map[[2]int]avro.Schema
+}
+
+var _ encoding.Codec = (*AvroCodec)(nil)
+
+// NewAvroCodec creates an AvroCodec backed by registry.
+func NewAvroCodec(registry SchemaRegistry, opts ...Option) *AvroCodec {
+	return &AvroCodec{
+		codec: newCodec(registry, Avro, func(schema string) (any, error) {
+			return parseAvro(schema)
+		}, opts...),
+		compat:   avro.NewSchemaCompatibility(),
+		resolved: make(map[[2]int]avro.Schema),
+	}
+}
+
+// Name implements encoding.Codec.
+func (c *AvroCodec) Name() string {
+	return AvroCodecName
+}
+
+// Register sets schema as the writer schema of the type of v (a value or a pointer),
+// registering it under subject, see TopicNameStrategy.
+func (c *AvroCodec) Register(ctx context.Context, v any, subject, schema string) error {
+	return c.register(ctx, v, subject, schema)
+}
+
+// Marshal implements encoding.Codec.
+func (c *AvroCodec) Marshal(v any) ([]byte, error) {
+	b := c.binding(v)
+	if b == nil {
+		return nil, fmt.Errorf("no avro schema registered for %T", v)
+	}
+
+	payload, err := avro.Marshal(b.schema.(avro.Schema), v)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeWire(b.id, payload), nil
+}
+
+// Unmarshal implements encoding.Codec.
+func (c *AvroCodec) Unmarshal(data []byte, v any) error {
+	id, payload, err := DecodeWire(data)
+	if err != nil {
+		return err
+	}
+
+	v = target(v)
+
+	schema, err := c.readerSchema(id, c.binding(v))
+	if err != nil {
+		return err
+	}
+	return avro.Unmarshal(schema, payload, v)
+}
+
+// readerSchema returns the schema decoding the payloads written with schema id into the type bound by b.
+func (c *AvroCodec) readerSchema(id int, b *binding) (avro.Schema, error) {
+	parsed, err := c.writer(id)
+	if err != nil {
+		return nil, err
+	}
+	writer := parsed.(avro.Schema)
+
+	if b == nil || b.id == id {
+		return writer, nil
+	}
+
+	key := [2]int{b.id, id}
+
+	c.mu.RLock()
+	schema, ok := c.resolved[key]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	if schema, err = c.compat.Resolve(b.schema.(avro.Schema), writer); err != nil {
+		return nil, fmt.Errorf("%w: writer schema [%d] against reader schema [%d]: %s", ErrIncompatibleSchema, id, b.id, err.Error())
+	}
+
+	c.mu.Lock()
+	c.resolved[key] = schema
+	c.mu.Unlock()
+
+	return schema, nil
+}
@@ -0,0 +1,136 @@
+package schemaregistry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// binding is the writer schema of a Go type.
+type binding struct {
+	subject string
+	id      int
+	schema  any
+}
+
+// codec holds the state shared by the codecs of every schema type: the writer schema of each
+// registered Go type, and the schemas fetched by ID to decode the received payloads.
+type codec struct {
+	registry   SchemaRegistry
+	options    options
+	schemaType SchemaType
+	parse      func(schema string) (any, error)
+
+	mu       sync.RWMutex
+	bindings map[reflect.Type]*binding
+	writers  map[int]any
+}
+
+func newCodec(registry SchemaRegistry, schemaType SchemaType, parse func(string) (any, error), opts ...Option) *codec {
+	return &codec{
+		registry:   registry,
+		options:    newOptions(opts...),
+		schemaType: schemaType,
+		parse:      parse,
+		bindings:   make(map[reflect.Type]*binding),
+		writers:    make(map[int]any),
+	}
+}
+
+func (c *codec) register(ctx context.Context, v any, subject, schema string) error {
+	if c.registry == nil {
+		return fmt.Errorf("schema registry is nil")
+	}
+	if subject == "" {
+		return fmt.Errorf("subject is empty")
+	}
+
+	t := typeOf(v)
+	if t == nil {
+		return fmt.Errorf("cannot register a schema for a nil value")
+	}
+
+	parsed, err := c.parse(schema)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	if c.options.autoRegister {
+		id, err = c.registry.Register(ctx, subject, c.schemaType, schema)
+	} else {
+		id, err = c.registry.Lookup(ctx, subject, c.schemaType, schema)
+	}
+	if err != nil {
+		return fmt.Errorf("register schema of %s under subject [%s]: %w", t, subject, err)
+	}
+
+	c.mu.Lock()
+	c.bindings[t] = &binding{subject: subject, id: id, schema: parsed}
+	c.writers[id] = parsed
+	c.mu.Unlock()
+
+	return nil
+}
+
+// binding returns the writer schema of the type of v, nil when none was registered.
+func (c *codec) binding(v any) *binding {
+	t := typeOf(v)
+	if t == nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.bindings[t]
+}
+
+// writer returns the parsed schema of the given ID, fetching it from the registry on first use.
+func (c *codec) writer(id int) (any, error) {
+	c.mu.RLock()
+	parsed, ok := c.writers[id]
+	c.mu.RUnlock()
+	if ok {
+		return parsed, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.timeout)
+	defer cancel()
+
+	s, err := c.registry.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch writer schema [%d]: %w", id, err)
+	}
+	if s.Type != c.schemaType {
+		return nil, fmt.Errorf("writer schema [%d] is a %s schema, expected %s", id, s.Type, c.schemaType)
+	}
+
+	if parsed, err = c.parse(s.Schema); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.writers[id] = parsed
+	c.mu.Unlock()
+
+	return parsed, nil
+}
+
+// target returns the value to decode into. The brokers pass a pointer to the message body,
+// which holds the pointer created by the binder.
+func target(v any) any {
+	if p, ok := v.(*any); ok && *p != nil {
+		return *p
+	}
+	return v
+}
+
+func typeOf(v any) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,197 @@
+package schemaregistry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tx7do/kratos-transport/broker"
+)
+
+const orderV1 = `{
+  "type": "record", "name": "Order", "namespace": "shop",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "amount", "type": "double"}
+  ]
+}`
+
+// orderV2 adds a field with a default: it can read the orders written with orderV1
+const orderV2 = `{
+  "type": "record", "name": "Order", "namespace": "shop",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "amount", "type": "double"},
+    {"name": "currency", "type": "string", "default": "EUR"}
+  ]
+}`
+
+// orderV3 adds a field without a default: it cannot read orderV2
+const orderV3 = `{
+  "type": "record", "name": "Order", "namespace": "shop",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "amount", "type": "double"},
+    {"name": "currency", "type": "string", "default": "EUR"},
+    {"name": "customer", "type": "string"}
+  ]
+}`
+
+type orderV1Record struct {
+	ID     string  `avro:"id" json:"id"`
+	Amount float64 `avro:"amount" json:"amount"`
+}
+
+type orderV2Record struct {
+	ID       string  `avro:"id"`
+	Amount   float64 `avro:"amount"`
+	Currency string  `avro:"currency"`
+}
+
+func TestWire_RoundTrip(t *testing.T) {
+	data := EncodeWire(258, []byte("payload"))
+	if len(data) != 12 || data[0] != 0 || data[3] != 1 || data[4] != 2 {
+		t.Fatalf("unexpected framing: %v", data)
+	}
+
+	id, payload, err := DecodeWire(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if id != 258 || string(payload) != "payload" {
+		t.Fatalf("unexpected id %d or payload %q", id, payload)
+	}
+
+	if _, _, err = DecodeWire([]byte(`{"id":1}`)); !errors.Is(err, ErrInvalidWireFormat) {
+		t.Fatalf("expected ErrInvalidWireFormat, got %v", err)
+	}
+}
+
+func TestMemoryRegistry_Compatibility(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRegistry()
+
+	id1, err := r.Register(ctx, "orders-value", Avro, orderV1)
+	if err != nil {
+		t.Fatalf("register v1: %v", err)
+	}
+	if again, _ := r.Register(ctx, "orders-value", Avro, orderV1); again != id1 {
+		t.Fatalf("registering twice returned a new id %d", again)
+	}
+
+	if ok, err := r.CheckCompatibility(ctx, "orders-value", Avro, orderV2); err != nil || !ok {
+		t.Fatalf("v2 should be compatible: %v, %v", ok, err)
+	}
+	id2, err := r.Register(ctx, "orders-value", Avro, orderV2)
+	if err != nil || id2 == id1 {
+		t.Fatalf("register v2: id=%d, %v", id2, err)
+	}
+
+	if ok, _ := r.CheckCompatibility(ctx, "orders-value", Avro, orderV3); ok {
+		t.Fatal("v3 should be incompatible")
+	}
+	if _, err = r.Register(ctx, "orders-value", Avro, orderV3); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("expected ErrIncompatibleSchema, got %v", err)
+	}
+
+	latest, err := r.GetLatest(ctx, "orders-value")
+	if err != nil || latest.ID != id2 || latest.Version != 2 {
+		t.Fatalf("unexpected latest version: %+v, %v", latest, err)
+	}
+
+	if _, err = r.GetByID(ctx, 42); !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("expected ErrSchemaNotFound, got %v", err)
+	}
+}
+
+func TestAvroCodec_SchemaEvolution(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryRegistry()
+
+	producer := NewAvroCodec(registry)
+	if err := producer.Register(ctx, &orderV1Record{}, TopicNameStrategy("orders"), orderV1); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	// the consumer was upgraded to v2 before the producer
+	consumer := NewAvroCodec(registry)
+	if err := consumer.Register(ctx, &orderV2Record{}, TopicNameStrategy("orders"), orderV2); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	data, err := broker.Marshal(producer, &orderV1Record{ID: "o-1", Amount: 9.5})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	// the brokers decode into the message body holding the pointer created by the binder
+	var body any = &orderV2Record{}
+	if err = broker.Unmarshal(consumer, data, &body); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	got := body.(*orderV2Record)
+	if got.ID != "o-1" || got.Amount != 9.5 || got.Currency != "EUR" {
+		t.Fatalf("unexpected order: %+v", got)
+	}
+
+	if _, err = producer.Marshal(&orderV2Record{}); err == nil {
+		t.Fatal("expected an error for a type without schema")
+	}
+}
+
+func TestAvroCodec_NoAutoRegister(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryRegistry()
+
+	c := NewAvroCodec(registry, WithAutoRegister(false))
+	if err := c.Register(ctx, orderV1Record{}, "orders-value", orderV1); !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("expected ErrSchemaNotFound, got %v", err)
+	}
+
+	if _, err := registry.Register(ctx, "orders-value", Avro, orderV1); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := c.Register(ctx, orderV1Record{}, "orders-value", orderV1); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+}
+
+func TestJSONSchemaCodec_Validation(t *testing.T) {
+	const schema = `{
+  "type": "object",
+  "properties": {
+    "id": {"type": "string", "minLength": 1},
+    "amount": {"type": "number", "minimum": 0}
+  },
+  "required": ["id", "amount"]
+}`
+
+	ctx := context.Background()
+	c := NewJSONSchemaCodec(NewMemoryRegistry())
+	if err := c.Register(ctx, orderV1Record{}, "orders-value", schema); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	data, err := c.Marshal(&orderV1Record{ID: "o-1", Amount: 3})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var got orderV1Record
+	if err = c.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.ID != "o-1" || got.Amount != 3 {
+		t.Fatalf("unexpected order: %+v", got)
+	}
+
+	if _, err = c.Marshal(&orderV1Record{ID: "o-2", Amount: -1}); err == nil {
+		t.Fatal("expected a validation error")
+	}
+
+	id, _, _ := DecodeWire(data)
+	if err = c.Unmarshal(EncodeWire(id, []byte(`{"id":""}`)), &got); err == nil {
+		t.Fatal("expected a validation error for an invalid payload")
+	}
+}
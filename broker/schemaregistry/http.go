@@ -0,0 +1,240 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	contentType        = "application/vnd.schemaregistry.v1+json"
+	defaultHTTPTimeout = 10 * time.Second
+)
+
+// Error is an error response of the schema registry REST API.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("schema registry error %d: %s", e.Code, e.Message)
+}
+
+// Unwrap maps the error codes of the registry to ErrSchemaNotFound, ErrIncompatibleSchema and ErrInvalidSchema.
+func (e *Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrSchemaNotFound
+	case e.StatusCode == http.StatusConflict:
+		return ErrIncompatibleSchema
+	case e.Code == 42201:
+		return ErrInvalidSchema
+	}
+	return nil
+}
+
+// HTTPOption configures an HTTPRegistry.
+type HTTPOption func(*HTTPRegistry)
+
+// WithHTTPClient sets the HTTP client used to call the registry.
+func WithHTTPClient(client *http.Client) HTTPOption {
+	return func(r *HTTPRegistry) {
+		if client != nil {
+			r.client = client
+		}
+	}
+}
+
+// WithBasicAuth authenticates the requests with HTTP basic authentication.
+func WithBasicAuth(username, password string) HTTPOption {
+	return func(r *HTTPRegistry) {
+		r.username = username
+		r.password = password
+	}
+}
+
+// HTTPRegistry is a SchemaRegistry client of the Confluent Schema Registry REST API,
+// also served by Redpanda, Karapace and Apicurio (in its Confluent compatible mode).
+// Schemas fetched by ID never change and are cached.
+type HTTPRegistry struct {
+	baseURL  string
+	client   *http.Client
+	username string
+	password string
+
+	mu  sync.RWMutex
+	ids map[int]*Schema
+}
+
+var _ SchemaRegistry = (*HTTPRegistry)(nil)
+
+// NewHTTPRegistry creates a client of the schema registry at baseURL, e.g. "http://localhost:8081".
+func NewHTTPRegistry(baseURL string, opts ...HTTPOption) *HTTPRegistry {
+	r := &HTTPRegistry{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: defaultHTTPTimeout},
+		ids:     make(map[int]*Schema),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+type schemaRequest struct {
+	Schema     string     `json:"schema"`
+	SchemaType SchemaType `json:"schemaType,omitempty"`
+}
+
+type schemaResponse struct {
+	Subject    string     `json:"subject"`
+	ID         int        `json:"id"`
+	Version    int        `json:"version"`
+	Schema     string     `json:"schema"`
+	SchemaType SchemaType `json:"schemaType"`
+}
+
+func (s *schemaResponse) toSchema() *Schema {
+	// the registry omits the type of Avro schemas
+	schemaType := s.SchemaType
+	if schemaType == "" {
+		schemaType = Avro
+	}
+	return &Schema{
+		ID:      s.ID,
+		Subject: s.Subject,
+		Version: s.Version,
+		Type:    schemaType,
+		Schema:  s.Schema,
+	}
+}
+
+// Register implements SchemaRegistry.
+func (r *HTTPRegistry) Register(ctx context.Context, subject string, schemaType SchemaType, schema string) (int, error) {
+	var resp schemaResponse
+	if err := r.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", newSchemaRequest(schemaType, schema), &resp); err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// Lookup implements SchemaRegistry.
+func (r *HTTPRegistry) Lookup(ctx context.Context, subject string, schemaType SchemaType, schema string) (int, error) {
+	var resp schemaResponse
+	if err := r.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject), newSchemaRequest(schemaType, schema), &resp); err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// GetByID implements SchemaRegistry.
+func (r *HTTPRegistry) GetByID(ctx context.Context, id int) (*Schema, error) {
+	r.mu.RLock()
+	s, ok := r.ids[id]
+	r.mu.RUnlock()
+	if ok {
+		cp := *s
+		return &cp, nil
+	}
+
+	var resp schemaResponse
+	if err := r.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	resp.ID = id
+	s = resp.toSchema()
+
+	r.mu.Lock()
+	r.ids[id] = s
+	r.mu.Unlock()
+
+	cp := *s
+	return &cp, nil
+}
+
+// GetLatest implements SchemaRegistry.
+func (r *HTTPRegistry) GetLatest(ctx context.Context, subject string) (*Schema, error) {
+	var resp schemaResponse
+	if err := r.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.toSchema(), nil
+}
+
+// CheckCompatibility implements SchemaRegistry.
+func (r *HTTPRegistry) CheckCompatibility(ctx context.Context, subject string, schemaType SchemaType, schema string) (bool, error) {
+	var resp struct {
+		IsCompatible bool `json:"is_compatible"`
+	}
+	err := r.do(ctx, http.MethodPost, "/compatibility/subjects/"+url.PathEscape(subject)+"/versions/latest", newSchemaRequest(schemaType, schema), &resp)
+	if err != nil {
+		// a subject without versions accepts any schema
+		if errors.Is(err, ErrSchemaNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	return resp.IsCompatible, nil
+}
+
+func newSchemaRequest(schemaType SchemaType, schema string) *schemaRequest {
+	req := &schemaRequest{Schema: schema}
+	if schemaType != Avro {
+		req.SchemaType = schemaType
+	}
+	return req
+}
+
+func (r *HTTPRegistry) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		regErr := &Error{StatusCode: resp.StatusCode}
+		if err = json.NewDecoder(resp.Body).Decode(regErr); err != nil || regErr.Message == "" {
+			regErr.Code = resp.StatusCode
+			regErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return regErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
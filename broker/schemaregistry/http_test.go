@@ -0,0 +1,133 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newFakeRegistry serves the REST API of the schema registry from a MemoryRegistry.
+func newFakeRegistry(t *testing.T, fetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	mem := NewMemoryRegistry()
+
+	writeError := func(w http.ResponseWriter, status, code int, err error) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error_code": code, "message": err.Error()})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, pass, ok := req.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			writeError(w, http.StatusUnauthorized, 401, errors.New("unauthorized"))
+			return
+		}
+
+		var in schemaRequest
+		if req.Method == http.MethodPost {
+			_ = json.NewDecoder(req.Body).Decode(&in)
+			if in.SchemaType == "" {
+				in.SchemaType = Avro
+			}
+		}
+
+		ctx := req.Context()
+		path := req.URL.Path
+		switch {
+		case req.Method == http.MethodGet && strings.HasPrefix(path, "/schemas/ids/"):
+			fetches.Add(1)
+			var id int
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(path, "/schemas/ids/")), &id)
+			s, err := mem.GetByID(ctx, id)
+			if err != nil {
+				writeError(w, http.StatusNotFound, 40403, err)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"schema": s.Schema})
+
+		case req.Method == http.MethodPost && strings.HasPrefix(path, "/compatibility/subjects/"):
+			subject := strings.TrimSuffix(strings.TrimPrefix(path, "/compatibility/subjects/"), "/versions/latest")
+			if _, err := mem.GetLatest(ctx, subject); err != nil {
+				writeError(w, http.StatusNotFound, 40401, err)
+				return
+			}
+			ok, _ := mem.CheckCompatibility(ctx, subject, in.SchemaType, in.Schema)
+			_ = json.NewEncoder(w).Encode(map[string]any{"is_compatible": ok})
+
+		case req.Method == http.MethodPost && strings.HasSuffix(path, "/versions"):
+			subject := strings.TrimSuffix(strings.TrimPrefix(path, "/subjects/"), "/versions")
+			id, err := mem.Register(ctx, subject, in.SchemaType, in.Schema)
+			if err != nil {
+				writeError(w, http.StatusConflict, 409, err)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+
+		case req.Method == http.MethodGet && strings.HasSuffix(path, "/versions/latest"):
+			subject := strings.TrimSuffix(strings.TrimPrefix(path, "/subjects/"), "/versions/latest")
+			s, err := mem.GetLatest(ctx, subject)
+			if err != nil {
+				writeError(w, http.StatusNotFound, 40401, err)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"subject": s.Subject, "id": s.ID, "version": s.Version, "schema": s.Schema})
+
+		default:
+			writeError(w, http.StatusNotFound, 404, errors.New("not found"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	var fetches atomic.Int32
+	srv := newFakeRegistry(t, &fetches)
+	ctx := context.Background()
+
+	if _, err := NewHTTPRegistry(srv.URL).GetLatest(ctx, "orders-value"); err == nil {
+		t.Fatal("expected an authentication error")
+	}
+
+	r := NewHTTPRegistry(srv.URL+"/", WithBasicAuth("user", "secret"))
+
+	if ok, err := r.CheckCompatibility(ctx, "orders-value", Avro, orderV1); err != nil || !ok {
+		t.Fatalf("a new subject accepts any schema: %v, %v", ok, err)
+	}
+
+	id, err := r.Register(ctx, "orders-value", Avro, orderV1)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	latest, err := r.GetLatest(ctx, "orders-value")
+	if err != nil || latest.ID != id || latest.Type != Avro || latest.Version != 1 {
+		t.Fatalf("unexpected latest version: %+v, %v", latest, err)
+	}
+
+	if ok, err := r.CheckCompatibility(ctx, "orders-value", Avro, orderV3); err != nil || ok {
+		t.Fatalf("v3 should be incompatible: %v, %v", ok, err)
+	}
+	if _, err = r.Register(ctx, "orders-value", Avro, orderV3); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("expected ErrIncompatibleSchema, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		s, err := r.GetByID(ctx, id)
+		if err != nil || s.Schema != orderV1 || s.Type != Avro {
+			t.Fatalf("unexpected schema: %+v, %v", s, err)
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Fatalf("schemas by id should be cached, fetched %d times", fetches.Load())
+	}
+
+	if _, err = r.GetByID(ctx, 42); !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("expected ErrSchemaNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// JSONSchemaCodecName is the name of JSONSchemaCodec, to pass to broker.WithCodec once registered with encoding.RegisterCodec.
+const JSONSchemaCodecName = "jsonschema"
+
+// JSONSchemaCodec encodes messages in JSON validated against a JSON Schema, framed in the Confluent wire format.
+//
+// Marshal validates a value against the schema registered for its type, Unmarshal validates
+// every payload against its writer schema, fetched from the registry.
+type JSONSchemaCodec struct {
+	*codec
+}
+
+var _ encoding.Codec = (*JSONSchemaCodec)(nil)
+
+// NewJSONSchemaCodec creates a JSONSchemaCodec backed by registry.
+func NewJSONSchemaCodec(registry SchemaRegistry, opts ...Option) *JSONSchemaCodec {
+	return &JSONSchemaCodec{
+		codec: newCodec(registry, JSONSchema, compileJSONSchema, opts...),
+	}
+}
+
+// Name implements encoding.Codec.
+func (c *JSONSchemaCodec) Name() string {
+	return JSONSchemaCodecName
+}
+
+// Register sets schema as the writer schema of the type of v (a value or a pointer),
+// registering it under subject, see TopicNameStrategy.
+func (c *JSONSchemaCodec) Register(ctx context.Context, v any, subject, schema string) error {
+	return c.register(ctx, v, subject, schema)
+}
+
+// Marshal implements encoding.Codec.
+func (c *JSONSchemaCodec) Marshal(v any) ([]byte, error) {
+	b := c.binding(v)
+	if b == nil {
+		return nil, fmt.Errorf("no json schema registered for %T", v)
+	}
+
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateJSON(b.schema.(*jsonschema.Schema), payload); err != nil {
+		return nil, err
+	}
+	return EncodeWire(b.id, payload), nil
+}
+
+// Unmarshal implements encoding.Codec.
+func (c *JSONSchemaCodec) Unmarshal(data []byte, v any) error {
+	id, payload, err := DecodeWire(data)
+	if err != nil {
+		return err
+	}
+
+	writer, err := c.writer(id)
+	if err != nil {
+		return err
+	}
+	if err = validateJSON(writer.(*jsonschema.Schema), payload); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(payload, target(v))
+}
+
+func compileJSONSchema(schema string) (any, error) {
+	const location = "schema.json"
+
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err.Error())
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err = compiler.AddResource(location, doc); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err.Error())
+	}
+
+	compiled, err := compiler.Compile(location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err.Error())
+	}
+	return compiled, nil
+}
+
+func validateJSON(schema *jsonschema.Schema, payload []byte) error {
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	return schema.Validate(inst)
+}
@@ -0,0 +1,201 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+)
+
+// MemoryRegistry is an in-process SchemaRegistry, for tests and single-process deployments.
+//
+// Like the Confluent registry with its default BACKWARD compatibility, it only accepts a new
+// Avro version when it can read the data written with the latest one. JSON and Protobuf
+// schemas are checked for syntax only.
+type MemoryRegistry struct {
+	sync.RWMutex
+
+	nextID   int
+	ids      map[int]*Schema
+	subjects map[string][]*Schema
+
+	compat *avro.SchemaCompatibility
+}
+
+var _ SchemaRegistry = (*MemoryRegistry)(nil)
+
+// NewMemoryRegistry creates an empty MemoryRegistry.
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		nextID:   1,
+		ids:      make(map[int]*Schema),
+		subjects: make(map[string][]*Schema),
+		compat:   avro.NewSchemaCompatibility(),
+	}
+}
+
+// Register implements SchemaRegistry.
+func (r *MemoryRegistry) Register(_ context.Context, subject string, schemaType SchemaType, schema string) (int, error) {
+	if err := validate(schemaType, schema); err != nil {
+		return 0, err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if s := r.lookup(subject, schemaType, schema); s != nil {
+		return s.ID, nil
+	}
+
+	versions := r.subjects[subject]
+	if len(versions) > 0 {
+		if err := r.compatible(versions[len(versions)-1], schemaType, schema); err != nil {
+			return 0, err
+		}
+	}
+
+	// the same schema registered under another subject keeps its ID
+	id := 0
+	for _, s := range r.ids {
+		if s.Type == schemaType && s.Schema == schema {
+			id = s.ID
+			break
+		}
+	}
+	if id == 0 {
+		id = r.nextID
+		r.nextID++
+	}
+
+	s := &Schema{
+		ID:      id,
+		Subject: subject,
+		Version: len(versions) + 1,
+		Type:    schemaType,
+		Schema:  schema,
+	}
+	r.subjects[subject] = append(versions, s)
+	if _, ok := r.ids[id]; !ok {
+		r.ids[id] = s
+	}
+
+	return id, nil
+}
+
+// Lookup implements SchemaRegistry.
+func (r *MemoryRegistry) Lookup(_ context.Context, subject string, schemaType SchemaType, schema string) (int, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	if s := r.lookup(subject, schemaType, schema); s != nil {
+		return s.ID, nil
+	}
+	return 0, fmt.Errorf("%w: subject [%s]", ErrSchemaNotFound, subject)
+}
+
+// GetByID implements SchemaRegistry.
+func (r *MemoryRegistry) GetByID(_ context.Context, id int) (*Schema, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	s, ok := r.ids[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id [%d]", ErrSchemaNotFound, id)
+	}
+	cp := *s
+	return &cp, nil
+}
+
+// GetLatest implements SchemaRegistry.
+func (r *MemoryRegistry) GetLatest(_ context.Context, subject string) (*Schema, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	versions := r.subjects[subject]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: subject [%s]", ErrSchemaNotFound, subject)
+	}
+	cp := *versions[len(versions)-1]
+	return &cp, nil
+}
+
+// CheckCompatibility implements SchemaRegistry.
+func (r *MemoryRegistry) CheckCompatibility(_ context.Context, subject string, schemaType SchemaType, schema string) (bool, error) {
+	if err := validate(schemaType, schema); err != nil {
+		return false, err
+	}
+
+	r.RLock()
+	defer r.RUnlock()
+
+	versions := r.subjects[subject]
+	if len(versions) == 0 {
+		return true, nil
+	}
+	return r.compatible(versions[len(versions)-1], schemaType, schema) == nil, nil
+}
+
+func (r *MemoryRegistry) lookup(subject string, schemaType SchemaType, schema string) *Schema {
+	for _, s := range r.subjects[subject] {
+		if s.Type == schemaType && s.Schema == schema {
+			return s
+		}
+	}
+	return nil
+}
+
+// compatible checks that schema can read the data written with latest.
+func (r *MemoryRegistry) compatible(latest *Schema, schemaType SchemaType, schema string) error {
+	if latest.Type != schemaType {
+		return fmt.Errorf("%w: subject [%s] holds %s schemas, got %s", ErrIncompatibleSchema, latest.Subject, latest.Type, schemaType)
+	}
+	if schemaType != Avro {
+		return nil
+	}
+
+	writer, err := parseAvro(latest.Schema)
+	if err != nil {
+		return err
+	}
+	reader, err := parseAvro(schema)
+	if err != nil {
+		return err
+	}
+
+	if err = r.compat.Compatible(reader, writer); err != nil {
+		return fmt.Errorf("%w: subject [%s]: %s", ErrIncompatibleSchema, latest.Subject, err.Error())
+	}
+	return nil
+}
+
+func validate(schemaType SchemaType, schema string) error {
+	switch schemaType {
+	case Avro:
+		if _, err := parseAvro(schema); err != nil {
+			return err
+		}
+	case JSONSchema:
+		if !json.Valid([]byte(schema)) {
+			return fmt.Errorf("%w: not a JSON document", ErrInvalidSchema)
+		}
+	case Protobuf:
+		if schema == "" {
+			return fmt.Errorf("%w: empty schema", ErrInvalidSchema)
+		}
+	default:
+		return fmt.Errorf("%w: unknown schema type %q", ErrInvalidSchema, schemaType)
+	}
+	return nil
+}
+
+// parseAvro parses an Avro schema with a cache of its own: the versions of a subject
+// define the same named types differently and must not collide in the global cache.
+func parseAvro(schema string) (avro.Schema, error) {
+	parsed, err := avro.ParseWithCache(schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err.Error())
+	}
+	return parsed, nil
+}
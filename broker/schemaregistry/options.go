@@ -0,0 +1,42 @@
+package schemaregistry
+
+import "time"
+
+const defaultTimeout = 10 * time.Second
+
+type options struct {
+	autoRegister bool
+	timeout      time.Duration
+}
+
+// Option configures the codecs of this package.
+type Option func(*options)
+
+func newOptions(opts ...Option) options {
+	o := options{
+		autoRegister: true,
+		timeout:      defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithAutoRegister sets whether Register registers the schemas in the registry, true by default.
+// When disabled, the schemas must have been registered beforehand (e.g. by a CI pipeline)
+// and Register only looks their ID up.
+func WithAutoRegister(enable bool) Option {
+	return func(o *options) {
+		o.autoRegister = enable
+	}
+}
+
+// WithTimeout sets the timeout of the registry calls made while encoding and decoding, 10 seconds by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package schemaregistry
+
+import (
+	"context"
+	"errors"
+)
+
+// SchemaType is the format of a schema, as named by the schema registry.
+type SchemaType string
+
+const (
+	Avro       SchemaType = "AVRO"
+	JSONSchema SchemaType = "JSON"
+	Protobuf   SchemaType = "PROTOBUF"
+)
+
+var (
+	// ErrSchemaNotFound is returned when a subject, version or schema ID is unknown to the registry.
+	ErrSchemaNotFound = errors.New("schema not found")
+	// ErrIncompatibleSchema is returned when a schema breaks the compatibility rules of its subject.
+	ErrIncompatibleSchema = errors.New("incompatible schema")
+	// ErrInvalidSchema is returned when a schema cannot be parsed.
+	ErrInvalidSchema = errors.New("invalid schema")
+)
+
+// Schema is a schema registered under a subject.
+type Schema struct {
+	ID      int
+	Subject string
+	Version int
+	Type    SchemaType
+	Schema  string
+}
+
+// SchemaRegistry stores the versions of the schemas of every subject and assigns them a global ID,
+// which the producers write in front of every payload (see EncodeWire).
+type SchemaRegistry interface {
+	// Register registers schema as the next version of subject and returns its ID.
+	// Registering a schema already present returns its ID; a schema incompatible with
+	// the latest version of subject fails with ErrIncompatibleSchema.
+	Register(ctx context.Context, subject string, schemaType SchemaType, schema string) (int, error)
+
+	// Lookup returns the ID of schema when it is registered under subject, ErrSchemaNotFound otherwise.
+	Lookup(ctx context.Context, subject string, schemaType SchemaType, schema string) (int, error)
+
+	// GetByID returns the schema of the given ID.
+	GetByID(ctx context.Context, id int) (*Schema, error)
+
+	// GetLatest returns the latest version of subject.
+	GetLatest(ctx context.Context, subject string) (*Schema, error)
+
+	// CheckCompatibility reports whether schema can be registered as the next version of subject.
+	CheckCompatibility(ctx context.Context, subject string, schemaType SchemaType, schema string) (bool, error)
+}
+
+// TopicNameStrategy returns the subject of the values of topic, "<topic>-value".
+func TopicNameStrategy(topic string) string {
+	return topic + "-value"
+}
+
+// TopicKeyNameStrategy returns the subject of the keys of topic, "<topic>-key".
+func TopicKeyNameStrategy(topic string) string {
+	return topic + "-key"
+}
@@ -0,0 +1,33 @@
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// magicByte starts every payload framed in the Confluent wire format.
+const magicByte byte = 0
+
+// wireHeaderSize is the size of the magic byte and the big-endian schema ID.
+const wireHeaderSize = 5
+
+// ErrInvalidWireFormat is returned by DecodeWire when data is not framed in the wire format.
+var ErrInvalidWireFormat = errors.New("payload is not in the schema registry wire format")
+
+// EncodeWire frames payload in the Confluent wire format: a zero magic byte,
+// the schema ID as a 4-byte big-endian integer, then the payload.
+func EncodeWire(id int, payload []byte) []byte {
+	buf := make([]byte, wireHeaderSize+len(payload))
+	buf[0] = magicByte
+	binary.BigEndian.PutUint32(buf[1:wireHeaderSize], uint32(id))
+	copy(buf[wireHeaderSize:], payload)
+	return buf
+}
+
+// DecodeWire returns the schema ID and the payload of data framed by EncodeWire.
+func DecodeWire(data []byte) (int, []byte, error) {
+	if len(data) < wireHeaderSize || data[0] != magicByte {
+		return 0, nil, ErrInvalidWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:wireHeaderSize])), data[wireHeaderSize:], nil
+}
@@ -3,6 +3,7 @@ package sqs
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
@@ -10,6 +11,14 @@ import (
 	"github.com/tx7do/kratos-transport/broker"
 )
 
+// maxVisibilityTimeout is the longest visibility timeout SQS accepts.
+const maxVisibilityTimeout = 12 * time.Hour
+
+var (
+	_ broker.Nacker           = (*publication)(nil)
+	_ broker.DeadlineExtender = (*publication)(nil)
+)
+
 type publication struct {
 	topic  string
 	msg    *broker.Message
@@ -19,6 +28,7 @@ type publication struct {
 	client   *sqs.Client
 	queueUrl string
 	acked    bool
+	nacked   bool
 }
 
 func (p *publication) Topic() string {
@@ -33,8 +43,16 @@ func (p *publication) RawMessage() any {
 	return p.sqsMsg
 }
 
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.sqsMsg == nil || p.sqsMsg.Body == nil {
+		return nil
+	}
+	return []byte(*p.sqsMsg.Body)
+}
+
 func (p *publication) Ack() error {
-	if p.acked {
+	if p.acked || p.nacked {
 		return nil
 	}
 	if p.client == nil {
@@ -59,6 +77,54 @@ func (p *publication) Ack() error {
 	return nil
 }
 
+// Nack makes the message visible again at once. SQS cannot drop a message without deleting
+// it: without requeue, it returns broker.ErrNotSupported; the redrive policy of the queue
+// moves messages received too often to its dead-letter queue.
+func (p *publication) Nack(requeue bool) error {
+	if !requeue {
+		return fmt.Errorf("SQS cannot reject a message: %w", broker.ErrNotSupported)
+	}
+	return p.NackWithDelay(0)
+}
+
+// NackWithDelay makes the message visible again after delay, at most 12 hours.
+func (p *publication) NackWithDelay(delay time.Duration) error {
+	if p.acked || p.nacked {
+		return fmt.Errorf("the message is already settled")
+	}
+	if err := p.changeVisibility(delay); err != nil {
+		return err
+	}
+	p.nacked = true
+	return nil
+}
+
+// ExtendDeadline keeps the message invisible to the other consumers for d from now, at most 12 hours.
+func (p *publication) ExtendDeadline(d time.Duration) error {
+	return p.changeVisibility(d)
+}
+
+func (p *publication) changeVisibility(d time.Duration) error {
+	if p.client == nil {
+		return fmt.Errorf("SQS client is nil")
+	}
+	if p.sqsMsg == nil || p.sqsMsg.ReceiptHandle == nil {
+		return fmt.Errorf("SQS message or receipt handle is nil")
+	}
+
+	d = min(max(d, 0), maxVisibilityTimeout)
+
+	_, err := p.client.ChangeMessageVisibility(context.Background(), &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          &p.queueUrl,
+		ReceiptHandle:     p.sqsMsg.ReceiptHandle,
+		VisibilityTimeout: int32(d / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("change message visibility failed: %w", err)
+	}
+	return nil
+}
+
 func (p *publication) Error() error {
 	return p.err
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -17,6 +18,16 @@ import (
 const (
 	defaultAddr   = "http://127.0.0.1:9324"
 	defaultRegion = "us-east-1"
+
+	defaultInboxPrefix = "inbox-"
+
+	// maxBatchEntries is the maximum number of messages of a SendMessageBatch request
+	maxBatchEntries = 10
+)
+
+var (
+	_ broker.BatchPublisher  = (*sqsBroker)(nil)
+	_ broker.BatchSubscriber = (*sqsBroker)(nil)
 )
 
 type sqsBroker struct {
@@ -32,6 +43,8 @@ type sqsBroker struct {
 	running bool
 
 	subscribers *broker.SubscriberSyncMap
+
+	requester *broker.Requester
 }
 
 func NewBroker(opts ...broker.Option) broker.Broker {
@@ -41,6 +54,8 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		options:     options,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	// queue names only allow alphanumeric characters, hyphens and underscores
+	b.requester = broker.NewRequester(b, broker.WithInboxPrefix(defaultInboxPrefix))
 
 	return b
 }
@@ -128,6 +143,8 @@ func (b *sqsBroker) Connect() error {
 }
 
 func (b *sqsBroker) Disconnect() error {
+	_ = b.requester.Close()
+
 	b.Lock()
 	defer b.Unlock()
 
@@ -145,8 +162,10 @@ func (b *sqsBroker) Disconnect() error {
 	return nil
 }
 
+// Request sends a request and waits for the reply. SQS queues are not created on demand,
+// so the reply queue must exist: pass its name with broker.WithReplyTopic.
 func (b *sqsBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *sqsBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -164,6 +183,7 @@ func (b *sqsBroker) internalPublish(ctx context.Context, topic string, msg *brok
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -207,18 +227,7 @@ func (b *sqsBroker) publish(ctx context.Context, topic string, msg *broker.Messa
 	}
 
 	// Set message attributes from headers
-	if msg.Headers != nil {
-		attrs := make(map[string]types.MessageAttributeValue)
-		for k, v := range msg.Headers {
-			attrs[k] = types.MessageAttributeValue{
-				DataType:    aws.String("String"),
-				StringValue: aws.String(v),
-			}
-		}
-		if len(attrs) > 0 {
-			input.MessageAttributes = attrs
-		}
-	}
+	input.MessageAttributes = messageAttributes(msg.Headers)
 
 	_, err := b.client.SendMessage(ctx, input)
 	if err != nil {
@@ -228,6 +237,112 @@ func (b *sqsBroker) publish(ctx context.Context, topic string, msg *broker.Messa
 	return nil
 }
 
+// PublishBatch publishes msgs to topic with SendMessageBatch, in requests of up to 10 messages.
+// WithDelaySeconds and WithMessageGroupId apply to every message; on FIFO queues the message ID
+// is used as deduplication ID instead of WithMessageDeduplicationId.
+func (b *sqsBroker) PublishBatch(ctx context.Context, topic string, msgs []*broker.Message, opts ...broker.PublishOption) ([]broker.PublishResult, error) {
+	if b.client == nil {
+		return nil, errors.New("SQS client is nil")
+	}
+
+	options := broker.PublishOptions{
+		Context: ctx,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	queueUrl := b.resolveQueueUrl(options.Context, topic)
+	if queueUrl == "" {
+		return nil, fmt.Errorf("queue url not resolved for topic: %s", topic)
+	}
+
+	var (
+		delaySeconds int32
+		groupId      string
+	)
+	if options.Context != nil {
+		if v, ok := options.Context.Value(delaySecondsKey{}).(int32); ok && v > 0 {
+			delaySeconds = v
+		}
+		if v, ok := options.Context.Value(messageGroupIdKey{}).(string); ok && v != "" {
+			groupId = v
+		}
+	}
+
+	results := broker.NewPublishResults(msgs)
+
+	var entries []types.SendMessageBatchRequestEntry
+	for i, msg := range msgs {
+		if msg == nil {
+			results[i].Err = errors.New("message is nil")
+			continue
+		}
+
+		buf, err := broker.Marshal(b.options.Codec, msg.Body)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		entry := types.SendMessageBatchRequestEntry{
+			// entry IDs are the indexes of the messages, to map the response back
+			Id:                aws.String(strconv.Itoa(i)),
+			MessageBody:       aws.String(string(buf)),
+			DelaySeconds:      delaySeconds,
+			MessageAttributes: messageAttributes(msg.Headers),
+		}
+		if groupId != "" {
+			entry.MessageGroupId = aws.String(groupId)
+			if msg.ID != "" {
+				entry.MessageDeduplicationId = aws.String(msg.ID)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	for start := 0; start < len(entries); start += maxBatchEntries {
+		chunk := entries[start:min(start+maxBatchEntries, len(entries))]
+
+		out, err := b.client.SendMessageBatch(options.Context, &sqs.SendMessageBatchInput{
+			QueueUrl: &queueUrl,
+			Entries:  chunk,
+		})
+		if err != nil {
+			for _, entry := range chunk {
+				i, _ := strconv.Atoi(*entry.Id)
+				results[i].Err = fmt.Errorf("send message batch failed: %w", err)
+			}
+			continue
+		}
+
+		for _, failed := range out.Failed {
+			i, convErr := strconv.Atoi(aws.ToString(failed.Id))
+			if convErr != nil || i < 0 || i >= len(results) {
+				continue
+			}
+			results[i].Err = fmt.Errorf("send message failed: %s: %s", aws.ToString(failed.Code), aws.ToString(failed.Message))
+		}
+	}
+
+	return results, broker.JoinPublishResults(results)
+}
+
+func messageAttributes(headers broker.Headers) map[string]types.MessageAttributeValue {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	attrs := make(map[string]types.MessageAttributeValue, len(headers))
+	for k, v := range headers {
+		attrs[k] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	return attrs
+}
+
 func (b *sqsBroker) Subscribe(topic string, handler broker.Handler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
 	if b.client == nil {
 		return nil, errors.New("SQS client is nil, call Connect() first")
@@ -241,49 +356,84 @@ func (b *sqsBroker) Subscribe(topic string, handler broker.Handler, binder broke
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
+	sub, ro, err := b.newSubscriber(topic, options)
+	if err != nil {
+		return nil, err
+	}
+
+	go sub.recv(handler, binder, ro)
+
+	b.subscribers.Add(topic, sub)
+
+	return sub, nil
+}
+
+// SubscribeBatch delivers the messages of each ReceiveMessage call (up to WithMaxMessages)
+// to handler as one batch. With auto-ack, a successful batch is deleted with DeleteMessageBatch.
+func (b *sqsBroker) SubscribeBatch(topic string, handler broker.BatchHandler, binder broker.Binder, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
+	if b.client == nil {
+		return nil, errors.New("SQS client is nil, call Connect() first")
+	}
+
+	options := broker.SubscribeOptions{
+		Context: context.Background(),
+		AutoAck: true,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	sub, ro, err := b.newSubscriber(topic, options)
+	if err != nil {
+		return nil, err
+	}
+	sub.batchHandler = broker.WrapSubscribeBatchHandler(handler, options)
+
+	go sub.recv(nil, binder, ro)
+
+	b.subscribers.Add(topic, sub)
+
+	return sub, nil
+}
+
+func (b *sqsBroker) newSubscriber(topic string, options broker.SubscribeOptions) (*subscriber, recvOpts, error) {
 	queueUrl := b.resolveQueueUrl(options.Context, topic)
 	if queueUrl == "" {
-		return nil, fmt.Errorf("queue url not resolved for topic: %s", topic)
+		return nil, recvOpts{}, fmt.Errorf("queue url not resolved for topic: %s", topic)
 	}
 
 	// Extract subscribe options
-	visibilityTimeout := int32(DefaultVisibilityTimeout)
-	waitTimeSeconds := int32(DefaultWaitTimeSeconds)
-	maxMessages := int32(DefaultMaxMessages)
+	ro := recvOpts{
+		visibilityTimeout: int32(DefaultVisibilityTimeout),
+		waitTimeSeconds:   int32(DefaultWaitTimeSeconds),
+		maxMessages:       int32(DefaultMaxMessages),
+	}
 
 	if options.Context != nil {
 		if v, ok := options.Context.Value(visibilityTimeoutKey{}).(int32); ok && v > 0 {
-			visibilityTimeout = v
+			ro.visibilityTimeout = v
 		}
 		if v, ok := options.Context.Value(waitTimeSecondsKey{}).(int32); ok && v > 0 {
-			waitTimeSeconds = v
+			ro.waitTimeSeconds = v
 		}
 		if v, ok := options.Context.Value(maxMessagesKey{}).(int32); ok && v > 0 {
-			maxMessages = v
+			ro.maxMessages = v
 		}
 	}
 
-	sub := &subscriber{
+	return &subscriber{
 		topic:    topic,
 		queueUrl: queueUrl,
 		options:  options,
 		b:        b,
 		client:   b.client,
-	}
-
-	go sub.recv(handler, binder, recvOpts{
-		visibilityTimeout: visibilityTimeout,
-		waitTimeSeconds:   waitTimeSeconds,
-		maxMessages:       maxMessages,
-	})
-
-	b.subscribers.Add(topic, sub)
-
-	return sub, nil
+		workers:  broker.NewSubscribeWorkerPool(options),
+	}, ro, nil
 }
 
 // resolveQueueUrl resolves the queue URL for a given topic.
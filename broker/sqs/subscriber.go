@@ -2,8 +2,11 @@ package sqs
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"sync"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 
@@ -17,10 +20,12 @@ type subscriber struct {
 	queueUrl string
 	options  broker.SubscribeOptions
 
-	b      *sqsBroker
-	client *sqs.Client
-	cancel context.CancelFunc
-	closed bool
+	b            *sqsBroker
+	client       *sqs.Client
+	workers      *broker.WorkerPool
+	batchHandler broker.BatchHandler
+	cancel       context.CancelFunc
+	closed       bool
 }
 
 func (s *subscriber) Options() broker.SubscribeOptions {
@@ -36,6 +41,9 @@ func (s *subscriber) Topic() string {
 }
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
+	// the handlers delete the messages with the receive context, they finish before it is canceled
+	s.workers.Drain()
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -101,13 +109,20 @@ func (s *subscriber) recv(handler broker.Handler, binder broker.Binder, opts rec
 			continue
 		}
 
+		if s.batchHandler != nil {
+			s.processBatch(ctx, binder, result.Messages)
+			continue
+		}
+
 		for _, sqsMsg := range result.Messages {
-			s.processMessage(ctx, handler, binder, sqsMsg)
+			s.workers.Submit(func() {
+				s.processMessage(ctx, handler, binder, sqsMsg)
+			})
 		}
 	}
 }
 
-func (s *subscriber) processMessage(ctx context.Context, handler broker.Handler, binder broker.Binder, sqsMsg types.Message) {
+func (s *subscriber) newPublication(binder broker.Binder, sqsMsg types.Message) (*publication, error) {
 	var m broker.Message
 
 	// Extract headers from message attributes
@@ -127,35 +142,110 @@ func (s *subscriber) processMessage(ctx context.Context, handler broker.Handler,
 		if binder != nil {
 			m.Body = binder()
 			if err := broker.Unmarshal(s.b.options.Codec, body, &m.Body); err != nil {
-				LogErrorf("unmarshal message failed: %v", err)
-				return
+				return nil, err
 			}
 		} else {
 			m.Body = body
 		}
 	}
 
-	p := &publication{
+	return &publication{
 		topic:    s.topic,
 		msg:      &m,
 		sqsMsg:   &sqsMsg,
 		client:   s.client,
 		queueUrl: s.queueUrl,
+	}, nil
+}
+
+func (s *subscriber) processMessage(ctx context.Context, handler broker.Handler, binder broker.Binder, sqsMsg types.Message) {
+	p, err := s.newPublication(binder, sqsMsg)
+	if err != nil {
+		LogErrorf("unmarshal message failed: %v", err)
+		return
 	}
 
-	if err := handler(ctx, p); err != nil {
+	if err = handler(ctx, p); err != nil {
 		p.err = err
 		LogErrorf("handle message failed: %v", err)
 		return
 	}
 
 	if s.options.AutoAck {
-		if err := p.Ack(); err != nil {
+		if err = p.Ack(); err != nil {
 			LogErrorf("unable to ack msg: %v", err)
 		}
 	}
 }
 
+// processBatch hands the messages of one receive call to the batch handler and,
+// with auto-ack, deletes them with a single DeleteMessageBatch call.
+func (s *subscriber) processBatch(ctx context.Context, binder broker.Binder, sqsMsgs []types.Message) {
+	evts := make([]broker.Event, 0, len(sqsMsgs))
+	pubs := make([]*publication, 0, len(sqsMsgs))
+	for _, sqsMsg := range sqsMsgs {
+		p, err := s.newPublication(binder, sqsMsg)
+		if err != nil {
+			LogErrorf("unmarshal message failed: %v", err)
+			continue
+		}
+		evts = append(evts, p)
+		pubs = append(pubs, p)
+	}
+
+	if len(evts) == 0 {
+		return
+	}
+
+	if err := s.batchHandler(ctx, evts); err != nil {
+		LogErrorf("handle batch failed: %v", err)
+		return
+	}
+
+	if !s.options.AutoAck {
+		return
+	}
+
+	entries := make([]types.DeleteMessageBatchRequestEntry, 0, len(pubs))
+	for i, p := range pubs {
+		// messages settled by the handler with Ack or Nack are left as they are
+		if p.acked || p.nacked {
+			continue
+		}
+		entries = append(entries, types.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: p.sqsMsg.ReceiptHandle,
+		})
+	}
+
+	if len(entries) == 0 {
+		return
+	}
+
+	result, err := s.client.DeleteMessageBatch(context.Background(), &sqs.DeleteMessageBatchInput{
+		QueueUrl: &s.queueUrl,
+		Entries:  entries,
+	})
+	if err != nil {
+		LogErrorf("unable to ack batch: %v", err)
+		return
+	}
+
+	for _, p := range pubs {
+		if !p.nacked {
+			p.acked = true
+		}
+	}
+	for _, f := range result.Failed {
+		i, _ := strconv.Atoi(aws.ToString(f.Id))
+		if i >= 0 && i < len(pubs) {
+			pubs[i].acked = false
+			pubs[i].err = fmt.Errorf("delete message failed: %s", aws.ToString(f.Message))
+		}
+		LogErrorf("unable to ack msg [%s]: %s", aws.ToString(f.Id), aws.ToString(f.Message))
+	}
+}
+
 // recvOpts holds the SQS receive parameters.
 type recvOpts struct {
 	visibilityTimeout int32
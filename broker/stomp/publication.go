@@ -40,3 +40,11 @@ func (p *publication) Message() *broker.Message {
 func (p *publication) RawMessage() any {
 	return p.msg
 }
+
+// Payload returns the body of the message as it was received, see broker.Payloader.
+func (p *publication) Payload() []byte {
+	if p.msg == nil {
+		return nil
+	}
+	return p.msg.Body
+}
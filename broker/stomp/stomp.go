@@ -38,6 +38,8 @@ type stompBroker struct {
 
 	subscribers *broker.SubscriberSyncMap
 
+	requester *broker.Requester
+
 	producerTracer *tracing.Tracer
 	consumerTracer *tracing.Tracer
 }
@@ -49,6 +51,7 @@ func NewBroker(opts ...broker.Option) broker.Broker {
 		options:     options,
 		subscribers: broker.NewSubscriberSyncMap(),
 	}
+	b.requester = broker.NewRequester(b)
 
 	return b
 }
@@ -169,6 +172,8 @@ func (b *stompBroker) Connect() error {
 func (b *stompBroker) Disconnect() error {
 	var err error
 
+	_ = b.requester.Close()
+
 	if b.stompConn != nil {
 		err = b.stompConn.Disconnect()
 	}
@@ -179,7 +184,7 @@ func (b *stompBroker) Disconnect() error {
 }
 
 func (b *stompBroker) Request(ctx context.Context, topic string, msg *broker.Message, opts ...broker.RequestOption) (*broker.Message, error) {
-	return nil, errors.New("not implemented")
+	return b.requester.Request(ctx, topic, msg, opts...)
 }
 
 func (b *stompBroker) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
@@ -197,6 +202,7 @@ func (b *stompBroker) internalPublish(ctx context.Context, topic string, msg *br
 	if err != nil {
 		return err
 	}
+	broker.RecordPayloadSize(ctx, len(buf))
 
 	sendMsg := msg.Clone()
 	sendMsg.Body = buf
@@ -257,9 +263,10 @@ func (b *stompBroker) Subscribe(topic string, handler broker.Handler, binder bro
 		o(&options)
 	}
 
-	if len(b.options.SubscriberMiddlewares) > 0 {
+	if len(b.options.SubscriberMiddlewares) > 0 && !broker.IsReplySubscription(options) {
 		handler = broker.ChainSubscriberMiddleware(handler, b.options.SubscriberMiddlewares)
 	}
+	handler = broker.WrapSubscribeHandler(b, handler, options)
 
 	stompOpt := make([]func(*frameV3.Frame) error, 0, len(opts))
 
@@ -291,9 +298,14 @@ func (b *stompBroker) Subscribe(topic string, handler broker.Handler, binder bro
 		return nil, err
 	}
 
+	// hand messages to a worker pool so that SubscribeOptions.Concurrency is honoured
+	pool := broker.NewSubscribeWorkerPool(options)
+
 	go func() {
 		for msg := range sub.C {
-			go func(msg *stompV3.Message) {
+			pool.Submit(func() {
+				var err error
+
 				m := &broker.Message{
 					Headers: stompHeaderToMap(msg.Header),
 				}
@@ -315,7 +327,7 @@ func (b *stompBroker) Subscribe(topic string, handler broker.Handler, binder bro
 					m.Body = msg.Body
 				}
 
-				if err = handler(ctx, p); p.err != nil {
+				if err = handler(ctx, p); err != nil {
 					p.err = err
 					b.finishConsumerSpan(ctx, span, p.err)
 					return
@@ -327,7 +339,7 @@ func (b *stompBroker) Subscribe(topic string, handler broker.Handler, binder bro
 				}
 
 				b.finishConsumerSpan(ctx, span, err)
-			}(msg)
+			})
 		}
 	}()
 
@@ -336,6 +348,7 @@ func (b *stompBroker) Subscribe(topic string, handler broker.Handler, binder bro
 		sub:     sub,
 		topic:   topic,
 		options: options,
+		pool:    pool,
 	}
 
 	b.subscribers.Add(topic, subs)
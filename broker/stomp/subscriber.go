@@ -15,6 +15,7 @@ type subscriber struct {
 	options broker.SubscribeOptions
 	topic   string
 	sub     *stompV3.Subscription
+	pool    *broker.WorkerPool
 	closed  bool
 }
 
@@ -33,6 +34,9 @@ func (s *subscriber) Topic() string {
 }
 
 func (s *subscriber) Unsubscribe(removeFromManager bool) error {
+	// the handlers ack on the connection, they finish before the subscription is removed
+	s.pool.Drain()
+
 	s.Lock()
 	defer s.Unlock()
 
@@ -25,6 +25,9 @@ type SubscribeOptions struct {
 	// RetryDelay is the delay between retries
 	RetryDelay time.Duration
 
+	// DeadLetter is the dead-letter policy applied once retries are exhausted
+	DeadLetter *DeadLetterPolicy
+
 	// Middlewares are subscriber middlewares
 	Middlewares []SubscriberMiddleware
 }
@@ -130,6 +133,16 @@ func WithSubscribeRetry(maxRetries int, delay time.Duration) SubscribeOption {
 	}
 }
 
+// WithSubscribeDeadLetter republishes messages to topic after maxAttempts failed handler attempts
+func WithSubscribeDeadLetter(topic string, maxAttempts int) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DeadLetter = &DeadLetterPolicy{
+			Topic:       topic,
+			MaxAttempts: maxAttempts,
+		}
+	}
+}
+
 // WithSubscribeMiddlewares sets subscriber middlewares
 func WithSubscribeMiddlewares(mws ...SubscriberMiddleware) SubscribeOption {
 	return func(o *SubscribeOptions) {
@@ -0,0 +1,92 @@
+package broker
+
+import (
+	"sync"
+)
+
+// WorkerPool runs subscriber tasks on a bounded number of goroutines.
+// It is how brokers honour SubscribeOptions.Concurrency.
+type WorkerPool struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mtx     sync.RWMutex
+	drained bool
+}
+
+// NewWorkerPool creates a WorkerPool running at most concurrency tasks at once.
+// A concurrency lower than 1 is treated as 1.
+func NewWorkerPool(concurrency int) *WorkerPool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &WorkerPool{
+		sem: make(chan struct{}, concurrency),
+	}
+}
+
+// NewSubscribeWorkerPool creates a WorkerPool sized by SubscribeOptions.Concurrency.
+func NewSubscribeWorkerPool(options SubscribeOptions) *WorkerPool {
+	return NewWorkerPool(options.Concurrency)
+}
+
+// Size returns the maximum number of tasks running at once.
+func (p *WorkerPool) Size() int {
+	return cap(p.sem)
+}
+
+// Submit runs task on the pool, blocking while all workers are busy.
+// A pool of size 1 runs task on the calling goroutine, which preserves delivery order.
+// Tasks submitted once the pool is drained are dropped.
+func (p *WorkerPool) Submit(task func()) {
+	if task == nil {
+		return
+	}
+
+	if !p.add() {
+		return
+	}
+
+	if cap(p.sem) == 1 {
+		defer p.wg.Done()
+		task()
+		return
+	}
+
+	p.sem <- struct{}{}
+	go func() {
+		defer func() {
+			<-p.sem
+			p.wg.Done()
+		}()
+		task()
+	}()
+}
+
+// Wait blocks until every submitted task has finished.
+func (p *WorkerPool) Wait() {
+	p.wg.Wait()
+}
+
+// Drain stops accepting tasks and blocks until the running ones have finished. Subscribers call
+// it when they unsubscribe, before closing the connection the tasks ack on. It must not be called
+// from a task.
+func (p *WorkerPool) Drain() {
+	p.mtx.Lock()
+	p.drained = true
+	p.mtx.Unlock()
+
+	p.wg.Wait()
+}
+
+// add counts a new task unless the pool is drained
+func (p *WorkerPool) add() bool {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	if p.drained {
+		return false
+	}
+	p.wg.Add(1)
+	return true
+}
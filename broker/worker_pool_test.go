@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_LimitsConcurrency(t *testing.T) {
+	p := NewWorkerPool(3)
+
+	var running, peak atomic.Int32
+	for i := 0; i < 20; i++ {
+		p.Submit(func() {
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+		})
+	}
+	p.Wait()
+
+	if peak.Load() > 3 {
+		t.Fatalf("concurrency exceeded: %d", peak.Load())
+	}
+	if peak.Load() < 2 {
+		t.Fatalf("tasks did not run concurrently: peak %d", peak.Load())
+	}
+}
+
+func TestWorkerPool_SizeOneRunsInline(t *testing.T) {
+	p := NewWorkerPool(0)
+	if p.Size() != 1 {
+		t.Fatalf("expected size 1, got %d", p.Size())
+	}
+
+	var seq []int
+	for i := 0; i < 5; i++ {
+		i := i
+		p.Submit(func() { seq = append(seq, i) })
+	}
+	p.Wait()
+
+	for i, v := range seq {
+		if v != i {
+			t.Fatalf("unexpected order: %v", seq)
+		}
+	}
+}
+
+func TestWorkerPool_DrainWaitsAndDropsLateTasks(t *testing.T) {
+	p := NewWorkerPool(4)
+
+	var finished atomic.Int32
+	for i := 0; i < 4; i++ {
+		p.Submit(func() {
+			time.Sleep(20 * time.Millisecond)
+			finished.Add(1)
+		})
+	}
+	p.Drain()
+
+	if finished.Load() != 4 {
+		t.Fatalf("drain returned before the running tasks finished: %d", finished.Load())
+	}
+
+	p.Submit(func() { finished.Add(1) })
+	p.Wait()
+	if finished.Load() != 4 {
+		t.Fatal("task submitted after drain was run")
+	}
+}
@@ -49,6 +49,13 @@ func WithClientId(clientId string) ServerOption {
 	}
 }
 
+// WithProtocolVersion sets the MQTT protocol version, 5 selects the MQTT 5 broker.
+func WithProtocolVersion(pv uint) ServerOption {
+	return func(s *Server) {
+		s.brokerOpts = append(s.brokerOpts, mqtt.WithProtocolVersion(pv))
+	}
+}
+
 func WithCodec(c string) ServerOption {
 	return func(s *Server) {
 		s.brokerOpts = append(s.brokerOpts, broker.WithCodec(c))